@@ -0,0 +1,108 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/utils"
+)
+
+// addonGap is the number of light modules between an EAN barcode and its
+// add-on. The specification allows 7 to 12 modules.
+const addonGap = 9
+
+// ean2Parity holds the parity of the two digits of an EAN-2 add-on, indexed
+// by its value modulo 4.
+var ean2Parity = [4]string{"OO", "OE", "EO", "EE"}
+
+// ean5Parity holds the parity of the five digits of an EAN-5 add-on, indexed
+// by its checksum. 'E' means even and 'O' means odd parity.
+var ean5Parity = [10]string{
+	"EEOOO", "EOEOO", "EOOEO", "EOOOE", "OEEOO",
+	"OOEEO", "OOOEE", "OEOEO", "OEOOE", "OOEOE",
+}
+
+// encodeEANWithAddon returns an EAN barcode for code followed by the two or
+// five digit add-on.
+func encodeEANWithAddon(code, addon string) (barcode.Barcode, error) {
+	if err := Validate(eanKind(code), code); err != nil {
+		return nil, err
+	}
+
+	main, err := ean.Encode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	supplement, err := encodeAddon(addon)
+	if err != nil {
+		return nil, err
+	}
+
+	bars := new(utils.BitList)
+	for x := 0; x < main.Bounds().Dx(); x++ {
+		bars.AddBit(isBar(main, x, 0))
+	}
+	for i := 0; i < addonGap; i++ {
+		bars.AddBit(false)
+	}
+	for i := 0; i < supplement.Len(); i++ {
+		bars.AddBit(supplement.GetBit(i))
+	}
+
+	kind := main.Metadata().CodeKind + "+" + fmt.Sprint(len(addon))
+	return utils.New1DCode(kind, main.Content()+" "+addon, bars), nil
+}
+
+// encodeAddon returns the bars of an EAN-2 or EAN-5 add-on.
+func encodeAddon(addon string) (*utils.BitList, error) {
+	if (len(addon) != 2 && len(addon) != 5) || !isDigits(addon) {
+		return nil, fmt.Errorf("EAN add-on must consist of 2 or 5 digits, got %q", addon)
+	}
+
+	var parity string
+	if len(addon) == 2 {
+		value := int(addon[0]-'0')*10 + int(addon[1]-'0')
+		parity = ean2Parity[value%4]
+	} else {
+		sum := 0
+		for i, r := range addon {
+			if i%2 == 0 {
+				sum += 3 * int(r-'0')
+			} else {
+				sum += 9 * int(r-'0')
+			}
+		}
+		parity = ean5Parity[sum%10]
+	}
+
+	bars := new(utils.BitList)
+	addPattern(bars, "1011")
+	for i, r := range addon {
+		if i > 0 {
+			addPattern(bars, "01")
+		}
+		if parity[i] == 'E' {
+			addPattern(bars, upcEven[r-'0'])
+		} else {
+			addPattern(bars, upcOdd[r-'0'])
+		}
+	}
+
+	return bars, nil
+}
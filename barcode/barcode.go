@@ -15,35 +15,89 @@
 // Package barcode provides helper methods for adding barcodes of different
 // types to your pdf document. It relies on the github.com/boombuler/barcode
 // package for the barcode creation.
+//
+// The package-level functions register barcodes in a registry that is shared
+// by all documents of the process. Use New() to obtain a Registry that only
+// holds the barcodes of a single document.
 package barcode
 
 import (
 	"bytes"
-	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
 	"image/jpeg"
+	"image/png"
 	"io"
+	"math"
 	"strconv"
 	"sync"
 
 	"github.com/boombuler/barcode"
-	"github.com/boombuler/barcode/aztec"
-	"github.com/boombuler/barcode/codabar"
-	"github.com/boombuler/barcode/code128"
-	"github.com/boombuler/barcode/code39"
-	"github.com/boombuler/barcode/datamatrix"
-	"github.com/boombuler/barcode/ean"
 	"github.com/boombuler/barcode/qr"
-	"github.com/boombuler/barcode/twooffive"
 	"github.com/jung-kurt/gofpdf/v2"
-	"github.com/ruudk/golang-pdf417"
+	"golang.org/x/image/font"
 )
 
-// barcodes represents the barcodes that have been registered through this
-// package. They will later be used to be scaled and put on the page.
-// RubenN: made this a struct with a mutex to prevent race condition
-var barcodes struct {
-	sync.Mutex
-	cache map[string]barcode.Barcode
+// barcodes represents the barcodes that have been registered through the
+// package-level functions. They will later be used to be scaled and put on the
+// page. Use New() to obtain a Registry that is bound to a single document.
+var barcodes = &barcodeCache{}
+
+// options holds the settings that affect how barcodes are rendered into the
+// PDF.
+type options struct {
+	format        string
+	jpegQuality   int
+	dpi           float64
+	reuse         bool
+	fg, bg        color.Color
+	transparent   bool
+	quietZone     int
+	qzColor       color.Color // Color of the quiet zone, nil for the background
+	strictAspect  bool
+	nameFunc      func(code string, x, y, w, h, dpi float64) string
+	guardBars     bool
+	noSmoothing   bool
+	deterministic bool
+	minQRContrast float64
+}
+
+// settings holds the package-wide options. Use the Set* functions to change
+// them.
+var settings = struct {
+	sync.RWMutex
+	options
+	errorPolicy ErrorPolicy
+}{
+	options: options{
+		format:        "png",
+		jpegQuality:   jpeg.DefaultQuality,
+		dpi:           defaultDPI,
+		fg:            color.Black,
+		bg:            color.White,
+		minQRContrast: defaultQRContrast,
+	},
+}
+
+// defaultDPI is the resolution of the barcode images unless it is changed with
+// SetDPI(). It is also the resolution at which the unscaled barcodes are
+// measured.
+const defaultDPI = 96
+
+// aspectTolerance is the relative deviation from the aspect ratio of a
+// two-dimensional barcode that is accepted in strict mode. See
+// SetStrictAspect().
+const aspectTolerance = 0.05
+
+// currentOptions returns a copy of the package-wide options, so that a barcode
+// is rendered consistently even if they are changed concurrently.
+func currentOptions() options {
+	settings.RLock()
+	defer settings.RUnlock()
+
+	return settings.options
 }
 
 // barcodePdf is a partial PDF implementation that only implements a subset of
@@ -56,55 +110,53 @@ type barcodePdf interface {
 	SetError(err error)
 }
 
-// printBarcode internally prints the scaled or unscaled barcode to the PDF. Used by both
-// Barcode() and BarcodeUnscalable().
-func printBarcode(pdf barcodePdf, code string, x, y float64, w, h *float64, flow bool) {
-	barcodes.Lock()
-	unscaled, ok := barcodes.cache[code]
-	barcodes.Unlock()
-
-	if !ok {
-		err := errors.New("Barcode not found")
-		pdf.SetError(err)
-		return
-	}
+// unitConverter is implemented by documents that convert their unit of
+// measure to points. The scaling helpers only need this part of barcodePdf.
+type unitConverter interface {
+	GetConversionRatio() float64
+}
 
-	bname := uniqueBarcodeName(code, x, y)
-	info := pdf.GetImageInfo(bname)
-	scaleToWidth := unscaled.Bounds().Dx()
-	scaleToHeight := unscaled.Bounds().Dy()
-
-	if info == nil {
-		bcode, err := barcode.Scale(
-			unscaled,
-			scaleToWidth,
-			scaleToHeight,
-		)
-
-		if err != nil {
-			pdf.SetError(err)
-			return
-		}
+// points is a unitConverter for sizes that are given in points.
+type points struct{}
 
-		err = registerScaledBarcode(pdf, bname, bcode)
-		if err != nil {
-			pdf.SetError(err)
-			return
-		}
-	}
+// GetConversionRatio returns 1, the number of points per point.
+func (points) GetConversionRatio() float64 {
+	return 1
+}
 
-	scaleToWidthF := float64(scaleToWidth)
-	scaleToHeightF := float64(scaleToHeight)
+// barcodeTextPdf extends barcodePdf with the functions that are required to
+// print the human-readable content of a barcode beneath it.
+type barcodeTextPdf interface {
+	barcodePdf
+	CellFormat(w, h float64, txtStr, borderStr string, ln int, alignStr string, fill bool, link int, linkStr string)
+	GetXY() (float64, float64)
+	SetXY(x, y float64)
+}
 
-	if w != nil {
-		scaleToWidthF = *w
-	}
-	if h != nil {
-		scaleToHeightF = *h
-	}
+// barcodeCellPdf extends barcodeTextPdf with the functions that are required
+// to put barcodes into table cells.
+type barcodeCellPdf interface {
+	barcodeTextPdf
+	GetCellMargin() float64
+}
 
-	pdf.Image(bname, x, y, scaleToWidthF, scaleToHeightF, flow, "jpg", 0, "")
+// barcodeCursorPdf extends barcodePdf with the functions that are required to
+// put barcodes at the current position.
+type barcodeCursorPdf interface {
+	barcodePdf
+	GetX() float64
+	GetY() float64
+}
 
+// barcodeVectorPdf extends barcodePdf with the functions that are required to
+// draw barcodes as vector graphics.
+type barcodeVectorPdf interface {
+	barcodePdf
+	GetFillColor() (int, int, int)
+	GetXY() (float64, float64)
+	Rect(x, y, w, h float64, styleStr string)
+	SetFillColor(r, g, b int)
+	SetXY(x, y float64)
 }
 
 // BarcodeUnscalable puts a registered barcode in the current page.
@@ -113,49 +165,579 @@ func printBarcode(pdf barcodePdf, code string, x, y float64, w, h *float64, flow
 // barcode in the width and/or height dimensions. This can be useful if you want to prevent
 // side effects of upscaling.
 func BarcodeUnscalable(pdf barcodePdf, code string, x, y float64, w, h *float64, flow bool) {
-	printBarcode(pdf, code, x, y, w, h, flow)
+	defaultRegistry(pdf).BarcodeUnscalable(code, x, y, w, h, flow)
 }
 
 // Barcode puts a registered barcode in the current page.
 //
 // The size should be specified in the units used to create the PDF document.
-// If width or height are left unspecfied, the barcode is not scaled in the unspecified dimensions.
+// If width or height is left unspecified, that is zero, it is computed from the
+// other so that the natural aspect ratio of the barcode is preserved. If both
+// are left unspecified, the barcode is put at its natural size of one pixel per
+// module at 96 DPI. Note that the natural height of one-dimensional barcodes is
+// a single module, so these usually need an explicit height. Negative sizes
+// result in ErrInvalidSize being set on the PDF.
 //
 // Positioning with x, y and flow is inherited from Fpdf.Image().
 func Barcode(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
-	printBarcode(pdf, code, x, y, &w, &h, flow)
+	defaultRegistry(pdf).Barcode(code, x, y, w, h, flow)
+}
+
+// BarcodeE puts a registered barcode in the current page like Barcode() does,
+// but returns any error instead of setting it on the PDF. This is the case if
+// the barcode has not been registered (ErrBarcodeNotFound) or if it could not
+// be scaled or encoded as an image.
+func BarcodeE(pdf barcodePdf, code string, x, y, w, h float64, flow bool) error {
+	return defaultRegistry(pdf).BarcodeE(code, x, y, w, h, flow)
+}
+
+// BarcodeRect puts a registered barcode in the current page like BarcodeE()
+// does and returns the size of the area it takes up on the page, in the units
+// used to create the PDF document. A zero width or height is returned as
+// computed from the aspect ratio of the barcode, so that content can be laid
+// out beneath a barcode whose height was derived automatically. The size
+// includes a caption the barcode has been registered with and is that of the
+// rotated barcode if it has been registered with a rotation.
+func BarcodeRect(pdf barcodePdf, code string, x, y, w, h float64, flow bool) (gofpdf.SizeType, error) {
+	return defaultRegistry(pdf).BarcodeRect(code, x, y, w, h, flow)
+}
+
+// MeasureBarcode returns the size of the area a registered barcode would take
+// up on the page if it was put there with BarcodeRect() and the given width
+// and height, without embedding or drawing anything. A zero width or height
+// is computed from the aspect ratio of the barcode, and the errors of an
+// unknown code, an invalid size or a distorting aspect ratio in strict mode
+// are returned as BarcodeRect() would. This keeps the first pass of a
+// two-pass layout cheap.
+func MeasureBarcode(pdf barcodePdf, code string, w, h float64) (gofpdf.SizeType, error) {
+	return defaultRegistry(pdf).MeasureBarcode(code, w, h)
+}
+
+// BarcodeByModule puts a registered barcode in the current page with the
+// given module width, the width of its narrowest bar or space that label
+// specifications call the X-dimension, instead of a total width. The barcode is
+// modules × moduleWidth wide, including the quiet zone, and height high, both
+// in the units used to create the PDF document. A zero height is computed from
+// the aspect ratio of the barcode as for Barcode().
+//
+// Each module is rendered with the same whole number of image pixels, which is
+// the module width at the resolution set with SetDPI() rounded to the nearest
+// pixel, so that bars keep their exact widths relative to each other. A module
+// width that is not positive results in ErrInvalidSize being set on the PDF.
+func BarcodeByModule(pdf barcodePdf, code string, x, y, moduleWidth, height float64, flow bool) {
+	defaultRegistry(pdf).BarcodeByModule(code, x, y, moduleWidth, height, flow)
+}
+
+// BarcodePx puts a registered barcode in the current page with an image of
+// exactly widthPx x heightPx pixels, for print production workflows that
+// specify barcodes in device pixels rather than in document units. The image
+// is scaled as by Render() and put at its natural size at the resolution set
+// with SetDPI(), so at the default of 96 DPI a pixel is 0.75 points. As with
+// Render(), a zero width or height is computed from the aspect ratio of the
+// barcode. The colors and quiet zone of the barcode are applied, but not the
+// rotation or caption of RegisterWithOptions().
+func BarcodePx(pdf barcodePdf, code string, x, y float64, widthPx, heightPx int, flow bool) {
+	defaultRegistry(pdf).BarcodePx(code, x, y, widthPx, heightPx, flow)
+}
+
+// BarcodeWithOptions puts a registered barcode in the current page like
+// Barcode() does, but with the given options, which take precedence over the
+// options the barcode has been registered with and over the package-wide
+// settings. Fields of opts with their zero value keep those settings.
+//
+// If a caption is given, CaptionHeight is taken from the bottom of the box
+// for the caption, which is printed centered beneath the barcode using the
+// current font, and pdf must support printing text as for BarcodeWithText().
+func BarcodeWithOptions(pdf barcodePdf, code string, x, y, w, h float64, flow bool, opts BarcodeOptions) {
+	defaultRegistry(pdf).BarcodeWithOptions(code, x, y, w, h, flow, opts)
+}
+
+// BarcodeWithOptionsE works like BarcodeWithOptions() but returns any error
+// instead of setting it on the PDF.
+func BarcodeWithOptionsE(pdf barcodePdf, code string, x, y, w, h float64, flow bool, opts BarcodeOptions) error {
+	return defaultRegistry(pdf).BarcodeWithOptionsE(code, x, y, w, h, flow, opts)
+}
+
+// BarcodeLink puts a registered barcode in the current page and makes it a
+// clickable link.
+//
+// Its arguments work in the same way as that of Barcode(). link and linkStr
+// are inherited from Fpdf.Image(): link is an internal link identifier as
+// returned by Fpdf.AddLink() and linkStr is a URL. A common use is a QR code
+// that links to the URL it encodes.
+func BarcodeLink(pdf barcodePdf, code string, x, y, w, h float64, flow bool, link int, linkStr string) {
+	defaultRegistry(pdf).BarcodeLink(code, x, y, w, h, flow, link, linkStr)
+}
+
+// BarcodeRotated puts a registered barcode in the current page like Barcode()
+// does, but rotated counter-clockwise by degrees, which must be 0, 90, 180 or
+// 270. Other angles result in an error being set on the PDF. The barcode image
+// is rotated before it is embedded, so its modules stay sharp.
+//
+// w and h are the size of the barcode before it is rotated, so a barcode
+// rotated by 90 or 270 degrees takes up h by w on the page, with x and y
+// denoting its upper left corner.
+func BarcodeRotated(pdf barcodePdf, code string, x, y, w, h float64, flow bool, degrees int) {
+	defaultRegistry(pdf).BarcodeRotated(code, x, y, w, h, flow, degrees)
+}
+
+// BarcodeVector puts a registered barcode in the current page like Barcode()
+// does, but draws one-dimensional barcodes as filled vector rectangles instead
+// of embedding an image. The bars are sharp at any resolution and the PDF
+// stays small. Two-dimensional barcodes are embedded as images as usual.
+//
+// When flow is true the barcode is put at the current vertical position, which
+// is then moved beneath the barcode. Unlike Fpdf.Image(), no automatic page
+// break is performed for vector barcodes.
+func BarcodeVector(pdf barcodeVectorPdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).BarcodeVector(code, x, y, w, h, flow)
+}
+
+// BarcodeHere puts a registered barcode in the current page like Barcode()
+// does, with its upper left corner at the current position, so that barcodes
+// compose with layouts built with Fpdf.CellFormat() and similar functions.
+// When flow is true the current position is moved beneath the barcode, like
+// Fpdf.Image() does for images. pdf must implement GetX() and GetY() as
+// gofpdf.Fpdf does.
+func BarcodeHere(pdf barcodeCursorPdf, code string, w, h float64, flow bool) {
+	defaultRegistry(pdf).BarcodeHere(code, w, h, flow)
+}
+
+// BarcodeOnPanel puts a registered barcode in the current page like Barcode()
+// does, on top of a rectangle filled with the panel color. The rectangle
+// extends pad units beyond the barcode on every side. This keeps barcodes with
+// a transparent background readable on busy content, without baking the
+// background into the image.
+//
+// pdf must implement Rect(), GetFillColor() and SetFillColor() as gofpdf.Fpdf
+// does; the fill color is restored after the panel has been drawn.
+func BarcodeOnPanel(pdf barcodeVectorPdf, code string, x, y, w, h, pad float64, panel color.Color) {
+	defaultRegistry(pdf).BarcodeOnPanel(code, x, y, w, h, pad, panel)
+}
+
+// BarcodeWithText puts a registered barcode in the current page and prints its
+// content centered beneath it using the current font.
+//
+// The barcode and its text together fit the box given by x, y, w and h:
+// textHeight is taken from the bottom of the box for the text and the barcode
+// takes the remaining height. A font must have been set on the PDF, otherwise
+// Fpdf.CellFormat() sets an error when the text is printed. When flow is true
+// the current position is moved beneath the text, like Fpdf.Image() does for
+// images.
+func BarcodeWithText(pdf barcodeTextPdf, code string, x, y, w, h float64, flow bool, textHeight float64) {
+	defaultRegistry(pdf).BarcodeWithText(code, x, y, w, h, flow, textHeight)
+}
+
+// BarcodeCell puts a registered barcode into a cell of size w x h at the
+// current position, like Fpdf.CellFormat() does with text. border, ln and the
+// advancement of the current position work as in Fpdf.CellFormat().
+//
+// The barcode is inset from the cell border by the cell margin. A
+// one-dimensional barcode fills the remaining area; a two-dimensional one is
+// scaled to fit it without distortion and aligned according to align, which
+// combines "L", "C" or "R" for horizontal with "T", "M" or "B" for vertical
+// alignment. The default is "LM", as for text.
+//
+// Besides the functions of the other placement functions, pdf must implement
+// CellFormat(), GetCellMargin(), GetXY() and SetXY() as gofpdf.Fpdf does.
+func BarcodeCell(pdf barcodeCellPdf, code string, w, h float64, border string, ln int, align string) {
+	defaultRegistry(pdf).BarcodeCell(code, w, h, border, ln, align)
 }
 
 // GetUnscaledBarcodeDimensions returns the width and height of the
 // unscaled barcode associated with the given code.
 func GetUnscaledBarcodeDimensions(pdf barcodePdf, code string) (w, h float64) {
-	barcodes.Lock()
-	unscaled, ok := barcodes.cache[code]
-	barcodes.Unlock()
-
-	if !ok {
-		err := errors.New("Barcode not found")
-		pdf.SetError(err)
-		return
+	return defaultRegistry(pdf).GetUnscaledBarcodeDimensions(code)
+}
+
+// GetBarcodeDimensions returns the width and height of the unscaled barcode
+// associated with the given code, in pixels of the image returned by the
+// barcode encoder. Usually a pixel corresponds to a module of the barcode, the
+// smallest bar or square of the symbology; one-dimensional barcodes have a
+// height of one. ok is false if the code has not been registered.
+//
+// The dimensions can be used to preserve the aspect ratio of a barcode, for
+// instance by computing the height to pass to Barcode() from a given width.
+func GetBarcodeDimensions(code string) (width, height int, ok bool) {
+	return defaultRegistry(nil).GetBarcodeDimensions(code)
+}
+
+// LastScale returns the size in pixels that the image of the barcode
+// associated with the given key was scaled to when it was last put on a page
+// with Barcode() or another function that embeds it as an image. The size is
+// that of the barcode including its quiet zone and before it is rotated. Each
+// pixel covers the placed width divided by wPx, so a size that is not larger
+// than the unscaled barcode from GetBarcodeDimensions() points at a low
+// resolution. ok is false if the barcode has not been put on a page.
+func LastScale(key string) (wPx, hPx int, ok bool) {
+	return defaultRegistry(nil).LastScale(key)
+}
+
+// Get returns the barcode registered with the given key, for instance to
+// render it outside of a PDF or to inspect its modules. The barcode is the
+// unscaled original, one pixel per module and without quiet zone. ok is false
+// if the key has not been registered.
+func Get(key string) (bcode barcode.Barcode, ok bool) {
+	return defaultRegistry(nil).Get(key)
+}
+
+// Keys returns the keys of all barcodes in the package-level registry in
+// sorted order, for instance to list the barcodes of a document. The slice is a
+// copy that is not affected by later registrations.
+func Keys() []string {
+	return defaultRegistry(nil).Keys()
+}
+
+// Encode writes the image of the barcode associated with the given key to w,
+// as it would be embedded in a PDF for a barcode of size widthDoc x heightDoc
+// in points (1/72 inch). As with Barcode(), a zero width or height is computed
+// from the aspect ratio of the barcode. The image has the resolution dpi, or
+// the one set with SetDPI() if dpi is not positive, and the format "png" or
+// "jpg", or the one set with SetImageFormat() if format is empty. The colors
+// and quiet zone are the ones set for barcodes on the page.
+func Encode(w io.Writer, key string, widthDoc, heightDoc float64, dpi float64, format string) error {
+	return defaultRegistry(nil).Encode(w, key, widthDoc, heightDoc, dpi, format)
+}
+
+// Render returns the image of the barcode associated with the given key,
+// scaled to widthPx x heightPx, without involving a PDF, for instance to
+// composite it into a larger label image. Unlike the sizes of the other
+// functions, which are in document units, the size is given in pixels of the
+// returned image. As with Barcode(), a zero width or height is computed from
+// the aspect ratio of the barcode, and the unscaled size of one pixel per
+// module is used if both are zero.
+//
+// The barcode is scaled with barcode.Scale(), or with nearest neighbor
+// sampling if SetSmoothing(false) was called. Scaling fails if the size is
+// smaller than the unscaled barcode and centers it on a background margin if
+// the size is not a multiple of it. The colors and quiet zone are the ones set
+// for barcodes on the page.
+func Render(key string, widthPx, heightPx int) (image.Image, error) {
+	return defaultRegistry(nil).Render(key, widthPx, heightPx)
+}
+
+// EncodeSVG writes the barcode associated with the given key to w as an SVG
+// document of widthDoc x heightDoc points (1/72 inch), without involving a
+// PDF, for web pages or print that has to scale without loss. As with
+// Barcode(), a zero width or height is computed from the aspect ratio of the
+// barcode.
+//
+// The bars of one-dimensional barcodes are written as a rectangle each, the
+// dark modules of two-dimensional barcodes as a grid of square rectangles.
+// They are drawn in the colors and with the quiet zone set for barcodes on the
+// page, on a background rectangle unless the background is transparent.
+// Logos and other decorations of the images of barcodes are not written.
+func EncodeSVG(w io.Writer, key string, widthDoc, heightDoc float64) error {
+	return defaultRegistry(nil).EncodeSVG(w, key, widthDoc, heightDoc)
+}
+
+// RenderWithCaption returns the image of the barcode associated with the
+// given key like Render(), with the content of the barcode printed centered
+// beneath it in the given font face. The barcode is scaled to widthPx x
+// heightPx and the caption takes captionPx more pixels, so the image is
+// captionPx pixels higher than the barcode. The caption is drawn in the
+// foreground color on the background color of the barcode.
+func RenderWithCaption(key string, widthPx, heightPx, captionPx int, face font.Face) (image.Image, error) {
+	return defaultRegistry(nil).RenderWithCaption(key, widthPx, heightPx, captionPx, face)
+}
+
+// GetMetadata returns the code kind, such as "Code 128" or "QR Code", and the
+// content of the barcode associated with the given key, for logging or
+// display purposes. ok is false if the key has not been registered.
+func GetMetadata(key string) (kind string, content string, ok bool) {
+	return defaultRegistry(nil).GetMetadata(key)
+}
+
+// SetImageFormat sets the image format used to embed barcodes in the PDF.
+// format is either "png" (the default) or "jpg" ("jpeg" is accepted as well).
+// jpegQuality is passed to jpeg.Encode() when format is "jpg" and should be
+// between 1 and 100 inclusive; it is ignored for PNG images.
+//
+// PNG is lossless and keeps the edges of bars and modules sharp. JPEG may
+// produce smaller files for large numbers of barcodes but its compression
+// artifacts could be problematic for barcode scanners.
+//
+// An unknown format results in an error being set on the PDF when the next
+// barcode is put on the page.
+func SetImageFormat(format string, jpegQuality int) {
+	settings.Lock()
+	settings.format = format
+	settings.jpegQuality = jpegQuality
+	settings.Unlock()
+}
+
+// SetDPI sets the resolution of the barcode images that are embedded in the
+// PDF. The default is 96 DPI; high-resolution print workflows may want 300 or
+// 600 DPI so that thin bars are rendered crisply. A value that is not positive
+// restores the default.
+//
+// Barcodes are scaled by an integer factor to keep all modules the same size,
+// so the actual resolution of an image is the highest one that does not exceed
+// dpi, but at least one pixel per module.
+func SetDPI(dpi float64) {
+	if dpi <= 0 {
+		dpi = defaultDPI
+	}
+
+	settings.Lock()
+	settings.dpi = dpi
+	settings.Unlock()
+}
+
+// SetReuseImages sets whether barcode images are shared by all placements of
+// a barcode with the same size. By default an image is added to the PDF for
+// every position a barcode is put at. When reuse is true, putting the same
+// barcode at many positions, as on a sheet of identical labels, adds a single
+// image to the PDF, which considerably reduces its size.
+func SetReuseImages(reuse bool) {
+	settings.Lock()
+	settings.reuse = reuse
+	settings.Unlock()
+}
+
+// SetColors sets the color of the bars or modules of barcodes (fg) and of the
+// spaces between them (bg). The default is black on white, a nil color
+// restores the default. BarcodeVector() draws its bars in the foreground color
+// and leaves the background unpainted.
+//
+// Barcode scanners rely on the contrast between the bars and the spaces. A
+// light foreground, a dark background or bars of a color that scanners with a
+// red light source can't see, such as red or orange, could render barcodes
+// unreadable. Dark bars on a light background are the safest choice. The
+// contrast of QR codes is checked, see SetQRContrast().
+func SetColors(fg, bg color.Color) {
+	if fg == nil {
+		fg = color.Black
+	}
+	if bg == nil {
+		bg = color.White
+	}
+
+	settings.Lock()
+	settings.fg = fg
+	settings.bg = bg
+	settings.Unlock()
+}
+
+// SetQRContrast sets the minimum contrast ratio between the foreground and
+// background colors set with SetColors() that QR codes are put on the page
+// with. Putting or rendering a QR code in colors of a lower contrast fails
+// with ErrLowContrast, so that branded QR codes can't silently become
+// unscannable. The ratio is computed from the relative luminance of the
+// colors as for the Web Content Accessibility Guidelines and ranges from 1
+// for equal colors to 21 for black and white. The default is 4.5; a ratio of
+// 0 disables the check. Transparent backgrounds are not checked.
+func SetQRContrast(ratio float64) {
+	if ratio < 0 || math.IsNaN(ratio) {
+		ratio = 0
+	}
+
+	settings.Lock()
+	settings.minQRContrast = ratio
+	settings.Unlock()
+}
+
+// SetTransparentBackground sets whether the spaces between the bars or modules
+// of barcode images are transparent instead of being filled with the
+// background color, so that barcodes can be put on colored regions of the
+// page. The bars keep the foreground color set with SetColors(). JPEG images
+// have no alpha channel, so the background stays opaque in that format.
+func SetTransparentBackground(transparent bool) {
+	settings.Lock()
+	settings.transparent = transparent
+	settings.Unlock()
+}
+
+// SetQuietZone sets the width in modules of the light margin that surrounds
+// barcodes. Scanners need a clear quiet zone to find the start and end of a
+// symbol. The quiet zone is part of the barcode, so the box given to Barcode()
+// and the other placement functions includes it, as do the dimensions returned
+// by GetUnscaledBarcodeDimensions(). One-dimensional barcodes only get a quiet
+// zone on their left and right.
+//
+// The default is 0, which draws barcodes edge-to-edge. The specifications of
+// the symbologies require at least the following quiet zones:
+//
+//	Code 128, Code 39, Code 93, Codabar, 2 of 5   10 modules
+//	EAN-13, UPC-A, UPC-E                          11 modules (7 on the right)
+//	EAN-8                                          7 modules
+//	QR                                             4 modules
+//	PDF417                                         2 modules
+//	Data Matrix                                    1 module
+//	Aztec                                          none
+//
+// ITF-14 barcodes include their quiet zones and bearer bars already. A value
+// that is not positive removes the quiet zone.
+func SetQuietZone(modules int) {
+	if modules < 0 {
+		modules = 0
 	}
 
-	return convertFrom96Dpi(pdf, float64(unscaled.Bounds().Dx())),
-		convertFrom96Dpi(pdf, float64(unscaled.Bounds().Dy()))
+	settings.Lock()
+	settings.quietZone = modules
+	settings.Unlock()
+}
+
+// SetQuietZoneColor sets the color of the quiet zone set with SetQuietZone(),
+// for print media that require the margin around barcodes in a color of the
+// label stock while the light modules stay in the background color, or vice
+// versa. Only the quiet zone is drawn in this color; a margin that centers a
+// barcode which cannot be scaled to the exact size of its box keeps the
+// background color. Vector barcodes, which draw their bars only, are not
+// affected. A nil color draws the quiet zone in the background color again,
+// which is the default.
+func SetQuietZoneColor(c color.Color) {
+	settings.Lock()
+	settings.qzColor = c
+	settings.Unlock()
+}
+
+// SetStrictAspect sets whether Barcode() and the other placement functions
+// reject sizes that distort two-dimensional barcodes. In strict mode, the
+// error ErrDistorted is set on the PDF if the ratio of the requested width and
+// height differs from the ratio of the barcode by more than 5 percent, because
+// distorted symbols may not be scannable. One-dimensional barcodes
+// can be stretched freely. The default is false.
+func SetStrictAspect(strict bool) {
+	settings.Lock()
+	settings.strictAspect = strict
+	settings.Unlock()
+}
+
+// SetGuardBars sets whether BarcodeVector() draws the guard bars of EAN-8,
+// EAN-13, UPC-A and UPC-E barcodes longer than the other bars, as the
+// specification of these symbologies demands. The start, center and end guard
+// bars then take the full height of the barcode and the other bars end five
+// modules above it, which leaves room for the human readable digits. Barcodes
+// embedded as images are not affected. The default is false.
+func SetGuardBars(guardBars bool) {
+	settings.Lock()
+	settings.guardBars = guardBars
+	settings.Unlock()
+}
+
+// SetSmoothing sets whether the images of barcodes are scaled with
+// barcode.Scale(), which is the default, or with nearest neighbor sampling
+// implemented by this package. Without smoothing, every pixel of an image is
+// taken from a single module of the barcode and drawn in the foreground or
+// background color only, so the bars have hard edges even if the encoder
+// produces intermediate colors, as the anti-aliased edges of a logo do. Vector
+// barcodes are not affected.
+func SetSmoothing(smoothing bool) {
+	settings.Lock()
+	settings.noSmoothing = !smoothing
+	settings.Unlock()
+}
+
+// ErrorPolicy tells how the functions of this package that don't return an
+// error report failures. See SetErrorPolicy().
+type ErrorPolicy int
+
+// The error policies that can be passed to SetErrorPolicy().
+const (
+	// ErrorPolicySetError sets errors on the PDF with Fpdf.SetError(), which
+	// stops all further output to the document. This is the default.
+	ErrorPolicySetError ErrorPolicy = iota
+	// ErrorPolicyPanic panics with the error, to fail fast in batch tools.
+	ErrorPolicyPanic
+	// ErrorPolicyReturn leaves the PDF untouched, so that callers that
+	// handle the errors of the *E variants of the functions aren't affected
+	// by a failure elsewhere.
+	ErrorPolicyReturn
+)
+
+// SetErrorPolicy sets how Barcode(), the Register* functions and the other
+// functions that don't return an error report failures such as an invalid
+// code or an unknown key. The default is ErrorPolicySetError.
+//
+// The *E variants of the functions, such as BarcodeE() and
+// RegisterCode128E(), and the other functions with an error result always
+// return their errors and are not affected by the policy. With
+// ErrorPolicyReturn they are the only way to learn about failures: the other
+// functions then silently do nothing, or return an empty key, if they fail.
+func SetErrorPolicy(policy ErrorPolicy) {
+	settings.Lock()
+	settings.errorPolicy = policy
+	settings.Unlock()
+}
+
+// currentErrorPolicy returns the error policy set with SetErrorPolicy().
+func currentErrorPolicy() ErrorPolicy {
+	settings.RLock()
+	defer settings.RUnlock()
+
+	return settings.errorPolicy
+}
+
+// SetDeterministicNames sets whether the names of barcode images only depend
+// on the code, the size of the image in pixels and the resolution. By default
+// the names also contain the position and the size on the page as floating
+// point numbers, so tiny differences in the layout give images different
+// names and the PDF differs byte for byte. With deterministic names an image
+// is shared by all placements of the barcode that result in the same pixels,
+// like SetReuseImages(true) does, and the same document is always generated
+// identically. A function set with SetNameFunc() takes precedence.
+func SetDeterministicNames(deterministic bool) {
+	settings.Lock()
+	settings.deterministic = deterministic
+	settings.Unlock()
+}
+
+// SetNameFunc sets the function that Barcode() and the other placement
+// functions use to derive the name of the image of a barcode from its code,
+// position, size and resolution. An image is only added to the PDF once per
+// name, so names must differ for images that differ. The function replaces the
+// default naming, which also takes the colors, the quiet zone, the image
+// format and SetReuseImages() into account, so it has to tell apart barcodes
+// that are put with different settings itself. Returning the same name for
+// every position shares the image like SetReuseImages(true) does. Rotated
+// barcodes get a suffix appended to the name. A nil function restores the
+// default naming.
+func SetNameFunc(fn func(code string, x, y, w, h, dpi float64) string) {
+	settings.Lock()
+	settings.nameFunc = fn
+	settings.Unlock()
 }
 
 // Register registers a barcode but does not put it on the page. Use Barcode()
 // with the same code to put the barcode on the PDF page.
 func Register(bcode barcode.Barcode) string {
-	barcodes.Lock()
-	if len(barcodes.cache) == 0 {
-		barcodes.cache = make(map[string]barcode.Barcode)
-	}
+	return defaultRegistry(nil).Register(bcode)
+}
 
-	key := barcodeKey(bcode)
-	barcodes.cache[key] = bcode
-	barcodes.Unlock()
+// RegisterWithOptions registers a barcode together with the options it is put
+// on the page with, but does not put it on the page. Use Barcode() or
+// BarcodeWithOptions() with the return value to put the barcode on the page.
+//
+// The options take precedence over the package-wide settings, so that
+// barcodes with different settings can be mixed on a page. The same barcode
+// registered with different options gets a different key. A rotation is
+// added to that of BarcodeRotated(), and a caption is printed by Barcode()
+// and BarcodeWithOptions(), which then require pdf to support printing text
+// as for BarcodeWithText(). Unsupported options result in an error being set
+// on the PDF.
+func RegisterWithOptions(pdf barcodePdf, bcode barcode.Barcode, opts BarcodeOptions) string {
+	return defaultRegistry(pdf).RegisterWithOptions(bcode, opts)
+}
 
-	return key
+// RegisterWithOptionsE works like RegisterWithOptions() but returns any error
+// instead of setting it on the PDF.
+func RegisterWithOptionsE(pdf barcodePdf, bcode barcode.Barcode, opts BarcodeOptions) (string, error) {
+	return defaultRegistry(pdf).RegisterWithOptionsE(bcode, opts)
+}
+
+// Unregister removes the barcode with the given key, as returned by one of the
+// Register functions, from the package-level registry.
+func Unregister(code string) {
+	defaultRegistry(nil).Unregister(code)
+}
+
+// Clear removes all barcodes from the package-level registry. Barcodes
+// registered through the package-level functions are kept for the life of
+// the process, so batch jobs that generate many documents should call Clear()
+// after each document has been written to reclaim their memory.
+func Clear() {
+	defaultRegistry(nil).Clear()
 }
 
 // RegisterAztec registers a barcode of type Aztec to the PDF, but not to
@@ -163,22 +745,96 @@ func Register(bcode barcode.Barcode) string {
 // code is the string to be encoded. minECCPercent is the error correction percentage. 33 is the default.
 // userSpecifiedLayers can be a value between -4 and 32 inclusive.
 func RegisterAztec(pdf barcodePdf, code string, minECCPercent int, userSpecifiedLayers int) string {
-	bcode, err := aztec.Encode([]byte(code), minECCPercent, userSpecifiedLayers)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterAztec(code, minECCPercent, userSpecifiedLayers)
+}
+
+// RegisterAztecE works like RegisterAztec() but returns any error instead of
+// setting it on the PDF.
+func RegisterAztecE(pdf barcodePdf, code string, minECCPercent int, userSpecifiedLayers int) (string, error) {
+	return defaultRegistry(pdf).RegisterAztecE(code, minECCPercent, userSpecifiedLayers)
 }
 
 // RegisterCodabar registers a barcode of type Codabar to the PDF, but not to
 // the page. Use Barcode() with the return value to put the barcode on the page.
 func RegisterCodabar(pdf barcodePdf, code string) string {
-	bcode, err := codabar.Encode(code)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterCodabar(code)
+}
+
+// RegisterCodabarE works like RegisterCodabar() but returns any error instead of
+// setting it on the PDF.
+func RegisterCodabarE(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterCodabarE(code)
+}
+
+// RegisterCodabarWithGuards registers a barcode of type Codabar to the PDF, but
+// not to the page. Unlike RegisterCodabar(), code does not include the start
+// and stop characters; they are given by start and stop, which must each be
+// one of A, B, C or D. Use Barcode() with the return value to put the barcode
+// on the page.
+func RegisterCodabarWithGuards(pdf barcodePdf, code string, start, stop byte) string {
+	return defaultRegistry(pdf).RegisterCodabarWithGuards(code, start, stop)
+}
+
+// RegisterCodabarWithGuardsE works like RegisterCodabarWithGuards() but
+// returns any error instead of setting it on the PDF.
+func RegisterCodabarWithGuardsE(pdf barcodePdf, code string, start, stop byte) (string, error) {
+	return defaultRegistry(pdf).RegisterCodabarWithGuardsE(code, start, stop)
+}
+
+// RegisterCode11 registers a barcode of type Code 11 to the PDF, but not to
+// the page. Use Barcode() with the return value to put the barcode on the
+// page.
+//
+// code consists of digits and dashes. checkDigits is the number of check
+// digits to append: 0, 1 for the C check digit or 2 for the C and K check
+// digits. The check digits are part of the content of the barcode.
+func RegisterCode11(pdf barcodePdf, code string, checkDigits int) string {
+	return defaultRegistry(pdf).RegisterCode11(code, checkDigits)
+}
+
+// RegisterCode11E works like RegisterCode11() but returns any error instead of
+// setting it on the PDF.
+func RegisterCode11E(pdf barcodePdf, code string, checkDigits int) (string, error) {
+	return defaultRegistry(pdf).RegisterCode11E(code, checkDigits)
 }
 
 // RegisterCode128 registers a barcode of type Code128 to the PDF, but not to
 // the page. Use Barcode() with the return value to put the barcode on the page.
 func RegisterCode128(pdf barcodePdf, code string) string {
-	bcode, err := code128.Encode(code)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterCode128(code)
+}
+
+// RegisterCode128E works like RegisterCode128() but returns any error instead of
+// setting it on the PDF.
+func RegisterCode128E(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterCode128E(code)
+}
+
+// RegisterCode128Batch registers a barcode of type Code128 for each of the
+// given codes to the PDF, but not to the page, as for a sheet of labels. It
+// returns the keys of the barcodes in the order of the codes. All valid codes
+// are registered; the keys of invalid ones are empty. The first error is
+// returned as a *BatchError that holds the index of the offending code. Errors
+// are not set on the PDF.
+func RegisterCode128Batch(pdf barcodePdf, codes []string) ([]string, error) {
+	return defaultRegistry(pdf).RegisterCode128Batch(codes)
+}
+
+// RegisterMany registers a barcode of the given kind for each of the given
+// codes to the PDF, but not to the page, like RegisterCode128Batch() does, but
+// encodes the barcodes concurrently with a worker per CPU. This speeds up
+// sheets of many distinct labels. The keys are returned in the order of the
+// codes, and the first error is returned as a *BatchError.
+//
+// kind is one of barcode.TypeCodabar, barcode.TypeCode128,
+// barcode.TypeCode39, barcode.TypeCode93, barcode.TypeDataMatrix,
+// barcode.TypeEAN8, barcode.TypeEAN13, barcode.Type2of5,
+// barcode.Type2of5Interleaved, TypeCode39FullASCII, TypeCode93FullASCII,
+// TypeDataBarLimited, TypeITF14, TypeUPCA or TypeUPCE. Code 39 barcodes are
+// registered without and Code 93 barcodes with their check characters. Other
+// kinds require parameters; an error is returned for them.
+func RegisterMany(pdf barcodePdf, kind string, codes []string) ([]string, error) {
+	return defaultRegistry(pdf).RegisterMany(kind, codes)
 }
 
 // RegisterCode39 registers a barcode of type Code39 to the PDF, but not to
@@ -186,16 +842,154 @@ func RegisterCode128(pdf barcodePdf, code string) string {
 //
 // includeChecksum and fullASCIIMode are inherited from code39.Encode().
 func RegisterCode39(pdf barcodePdf, code string, includeChecksum, fullASCIIMode bool) string {
-	bcode, err := code39.Encode(code, includeChecksum, fullASCIIMode)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterCode39(code, includeChecksum, fullASCIIMode)
+}
+
+// RegisterCode39E works like RegisterCode39() but returns any error instead of
+// setting it on the PDF.
+func RegisterCode39E(pdf barcodePdf, code string, includeChecksum, fullASCIIMode bool) (string, error) {
+	return defaultRegistry(pdf).RegisterCode39E(code, includeChecksum, fullASCIIMode)
+}
+
+// RegisterCode93 registers a barcode of type Code93 to the PDF, but not to
+// the page. Use Barcode() with the return value to put the barcode on the page.
+//
+// includeChecksum and fullASCIIMode are inherited from code93.Encode().
+func RegisterCode93(pdf barcodePdf, code string, includeChecksum, fullASCIIMode bool) string {
+	return defaultRegistry(pdf).RegisterCode93(code, includeChecksum, fullASCIIMode)
+}
+
+// RegisterCode93E works like RegisterCode93() but returns any error instead of
+// setting it on the PDF.
+func RegisterCode93E(pdf barcodePdf, code string, includeChecksum, fullASCIIMode bool) (string, error) {
+	return defaultRegistry(pdf).RegisterCode93E(code, includeChecksum, fullASCIIMode)
 }
 
 // RegisterDataMatrix registers a barcode of type DataMatrix to the PDF, but not
 // to the page. Use Barcode() with the return value to put the barcode on the
 // page.
 func RegisterDataMatrix(pdf barcodePdf, code string) string {
-	bcode, err := datamatrix.Encode(code)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterDataMatrix(code)
+}
+
+// RegisterDataMatrixE works like RegisterDataMatrix() but returns any error instead of
+// setting it on the PDF.
+func RegisterDataMatrixE(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterDataMatrixE(code)
+}
+
+// RegisterDataMatrixSized registers a barcode of type DataMatrix with the
+// given number of module rows and columns to the PDF, but not to the page. Use
+// Barcode() with the return value to put the barcode on the page.
+//
+// Unlike RegisterDataMatrix(), which picks the smallest square symbol that
+// holds the code, the size is given by the caller, as label specifications
+// often require. The square ECC 200 sizes from 10x10 to 144x144 and the
+// rectangular sizes 8x18, 8x32, 12x26, 12x36, 16x36 and 16x48 are supported.
+// Other sizes, and codes that don't fit into the size, result in an error.
+func RegisterDataMatrixSized(pdf barcodePdf, code string, rows, cols int) string {
+	return defaultRegistry(pdf).RegisterDataMatrixSized(code, rows, cols)
+}
+
+// RegisterDataMatrixSizedE works like RegisterDataMatrixSized() but returns
+// any error instead of setting it on the PDF.
+func RegisterDataMatrixSizedE(pdf barcodePdf, code string, rows, cols int) (string, error) {
+	return defaultRegistry(pdf).RegisterDataMatrixSizedE(code, rows, cols)
+}
+
+// RegisterDataBarExpanded registers a barcode of type GS1 DataBar Expanded to
+// the PDF, but not to the page. Use Barcode() with the return value to put the
+// barcode on the page.
+//
+// ais maps GS1 application identifiers to their values and is validated like
+// in RegisterGS1_128(). A GTIN (01) is encoded in compressed form. The symbol
+// is a single row of at most 21 data characters of 12 bits, which hold about
+// 74 digits or 41 letters, and longer data results in an error. The content
+// of the barcode is the human readable element string.
+func RegisterDataBarExpanded(pdf barcodePdf, ais map[string]string) string {
+	return defaultRegistry(pdf).RegisterDataBarExpanded(ais)
+}
+
+// RegisterDataBarExpandedE works like RegisterDataBarExpanded() but returns
+// any error instead of setting it on the PDF.
+func RegisterDataBarExpandedE(pdf barcodePdf, ais map[string]string) (string, error) {
+	return defaultRegistry(pdf).RegisterDataBarExpandedE(ais)
+}
+
+// RegisterDataBarLimited registers a barcode of type GS1 DataBar Limited to
+// the PDF, but not to the page. Use Barcode() with the return value to put the
+// barcode on the page.
+//
+// gtin must be a GTIN of 14 digits including the check digit that starts with
+// 0 or 1. The symbol is 74 modules wide, and its content is the human
+// readable element string "(01)" followed by the GTIN.
+func RegisterDataBarLimited(pdf barcodePdf, gtin string) string {
+	return defaultRegistry(pdf).RegisterDataBarLimited(gtin)
+}
+
+// RegisterDataBarLimitedE works like RegisterDataBarLimited() but returns any
+// error instead of setting it on the PDF.
+func RegisterDataBarLimitedE(pdf barcodePdf, gtin string) (string, error) {
+	return defaultRegistry(pdf).RegisterDataBarLimitedE(gtin)
+}
+
+// RegisterImage registers an arbitrary image, such as a barcode rendered by
+// another library or a scanned one, as a barcode with the given key to the
+// PDF, but not to the page. Use Barcode() with the return value, which is key,
+// to put the image on the page. It is scaled and placed like the other
+// barcodes, so its natural size is its size in pixels at 96 DPI.
+//
+// The image is scaled by an integer factor without interpolation, so the
+// quality of the result depends on the resolution of the source image: each
+// module should be at least one pixel wide, and the image should not contain
+// a blurred or anti-aliased rendering. The image keeps its own colors, so
+// SetColors() and SetTransparentBackground() don't affect it.
+func RegisterImage(pdf barcodePdf, key string, img image.Image) string {
+	return defaultRegistry(pdf).RegisterImage(key, img)
+}
+
+// RegisterImageE works like RegisterImage() but returns any error instead of
+// setting it on the PDF.
+func RegisterImageE(pdf barcodePdf, key string, img image.Image) (string, error) {
+	return defaultRegistry(pdf).RegisterImageE(key, img)
+}
+
+// RegisterIntelligentMail registers a USPS Intelligent Mail barcode to the
+// PDF, but not to the page. Use Barcode() with the return value to put the
+// barcode on the page.
+//
+// tracking is the 20 digit tracking code: the barcode identifier, whose second
+// digit is 0 to 4, the service type identifier, the mailer identifier and the
+// serial number. routing is the ZIP code of 5, 9 or 11 digits, or empty. The
+// content of the barcode is the tracking code followed by the routing code.
+//
+// The bars are one module wide and one module apart, and the ascenders,
+// trackers and descenders are one module high, so the barcode has to be
+// scaled to the size prescribed by USPS-B-3200 when it is put on the page.
+func RegisterIntelligentMail(pdf barcodePdf, tracking, routing string) string {
+	return defaultRegistry(pdf).RegisterIntelligentMail(tracking, routing)
+}
+
+// RegisterIntelligentMailE works like RegisterIntelligentMail() but returns
+// any error instead of setting it on the PDF.
+func RegisterIntelligentMailE(pdf barcodePdf, tracking, routing string) (string, error) {
+	return defaultRegistry(pdf).RegisterIntelligentMailE(tracking, routing)
+}
+
+// RegisterMSI registers a barcode of type MSI Plessey to the PDF, but not to
+// the page. Use Barcode() with the return value to put the barcode on the
+// page.
+//
+// code must consist of digits. The check digits selected by checksum are
+// appended to it and are part of the content of the barcode.
+func RegisterMSI(pdf barcodePdf, code string, checksum MSIChecksumMode) string {
+	return defaultRegistry(pdf).RegisterMSI(code, checksum)
+}
+
+// RegisterMSIE works like RegisterMSI() but returns any error instead of
+// setting it on the PDF.
+func RegisterMSIE(pdf barcodePdf, code string, checksum MSIChecksumMode) (string, error) {
+	return defaultRegistry(pdf).RegisterMSIE(code, checksum)
 }
 
 // RegisterPdf417 registers a barcode of type Pdf417 to the PDF, but not to the
@@ -205,17 +999,77 @@ func RegisterDataMatrix(pdf barcodePdf, code string) string {
 // inclusive. Barcodes for use with FedEx must set columns to 10 and
 // securityLevel to 5. Use Barcode() with the return value to put the barcode
 // on the page.
+//
+// Pdf417 is a stacked symbology with rows that are considerably wider than they
+// are high. The barcode is stretched to the width and height given to
+// Barcode(), so choose them with the aspect ratio of the symbol in mind; see
+// GetUnscaledBarcodeDimensions().
 func RegisterPdf417(pdf barcodePdf, code string, columns int, securityLevel int) string {
-	bcode := pdf417.Encode(code, columns, securityLevel)
-	return registerBarcode(pdf, bcode, nil)
+	return defaultRegistry(pdf).RegisterPdf417(code, columns, securityLevel)
+}
+
+// RegisterPdf417E works like RegisterPdf417() but returns any error instead of
+// setting it on the PDF.
+func RegisterPdf417E(pdf barcodePdf, code string, columns int, securityLevel int) (string, error) {
+	return defaultRegistry(pdf).RegisterPdf417E(code, columns, securityLevel)
 }
 
 // RegisterEAN registers a barcode of type EAN to the PDF, but not to the page.
 // It will automatically detect if the barcode is EAN8 or EAN13. Use Barcode()
 // with the return value to put the barcode on the page.
 func RegisterEAN(pdf barcodePdf, code string) string {
-	bcode, err := ean.Encode(code)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterEAN(code)
+}
+
+// RegisterEANE works like RegisterEAN() but returns any error instead of
+// setting it on the PDF.
+func RegisterEANE(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterEANE(code)
+}
+
+// RegisterEANChecked registers a barcode of type EAN-8 or EAN-13 to the PDF,
+// but not to the page, like RegisterEANE(). Unlike RegisterEANE(), which
+// computes the check digit of codes of 7 or 12 digits, it requires code to
+// include the check digit and returns a descriptive error if that does not
+// match the one computed from the other digits, so that mistyped codes are
+// not silently encoded. Use Barcode() with the return value to put the
+// barcode on the page.
+func RegisterEANChecked(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterEANChecked(code)
+}
+
+// RegisterEANWithAddon registers a barcode of type EAN followed by a
+// supplemental EAN-2 or EAN-5 add-on to the PDF, but not to the page. Use
+// Barcode() with the return value to put the barcode on the page.
+//
+// Add-ons are used for the issue number of periodicals and the price of
+// books, for example. addon must consist of exactly 2 or 5 digits. The add-on
+// is put to the right of the main barcode, separated by 9 light modules, in a
+// single image. Its bars have the same height as those of the main barcode.
+func RegisterEANWithAddon(pdf barcodePdf, code, addon string) string {
+	return defaultRegistry(pdf).RegisterEANWithAddon(code, addon)
+}
+
+// RegisterEANWithAddonE works like RegisterEANWithAddon() but returns any
+// error instead of setting it on the PDF.
+func RegisterEANWithAddonE(pdf barcodePdf, code, addon string) (string, error) {
+	return defaultRegistry(pdf).RegisterEANWithAddonE(code, addon)
+}
+
+// RegisterPharmacode registers a barcode of type Pharmacode, the one-track
+// binary code used on pharmaceutical packaging, to the PDF, but not to the
+// page. Use Barcode() with the return value to put the barcode on the page.
+//
+// number must be between 3 and 131070. Narrow bars are one module wide, wide
+// bars three modules and the spaces between them two modules.
+func RegisterPharmacode(pdf barcodePdf, number int) string {
+	return defaultRegistry(pdf).RegisterPharmacode(number)
+}
+
+// RegisterPharmacodeE works like RegisterPharmacode() but returns any error
+// instead of setting it on the PDF.
+func RegisterPharmacodeE(pdf barcodePdf, number int) (string, error) {
+	return defaultRegistry(pdf).RegisterPharmacodeE(number)
 }
 
 // RegisterQR registers a barcode of type QR to the PDF, but not to the page.
@@ -223,8 +1077,77 @@ func RegisterEAN(pdf barcodePdf, code string) string {
 //
 // The ErrorCorrectionLevel and Encoding mode are inherited from qr.Encode().
 func RegisterQR(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding) string {
-	bcode, err := qr.Encode(code, ecl, mode)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterQR(code, ecl, mode)
+}
+
+// RegisterQRE works like RegisterQR() but returns any error instead of
+// setting it on the PDF.
+func RegisterQRE(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding) (string, error) {
+	return defaultRegistry(pdf).RegisterQRE(code, ecl, mode)
+}
+
+// RegisterQRWithLogo registers a barcode of type QR with a logo in its center
+// to the PDF, but not to the page. Use Barcode() with the return value to put
+// the barcode on the page.
+//
+// coverage is the fraction of the area of the QR code that is covered by the
+// logo, which keeps its aspect ratio. The modules beneath the logo are lost,
+// so they must be recovered by the error correction of the QR code. coverage
+// must not exceed the share of the code that ecl can restore, which is 7% for
+// qr.L, 15% for qr.M, 25% for qr.Q and 30% for qr.H, otherwise an error is set
+// on the PDF. The limits assume a perfectly printed code, so a level of qr.H
+// with a coverage well below 30% is recommended. The logo is drawn at the
+// resolution of the barcode image and is not affected by SetColors().
+func RegisterQRWithLogo(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, logo image.Image, coverage float64) string {
+	return defaultRegistry(pdf).RegisterQRWithLogo(code, ecl, logo, coverage)
+}
+
+// RegisterQRWithLogoE works like RegisterQRWithLogo() but returns any error
+// instead of setting it on the PDF.
+func RegisterQRWithLogoE(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, logo image.Image, coverage float64) (string, error) {
+	return defaultRegistry(pdf).RegisterQRWithLogoE(code, ecl, logo, coverage)
+}
+
+// RegisterQRVersion registers a barcode of type QR with the given version to
+// the PDF, but not to the page. Use Barcode() with the return value to put the
+// barcode on the page.
+//
+// Unlike RegisterQR(), which picks the smallest version that holds the code,
+// the version from 1 (21x21 modules) to 40 (177x177 modules) is given by the
+// caller, so that all codes of a batch have the same number of modules. Codes
+// that don't fit into the version result in an error. Together with
+// SetQuietZone(), which applies to these codes as well, the codes of a sheet
+// look uniform regardless of their content.
+func RegisterQRVersion(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, version int) string {
+	return defaultRegistry(pdf).RegisterQRVersion(code, ecl, mode, version)
+}
+
+// RegisterQRVersionE works like RegisterQRVersion() but returns any error
+// instead of setting it on the PDF.
+func RegisterQRVersionE(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, version int) (string, error) {
+	return defaultRegistry(pdf).RegisterQRVersionE(code, ecl, mode, version)
+}
+
+// RegisterQRStructured registers a sequence of QR codes that hold data
+// together to the PDF, but not to the page, and returns their keys in the
+// order of the sequence. Use Barcode() with each of the keys to put the codes
+// on the page; readers that support structured append reassemble data
+// regardless of the order in which the codes are scanned.
+//
+// data is split into parts of at most maxPerSymbol bytes, without splitting
+// UTF-8 encoded characters, and each part is encoded in the smallest QR code
+// that holds it together with the structured append header. The header holds
+// the position of the code, the number of codes and the parity of data, which
+// the barcodes returned by Get() report through their StructuredAppend()
+// method. A sequence has at most 16 codes; longer data results in an error.
+func RegisterQRStructured(pdf barcodePdf, data string, ecl qr.ErrorCorrectionLevel, maxPerSymbol int) []string {
+	return defaultRegistry(pdf).RegisterQRStructured(data, ecl, maxPerSymbol)
+}
+
+// RegisterQRStructuredE works like RegisterQRStructured() but returns any
+// error instead of setting it on the PDF.
+func RegisterQRStructuredE(pdf barcodePdf, data string, ecl qr.ErrorCorrectionLevel, maxPerSymbol int) ([]string, error) {
+	return defaultRegistry(pdf).RegisterQRStructuredE(data, ecl, maxPerSymbol)
 }
 
 // RegisterTwoOfFive registers a barcode of type TwoOfFive to the PDF, but not
@@ -233,70 +1156,362 @@ func RegisterQR(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, mode q
 //
 // The interleaved bool is inherited from twooffive.Encode().
 func RegisterTwoOfFive(pdf barcodePdf, code string, interleaved bool) string {
-	bcode, err := twooffive.Encode(code, interleaved)
-	return registerBarcode(pdf, bcode, err)
+	return defaultRegistry(pdf).RegisterTwoOfFive(code, interleaved)
 }
 
-// registerBarcode registers a barcode internally using the Register() function.
-// In case of an error generating the barcode it will not be registered and will
-// set an error on the PDF. It will return a unique key for the barcode type and
-// content that can be used to put the barcode on the page.
-func registerBarcode(pdf barcodePdf, bcode barcode.Barcode, err error) string {
-	if err != nil {
-		pdf.SetError(err)
-		return ""
+// RegisterTwoOfFiveE works like RegisterTwoOfFive() but returns any error instead of
+// setting it on the PDF.
+func RegisterTwoOfFiveE(pdf barcodePdf, code string, interleaved bool) (string, error) {
+	return defaultRegistry(pdf).RegisterTwoOfFiveE(code, interleaved)
+}
+
+// RegisterITF14 registers a barcode of type ITF-14 to the PDF, but not to the
+// page. Use Barcode() with the return value to put the barcode on the page.
+//
+// code must be a GTIN of 14 digits including the check digit. It is encoded as
+// an Interleaved 2 of 5 barcode that is surrounded by a bearer bar frame. The
+// frame encloses the mandatory quiet zones of ten times the narrow bar width
+// on either side of the bars, so do not place other content inside it. The
+// bearer bars are part of the barcode image and are scaled with it.
+func RegisterITF14(pdf barcodePdf, code string) string {
+	return defaultRegistry(pdf).RegisterITF14(code)
+}
+
+// RegisterITF14E works like RegisterITF14() but returns any error instead of
+// setting it on the PDF.
+func RegisterITF14E(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterITF14E(code)
+}
+
+// RegisterGS1_128 registers a barcode of type GS1-128 to the PDF, but not to
+// the page. Use Barcode() with the return value to put the barcode on the
+// page.
+//
+// ais maps GS1 application identifiers, such as "01" for the GTIN or "10" for
+// the batch number, to their values. The element string is encoded as a Code
+// 128 barcode that starts with FNC1, with variable-length fields separated by
+// FNC1. The lengths of the values are validated, as are the check digits of
+// identifiers such as the GTIN and SSCC. Unknown identifiers result in an
+// error. The content of the barcode, as printed by BarcodeWithText(), is the
+// human readable element string that is also returned by GS1ElementString().
+func RegisterGS1_128(pdf barcodePdf, ais map[string]string) string {
+	return defaultRegistry(pdf).RegisterGS1_128(ais)
+}
+
+// RegisterGS1_128E works like RegisterGS1_128() but returns any error instead
+// of setting it on the PDF.
+func RegisterGS1_128E(pdf barcodePdf, ais map[string]string) (string, error) {
+	return defaultRegistry(pdf).RegisterGS1_128E(ais)
+}
+
+// RegisterUPCA registers a barcode of type UPC-A to the PDF, but not to the
+// page. Use Barcode() with the return value to put the barcode on the page.
+//
+// code must consist of 12 digits including the check digit. The barcode is
+// encoded as the equivalent EAN-13 barcode with a leading zero.
+func RegisterUPCA(pdf barcodePdf, code string) string {
+	return defaultRegistry(pdf).RegisterUPCA(code)
+}
+
+// RegisterUPCAE works like RegisterUPCA() but returns any error instead of
+// setting it on the PDF.
+func RegisterUPCAE(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterUPCAE(code)
+}
+
+// RegisterUPCE registers a barcode of type UPC-E to the PDF, but not to the
+// page. Use Barcode() with the return value to put the barcode on the page.
+//
+// code must consist of 8 digits: the number system (0 or 1), the six digits of
+// the zero-suppressed code and the check digit of the equivalent UPC-A code.
+func RegisterUPCE(pdf barcodePdf, code string) string {
+	return defaultRegistry(pdf).RegisterUPCE(code)
+}
+
+// RegisterUPCEE works like RegisterUPCE() but returns any error instead of
+// setting it on the PDF.
+func RegisterUPCEE(pdf barcodePdf, code string) (string, error) {
+	return defaultRegistry(pdf).RegisterUPCEE(code)
+}
+
+// defaultRegistry returns a Registry for the given PDF that is backed by the
+// barcodes shared by the package-level functions.
+func defaultRegistry(pdf barcodePdf) *Registry {
+	return &Registry{pdf: pdf, barcodes: barcodes}
+}
+
+// naturalSize returns the given width and height, except that a zero width or
+// height is computed from the other using the aspect ratio of the unscaled
+// barcode. If both are zero the unscaled size of the barcode at 96 DPI is
+// returned.
+func naturalSize(pdf unitConverter, unscaled barcode.Barcode, w, h float64) (float64, float64) {
+	dx := float64(unscaled.Bounds().Dx())
+	dy := float64(unscaled.Bounds().Dy())
+
+	switch {
+	case w == 0 && h == 0:
+		return convertFromDpi(pdf, dx, defaultDPI), convertFromDpi(pdf, dy, defaultDPI)
+	case w == 0:
+		return h * dx / dy, h
+	case h == 0:
+		return w, w * dy / dx
+	}
+
+	return w, h
+}
+
+// validateSize returns ErrInvalidSize unless w and h are finite numbers that
+// are zero or positive.
+func validateSize(w, h float64) error {
+	if math.IsNaN(w) || math.IsInf(w, 0) || math.IsNaN(h) || math.IsInf(h, 0) {
+		return fmt.Errorf("%w: %g x %g, width and height must be finite numbers", ErrInvalidSize, w, h)
+	}
+	if w < 0 || h < 0 {
+		return fmt.Errorf("%w: %g x %g, width and height must not be negative", ErrInvalidSize, w, h)
 	}
 
-	return Register(bcode)
+	return nil
 }
 
 // uniqueBarcodeName makes sure every barcode has a unique name for its
 // dimensions. Scaling a barcode image results in quality loss, which could be
-// a problem for barcode readers.
-func uniqueBarcodeName(code string, x, y float64) string {
+// a problem for barcode readers. The size and resolution are part of the name,
+// so images that are scaled differently do not collide.
+func uniqueBarcodeName(code string, x, y, w, h float64, opts options) string {
 	xStr := strconv.FormatFloat(x, 'E', -1, 64)
 	yStr := strconv.FormatFloat(y, 'E', -1, 64)
 
-	return "barcode-" + code + "-" + xStr + yStr
+	return sharedBarcodeName(code, w, h, opts) + "-" + xStr + yStr
+}
+
+// sharedBarcodeName returns a name for a barcode image that only depends on
+// its size and resolution, so that it can be shared by all placements of the
+// barcode with that size.
+func sharedBarcodeName(code string, w, h float64, opts options) string {
+	wStr := strconv.FormatFloat(w, 'E', -1, 64)
+	hStr := strconv.FormatFloat(h, 'E', -1, 64)
+	dpiStr := strconv.FormatFloat(opts.dpi, 'E', -1, 64)
+
+	return "barcode-" + code + "-" + wStr + "x" + hStr + "-" + dpiStr + barcodeNameSuffix(opts)
+}
+
+// deterministicBarcodeName returns a name for a barcode image that only
+// depends on its size in pixels and its resolution, so that it is stable
+// however the barcode is laid out. See SetDeterministicNames().
+func deterministicBarcodeName(code string, wPx, hPx int, opts options) string {
+	dpiStr := strconv.FormatFloat(opts.dpi, 'E', -1, 64)
+
+	return "barcode-" + code + "-" + strconv.Itoa(wPx) + "x" + strconv.Itoa(hPx) + "px-" + dpiStr + barcodeNameSuffix(opts)
+}
+
+// barcodeNameSuffix returns the part of the name of a barcode image that
+// tells apart images rendered with different options.
+func barcodeNameSuffix(opts options) string {
+	name := ""
+	if !isDefaultColors(opts.fg, opts.bg) {
+		name += "-" + colorName(opts.fg) + colorName(opts.bg)
+	}
+	if opts.transparent {
+		name += "-transparent"
+	}
+	if opts.quietZone > 0 {
+		name += "-q" + strconv.Itoa(opts.quietZone)
+		if opts.qzColor != nil {
+			name += colorName(opts.qzColor)
+		}
+	}
+	if opts.noSmoothing {
+		name += "-nearest"
+	}
+	if format := imageType(opts.format); format == "jpg" {
+		name += "-" + format + strconv.Itoa(opts.jpegQuality)
+	}
+
+	return name
+}
+
+// scaledSize returns the size in pixels to scale the unscaled barcode to, so
+// that it is put on the page with the given width and height at no more than
+// the given resolution. The barcode is scaled by an integer factor of at least
+// one, which keeps all of its modules the same size.
+func scaledSize(pdf unitConverter, unscaled barcode.Barcode, w, h, dpi float64) (int, int) {
+	dx := unscaled.Bounds().Dx()
+	dy := unscaled.Bounds().Dy()
+
+	factor := int(convertToDpi(pdf, w, dpi)) / dx
+	if unscaled.Metadata().Dimensions != 1 {
+		if factorY := int(convertToDpi(pdf, h, dpi)) / dy; factorY < factor {
+			factor = factorY
+		}
+	}
+	if factor < 1 {
+		factor = 1
+	}
+
+	return dx * factor, dy * factor
 }
 
 // barcodeKey combines the code type and code value into a unique identifier for
 // a barcode type. This is so that we can store several barcodes with the same
 // code but different type in the barcodes map.
 func barcodeKey(bcode barcode.Barcode) string {
+	if k, ok := bcode.(keyer); ok {
+		return k.key()
+	}
+
 	return bcode.Metadata().CodeKind + bcode.Content()
 }
 
-// registerScaledBarcode registers a barcode with its exact dimensions to the
-// PDF but does not put it on the page. Use Fpdf.Image() with the same code to
-// add the barcode to the page.
-func registerScaledBarcode(pdf barcodePdf, code string, bcode barcode.Barcode) error {
+// registerScaledBarcode registers the image of a barcode with its exact
+// dimensions to the PDF but does not put it on the page. Use Fpdf.Image() with
+// the same code to add the barcode to the page.
+//
+// The image is encoded in the given format. A lossy format such as JPEG blurs
+// the sharp transitions between bars and spaces, which could be problematic
+// for barcode scanners.
+func registerScaledBarcode(pdf barcodePdf, code string, img image.Image, opts options) error {
 	buf := new(bytes.Buffer)
-	err := jpeg.Encode(buf, bcode, nil)
-
-	if err != nil {
+	if err := encodeImage(buf, img, opts); err != nil {
 		return err
 	}
 
 	reader := bytes.NewReader(buf.Bytes())
-	pdf.RegisterImageReader(code, "jpg", reader)
+	pdf.RegisterImageReader(code, imageType(opts.format), reader)
 
 	return nil
 }
 
-// convertTo96DPI converts the given value, which is based on a 72 DPI value
-// like the rest of the PDF document, to a 96 DPI value that is required for
-// an Image.
+// encodeImage writes img to w in the image format of opts.
+func encodeImage(w io.Writer, img image.Image, opts options) error {
+	switch imageType(opts.format) {
+	case "png":
+		return png.Encode(w, img)
+	case "jpg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: opts.jpegQuality})
+	}
+
+	return fmt.Errorf("unsupported barcode image format %q", opts.format)
+}
+
+// scaledImage returns the image of the registered barcode, with the quiet
+// zone already applied in unscaled, scaled for the given size in the units of
+// pdf and rotated by degrees.
+func scaledImage(pdf unitConverter, registered, unscaled barcode.Barcode, w, h float64, degrees int, opts options) (img image.Image, err error) {
+	defer recoverEncodeError(&err)
+	if err := checkContrast(registered, opts); err != nil {
+		return nil, err
+	}
+	scaleToWidth, scaleToHeight := scaledSize(pdf, unscaled, w, h, opts.dpi)
+	bcode, err := scaleBarcode(unscaled, scaleToWidth, scaleToHeight, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if custom, ok := registered.(renderer); ok {
+		return custom.render(rotate(bcode, degrees), unscaled, opts), nil
+	}
+
+	return renderImage(rotate(bcode, degrees), opts), nil
+}
+
+// keyer is implemented by barcodes whose key in the registry is not derived
+// from their kind and content.
+type keyer interface {
+	key() string
+}
+
+// renderer is implemented by registered barcodes that are not rendered by
+// renderImage(). render returns the image of bcode, which is scaled from
+// padded, the registered barcode including its quiet zone.
+type renderer interface {
+	render(bcode, padded barcode.Barcode, opts options) image.Image
+}
+
+// renderImage draws the barcode into an 8-bit image with the colors of opts.
+// Opaque black on white barcodes are drawn in grayscale, all others in RGBA.
+// The barcode package uses a 16-bit color model which is not supported by
+// gofpdf, hence the conversion.
+func renderImage(bcode barcode.Barcode, opts options) image.Image {
+	bounds := bcode.Bounds()
+	transparent := opts.transparent && imageType(opts.format) == "png"
+
+	if !transparent && isDefaultColors(opts.fg, opts.bg) && opts.qzColor == nil {
+		img := image.NewGray(bounds)
+		draw.Draw(img, bounds, bcode, bounds.Min, draw.Src)
+		return img
+	}
+
+	bg := opts.bg
+	if transparent {
+		bg = color.Transparent
+	}
+
+	img := image.NewNRGBA(bounds)
+	fgN, bgN := color.NRGBAModel.Convert(opts.fg), color.NRGBAModel.Convert(bg)
+	qzN := bgN
+	if opts.qzColor != nil {
+		qzN = color.NRGBAModel.Convert(opts.qzColor)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			switch {
+			case isBar(bcode, x, y):
+				img.Set(x, y, fgN)
+			case isQuietZone(bcode, x, y):
+				img.Set(x, y, qzN)
+			default:
+				img.Set(x, y, bgN)
+			}
+		}
+	}
+
+	return img
+}
+
+// isDefaultColors reports whether fg and bg are black and white.
+func isDefaultColors(fg, bg color.Color) bool {
+	return sameColor(fg, color.Black) && sameColor(bg, color.White)
+}
+
+// sameColor reports whether a and b are the same color.
+func sameColor(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
+
+// colorName returns the hexadecimal non-premultiplied RGBA value of c.
+func colorName(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+// imageType returns the gofpdf image type for the given image format, or an
+// empty string if the format is not supported.
+func imageType(format string) string {
+	switch format {
+	case "png":
+		return "png"
+	case "jpg", "jpeg":
+		return "jpg"
+	}
+
+	return ""
+}
+
+// convertToDpi converts the given value, which is based on a 72 DPI value
+// like the rest of the PDF document, to a value at the given resolution that is
+// required for an Image.
 //
 // Doing this through the Fpdf.Image() function would mean that it uses a 72 DPI
-// value and stretches it to a 96 DPI value. This results in quality loss which
-// could be problematic for barcode scanners.
-func convertTo96Dpi(pdf barcodePdf, value float64) float64 {
-	return value * pdf.GetConversionRatio() / 72 * 96
+// value and stretches it to the given resolution. This results in quality loss
+// which could be problematic for barcode scanners.
+func convertToDpi(pdf unitConverter, value, dpi float64) float64 {
+	return value * pdf.GetConversionRatio() / 72 * dpi
 }
 
-// convertFrom96Dpi converts the given value, which is based on a 96 DPI value
-// required for an Image, to a 72 DPI value like the rest of the PDF document.
-func convertFrom96Dpi(pdf barcodePdf, value float64) float64 {
-	return value / pdf.GetConversionRatio() * 72 / 96
+// convertFromDpi converts the given value, which is based on the given
+// resolution of an Image, to a 72 DPI value like the rest of the PDF document.
+func convertFromDpi(pdf unitConverter, value, dpi float64) float64 {
+	return value / pdf.GetConversionRatio() * 72 / dpi
 }
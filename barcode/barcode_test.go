@@ -1,15 +1,63 @@
 package barcode_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
+	bc "github.com/boombuler/barcode"
 	"github.com/boombuler/barcode/code128"
 	"github.com/boombuler/barcode/qr"
 	"github.com/jung-kurt/gofpdf/v2"
 	"github.com/jung-kurt/gofpdfcontrib/barcode"
 	"github.com/jung-kurt/gofpdfcontrib/internal/example"
+	"golang.org/x/image/font/basicfont"
 )
 
+// imagePdf records the names and data of the images that are registered to the
+// PDF and the sizes of the images that are put on the page.
+type imagePdf struct {
+	*gofpdf.Fpdf
+	names  []string
+	images [][]byte
+	sizes  []gofpdf.SizeType
+}
+
+func (pdf *imagePdf) RegisterImageReader(imgName, tp string, r io.Reader) *gofpdf.ImageInfoType {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		pdf.SetError(err)
+		return nil
+	}
+
+	pdf.names = append(pdf.names, imgName)
+	pdf.images = append(pdf.images, data)
+	return pdf.Fpdf.RegisterImageReader(imgName, tp, bytes.NewReader(data))
+}
+
+func (pdf *imagePdf) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+	pdf.sizes = append(pdf.sizes, gofpdf.SizeType{Wd: w, Ht: h})
+	pdf.Fpdf.Image(imageNameStr, x, y, w, h, flow, tp, link, linkStr)
+}
+
+func createImagePdf() *imagePdf {
+	return &imagePdf{Fpdf: createPdf()}
+}
+
 func createPdf() (pdf *gofpdf.Fpdf) {
 	pdf = gofpdf.New("L", "mm", "A4", "")
 	pdf.SetFont("Helvetica", "", 12)
@@ -38,6 +86,118 @@ func ExampleRegister() {
 	// Successfully generated ../pdf/contrib_barcode_Register.pdf
 }
 
+func ExampleNew() {
+	pdf := createPdf()
+	reg := barcode.New(pdf)
+
+	key := reg.RegisterCode128("gofpdf")
+	reg.Barcode(key, 15, 15, 100, 10, false)
+
+	fileStr := example.Filename("contrib_barcode_New")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_New.pdf
+}
+
+func ExampleBarcodeLink() {
+	pdf := createPdf()
+
+	url := "https://github.com/jung-kurt/gofpdf"
+	key := barcode.RegisterQR(pdf, url, qr.M, qr.Auto)
+	barcode.BarcodeLink(pdf, key, 15, 15, 40, 40, false, 0, url)
+
+	fileStr := example.Filename("contrib_barcode_BarcodeLink")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_BarcodeLink.pdf
+}
+
+func ExampleDrawCode128() {
+	pdf := createPdf()
+
+	barcode.DrawCode128(pdf, "gofpdf", 15, 15, 100, 10, false)
+	barcode.DrawQR(pdf, "gofpdf", qr.M, qr.Auto, 15, 35, 30, 30, false)
+
+	fileStr := example.Filename("contrib_barcode_DrawCode128")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_DrawCode128.pdf
+}
+
+func ExampleBarcodeVector() {
+	pdf := createPdf()
+
+	key := barcode.RegisterCode128(pdf, "gofpdf")
+	barcode.BarcodeVector(pdf, key, 15, 15, 100, 10, false)
+
+	key = barcode.RegisterQR(pdf, "gofpdf", qr.M, qr.Auto)
+	barcode.BarcodeVector(pdf, key, 15, 35, 30, 30, false)
+
+	fileStr := example.Filename("contrib_barcode_BarcodeVector")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_BarcodeVector.pdf
+}
+
+func ExampleBarcodeOnPanel() {
+	pdf := createPdf()
+
+	pdf.SetFillColor(80, 120, 200)
+	pdf.Rect(10, 10, 110, 50, "F")
+
+	barcode.SetTransparentBackground(true)
+	defer barcode.SetTransparentBackground(false)
+
+	key := barcode.RegisterCode128(pdf, "gofpdf")
+	barcode.BarcodeOnPanel(pdf, key, 20, 20, 90, 15, 3, color.White)
+
+	fileStr := example.Filename("contrib_barcode_BarcodeOnPanel")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_BarcodeOnPanel.pdf
+}
+
+func ExampleBarcodeWithText() {
+	pdf := createPdf()
+
+	key := barcode.RegisterEAN(pdf, "5901234123457")
+	barcode.BarcodeWithText(pdf, key, 15, 15, 60, 25, false, 5)
+
+	fileStr := example.Filename("contrib_barcode_BarcodeWithText")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_BarcodeWithText.pdf
+}
+
+func ExampleBarcodeCell() {
+	pdf := createPdf()
+
+	products := [][2]string{
+		{"Widget", "5901234123457"},
+		{"Gadget", "4006381333931"},
+		{"Gizmo", "9780201379624"},
+	}
+	for _, product := range products {
+		pdf.CellFormat(40, 20, product[0], "1", 0, "L", false, 0, "")
+		key := barcode.RegisterEAN(pdf, product[1])
+		barcode.BarcodeCell(pdf, key, 60, 20, "1", 0, "")
+		key = barcode.RegisterQR(pdf, product[1], qr.M, qr.Auto)
+		barcode.BarcodeCell(pdf, key, 20, 20, "1", 1, "C")
+	}
+
+	fileStr := example.Filename("contrib_barcode_BarcodeCell")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_BarcodeCell.pdf
+}
+
 func ExampleRegisterCodabar() {
 	pdf := createPdf()
 
@@ -92,6 +252,19 @@ func ExampleRegisterCode39() {
 	// Successfully generated ../pdf/contrib_barcode_RegisterCode39.pdf
 }
 
+func ExampleRegisterCode93() {
+	pdf := createPdf()
+
+	key := barcode.RegisterCode93(pdf, "CODE93", true, false)
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+
+	fileStr := example.Filename("contrib_barcode_RegisterCode93")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_RegisterCode93.pdf
+}
+
 func ExampleRegisterDataMatrix() {
 	pdf := createPdf()
 
@@ -144,6 +317,35 @@ func ExampleRegisterTwoOfFive() {
 	// Successfully generated ../pdf/contrib_barcode_RegisterTwoOfFive.pdf
 }
 
+func ExampleRegisterUPCA() {
+	pdf := createPdf()
+
+	key := barcode.RegisterUPCA(pdf, "036000291452")
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+
+	key = barcode.RegisterUPCE(pdf, "04252614")
+	barcode.Barcode(pdf, key, 15, 35, 50, 10, false)
+
+	fileStr := example.Filename("contrib_barcode_RegisterUPCA")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_RegisterUPCA.pdf
+}
+
+func ExampleRegisterITF14() {
+	pdf := createPdf()
+
+	key := barcode.RegisterITF14(pdf, "15400141288763")
+	barcode.Barcode(pdf, key, 15, 15, 150, 40, false)
+
+	fileStr := example.Filename("contrib_barcode_RegisterITF14")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../pdf/contrib_barcode_RegisterITF14.pdf
+}
+
 func ExampleRegisterPdf417() {
 	pdf := createPdf()
 
@@ -222,3 +424,2917 @@ func TestBarcodeNonIntegerScalingFactors(t *testing.T) {
 	// Output:
 	// Successfully generated ../pdf/contrib_barcode_BarcodeScaling.pdf
 }
+
+// TestSetImageFormat shows that barcodes may be embedded as JPEG images and
+// that an unknown image format results in an error on the PDF.
+func TestSetImageFormat(t *testing.T) {
+	defer barcode.SetImageFormat("png", 0)
+
+	pdf := createPdf()
+	barcode.SetImageFormat("jpg", 90)
+	key := barcode.RegisterCode128(pdf, "code128")
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+
+	fileStr := example.Filename("contrib_barcode_SetImageFormat")
+	err := pdf.OutputFileAndClose(fileStr)
+	if err != nil {
+		t.Fatalf("unexpected error for jpg format: %s", err)
+	}
+
+	pdf = createPdf()
+	barcode.SetImageFormat("gif", 0)
+	key = barcode.RegisterCode128(pdf, "code128")
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+
+	if pdf.Error() == nil {
+		t.Fatal("expected an error for an unknown image format")
+	}
+}
+
+// TestRegisterConcurrent ensures that barcodes can be registered and put on
+// pages from several goroutines at once. Run with -race to detect data races.
+func TestRegisterConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			pdf := createPdf()
+			key := barcode.RegisterCode128(pdf, "code128-"+strconv.Itoa(i))
+			barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+			key = barcode.RegisterQR(pdf, "qrcode", qr.H, qr.Unicode)
+			barcode.Barcode(pdf, key, 15, 35, 30, 30, false)
+
+			if err := pdf.Output(ioutil.Discard); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// TestRegistryIsolation ensures that barcodes registered with a Registry are
+// not visible to other registries or to the package-level functions.
+func TestRegistryIsolation(t *testing.T) {
+	pdf := createPdf()
+	reg := barcode.New(pdf)
+	key := reg.RegisterCode128("registry only")
+
+	barcode.New(pdf).Barcode(key, 15, 15, 100, 10, false)
+	if pdf.Error() == nil {
+		t.Fatal("expected barcode to be unknown to another registry")
+	}
+
+	pdf = createPdf()
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+	if pdf.Error() == nil {
+		t.Fatal("expected barcode to be unknown to the package-level registry")
+	}
+}
+
+// TestClear ensures that barcodes can no longer be put on the page after they
+// have been removed from the registry.
+func TestClear(t *testing.T) {
+	pdf := createPdf()
+	key := barcode.RegisterCode128(pdf, "unregister")
+	barcode.Unregister(key)
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+	if pdf.Error() == nil {
+		t.Fatal("expected an error for an unregistered barcode")
+	}
+
+	pdf = createPdf()
+	key = barcode.RegisterCode128(pdf, "clear")
+	barcode.Clear()
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+	if pdf.Error() == nil {
+		t.Fatal("expected an error for a cleared barcode")
+	}
+
+	pdf = createPdf()
+	key = barcode.RegisterCode128(pdf, "after clear")
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatalf("unexpected error after clear: %s", err)
+	}
+}
+
+// TestRegisterCode93 ensures that a Code93 barcode is registered and that its
+// image is added to the PDF when it is put on the page.
+func TestRegisterCode93(t *testing.T) {
+	pdf := createImagePdf()
+
+	key := barcode.RegisterCode93(pdf, "CODE93", true, false)
+	if key == "" {
+		t.Fatalf("expected a key, got error %v", pdf.Error())
+	}
+
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+	if len(pdf.names) != 1 || pdf.GetImageInfo(pdf.names[0]) == nil {
+		t.Fatalf("expected one registered image, got %v", pdf.names)
+	}
+}
+
+// TestRegisterPdf417 ensures that a Pdf417 barcode with a representative
+// shipping payload is registered and placed with the requested size.
+func TestRegisterPdf417(t *testing.T) {
+	pdf := createImagePdf()
+
+	key := barcode.RegisterPdf417(pdf, "01 02 123456789 840 001 SHIP TO JOHN DOE", 10, 5)
+	if key == "" {
+		t.Fatalf("expected a key, got error %v", pdf.Error())
+	}
+
+	barcode.Barcode(pdf, key, 15, 15, 120, 30, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pdf.names) != 1 || pdf.GetImageInfo(pdf.names[0]) == nil {
+		t.Fatalf("expected one registered image, got %v", pdf.names)
+	}
+}
+
+// TestRegisterPdf417InvalidCharacter ensures that a payload the Pdf417
+// encoder cannot represent is reported through the PDF error instead of
+// panicking.
+func TestRegisterPdf417InvalidCharacter(t *testing.T) {
+	pdf := createImagePdf()
+
+	key := barcode.RegisterPdf417(pdf, "[)>\x1e01\x1d02", 10, 5)
+	if key != "" {
+		t.Fatalf("expected no key, got %q", key)
+	}
+	if err := pdf.Error(); err == nil || !strings.Contains(err.Error(), "Cannot encode character") {
+		t.Fatalf("expected an encoding error, got %v", err)
+	}
+}
+
+// TestRegisterAztec ensures that an Aztec barcode with a URL payload is square
+// and can be put on the page.
+func TestRegisterAztec(t *testing.T) {
+	pdf := createImagePdf()
+
+	key := barcode.RegisterAztec(pdf, "https://github.com/jung-kurt/gofpdf", 33, 0)
+	if key == "" {
+		t.Fatalf("expected a key, got error %v", pdf.Error())
+	}
+
+	w, h := barcode.GetUnscaledBarcodeDimensions(pdf, key)
+	if w <= 0 || w != h {
+		t.Fatalf("expected square dimensions, got %fx%f", w, h)
+	}
+
+	barcode.Barcode(pdf, key, 15, 15, 40, 40, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pdf.names) != 1 || pdf.GetImageInfo(pdf.names[0]) == nil {
+		t.Fatalf("expected one registered image, got %v", pdf.names)
+	}
+}
+
+// TestRegisterUPC ensures that malformed UPC-A and UPC-E codes result in an
+// error on the PDF.
+func TestRegisterUPC(t *testing.T) {
+	for _, c := range []struct {
+		upce  bool
+		code  string
+		valid bool
+	}{
+		{false, "036000291452", true},
+		{false, "036000291453", false},
+		{false, "03600029145", false},
+		{false, "03600029145X", false},
+		{true, "04252614", true},
+		{true, "04252615", false},
+		{true, "24252614", false},
+		{true, "0425261", false},
+	} {
+		pdf := createPdf()
+		var key string
+		if c.upce {
+			key = barcode.RegisterUPCE(pdf, c.code)
+		} else {
+			key = barcode.RegisterUPCA(pdf, c.code)
+		}
+		if c.valid && (key == "" || pdf.Error() != nil) {
+			t.Errorf("%s: unexpected error %v", c.code, pdf.Error())
+		}
+		if !c.valid && pdf.Error() == nil {
+			t.Errorf("%s: expected an error", c.code)
+		}
+	}
+}
+
+// TestRegisterITF14 ensures that ITF-14 codes are validated, including their
+// check digit.
+func TestRegisterITF14(t *testing.T) {
+	for _, code := range []string{"15400141288762", "1540014128876", "1540014128876A"} {
+		pdf := createPdf()
+		barcode.RegisterITF14(pdf, code)
+		if pdf.Error() == nil {
+			t.Errorf("%s: expected an error", code)
+		}
+	}
+
+	pdf := createPdf()
+	key := barcode.RegisterITF14(pdf, "15400141288763")
+	w, h := barcode.GetUnscaledBarcodeDimensions(pdf, key)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if w <= h {
+		t.Fatalf("expected a landscape barcode, got %fx%f", w, h)
+	}
+}
+
+// TestBarcodeWithText ensures that the text beneath a barcode requires a font
+// and that flowing moves the current position beneath the text.
+func TestBarcodeWithText(t *testing.T) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.AddPage()
+	key := barcode.RegisterCode128(pdf, "no font")
+	barcode.BarcodeWithText(pdf, key, 15, 15, 100, 20, false, 5)
+	if pdf.Error() == nil {
+		t.Fatal("expected an error when no font is set")
+	}
+
+	pdf = createPdf()
+	key = barcode.RegisterCode128(pdf, "flow")
+	pdf.SetY(20)
+	barcode.BarcodeWithText(pdf, key, 15, 0, 100, 20, true, 5)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if y := pdf.GetY(); y != 40 {
+		t.Fatalf("expected current position at 40, got %f", y)
+	}
+}
+
+// TestBarcodeE ensures that errors are returned rather than set on the PDF.
+func TestBarcodeE(t *testing.T) {
+	pdf := createPdf()
+
+	err := barcode.BarcodeE(pdf, "unknown", 15, 15, 100, 10, false)
+	if err != barcode.ErrBarcodeNotFound {
+		t.Fatalf("expected ErrBarcodeNotFound, got %v", err)
+	}
+	if pdf.Error() != nil {
+		t.Fatalf("expected no error on the PDF, got %v", pdf.Error())
+	}
+
+	key := barcode.RegisterCode128(pdf, "code128")
+	if err = barcode.BarcodeE(pdf, key, 15, 15, 100, 10, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRegisterE ensures that the error returning Register functions return
+// encoding errors for invalid input instead of setting them on the PDF.
+func TestRegisterE(t *testing.T) {
+	pdf := createPdf()
+
+	for _, c := range []struct {
+		name     string
+		register func() (string, error)
+	}{
+		{"Aztec", func() (string, error) { return barcode.RegisterAztecE(pdf, "aztec", 33, 99) }},
+		{"Codabar", func() (string, error) { return barcode.RegisterCodabarE(pdf, "A12X34B") }},
+		{"Code128", func() (string, error) { return barcode.RegisterCode128E(pdf, "Invalid character: é") }},
+		{"Code39", func() (string, error) { return barcode.RegisterCode39E(pdf, "code39", false, false) }},
+		{"Code93", func() (string, error) { return barcode.RegisterCode93E(pdf, "code93", false, false) }},
+		{"DataMatrix", func() (string, error) { return barcode.RegisterDataMatrixE(pdf, strings.Repeat("x", 2000)) }},
+		{"EAN", func() (string, error) { return barcode.RegisterEANE(pdf, "12345") }},
+		{"ITF14", func() (string, error) { return barcode.RegisterITF14E(pdf, "123") }},
+		{"QR", func() (string, error) { return barcode.RegisterQRE(pdf, "qrcode", qr.H, qr.Numeric) }},
+		{"TwoOfFive", func() (string, error) { return barcode.RegisterTwoOfFiveE(pdf, "123", true) }},
+		{"UPCA", func() (string, error) { return barcode.RegisterUPCAE(pdf, "123") }},
+		{"UPCE", func() (string, error) { return barcode.RegisterUPCEE(pdf, "123") }},
+	} {
+		key, err := c.register()
+		if err == nil || key != "" {
+			t.Errorf("%s: expected an error and no key, got %q", c.name, key)
+		}
+	}
+
+	if err := pdf.Error(); err != nil {
+		t.Fatalf("expected no error on the PDF, got %v", err)
+	}
+
+	key, err := barcode.RegisterCode128E(pdf, "code128")
+	if err != nil || key == "" {
+		t.Fatalf("expected a key, got error %v", err)
+	}
+}
+
+// TestGetBarcodeDimensions ensures that the unscaled dimensions of registered
+// barcodes are returned.
+func TestGetBarcodeDimensions(t *testing.T) {
+	pdf := createPdf()
+
+	key := barcode.RegisterDataMatrix(pdf, "datamatrix")
+	w, h, ok := barcode.GetBarcodeDimensions(key)
+	if !ok || w <= 0 || w != h {
+		t.Fatalf("expected square dimensions, got %dx%d (ok %v)", w, h, ok)
+	}
+
+	key = barcode.RegisterCode128(pdf, "code128")
+	w, h, ok = barcode.GetBarcodeDimensions(key)
+	if !ok || w <= 0 || h != 1 {
+		t.Fatalf("expected a one-dimensional barcode, got %dx%d (ok %v)", w, h, ok)
+	}
+
+	if _, _, ok = barcode.GetBarcodeDimensions("unknown"); ok {
+		t.Fatal("expected unknown barcode not to be found")
+	}
+}
+
+// TestBarcodeNaturalSize ensures that a zero width or height is computed from
+// the aspect ratio of the barcode.
+func TestBarcodeNaturalSize(t *testing.T) {
+	pdf := createImagePdf()
+
+	key := barcode.RegisterPdf417(pdf, "pdf417", 10, 5)
+	w, h := barcode.GetUnscaledBarcodeDimensions(pdf, key)
+	ratio := w / h
+
+	barcode.Barcode(pdf, key, 15, 15, 0, 20, false)
+	barcode.Barcode(pdf, key, 15, 45, 100, 0, false)
+	barcode.Barcode(pdf, key, 15, 85, 0, 0, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []gofpdf.SizeType{{Wd: 20 * ratio, Ht: 20}, {Wd: 100, Ht: 100 / ratio}, {Wd: w, Ht: h}} {
+		got := pdf.sizes[i]
+		if math.Abs(got.Wd-want.Wd) > 1e-9 || math.Abs(got.Ht-want.Ht) > 1e-9 {
+			t.Errorf("placement %d: expected %v, got %v", i, want, got)
+		}
+	}
+}
+
+// TestBarcodeRect ensures that the size a barcode takes up is returned with
+// the computed dimension filled in.
+func TestBarcodeRect(t *testing.T) {
+	pdf := createImagePdf()
+
+	key := barcode.RegisterQR(pdf, "rect", qr.M, qr.Auto)
+	size, err := barcode.BarcodeRect(pdf, key, 15, 15, 40, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != (gofpdf.SizeType{Wd: 40, Ht: 40}) || size != pdf.sizes[0] {
+		t.Errorf("expected 40x40 as placed %v, got %v", pdf.sizes[0], size)
+	}
+
+	key = barcode.RegisterPdf417(pdf, "rect", 10, 5)
+	w, h := barcode.GetUnscaledBarcodeDimensions(pdf, key)
+	size, err = barcode.BarcodeRect(pdf, key, 15, 60, 0, 20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(size.Wd-20*w/h) > 1e-9 || size.Ht != 20 {
+		t.Errorf("expected %gx20, got %v", 20*w/h, size)
+	}
+
+	// A rotated barcode with a caption takes up its rotated footprint and
+	// the caption beneath it.
+	bcode, err := code128.Encode("rect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key = barcode.RegisterWithOptions(pdf, bcode, barcode.BarcodeOptions{Rotation: 90, Caption: "rect", CaptionHeight: 5})
+	size, err = barcode.BarcodeRect(pdf, key, 15, 100, 10, 45, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != (gofpdf.SizeType{Wd: 40, Ht: 15}) {
+		t.Errorf("expected 40x15 for the rotated barcode with caption, got %v", size)
+	}
+
+	if _, err := barcode.BarcodeRect(pdf, "missing", 15, 15, 40, 0, false); !errors.Is(err, barcode.ErrBarcodeNotFound) {
+		t.Errorf("expected ErrBarcodeNotFound, got %v", err)
+	}
+}
+
+// TestMeasureBarcode ensures that MeasureBarcode returns the size that
+// BarcodeRect puts the barcode with, without embedding anything.
+func TestMeasureBarcode(t *testing.T) {
+	pdf := createImagePdf()
+
+	bcode, err := code128.Encode("measure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []struct {
+		key  string
+		w, h float64
+	}{
+		{barcode.RegisterQR(pdf, "measure", qr.M, qr.Auto), 40, 0},
+		{barcode.RegisterPdf417(pdf, "measure", 10, 5), 0, 20},
+		{barcode.RegisterCode128(pdf, "measure"), 60, 15},
+		{barcode.RegisterWithOptions(pdf, bcode, barcode.BarcodeOptions{Rotation: 90, Caption: "measure", CaptionHeight: 5}), 10, 45},
+		{barcode.RegisterWithOptions(pdf, bcode, barcode.BarcodeOptions{Caption: "auto", CaptionHeight: 5}), 60, 0},
+	}
+
+	for j, tt := range keys {
+		measured, err := barcode.MeasureBarcode(pdf, tt.key, tt.w, tt.h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pdf.names) != j {
+			t.Fatalf("expected no image to be registered by MeasureBarcode, got %v", pdf.names[j:])
+		}
+
+		placed, err := barcode.BarcodeRect(pdf, tt.key, 15, 15+float64(j)*50, tt.w, tt.h, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if measured != placed {
+			t.Errorf("expected %v for %s as placed, got %v", placed, tt.key, measured)
+		}
+	}
+
+	if _, err := barcode.MeasureBarcode(pdf, "missing", 40, 0); !errors.Is(err, barcode.ErrBarcodeNotFound) {
+		t.Errorf("expected ErrBarcodeNotFound, got %v", err)
+	}
+	if _, err := barcode.MeasureBarcode(pdf, keys[0].key, -1, 0); !errors.Is(err, barcode.ErrInvalidSize) {
+		t.Errorf("expected ErrInvalidSize, got %v", err)
+	}
+}
+
+// TestBarcodeInvalidSize ensures that negative, infinite and NaN sizes are
+// rejected with ErrInvalidSize before the barcode is scaled, and that no image
+// is put on the page.
+func TestBarcodeInvalidSize(t *testing.T) {
+	tests := []struct {
+		w, h   float64
+		reason string
+	}{
+		{-100, 10, "must not be negative"},
+		{100, -10, "must not be negative"},
+		{-100, -10, "must not be negative"},
+		{-100, 0, "must not be negative"},
+		{0, -10, "must not be negative"},
+		{math.NaN(), 10, "must be finite numbers"},
+		{100, math.Inf(1), "must be finite numbers"},
+		{-100, math.Inf(-1), "must be finite numbers"},
+	}
+
+	for _, tt := range tests {
+		pdf := createImagePdf()
+		key := barcode.RegisterCode128(pdf, "size")
+
+		err := barcode.BarcodeE(pdf, key, 15, 15, tt.w, tt.h, false)
+		if !errors.Is(err, barcode.ErrInvalidSize) {
+			t.Errorf("%gx%g: expected ErrInvalidSize, got %v", tt.w, tt.h, err)
+		} else if !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%gx%g: expected the error to say the size %s, got %v", tt.w, tt.h, tt.reason, err)
+		}
+		barcode.Barcode(pdf, key, 15, 15, tt.w, tt.h, false)
+		if !errors.Is(pdf.Error(), barcode.ErrInvalidSize) || len(pdf.sizes) != 0 {
+			t.Errorf("%gx%g: expected ErrInvalidSize on the PDF and no image, got %v", tt.w, tt.h, pdf.Error())
+		}
+
+		vectorPdf := createPdf()
+		barcode.BarcodeVector(vectorPdf, barcode.RegisterCode128(vectorPdf, "size"), 15, 15, tt.w, tt.h, false)
+		if !errors.Is(vectorPdf.Error(), barcode.ErrInvalidSize) {
+			t.Errorf("%gx%g: expected ErrInvalidSize for vector barcode, got %v", tt.w, tt.h, vectorPdf.Error())
+		}
+
+		if err := barcode.Encode(ioutil.Discard, key, tt.w, tt.h, 0, "png"); !errors.Is(err, barcode.ErrInvalidSize) {
+			t.Errorf("%gx%g: expected ErrInvalidSize from Encode, got %v", tt.w, tt.h, err)
+		}
+	}
+}
+
+// TestBarcodeByModule ensures that a barcode placed by module width is modules
+// × moduleWidth wide and that its image has a whole number of pixels per
+// module.
+func TestBarcodeByModule(t *testing.T) {
+	defer barcode.SetDPI(0)
+
+	tests := []struct {
+		dpi         float64
+		moduleWidth float64
+		pixels      int
+	}{
+		{96, 0.33, 1},
+		{96, 0.5, 2},
+		{96, 1, 4},
+		{300, 0.33, 4},
+		{300, 0.254, 3},
+	}
+
+	for _, tt := range tests {
+		barcode.SetDPI(tt.dpi)
+		pdf := createImagePdf()
+		key := barcode.RegisterCode128(pdf, "module")
+		modules, _, _ := barcode.GetBarcodeDimensions(key)
+
+		barcode.BarcodeByModule(pdf, key, 15, 15, tt.moduleWidth, 15, false)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := float64(modules) * tt.moduleWidth
+		if got := pdf.sizes[0]; math.Abs(got.Wd-want) > 1e-9 || got.Ht != 15 {
+			t.Errorf("%g mm at %g DPI: expected %gx15, got %v", tt.moduleWidth, tt.dpi, want, got)
+		}
+		cfg, err := png.DecodeConfig(bytes.NewReader(pdf.images[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Width != modules*tt.pixels {
+			t.Errorf("%g mm at %g DPI: expected %d pixels per module, got image width %d for %d modules",
+				tt.moduleWidth, tt.dpi, tt.pixels, cfg.Width, modules)
+		}
+	}
+
+	pdf := createImagePdf()
+	barcode.BarcodeByModule(pdf, barcode.RegisterCode128(pdf, "module"), 15, 15, 0, 15, false)
+	if !errors.Is(pdf.Error(), barcode.ErrInvalidSize) {
+		t.Errorf("expected ErrInvalidSize for a zero module width, got %v", pdf.Error())
+	}
+}
+
+// TestBarcodePx ensures that the image of a barcode placed by pixels has the
+// exact requested size and is put at its natural size.
+func TestBarcodePx(t *testing.T) {
+	defer barcode.SetDPI(0)
+
+	pdf := createImagePdf()
+	key := barcode.RegisterCode128(pdf, "pixels")
+	barcode.BarcodePx(pdf, key, 15, 15, 600, 120, false)
+	barcode.SetDPI(300)
+	barcode.BarcodePx(pdf, key, 15, 60, 600, 120, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, dpi := range []float64{96, 300} {
+		cfg, err := png.DecodeConfig(bytes.NewReader(pdf.images[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Width != 600 || cfg.Height != 120 {
+			t.Errorf("%g DPI: expected a 600x120 image, got %dx%d", dpi, cfg.Width, cfg.Height)
+		}
+		want := gofpdf.SizeType{Wd: 600 / dpi * 25.4, Ht: 120 / dpi * 25.4}
+		if got := pdf.sizes[i]; math.Abs(got.Wd-want.Wd) > 1e-9 || math.Abs(got.Ht-want.Ht) > 1e-9 {
+			t.Errorf("%g DPI: expected %v, got %v", dpi, want, got)
+		}
+	}
+
+	barcode.BarcodePx(pdf, "unknown", 15, 15, 600, 120, false)
+	if pdf.Error() != barcode.ErrBarcodeNotFound {
+		t.Errorf("expected ErrBarcodeNotFound, got %v", pdf.Error())
+	}
+}
+
+// panicBarcode is a barcode whose modules cannot be read.
+type panicBarcode struct {
+	bc.Barcode
+}
+
+func (panicBarcode) At(x, y int) color.Color {
+	panic("unreadable module")
+}
+
+// TestEncoderPanic ensures that panics of encoders and of barcodes that are
+// scaled are turned into errors.
+func TestEncoderPanic(t *testing.T) {
+	pdf := createImagePdf()
+
+	// qr.Encode dereferences a nil encoder for unknown encodings.
+	if _, err := barcode.RegisterQRE(pdf, "panic", qr.M, qr.Encoding(42)); err == nil {
+		t.Error("expected an error from RegisterQRE")
+	}
+	if key := barcode.RegisterQR(pdf, "panic", qr.M, qr.Encoding(42)); key != "" || pdf.Ok() {
+		t.Errorf("expected no key and an error on the PDF, got %q and %v", key, pdf.Error())
+	}
+
+	pdf = createImagePdf()
+	bcode, err := code128.Encode("panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := barcode.Register(panicBarcode{bcode})
+	defer barcode.Unregister(key)
+	barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+	if pdf.Ok() || len(pdf.sizes) != 0 {
+		t.Errorf("expected an error on the PDF and no image, got %v", pdf.Error())
+	}
+	if _, err := barcode.Render(key, 0, 0); err == nil {
+		t.Error("expected an error from Render")
+	}
+}
+
+// TestSetDPI ensures that a higher resolution results in a larger barcode
+// image.
+func TestSetDPI(t *testing.T) {
+	defer barcode.SetDPI(0)
+
+	var widths []float64
+	for _, dpi := range []float64{96, 300} {
+		barcode.SetDPI(dpi)
+		pdf := createImagePdf()
+		key := barcode.RegisterCode128(pdf, "code128")
+		barcode.Barcode(pdf, key, 15, 15, 100, 10, false)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+		widths = append(widths, pdf.GetImageInfo(pdf.names[0]).Width())
+	}
+
+	if widths[1] <= widths[0] {
+		t.Fatalf("expected a larger image at a higher resolution, got %v", widths)
+	}
+}
+
+// TestBarcodeSizes ensures that a barcode that is put at the same position in
+// different sizes results in an image for each size.
+func TestBarcodeSizes(t *testing.T) {
+	pdf := createImagePdf()
+
+	key := barcode.RegisterQR(pdf, "qrcode", qr.H, qr.Unicode)
+	barcode.Barcode(pdf, key, 15, 15, 20, 20, false)
+	barcode.Barcode(pdf, key, 15, 15, 80, 80, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pdf.names) != 2 || pdf.names[0] == pdf.names[1] {
+		t.Fatalf("expected two images, got %v", pdf.names)
+	}
+	for _, name := range pdf.names {
+		if pdf.GetImageInfo(name) == nil {
+			t.Fatalf("expected image %s to be registered", name)
+		}
+	}
+}
+
+// TestSetReuseImages ensures that a barcode that is put at many positions
+// results in a single image when images are reused.
+func TestSetReuseImages(t *testing.T) {
+	defer barcode.SetReuseImages(false)
+	barcode.SetReuseImages(true)
+
+	pdf := createImagePdf()
+	key := barcode.RegisterCode128(pdf, "label")
+	for j := 0; j < 20; j++ {
+		barcode.Barcode(pdf, key, float64(15+j%4*65), float64(15+j/4*35), 60, 15, false)
+	}
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pdf.names) != 1 {
+		t.Fatalf("expected a single image, got %d", len(pdf.names))
+	}
+}
+
+// TestSetDeterministicNames ensures that a document laid out with slightly
+// different coordinates has the same barcode images with the same names.
+func TestSetDeterministicNames(t *testing.T) {
+	defer barcode.SetDeterministicNames(false)
+	barcode.SetDeterministicNames(true)
+
+	generate := func(offset float64) *imagePdf {
+		pdf := createImagePdf()
+		key := barcode.RegisterCode128(pdf, "label")
+		barcode.Barcode(pdf, key, 15+offset, 15-offset, 60+offset, 15, false)
+		barcode.Barcode(pdf, key, 15+offset, 50+offset, 60, 15-offset, false)
+		key = barcode.RegisterQR(pdf, "label", qr.M, qr.Auto)
+		barcode.Barcode(pdf, key, 15, 80+offset, 30, 30, false)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+		return pdf
+	}
+
+	first, second := generate(0), generate(1e-9)
+	if len(first.names) != 2 {
+		t.Fatalf("expected an image per barcode, got %v", first.names)
+	}
+	if !reflect.DeepEqual(first.names, second.names) {
+		t.Fatalf("expected the same names, got %v and %v", first.names, second.names)
+	}
+	for j := range first.images {
+		if !bytes.Equal(first.images[j], second.images[j]) {
+			t.Fatalf("expected identical data of image %s", first.names[j])
+		}
+	}
+}
+
+// TestSetNameFunc ensures that the names of barcode images are derived with
+// the function set by SetNameFunc.
+func TestSetNameFunc(t *testing.T) {
+	defer barcode.SetNameFunc(nil)
+	barcode.SetNameFunc(func(code string, x, y, w, h, dpi float64) string {
+		return fmt.Sprintf("label-%g-%g-%gx%g", x, y, w, h)
+	})
+
+	pdf := createImagePdf()
+	key := barcode.RegisterCode128(pdf, "label")
+	barcode.Barcode(pdf, key, 15, 20, 60, 15, false)
+	barcode.Barcode(pdf, key, 15, 20, 60, 15, false)
+	barcode.BarcodeRotated(pdf, key, 15, 50, 60, 15, false, 90)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"label-15-20-60x15", "label-15-50-60x15-r90"}
+	if !reflect.DeepEqual(pdf.names, expected) {
+		t.Fatalf("expected images %v, got %v", expected, pdf.names)
+	}
+
+	barcode.SetNameFunc(nil)
+	barcode.Barcode(pdf, key, 15, 80, 60, 15, false)
+	if name := pdf.names[len(pdf.names)-1]; !strings.HasPrefix(name, "barcode-") {
+		t.Fatalf("expected the default name, got %s", name)
+	}
+}
+
+// rectPdf records the rectangles that are drawn on the PDF.
+type rectPdf struct {
+	*imagePdf
+	rects []gofpdf.SizeType
+}
+
+func (pdf *rectPdf) Rect(x, y, w, h float64, styleStr string) {
+	pdf.rects = append(pdf.rects, gofpdf.SizeType{Wd: w, Ht: h})
+	pdf.Fpdf.Rect(x, y, w, h, styleStr)
+}
+
+// TestBarcodeVector ensures that one-dimensional barcodes are drawn as
+// rectangles of the requested height, and that two-dimensional barcodes
+// are embedded as images.
+func TestBarcodeVector(t *testing.T) {
+	pdf := &rectPdf{imagePdf: createImagePdf()}
+
+	key := barcode.RegisterCode128(pdf, "vector")
+	barcode.BarcodeVector(pdf, key, 15, 15, 100, 10, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pdf.names) != 0 || len(pdf.rects) == 0 {
+		t.Fatalf("expected only rectangles, got %d images and %d rectangles", len(pdf.names), len(pdf.rects))
+	}
+	for _, rect := range pdf.rects {
+		if rect.Ht != 10 {
+			t.Fatalf("expected bars of height 10, got %f", rect.Ht)
+		}
+	}
+
+	key = barcode.RegisterQR(pdf, "vector", qr.M, qr.Auto)
+	barcode.BarcodeVector(pdf, key, 15, 35, 30, 30, false)
+	if len(pdf.names) != 1 {
+		t.Fatalf("expected an image for a QR code, got %d", len(pdf.names))
+	}
+}
+
+// panelPdf records the order in which rectangles and images are drawn.
+type panelPdf struct {
+	*gofpdf.Fpdf
+	ops []string
+}
+
+func (pdf *panelPdf) Rect(x, y, w, h float64, styleStr string) {
+	pdf.ops = append(pdf.ops, fmt.Sprintf("rect %g %g %g %g", x, y, w, h))
+	pdf.Fpdf.Rect(x, y, w, h, styleStr)
+}
+
+func (pdf *panelPdf) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+	pdf.ops = append(pdf.ops, fmt.Sprintf("image %g %g %g %g", x, y, w, h))
+	pdf.Fpdf.Image(imageNameStr, x, y, w, h, flow, tp, link, linkStr)
+}
+
+// TestBarcodeOnPanel ensures that the panel is drawn around the barcode before
+// the barcode itself, and that the fill color is restored afterwards.
+func TestBarcodeOnPanel(t *testing.T) {
+	pdf := &panelPdf{Fpdf: createPdf()}
+	pdf.SetFillColor(10, 20, 30)
+
+	key := barcode.RegisterCode128(pdf, "panel")
+	barcode.BarcodeOnPanel(pdf, key, 20, 30, 80, 10, 2, color.White)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"rect 18 28 84 14", "image 20 30 80 10"}
+	if strings.Join(pdf.ops, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected %v, got %v", want, pdf.ops)
+	}
+	if r, g, b := pdf.GetFillColor(); r != 10 || g != 20 || b != 30 {
+		t.Fatalf("expected the fill color to be restored, got %d %d %d", r, g, b)
+	}
+
+	pdf = &panelPdf{Fpdf: createPdf()}
+	barcode.BarcodeOnPanel(pdf, "missing", 20, 30, 80, 10, 2, color.White)
+	if !errors.Is(pdf.Error(), barcode.ErrBarcodeNotFound) || len(pdf.ops) != 0 {
+		t.Fatalf("expected ErrBarcodeNotFound and nothing drawn, got %v and %v", pdf.Error(), pdf.ops)
+	}
+}
+
+// TestBarcodeHere ensures that barcodes are put at the current position,
+// which is moved beneath them only if flow is true.
+func TestBarcodeHere(t *testing.T) {
+	pdf := &panelPdf{Fpdf: createPdf()}
+	key := barcode.RegisterCode128(pdf, "here")
+
+	pdf.SetXY(30, 40)
+	barcode.BarcodeHere(pdf, key, 60, 15, true)
+	if x, y := pdf.GetXY(); x != 30 || y != 55 {
+		t.Errorf("expected the position to move to 30, 55, got %g, %g", x, y)
+	}
+	barcode.BarcodeHere(pdf, key, 60, 15, false)
+	if x, y := pdf.GetXY(); x != 30 || y != 55 {
+		t.Errorf("expected the position to stay at 30, 55, got %g, %g", x, y)
+	}
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"image 30 40 60 15", "image 30 55 60 15"}
+	if strings.Join(pdf.ops, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected %v, got %v", want, pdf.ops)
+	}
+}
+
+// TestSetGuardBars ensures that the guard bars of EAN and UPC barcodes are
+// drawn longer than the other bars, and only if guard bars are enabled.
+func TestSetGuardBars(t *testing.T) {
+	defer barcode.SetGuardBars(false)
+
+	tests := []struct {
+		code     string
+		register func(pdf *rectPdf, code string) string
+		guards   int
+	}{
+		{"4006381333931", func(pdf *rectPdf, code string) string { return barcode.RegisterEAN(pdf, code) }, 6},
+		{"96385074", func(pdf *rectPdf, code string) string { return barcode.RegisterEAN(pdf, code) }, 6},
+		{"01234565", func(pdf *rectPdf, code string) string { return barcode.RegisterUPCE(pdf, code) }, 5},
+	}
+	for _, tt := range tests {
+		for _, enabled := range []bool{false, true} {
+			barcode.SetGuardBars(enabled)
+			pdf := &rectPdf{imagePdf: createImagePdf()}
+			key := tt.register(pdf, tt.code)
+			barcode.BarcodeVector(pdf, key, 15, 15, 100, 20, false)
+			if err := pdf.Error(); err != nil {
+				t.Fatal(err)
+			}
+
+			long := 0
+			for _, rect := range pdf.rects {
+				if rect.Ht == 20 {
+					long++
+				}
+			}
+			if !enabled && long != len(pdf.rects) {
+				t.Errorf("%s: expected bars of equal height, got %d of %d long", tt.code, long, len(pdf.rects))
+			}
+			if enabled && long != tt.guards {
+				t.Errorf("%s: expected %d long guard bars, got %d", tt.code, tt.guards, long)
+			}
+		}
+	}
+}
+
+// TestSetColors ensures that barcode images are drawn in the configured
+// foreground and background colors.
+func TestSetColors(t *testing.T) {
+	fg := color.NRGBA{R: 0x00, G: 0x33, B: 0x99, A: 0xff}
+	bg := color.NRGBA{R: 0xff, G: 0xff, B: 0xcc, A: 0xff}
+	barcode.SetColors(fg, bg)
+	defer barcode.SetColors(nil, nil)
+
+	pdf := createImagePdf()
+	key := barcode.RegisterQR(pdf, "colors", qr.H, qr.Unicode)
+	barcode.Barcode(pdf, key, 15, 15, 50, 50, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pdf.images) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(pdf.images))
+	}
+
+	img, err := png.Decode(bytes.NewReader(pdf.images[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[color.NRGBA]bool{}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c != fg && c != bg {
+				t.Fatalf("unexpected color %v at %d, %d", c, x, y)
+			}
+			found[c] = true
+		}
+	}
+	if !found[fg] || !found[bg] {
+		t.Errorf("expected both colors in the image, got %v", found)
+	}
+}
+
+// TestSetQRContrast ensures that QR codes in colors of too little contrast
+// are refused, unless the check is disabled, while other barcodes are not
+// affected.
+func TestSetQRContrast(t *testing.T) {
+	defer barcode.SetColors(nil, nil)
+	defer barcode.SetQRContrast(4.5)
+
+	tests := []struct {
+		fg, bg color.Color
+		ok     bool
+	}{
+		{color.NRGBA{R: 0x00, G: 0x33, B: 0x99, A: 0xff}, color.NRGBA{R: 0xff, G: 0xff, B: 0xcc, A: 0xff}, true},
+		{color.NRGBA{R: 0xff, G: 0xa5, B: 0x00, A: 0xff}, color.White, false},
+	}
+	for _, tt := range tests {
+		barcode.SetColors(tt.fg, tt.bg)
+		pdf := createImagePdf()
+		key := barcode.RegisterQR(pdf, "contrast", qr.M, qr.Auto)
+		barcode.Barcode(pdf, key, 15, 15, 50, 50, false)
+		if tt.ok && pdf.Error() != nil {
+			t.Errorf("expected %v on %v to pass, got %v", tt.fg, tt.bg, pdf.Error())
+		} else if !tt.ok && !errors.Is(pdf.Error(), barcode.ErrLowContrast) {
+			t.Errorf("expected ErrLowContrast for %v on %v, got %v", tt.fg, tt.bg, pdf.Error())
+		}
+		if _, err := barcode.Render(key, 0, 0); tt.ok != (err == nil) {
+			t.Errorf("expected Render of %v on %v to pass: %v, got %v", tt.fg, tt.bg, tt.ok, err)
+		}
+	}
+
+	// The failing colors are accepted for other barcodes and once the check
+	// is disabled.
+	pdf := createImagePdf()
+	barcode.Barcode(pdf, barcode.RegisterCode128(pdf, "contrast"), 15, 15, 60, 15, false)
+	barcode.SetQRContrast(0)
+	barcode.Barcode(pdf, barcode.RegisterQR(pdf, "contrast", qr.M, qr.Auto), 15, 40, 50, 50, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSetTransparentBackground ensures that the background of barcode images
+// is fully transparent while the bars stay opaque.
+func TestSetTransparentBackground(t *testing.T) {
+	barcode.SetTransparentBackground(true)
+	defer barcode.SetTransparentBackground(false)
+
+	pdf := createImagePdf()
+	key := barcode.RegisterQR(pdf, "transparent", qr.H, qr.Unicode)
+	barcode.Barcode(pdf, key, 15, 15, 50, 50, false)
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if len(pdf.images) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(pdf.images))
+	}
+
+	img, err := png.Decode(bytes.NewReader(pdf.images[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bars, spaces int
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			switch c {
+			case color.NRGBA{A: 0xff}:
+				bars++
+			case color.NRGBA{}:
+				spaces++
+			default:
+				t.Fatalf("unexpected color %v at %d, %d", c, x, y)
+			}
+		}
+	}
+	if bars == 0 || spaces == 0 {
+		t.Errorf("expected opaque bars and transparent spaces, got %d and %d", bars, spaces)
+	}
+}
+
+// TestSetQuietZone ensures that barcode images are padded with the quiet zone
+// horizontally for one-dimensional and on all sides for two-dimensional
+// barcodes.
+func TestSetQuietZone(t *testing.T) {
+	imageSize := func(register func(pdf *imagePdf) string) (w, h int) {
+		pdf := createImagePdf()
+		barcode.BarcodeUnscalable(pdf, register(pdf), 15, 15, nil, nil, false)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(pdf.images[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg.Width, cfg.Height
+	}
+
+	tests := []struct {
+		name     string
+		register func(pdf *imagePdf) string
+		padsY    bool
+	}{
+		{"Code128", func(pdf *imagePdf) string { return barcode.RegisterCode128(pdf, "quiet") }, false},
+		{"QR", func(pdf *imagePdf) string { return barcode.RegisterQR(pdf, "quiet", qr.H, qr.Unicode) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bareW, bareH := imageSize(tt.register)
+
+			barcode.SetQuietZone(10)
+			defer barcode.SetQuietZone(0)
+
+			w, h := imageSize(tt.register)
+			if w <= bareW {
+				t.Errorf("expected padded width larger than %d, got %d", bareW, w)
+			}
+			if tt.padsY && h <= bareH || !tt.padsY && h != bareH {
+				t.Errorf("unexpected padded height %d for bare height %d", h, bareH)
+			}
+		})
+	}
+}
+
+// TestSetQuietZoneColor ensures that only the quiet zone is drawn in its own
+// color while the light modules keep the background color.
+func TestSetQuietZoneColor(t *testing.T) {
+	barcode.SetQuietZone(4)
+	barcode.SetQuietZoneColor(color.NRGBA{R: 0xff, G: 0xee, B: 0x88, A: 0xff})
+	defer barcode.SetQuietZone(0)
+	defer barcode.SetQuietZoneColor(nil)
+
+	pdf := createImagePdf()
+	key := barcode.RegisterQR(pdf, "quiet color", qr.M, qr.Auto)
+	dx, _, _ := barcode.GetBarcodeDimensions(key)
+	barcode.Barcode(pdf, key, 15, 15, 50, 50, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(pdf.images[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	factor := img.Bounds().Dx() / (dx + 8)
+
+	// The separator right of the upper left finder pattern is light.
+	margin := color.NRGBAModel.Convert(img.At(0, 0))
+	light := color.NRGBAModel.Convert(img.At((4+7)*factor, 4*factor))
+	if margin != (color.NRGBA{R: 0xff, G: 0xee, B: 0x88, A: 0xff}) {
+		t.Errorf("expected the quiet zone color, got %v", margin)
+	}
+	if light != (color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("expected a white light module, got %v", light)
+	}
+
+	barcode.SetQuietZoneColor(nil)
+	rendered, err := barcode.Render(key, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := rendered.At(0, 0).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Errorf("expected a white quiet zone without color, got %v", rendered.At(0, 0))
+	}
+}
+
+// TestBarcodeRotated ensures that barcodes are rotated counter-clockwise by
+// the cardinal angles and that other angles are rejected.
+func TestBarcodeRotated(t *testing.T) {
+	place := func(degrees int) (image.Image, gofpdf.SizeType) {
+		pdf := createImagePdf()
+		key := barcode.RegisterQR(pdf, "rotated", qr.H, qr.Unicode)
+		barcode.BarcodeRotated(pdf, key, 15, 15, 40, 20, false, degrees)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := png.Decode(bytes.NewReader(pdf.images[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img, pdf.sizes[0]
+	}
+
+	base, _ := place(0)
+	w, h := base.Bounds().Dx(), base.Bounds().Dy()
+
+	tests := []struct {
+		degrees int
+		size    gofpdf.SizeType
+		at      func(x, y int) (int, int)
+	}{
+		{0, gofpdf.SizeType{Wd: 40, Ht: 20}, func(x, y int) (int, int) { return x, y }},
+		{90, gofpdf.SizeType{Wd: 20, Ht: 40}, func(x, y int) (int, int) { return w - 1 - y, x }},
+		{180, gofpdf.SizeType{Wd: 40, Ht: 20}, func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }},
+		{270, gofpdf.SizeType{Wd: 20, Ht: 40}, func(x, y int) (int, int) { return y, h - 1 - x }},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.degrees), func(t *testing.T) {
+			img, size := place(tt.degrees)
+			if size != tt.size {
+				t.Errorf("expected size %v, got %v", tt.size, size)
+			}
+
+			bounds := img.Bounds()
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					bx, by := tt.at(x, y)
+					if img.At(x, y) != base.At(bx, by) {
+						t.Fatalf("pixel %d, %d does not match pixel %d, %d of the unrotated barcode", x, y, bx, by)
+					}
+				}
+			}
+		})
+	}
+
+	pdf := createImagePdf()
+	key := barcode.RegisterQR(pdf, "rotated", qr.H, qr.Unicode)
+	barcode.BarcodeRotated(pdf, key, 15, 15, 40, 20, false, 45)
+	if pdf.Error() == nil {
+		t.Error("expected an error for a rotation of 45 degrees")
+	}
+}
+
+// TestDraw ensures that the Draw* functions put a barcode on the page, and set
+// an error on the PDF if the code can't be encoded.
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name string
+		draw func(pdf *imagePdf)
+	}{
+		{"Aztec", func(pdf *imagePdf) { barcode.DrawAztec(pdf, "draw", 33, 0, 15, 15, 30, 30, false) }},
+		{"Codabar", func(pdf *imagePdf) { barcode.DrawCodabar(pdf, "A1234B", 15, 15, 100, 10, false) }},
+		{"Code11", func(pdf *imagePdf) { barcode.DrawCode11(pdf, "123-45", 2, 15, 15, 100, 10, false) }},
+		{"CodabarWithGuards", func(pdf *imagePdf) { barcode.DrawCodabarWithGuards(pdf, "1234", 'C', 'D', 15, 15, 100, 10, false) }},
+		{"Code128", func(pdf *imagePdf) { barcode.DrawCode128(pdf, "draw", 15, 15, 100, 10, false) }},
+		{"Code39", func(pdf *imagePdf) { barcode.DrawCode39(pdf, "DRAW", false, true, 15, 15, 100, 10, false) }},
+		{"Code93", func(pdf *imagePdf) { barcode.DrawCode93(pdf, "DRAW", true, false, 15, 15, 100, 10, false) }},
+		{"DataMatrix", func(pdf *imagePdf) { barcode.DrawDataMatrix(pdf, "draw", 15, 15, 30, 30, false) }},
+		{"DataBarExpanded", func(pdf *imagePdf) {
+			barcode.DrawDataBarExpanded(pdf, map[string]string{"10": "draw"}, 15, 15, 100, 10, false)
+		}},
+		{"DataBarLimited", func(pdf *imagePdf) { barcode.DrawDataBarLimited(pdf, "15012345678907", 15, 15, 100, 10, false) }},
+		{"GS1_128", func(pdf *imagePdf) { barcode.DrawGS1_128(pdf, map[string]string{"10": "draw"}, 15, 15, 100, 10, false) }},
+		{"DataMatrixSized", func(pdf *imagePdf) { barcode.DrawDataMatrixSized(pdf, "draw", 12, 26, 15, 15, 52, 24, false) }},
+		{"IntelligentMail", func(pdf *imagePdf) {
+			barcode.DrawIntelligentMail(pdf, "01234567094987654321", "01234", 15, 15, 75, 3.7, false)
+		}},
+		{"MSI", func(pdf *imagePdf) { barcode.DrawMSI(pdf, "1234567", barcode.MSIChecksumMod10, 15, 15, 100, 10, false) }},
+		{"Pdf417", func(pdf *imagePdf) { barcode.DrawPdf417(pdf, "draw", 10, 2, 15, 15, 100, 30, false) }},
+		{"EAN", func(pdf *imagePdf) { barcode.DrawEAN(pdf, "96385074", 15, 15, 100, 10, false) }},
+		{"EANWithAddon", func(pdf *imagePdf) { barcode.DrawEANWithAddon(pdf, "96385074", "12", 15, 15, 100, 10, false) }},
+		{"Pharmacode", func(pdf *imagePdf) { barcode.DrawPharmacode(pdf, 1234, 15, 15, 40, 10, false) }},
+		{"QR", func(pdf *imagePdf) { barcode.DrawQR(pdf, "draw", qr.H, qr.Unicode, 15, 15, 30, 30, false) }},
+		{"QRVersion", func(pdf *imagePdf) { barcode.DrawQRVersion(pdf, "draw", qr.M, qr.Auto, 3, 15, 15, 30, 30, false) }},
+		{"QRWithLogo", func(pdf *imagePdf) {
+			barcode.DrawQRWithLogo(pdf, "draw", qr.H, image.NewGray(image.Rect(0, 0, 8, 8)), 0.1, 15, 15, 30, 30, false)
+		}},
+		{"TwoOfFive", func(pdf *imagePdf) { barcode.DrawTwoOfFive(pdf, "1234567895", true, 15, 15, 100, 10, false) }},
+		{"ITF14", func(pdf *imagePdf) { barcode.DrawITF14(pdf, "10012345678902", 15, 15, 100, 30, false) }},
+		{"UPCA", func(pdf *imagePdf) { barcode.DrawUPCA(pdf, "036000291452", 15, 15, 100, 10, false) }},
+		{"UPCE", func(pdf *imagePdf) { barcode.DrawUPCE(pdf, "01234565", 15, 15, 100, 10, false) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := createImagePdf()
+			tt.draw(pdf)
+			if err := pdf.Error(); err != nil {
+				t.Fatal(err)
+			}
+			if len(pdf.sizes) != 1 {
+				t.Errorf("expected 1 barcode on the page, got %d", len(pdf.sizes))
+			}
+		})
+	}
+
+	pdf := createImagePdf()
+	barcode.DrawEAN(pdf, "invalid", 15, 15, 100, 10, false)
+	if pdf.Error() == nil || len(pdf.sizes) != 0 {
+		t.Error("expected an error and no barcode for an invalid code")
+	}
+}
+
+// TestRegisterGS1_128 ensures that GS1-128 element strings are assembled with
+// FNC1 separators after variable-length fields only, and that invalid
+// application identifiers and values are rejected.
+func TestRegisterGS1_128(t *testing.T) {
+	ais := map[string]string{
+		"10": "AB-123",
+		"01": "09501101530003",
+		"21": "X1",
+		"17": "251231",
+	}
+
+	text, err := barcode.GS1ElementString(ais)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(01)09501101530003(17)251231(10)AB-123(21)X1"; text != want {
+		t.Errorf("expected element string %q, got %q", want, text)
+	}
+
+	pdf := createImagePdf()
+	key, err := barcode.RegisterGS1_128E(pdf, ais)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fnc1 := string(code128.FNC1)
+	want, err := code128.Encode(fnc1 + "0109501101530003" + "17251231" + "10AB-123" + fnc1 + "21X1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _, _ := barcode.GetBarcodeDimensions(key); w != want.Bounds().Dx() {
+		t.Errorf("expected %d modules, got %d", want.Bounds().Dx(), w)
+	}
+
+	invalid := []map[string]string{
+		nil,
+		{"01": "09501101530004"},
+		{"01": "0950110153000"},
+		{"17": "25123A"},
+		{"10": strings.Repeat("A", 21)},
+		{"10": "ä"},
+		{"19": "1"},
+		{"AB": "1"},
+	}
+	for _, ais := range invalid {
+		if _, err := barcode.RegisterGS1_128E(pdf, ais); err == nil {
+			t.Errorf("expected an error for %v", ais)
+		}
+	}
+}
+
+// TestRegisterEANChecked ensures that EAN codes are only registered with a
+// correct check digit.
+func TestRegisterEANChecked(t *testing.T) {
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{"4006381333931", true},
+		{"96385074", true},
+		{"4006381333932", false},
+		{"96385075", false},
+		{"400638133393", false},
+		{"400638133393X", false},
+	}
+	for _, tt := range tests {
+		pdf := createPdf()
+		key, err := barcode.RegisterEANChecked(pdf, tt.code)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.code, err)
+				continue
+			}
+			if _, content, _ := barcode.GetMetadata(key); content != tt.code {
+				t.Errorf("%s: expected the code to be encoded, got %s", tt.code, content)
+			}
+		} else if err == nil {
+			t.Errorf("%s: expected an error", tt.code)
+		}
+	}
+
+	_, err := barcode.RegisterEANChecked(createPdf(), "4006381333932")
+	if err == nil || !strings.Contains(err.Error(), "check digit 2") {
+		t.Errorf("expected an error naming the wrong check digit, got %v", err)
+	}
+}
+
+// TestRegisterEANWithAddon ensures that add-ons are appended to EAN barcodes
+// with the correct gap and that only 2 and 5 digit add-ons are accepted.
+func TestRegisterEANWithAddon(t *testing.T) {
+	pdf := createImagePdf()
+
+	// ISBN 978-0-306-40615-7 with a price of USD 19.95
+	key, err := barcode.RegisterEANWithAddonE(pdf, "9780306406157", "51995")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _, _ := barcode.GetBarcodeDimensions(key); w != 95+9+47 {
+		t.Errorf("expected %d modules, got %d", 95+9+47, w)
+	}
+
+	key, err = barcode.RegisterEANWithAddonE(pdf, "9770317847001", "03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _, _ := barcode.GetBarcodeDimensions(key); w != 95+9+20 {
+		t.Errorf("expected %d modules, got %d", 95+9+20, w)
+	}
+
+	for _, addon := range []string{"", "1", "123", "1234", "123456", "5199A"} {
+		if _, err := barcode.RegisterEANWithAddonE(pdf, "9780306406157", addon); err == nil {
+			t.Errorf("expected an error for add-on %q", addon)
+		}
+	}
+	if _, err := barcode.RegisterEANWithAddonE(pdf, "9780306406158", "51995"); err == nil {
+		t.Error("expected an error for an invalid check digit")
+	}
+}
+
+// TestRegisterQRWithLogo ensures that the logo is drawn in the center of the
+// QR code, that QR codes with different logos are kept apart and that the
+// coverage is limited by the error correction level.
+func TestRegisterQRWithLogo(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	logo := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+
+	pdf := createImagePdf()
+	key, err := barcode.RegisterQRWithLogoE(pdf, "logo", qr.H, logo, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain := barcode.RegisterQR(pdf, "logo", qr.H, qr.Auto); plain == key {
+		t.Error("expected different keys for QR codes with and without logo")
+	}
+
+	barcode.Barcode(pdf, key, 15, 15, 50, 50, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(pdf.images[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bounds := img.Bounds()
+	var logoPixels int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.NRGBAModel.Convert(img.At(x, y)) == red {
+				logoPixels++
+			}
+		}
+	}
+	center := color.NRGBAModel.Convert(img.At(bounds.Dx()/2, bounds.Dy()/2))
+	if center != red {
+		t.Errorf("expected the logo in the center, got %v", center)
+	}
+	if share := float64(logoPixels) / float64(bounds.Dx()*bounds.Dy()); share < 0.1 || share > 0.2 {
+		t.Errorf("expected the logo to cover about 20%% of the image, got %.2f", share)
+	}
+
+	for _, tt := range []struct {
+		ecl      qr.ErrorCorrectionLevel
+		coverage float64
+	}{{qr.L, 0.1}, {qr.M, 0.2}, {qr.H, 0.35}, {qr.H, 0}} {
+		if _, err := barcode.RegisterQRWithLogoE(pdf, "logo", tt.ecl, logo, tt.coverage); err == nil {
+			t.Errorf("expected an error for a coverage of %g", tt.coverage)
+		}
+	}
+}
+
+// TestValidate ensures that codes are validated according to the rules of
+// their barcode kind.
+func TestValidate(t *testing.T) {
+	fnc1 := string(code128.FNC1)
+
+	tests := []struct {
+		kind  string
+		code  string
+		valid bool
+	}{
+		{bc.TypeAztec, "aztec", true},
+		{bc.TypeAztec, "", false},
+		{bc.TypeCodabar, "A40156B", true},
+		{bc.TypeCodabar, "AB", true},
+		{bc.TypeCodabar, "40156", false},
+		{bc.TypeCodabar, "A40X56B", false},
+		{bc.TypeCode128, "Code 128", true},
+		{bc.TypeCode128, fnc1 + "0109501101530003", true},
+		{bc.TypeCode128, "Käse", false},
+		{bc.TypeCode39, "CODE-39 $/+%", true},
+		{bc.TypeCode39, "code39", false},
+		{bc.TypeCode39, "CODE*39", false},
+		{barcode.TypeCode39FullASCII, "code*39", true},
+		{barcode.TypeCode39FullASCII, "Käse", false},
+		{bc.TypeCode93, "CODE 93", true},
+		{bc.TypeCode93, "code93", false},
+		{barcode.TypeCode93FullASCII, "code93", true},
+		{bc.TypeDataMatrix, "datamatrix", true},
+		{bc.TypeDataMatrix, "", false},
+		{bc.TypeEAN8, "96385074", true},
+		{bc.TypeEAN8, "9638507", true},
+		{bc.TypeEAN8, "96385075", false},
+		{bc.TypeEAN8, "963850", false},
+		{bc.TypeEAN13, "9780306406157", true},
+		{bc.TypeEAN13, "978030640615", true},
+		{bc.TypeEAN13, "9780306406158", false},
+		{bc.TypeEAN13, "978030640615X", false},
+		{bc.TypePDF, "pdf417", true},
+		{bc.TypePDF, "", false},
+		{bc.TypeQR, "qr", true},
+		{bc.TypeQR, "", false},
+		{bc.Type2of5, "12345", true},
+		{bc.Type2of5, "12A45", false},
+		{bc.Type2of5Interleaved, "123456", true},
+		{bc.Type2of5Interleaved, "12345", false},
+		{barcode.TypeITF14, "10012345678902", true},
+		{barcode.TypeITF14, "10012345678903", false},
+		{barcode.TypeITF14, "1001234567890", false},
+		{barcode.TypeCode11, "123-45", true},
+		{barcode.TypeCode11, "123+45", false},
+		{barcode.TypeDataBarLimited, "15012345678907", true},
+		{barcode.TypeDataBarLimited, "25012345678904", false},
+		{barcode.TypeDataBarLimited, "1501234567890", false},
+		{barcode.TypeIntelligentMail, "0123456709498765432101234", true},
+		{barcode.TypeIntelligentMail, "01234567094987654321012", false},
+		{barcode.TypeMSI, "1234567", true},
+		{barcode.TypeMSI, "12A4567", false},
+		{barcode.TypePharmacode, "3", true},
+		{barcode.TypePharmacode, "131070", true},
+		{barcode.TypePharmacode, "2", false},
+		{barcode.TypePharmacode, "131071", false},
+		{barcode.TypePharmacode, "+12", false},
+		{barcode.TypeUPCA, "036000291452", true},
+		{barcode.TypeUPCA, "036000291453", false},
+		{barcode.TypeUPCE, "01234565", true},
+		{barcode.TypeUPCE, "21234565", false},
+		{barcode.TypeUPCE, "01234566", false},
+		{"Unknown", "code", false},
+	}
+
+	for _, tt := range tests {
+		err := barcode.Validate(tt.kind, tt.code)
+		if tt.valid && err != nil {
+			t.Errorf("expected %s code %q to be valid, got %v", tt.kind, tt.code, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("expected %s code %q to be invalid", tt.kind, tt.code)
+		}
+	}
+
+	pdf := createPdf()
+	if _, err := barcode.RegisterCode39E(pdf, "code39", false, false); err == nil {
+		t.Error("expected RegisterCode39E to validate the code")
+	}
+}
+
+// TestSetErrorPolicy ensures that failures are set on the PDF, panicked
+// with or ignored according to the error policy, while the *E variants
+// return them regardless of the policy.
+func TestSetErrorPolicy(t *testing.T) {
+	defer barcode.SetErrorPolicy(barcode.ErrorPolicySetError)
+
+	// fail puts an unregistered barcode and returns the value it panics with.
+	fail := func(pdf *gofpdf.Fpdf) (recovered interface{}) {
+		defer func() { recovered = recover() }()
+		barcode.Barcode(pdf, "missing", 15, 15, 60, 15, false)
+		return nil
+	}
+
+	tests := []struct {
+		policy   barcode.ErrorPolicy
+		panics   bool
+		pdfError bool
+	}{
+		{barcode.ErrorPolicySetError, false, true},
+		{barcode.ErrorPolicyPanic, true, false},
+		{barcode.ErrorPolicyReturn, false, false},
+	}
+	for _, tt := range tests {
+		barcode.SetErrorPolicy(tt.policy)
+		pdf := createPdf()
+
+		recovered := fail(pdf)
+		if err, _ := recovered.(error); tt.panics != errors.Is(err, barcode.ErrBarcodeNotFound) || (!tt.panics && recovered != nil) {
+			t.Errorf("policy %d: expected panic %v, got %v", tt.policy, tt.panics, recovered)
+		}
+		if tt.pdfError != errors.Is(pdf.Error(), barcode.ErrBarcodeNotFound) {
+			t.Errorf("policy %d: expected error on the PDF %v, got %v", tt.policy, tt.pdfError, pdf.Error())
+		}
+
+		pdf = createPdf()
+		if err := barcode.BarcodeE(pdf, "missing", 15, 15, 60, 15, false); !errors.Is(err, barcode.ErrBarcodeNotFound) {
+			t.Errorf("policy %d: expected BarcodeE to return ErrBarcodeNotFound, got %v", tt.policy, err)
+		}
+		if !pdf.Ok() {
+			t.Errorf("policy %d: expected BarcodeE not to set an error, got %v", tt.policy, pdf.Error())
+		}
+	}
+}
+
+// TestCapabilities ensures that every kind accepted by Validate() is
+// described, and that a valid code of each kind fits its description.
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		kind       string
+		code       string
+		dimensions int
+		numeric    bool
+		checkDigit bool
+	}{
+		{bc.TypeAztec, "aztec", 2, false, false},
+		{bc.TypeCodabar, "A40156B", 1, false, false},
+		{bc.TypeCode128, "Code 128", 1, false, true},
+		{bc.TypeCode39, "CODE-39", 1, false, true},
+		{barcode.TypeCode39FullASCII, "code*39", 1, false, true},
+		{bc.TypeCode93, "CODE 93", 1, false, true},
+		{barcode.TypeCode93FullASCII, "code93", 1, false, true},
+		{bc.TypeDataMatrix, "datamatrix", 2, false, false},
+		{bc.TypeEAN8, "96385074", 1, true, true},
+		{bc.TypeEAN13, "9780306406157", 1, true, true},
+		{bc.TypePDF, "pdf417", 2, false, false},
+		{bc.TypeQR, "qr", 2, false, false},
+		{bc.Type2of5, "12345", 1, true, false},
+		{bc.Type2of5Interleaved, "123456", 1, true, false},
+		{barcode.TypeCode11, "123-45", 1, false, true},
+		{barcode.TypeDataBarLimited, "15012345678907", 1, true, true},
+		{barcode.TypeIntelligentMail, "0123456709498765432101234", 1, true, false},
+		{barcode.TypeITF14, "10012345678902", 1, true, true},
+		{barcode.TypeMSI, "1234567", 1, true, true},
+		{barcode.TypePharmacode, "131070", 1, true, false},
+		{barcode.TypeUPCA, "036000291452", 1, true, true},
+		{barcode.TypeUPCE, "01234565", 1, true, true},
+	}
+
+	for _, tt := range tests {
+		if err := barcode.Validate(tt.kind, tt.code); err != nil {
+			t.Fatalf("expected %s code %q to be valid, got %v", tt.kind, tt.code, err)
+		}
+
+		info, ok := barcode.Capabilities(tt.kind)
+		if !ok {
+			t.Errorf("expected capabilities of %s", tt.kind)
+			continue
+		}
+		if info.Kind != tt.kind || info.Dimensions != tt.dimensions || info.Numeric != tt.numeric || info.CheckDigit != tt.checkDigit {
+			t.Errorf("unexpected capabilities of %s: %+v", tt.kind, info)
+		}
+		if len(tt.code) < info.MinLength || (info.MaxLength > 0 && len(tt.code) > info.MaxLength) {
+			t.Errorf("expected %s code %q to be %d to %d characters long", tt.kind, tt.code, info.MinLength, info.MaxLength)
+		}
+		for _, r := range tt.code {
+			if info.Charset != "" && !strings.ContainsRune(info.Charset, r) {
+				t.Errorf("expected %q in the charset of %s %q", r, tt.kind, info.Charset)
+			}
+		}
+	}
+
+	if _, ok := barcode.Capabilities("Unknown"); ok {
+		t.Error("expected no capabilities of an unknown kind")
+	}
+}
+
+// TestRegisterImage ensures that arbitrary images are registered with the
+// given key and keep their colors when they are scaled.
+func TestRegisterImage(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(src, image.Rect(0, 0, 10, 10), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(10, 0, 20, 10), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	pdf := createImagePdf()
+	key := barcode.RegisterImage(pdf, "custom", src)
+	if key != "custom" {
+		t.Errorf("expected key %q, got %q", "custom", key)
+	}
+
+	barcode.Barcode(pdf, key, 15, 15, 40, 0, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if size := pdf.sizes[0]; math.Abs(size.Ht-20) > 1e-9 {
+		t.Errorf("expected the aspect ratio of the image to be preserved, got %v", size)
+	}
+
+	img, err := png.Decode(bytes.NewReader(pdf.images[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx()%20 != 0 || bounds.Dy()%10 != 0 || bounds.Dx() < 20 {
+		t.Errorf("expected the image to be scaled by an integer factor, got %v", bounds)
+	}
+	if c := color.NRGBAModel.Convert(img.At(0, 0)); c != red {
+		t.Errorf("expected %v on the left, got %v", red, c)
+	}
+	if c := color.NRGBAModel.Convert(img.At(bounds.Dx()-1, 0)); c != blue {
+		t.Errorf("expected %v on the right, got %v", blue, c)
+	}
+
+	if _, err := barcode.RegisterImageE(pdf, "", src); err == nil {
+		t.Error("expected an error for an empty key")
+	}
+	if _, err := barcode.RegisterImageE(pdf, "empty", image.NewGray(image.Rectangle{})); err == nil {
+		t.Error("expected an error for an empty image")
+	}
+}
+
+// TestRegisterCode128Batch ensures that all valid codes of a batch are
+// registered and that the first invalid code is reported with its index.
+func TestRegisterCode128Batch(t *testing.T) {
+	pdf := createPdf()
+
+	keys, err := barcode.RegisterCode128Batch(pdf, []string{"label-1", "Käse", "label-3", "Brötchen"})
+	if len(keys) != 4 {
+		t.Fatalf("expected 4 keys, got %d", len(keys))
+	}
+	if keys[0] == "" || keys[1] != "" || keys[2] == "" || keys[3] != "" {
+		t.Errorf("expected keys for the valid codes only, got %q", keys)
+	}
+
+	batchErr, ok := err.(*barcode.BatchError)
+	if !ok {
+		t.Fatalf("expected a *BatchError, got %v", err)
+	}
+	if batchErr.Index != 1 {
+		t.Errorf("expected the error of code 1, got %d", batchErr.Index)
+	}
+	if pdf.Err() {
+		t.Errorf("expected no error on the PDF, got %v", pdf.Error())
+	}
+
+	barcode.Barcode(pdf, keys[2], 15, 15, 100, 10, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err = barcode.RegisterCode128Batch(pdf, []string{"a", "b"})
+	if err != nil || len(keys) != 2 {
+		t.Errorf("expected 2 keys and no error, got %q and %v", keys, err)
+	}
+}
+
+// TestRegisterMany ensures that concurrently registered barcodes keep the
+// order of their codes and match serially registered ones.
+func TestRegisterMany(t *testing.T) {
+	pdf := createPdf()
+	reg := barcode.New(pdf)
+
+	codes := make([]string, 50)
+	for i := range codes {
+		codes[i] = "label-" + strconv.Itoa(i)
+	}
+	keys, err := reg.RegisterMany(bc.TypeCode128, codes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, code := range codes {
+		if want := reg.RegisterCode128(code); keys[i] != want {
+			t.Errorf("code %d: expected key %q, got %q", i, want, keys[i])
+		}
+	}
+
+	keys, err = barcode.RegisterMany(pdf, bc.TypeEAN13, []string{"4006381333931", "4006381333932", "96385074", "4006381333931"})
+	var batchErr *barcode.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Fatalf("expected a *BatchError for code 1, got %v", err)
+	}
+	if keys[0] == "" || keys[1] != "" || keys[2] != "" || keys[3] != keys[0] {
+		t.Errorf("expected keys for the valid EAN-13 codes only, got %q", keys)
+	}
+	if pdf.Err() {
+		t.Errorf("expected no error on the PDF, got %v", pdf.Error())
+	}
+
+	if _, err := barcode.RegisterMany(pdf, bc.TypeQR, codes); err == nil {
+		t.Error("expected an error for a kind that requires parameters")
+	}
+}
+
+// TestGetMetadata ensures that the kind and content of registered barcodes
+// are returned, and that unknown keys are reported.
+func TestGetMetadata(t *testing.T) {
+	pdf := createPdf()
+
+	key := barcode.RegisterCode128(pdf, "metadata")
+	kind, content, ok := barcode.GetMetadata(key)
+	if !ok || kind != bc.TypeCode128 || content != "metadata" {
+		t.Errorf("expected %q and %q, got %q, %q and %v", bc.TypeCode128, "metadata", kind, content, ok)
+	}
+
+	registry := barcode.New(pdf)
+	key = registry.RegisterQR("metadata", qr.M, qr.Auto)
+	kind, content, ok = registry.GetMetadata(key)
+	if !ok || kind != bc.TypeQR || content != "metadata" {
+		t.Errorf("expected %q and %q, got %q, %q and %v", bc.TypeQR, "metadata", kind, content, ok)
+	}
+
+	if _, _, ok := barcode.GetMetadata("unknown"); ok {
+		t.Error("expected ok to be false for an unknown key")
+	}
+}
+
+// TestGet ensures that the unscaled barcode of a key is returned.
+func TestGet(t *testing.T) {
+	pdf := createPdf()
+	key := barcode.RegisterCode128(pdf, "get")
+
+	bcode, ok := barcode.Get(key)
+	if !ok {
+		t.Fatal("expected the barcode to be found")
+	}
+	w, h, _ := barcode.GetBarcodeDimensions(key)
+	if bcode.Content() != "get" || bcode.Bounds().Dx() != w || bcode.Bounds().Dy() != h {
+		t.Errorf("expected the unscaled barcode of %q, got %q of %v", "get", bcode.Content(), bcode.Bounds())
+	}
+
+	if _, ok := barcode.New(pdf).Get(key); ok {
+		t.Error("expected a new registry not to know the key")
+	}
+	if _, ok := barcode.Get("unknown"); ok {
+		t.Error("expected ok to be false for an unknown key")
+	}
+}
+
+// TestKeys ensures that Keys() lists the registered barcodes in sorted order
+// and returns a copy of the keys.
+func TestKeys(t *testing.T) {
+	pdf := createPdf()
+	reg := barcode.New(pdf)
+	if keys := reg.Keys(); len(keys) != 0 {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+
+	want := []string{
+		reg.RegisterQR("keys", qr.M, qr.Auto),
+		reg.RegisterCode128("keys"),
+		reg.RegisterEAN("96385074"),
+	}
+	sort.Strings(want)
+
+	keys := reg.Keys()
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected keys %v, got %v", want, keys)
+	}
+
+	reg.Unregister(want[0])
+	if len(keys) != 3 || len(reg.Keys()) != 2 {
+		t.Errorf("expected a copy of the keys, got %v after %v", reg.Keys(), keys)
+	}
+}
+
+// TestRegisterDataMatrixSized ensures that Data Matrix codes are encoded in
+// the requested square or rectangular size, that square codes match those of
+// RegisterDataMatrix() and that unsupported sizes are rejected.
+func TestRegisterDataMatrixSized(t *testing.T) {
+	render := func(register func(pdf *imagePdf) string) []byte {
+		pdf := createImagePdf()
+		barcode.BarcodeUnscalable(pdf, register(pdf), 15, 15, nil, nil, false)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+		return pdf.images[0]
+	}
+
+	tests := []struct {
+		code       string
+		rows, cols int
+	}{
+		{"0123456789ABCDEF", 16, 16},
+		{strings.Repeat("gofpdf", 10), 32, 32},
+		{strings.Repeat("gofpdf", 30), 52, 52},
+		{strings.Repeat("gofpdf", 40), 64, 64},
+		{"0123456789ABC", 12, 26},
+		{"gofpdf", 16, 48},
+		{"123456", 8, 18},
+		{"1234567890123456", 8, 32},
+	}
+
+	var compared int
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.rows)+"x"+strconv.Itoa(tt.cols), func(t *testing.T) {
+			pdf := createPdf()
+			key, err := barcode.RegisterDataMatrixSizedE(pdf, tt.code, tt.rows, tt.cols)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w, h, _ := barcode.GetBarcodeDimensions(key); w != tt.cols || h != tt.rows {
+				t.Errorf("expected %dx%d modules, got %dx%d", tt.cols, tt.rows, w, h)
+			}
+
+			auto := barcode.RegisterDataMatrix(pdf, tt.code)
+			if w, h, _ := barcode.GetBarcodeDimensions(auto); w != tt.cols || h != tt.rows {
+				return
+			}
+			sized := render(func(pdf *imagePdf) string { return barcode.RegisterDataMatrixSized(pdf, tt.code, tt.rows, tt.cols) })
+			if !bytes.Equal(sized, render(func(pdf *imagePdf) string { return barcode.RegisterDataMatrix(pdf, tt.code) })) {
+				t.Error("expected the same symbol as RegisterDataMatrix()")
+			}
+			compared++
+		})
+	}
+	if compared != 4 {
+		t.Errorf("expected 4 square symbols to be compared, got %d", compared)
+	}
+
+	pdf := createPdf()
+	if _, err := barcode.RegisterDataMatrixSizedE(pdf, "gofpdf", 17, 17); err == nil {
+		t.Error("expected an error for an unsupported size")
+	}
+	if _, err := barcode.RegisterDataMatrixSizedE(pdf, strings.Repeat("gofpdf", 10), 12, 12); err == nil {
+		t.Error("expected an error for a code that does not fit")
+	}
+}
+
+// TestRegisterQRVersion ensures that QR codes are encoded in the requested
+// version, also with a quiet zone, and that codes which don't fit into the
+// version are rejected.
+func TestRegisterQRVersion(t *testing.T) {
+	tests := []struct {
+		code    string
+		ecl     qr.ErrorCorrectionLevel
+		mode    qr.Encoding
+		version int
+	}{
+		{"gofpdf", qr.H, qr.Unicode, 1},
+		{"gofpdf", qr.M, qr.Auto, 5},
+		{"0123456789", qr.L, qr.Numeric, 7},
+		{"GOFPDF CONTRIB", qr.Q, qr.AlphaNumeric, 10},
+		{strings.Repeat("gofpdf", 100), qr.L, qr.Unicode, 27},
+		{"gofpdf", qr.H, qr.Auto, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.version), func(t *testing.T) {
+			pdf := createPdf()
+			key, err := barcode.RegisterQRVersionE(pdf, tt.code, tt.ecl, tt.mode, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := 17 + 4*tt.version
+			if w, h, _ := barcode.GetBarcodeDimensions(key); w != size || h != size {
+				t.Errorf("expected %dx%d modules, got %dx%d", size, size, w, h)
+			}
+			if kind, content, _ := barcode.GetMetadata(key); kind != "QR Code" || content != tt.code {
+				t.Errorf("unexpected metadata %q, %q", kind, content)
+			}
+		})
+	}
+
+	imageSize := func(code string) (w, h int) {
+		pdf := createImagePdf()
+		barcode.BarcodeUnscalable(pdf, barcode.RegisterQRVersion(pdf, code, qr.M, qr.Auto, 4), 15, 15, nil, nil, false)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(pdf.images[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg.Width, cfg.Height
+	}
+
+	bareW, bareH := imageSize("1")
+	barcode.SetQuietZone(4)
+	defer barcode.SetQuietZone(0)
+	shortW, shortH := imageSize("1")
+	longW, longH := imageSize(strings.Repeat("gofpdf", 8))
+	if shortW != longW || shortH != longH {
+		t.Errorf("expected codes of the same size, got %dx%d and %dx%d", shortW, shortH, longW, longH)
+	}
+	if shortW*bareH != bareW*shortH || shortW*33 != bareW*41 {
+		t.Errorf("expected a quiet zone of 4 modules, got %dx%d for %dx%d", shortW, shortH, bareW, bareH)
+	}
+
+	pdf := createPdf()
+	if _, err := barcode.RegisterQRVersionE(pdf, strings.Repeat("gofpdf", 10), qr.H, qr.Unicode, 2); err == nil {
+		t.Error("expected an error for a code that does not fit")
+	}
+	if _, err := barcode.RegisterQRVersionE(pdf, "gofpdf", qr.H, qr.Numeric, 2); err == nil {
+		t.Error("expected an error for a code that can't be encoded in the mode")
+	}
+	if _, err := barcode.RegisterQRVersionE(pdf, "gofpdf", qr.H, qr.Unicode, 41); err == nil {
+		t.Error("expected an error for an unsupported version")
+	}
+}
+
+// qrCodewords returns the first n codewords of the QR code img of version 1 to
+// 6, which is read without quiet zone at one pixel per module. The mask is
+// taken from the format information.
+func qrCodewords(img image.Image, n int) []byte {
+	size := img.Bounds().Dx()
+	dark := func(x, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r < 0x8000
+	}
+	function := func(x, y int) bool {
+		a := size - 7
+		return x < 9 && y < 9 || x >= size-8 && y < 9 || x < 9 && y >= size-8 || x == 6 || y == 6 ||
+			size > 21 && x >= a-2 && x <= a+2 && y >= a-2 && y <= a+2
+	}
+
+	format := 0
+	for x := 0; x < 6; x++ {
+		format <<= 1
+		if dark(x, 8) {
+			format |= 1
+		}
+	}
+	masks := []func(x, y int) bool{
+		func(x, y int) bool { return (x+y)%2 == 0 },
+		func(x, y int) bool { return y%2 == 0 },
+		func(x, y int) bool { return x%3 == 0 },
+		func(x, y int) bool { return (x+y)%3 == 0 },
+		func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+		func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+		func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+		func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+	}
+	mask := masks[(format^0x5412>>9)>>1&7]
+
+	codewords := make([]byte, n)
+	i := 0
+	for right := size - 1; right >= 1 && i < n*8; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = size - 1 - vert
+			}
+			for x := right; x >= right-1 && i < n*8; x-- {
+				if function(x, y) {
+					continue
+				}
+				if dark(x, y) != mask(x, y) {
+					codewords[i/8] |= 0x80 >> uint(i%8)
+				}
+				i++
+			}
+		}
+	}
+
+	return codewords
+}
+
+// TestRegisterQRStructured ensures that data is split into the expected
+// number of QR codes, which carry the structured append header with their
+// position and the parity of the data.
+func TestRegisterQRStructured(t *testing.T) {
+	data := "gofpdfcontrib structured append"
+	var parity byte
+	for i := 0; i < len(data); i++ {
+		parity ^= data[i]
+	}
+
+	pdf := createPdf()
+	keys, err := barcode.RegisterQRStructuredE(pdf, data, qr.M, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 symbols, got %d", len(keys))
+	}
+
+	var joined string
+	for i, key := range keys {
+		bcode, ok := barcode.Get(key)
+		if !ok {
+			t.Fatalf("symbol %d is not registered", i)
+		}
+		joined += bcode.Content()
+
+		sa, ok := bcode.(interface {
+			StructuredAppend() (index, total int, parity byte)
+		})
+		if !ok {
+			t.Fatalf("symbol %d has no structured append header", i)
+		}
+		if index, total, p := sa.StructuredAppend(); index != i || total != 3 || p != parity {
+			t.Errorf("symbol %d: expected header %d/3 %02x, got %d/%d %02x", i, i, parity, index, total, p)
+		}
+
+		header := qrCodewords(bcode, 3)
+		want := []byte{0x30 | byte(i), 2<<4 | parity>>4, parity << 4}
+		if header[0] != want[0] || header[1] != want[1] || header[2]&0xF0 != want[2] {
+			t.Errorf("symbol %d: expected header bytes % x, got % x", i, want, header)
+		}
+	}
+	if joined != data {
+		t.Errorf("expected the symbols to hold %q, got %q", data, joined)
+	}
+
+	if _, err := barcode.RegisterQRStructuredE(pdf, strings.Repeat("gofpdf", 20), qr.M, 6); err == nil {
+		t.Error("expected an error for more than 16 symbols")
+	}
+	if _, err := barcode.RegisterQRStructuredE(pdf, data, qr.M, 0); err == nil {
+		t.Error("expected an error for an invalid symbol capacity")
+	}
+}
+
+// TestRegisterPharmacode ensures that Pharmacode barcodes consist of the
+// expected bars for known values and that the range of numbers is checked.
+func TestRegisterPharmacode(t *testing.T) {
+	tests := []struct {
+		number int
+		width  int
+	}{
+		{3, 1 + 2 + 1},            // two narrow bars
+		{4, 1 + 2 + 3},            // narrow and wide bar
+		{1234, 5*3 + 5*1 + 9*2},   // NNWWNWNNWW
+		{131070, 16*3 + 15*2},     // sixteen wide bars
+		{131069, 1 + 15*3 + 15*2}, // fifteen wide bars and a narrow one
+	}
+
+	pdf := createPdf()
+	for _, tt := range tests {
+		key, err := barcode.RegisterPharmacodeE(pdf, tt.number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, h, _ := barcode.GetBarcodeDimensions(key); w != tt.width || h != 1 {
+			t.Errorf("expected %d modules for %d, got %dx%d", tt.width, tt.number, w, h)
+		}
+	}
+
+	for _, number := range []int{-1, 0, 2, 131071} {
+		if _, err := barcode.RegisterPharmacodeE(pdf, number); err == nil {
+			t.Errorf("expected an error for %d", number)
+		}
+	}
+}
+
+// TestRegisterMSI ensures that MSI Plessey barcodes get the check digits of
+// each checksum mode and consist of the expected bars.
+func TestRegisterMSI(t *testing.T) {
+	tests := []struct {
+		mode    barcode.MSIChecksumMode
+		content string
+	}{
+		{barcode.MSIChecksumNone, "1234567"},
+		{barcode.MSIChecksumMod10, "12345674"},
+		{barcode.MSIChecksumMod11, "12345674"},
+		{barcode.MSIChecksumMod1010, "123456741"},
+		{barcode.MSIChecksumMod1110, "123456741"},
+	}
+
+	pdf := createPdf()
+	for _, tt := range tests {
+		key, err := barcode.RegisterMSIE(pdf, "1234567", tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, content, _ := barcode.GetMetadata(key); content != tt.content {
+			t.Errorf("expected content %q for mode %d, got %q", tt.content, tt.mode, content)
+		}
+		if w, _, _ := barcode.GetBarcodeDimensions(key); w != 3+12*len(tt.content)+4 {
+			t.Errorf("expected %d modules for mode %d, got %d", 3+12*len(tt.content)+4, tt.mode, w)
+		}
+	}
+
+	key, _ := barcode.RegisterMSIE(pdf, "6", barcode.MSIChecksumMod11)
+	if _, content, _ := barcode.GetMetadata(key); content != "610" {
+		t.Errorf("expected a check value of 10 to be appended as \"10\", got %q", content)
+	}
+
+	// The digit 1 is encoded as 0001 between the start and stop patterns.
+	rects := &rectPdf{imagePdf: createImagePdf()}
+	key = barcode.RegisterMSI(rects, "1", barcode.MSIChecksumNone)
+	barcode.BarcodeVector(rects, key, 0, 0, 3+12+4, 10, false)
+	var widths []float64
+	for _, rect := range rects.rects {
+		widths = append(widths, math.Round(rect.Wd))
+	}
+	if want := []float64{2, 1, 1, 1, 2, 1, 1}; !reflect.DeepEqual(widths, want) {
+		t.Errorf("expected bars %v, got %v", want, widths)
+	}
+
+	if _, err := barcode.RegisterMSIE(pdf, "1234567", barcode.MSIChecksumMode(42)); err == nil {
+		t.Error("expected an error for an unknown checksum mode")
+	}
+}
+
+// TestSetStrictAspect ensures that squashed two-dimensional barcodes are
+// rejected in strict mode only.
+func TestSetStrictAspect(t *testing.T) {
+	pdf := createPdf()
+	key := barcode.RegisterDataMatrix(pdf, "strict")
+	linear := barcode.RegisterCode128(pdf, "strict")
+
+	barcode.Barcode(pdf, key, 15, 15, 40, 20, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatalf("expected no error outside strict mode, got %v", err)
+	}
+
+	barcode.SetStrictAspect(true)
+	defer barcode.SetStrictAspect(false)
+
+	barcode.Barcode(pdf, key, 15, 15, 40, 40, false)
+	barcode.Barcode(pdf, linear, 15, 60, 100, 10, false)
+	if err := pdf.Error(); err != nil {
+		t.Fatalf("expected no error for undistorted barcodes, got %v", err)
+	}
+
+	barcode.Barcode(pdf, key, 15, 15, 40, 20, false)
+	if err := pdf.Error(); !errors.Is(err, barcode.ErrDistorted) {
+		t.Errorf("expected ErrDistorted for a squashed DataMatrix, got %v", err)
+	}
+}
+
+// TestRegisterCodabarWithGuards ensures that every pair of valid start and stop
+// characters is encoded around the code and that other guards are rejected.
+func TestRegisterCodabarWithGuards(t *testing.T) {
+	pdf := createPdf()
+	for _, start := range []byte("ABCD") {
+		for _, stop := range []byte("ABCD") {
+			key, err := barcode.RegisterCodabarWithGuardsE(pdf, "40156", start, stop)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := string(start) + "40156" + string(stop)
+			if _, content, _ := barcode.GetMetadata(key); content != want {
+				t.Errorf("expected content %q, got %q", want, content)
+			}
+		}
+	}
+
+	tests := []struct {
+		code        string
+		start, stop byte
+	}{
+		{"40156", 'E', 'B'},
+		{"40156", 'A', 'a'},
+		{"40156", '1', 'B'},
+		{"A40156", 'A', 'B'},
+	}
+	for _, tt := range tests {
+		if _, err := barcode.RegisterCodabarWithGuardsE(pdf, tt.code, tt.start, tt.stop); err == nil {
+			t.Errorf("expected an error for %q with guards %q and %q", tt.code, tt.start, tt.stop)
+		}
+	}
+}
+
+// TestEncode ensures that barcode images are written at the requested size,
+// resolution and format.
+func TestEncode(t *testing.T) {
+	pdf := createPdf()
+	key := barcode.RegisterQR(pdf, "encode", qr.M, qr.Auto)
+	dx, dy, _ := barcode.GetBarcodeDimensions(key)
+
+	// Three pixels per module at 96 DPI
+	buf := new(bytes.Buffer)
+	if err := barcode.Encode(buf, key, float64(dx)*3*72/96, 0, 96, "png"); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 3*dx || img.Bounds().Dy() != 3*dy {
+		t.Errorf("expected a %dx%d image, got %v", 3*dx, 3*dy, img.Bounds())
+	}
+
+	buf.Reset()
+	if err := barcode.Encode(buf, key, 72, 72, 300, "jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jpeg.Decode(buf); err != nil {
+		t.Errorf("expected a JPEG image, got %v", err)
+	}
+
+	if err := barcode.Encode(buf, "unknown", 72, 72, 96, "png"); err != barcode.ErrBarcodeNotFound {
+		t.Errorf("expected ErrBarcodeNotFound, got %v", err)
+	}
+	if err := barcode.Encode(buf, key, 72, 72, 96, "gif"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+// TestRender ensures that barcodes are rendered at the requested size in
+// pixels without a PDF.
+func TestRender(t *testing.T) {
+	pdf := createPdf()
+	key := barcode.RegisterQR(pdf, "render", qr.M, qr.Auto)
+	dx, dy, _ := barcode.GetBarcodeDimensions(key)
+
+	tests := []struct {
+		w, h         int
+		wantW, wantH int
+	}{
+		{3 * dx, 3 * dy, 3 * dx, 3 * dy},
+		{4 * dx, 0, 4 * dx, 4 * dy},
+		{0, 2 * dy, 2 * dx, 2 * dy},
+		{0, 0, dx, dy},
+	}
+	for _, tt := range tests {
+		img, err := barcode.Render(key, tt.w, tt.h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Dx() != tt.wantW || img.Bounds().Dy() != tt.wantH {
+			t.Errorf("%dx%d: expected a %dx%d image, got %v", tt.w, tt.h, tt.wantW, tt.wantH, img.Bounds())
+		}
+	}
+
+	key = barcode.RegisterCode128(pdf, "render")
+	img, err := barcode.Render(key, 400, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 400 || img.Bounds().Dy() != 50 {
+		t.Errorf("expected a 400x50 image, got %v", img.Bounds())
+	}
+	if r, _, _, _ := img.At(200, 25).RGBA(); r != 0 && r != 0xffff {
+		t.Errorf("expected black or white modules, got red %d", r)
+	}
+
+	if _, err := barcode.Render("unknown", 100, 100); err != barcode.ErrBarcodeNotFound {
+		t.Errorf("expected ErrBarcodeNotFound, got %v", err)
+	}
+	if _, err := barcode.Render(key, -1, 50); !errors.Is(err, barcode.ErrInvalidSize) {
+		t.Errorf("expected ErrInvalidSize, got %v", err)
+	}
+	if _, err := barcode.Render(key, 10, 50); err == nil {
+		t.Error("expected an error for a size smaller than the barcode")
+	}
+}
+
+// TestLastScale ensures that the pixel size of the image of the last placed
+// barcode is recorded as the resolution and the size of the barcode demand.
+func TestLastScale(t *testing.T) {
+	defer barcode.SetDPI(0)
+	barcode.SetDPI(300)
+
+	pdf := createImagePdf()
+	key := barcode.RegisterCode128(pdf, "last scale")
+	if _, _, ok := barcode.LastScale(key); ok {
+		t.Error("expected no scale before the barcode is placed")
+	}
+	dx, _, _ := barcode.GetBarcodeDimensions(key)
+
+	for _, w := range []float64{100, 50} {
+		barcode.Barcode(pdf, key, 15, 15, w, 20, false)
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+		factor := int(w*pdf.GetConversionRatio()/72*300) / dx
+		wPx, hPx, ok := barcode.LastScale(key)
+		if !ok || wPx != dx*factor || hPx != factor {
+			t.Errorf("width %g: expected %dx%d pixels, got %dx%d (%v)", w, dx*factor, factor, wPx, hPx, ok)
+		}
+		if bounds := pdf.sizes[len(pdf.sizes)-1]; bounds.Wd != w {
+			t.Errorf("width %g: expected the barcode placed at its width, got %g", w, bounds.Wd)
+		}
+	}
+
+	key = barcode.RegisterQR(pdf, "last scale", qr.M, qr.Auto)
+	dx, dy, _ := barcode.GetBarcodeDimensions(key)
+	barcode.Barcode(pdf, key, 15, 50, 30, 30, false)
+	factor := int(30*pdf.GetConversionRatio()/72*300) / dx
+	if wPx, hPx, _ := barcode.LastScale(key); wPx != dx*factor || hPx != dy*factor {
+		t.Errorf("expected %dx%d pixels, got %dx%d", dx*factor, dy*factor, wPx, hPx)
+	}
+
+	// A barcode whose image is not put on the page has no scale.
+	failed := createImagePdf()
+	key = barcode.RegisterCode128(failed, "failed scale")
+	failed.SetError(errors.New("broken PDF"))
+	barcode.Barcode(failed, key, 15, 15, 100, 20, false)
+	if _, _, ok := barcode.LastScale(key); ok {
+		t.Error("expected no scale for a barcode that was not put on the page")
+	}
+}
+
+// softBarcode is a barcode whose dark modules are dark gray, like the
+// anti-aliased edges of bars.
+type softBarcode struct {
+	bc.Barcode
+}
+
+func (s softBarcode) At(x, y int) color.Color {
+	if r, _, _, _ := s.Barcode.At(x, y).RGBA(); r < 0x8000 {
+		return color.Gray{Y: 0x40}
+	}
+	return color.White
+}
+
+// TestSetSmoothing ensures that barcodes scaled without smoothing consist of
+// black and white pixels only, while they keep the colors of the encoder
+// otherwise.
+func TestSetSmoothing(t *testing.T) {
+	defer barcode.SetSmoothing(true)
+
+	bcode, err := code128.Encode("smooth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := barcode.Register(softBarcode{bcode})
+	dx, _, _ := barcode.GetBarcodeDimensions(key)
+
+	edges := map[bool]color.Color{}
+	for _, smoothing := range []bool{true, false} {
+		barcode.SetSmoothing(smoothing)
+		img, err := barcode.Render(key, 3*dx, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Code 128 barcodes start with a bar.
+		edges[smoothing] = color.GrayModel.Convert(img.At(0, 10))
+	}
+
+	if edges[true] != (color.Gray{Y: 0x40}) {
+		t.Errorf("expected the gray edge of the encoder with smoothing, got %v", edges[true])
+	}
+	if edges[false] != (color.Gray{Y: 0}) {
+		t.Errorf("expected a black edge without smoothing, got %v", edges[false])
+	}
+}
+
+// TestEncodeSVG ensures that a rectangle is written per bar of a
+// one-dimensional barcode and per dark module of a two-dimensional one, on a
+// background rectangle.
+func TestEncodeSVG(t *testing.T) {
+	linear, err := code128.Encode("svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bars := 0
+	for x := 0; x < linear.Bounds().Dx(); x++ {
+		if isDark(linear.At(x, 0)) && (x == 0 || !isDark(linear.At(x-1, 0))) {
+			bars++
+		}
+	}
+
+	matrix, err := qr.Encode("svg", qr.M, qr.Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules := 0
+	for y := 0; y < matrix.Bounds().Dy(); y++ {
+		for x := 0; x < matrix.Bounds().Dx(); x++ {
+			if isDark(matrix.At(x, y)) {
+				modules++
+			}
+		}
+	}
+
+	tests := []struct {
+		bcode bc.Barcode
+		rects int
+	}{
+		{linear, bars + 1},
+		{matrix, modules + 1},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := barcode.EncodeSVG(&buf, barcode.Register(tt.bcode), 60, 20); err != nil {
+			t.Fatal(err)
+		}
+		svg := buf.String()
+		if !strings.HasPrefix(svg, "<svg ") || !strings.Contains(svg, `width="60pt" height="20pt"`) {
+			t.Errorf("expected an SVG document of 60 x 20, got %.100s", svg)
+		}
+		if n := strings.Count(svg, "<rect "); n != tt.rects {
+			t.Errorf("expected %d rectangles for %s, got %d", tt.rects, tt.bcode.Metadata().CodeKind, n)
+		}
+	}
+
+	if err := barcode.EncodeSVG(ioutil.Discard, "missing", 60, 20); !errors.Is(err, barcode.ErrBarcodeNotFound) {
+		t.Errorf("expected ErrBarcodeNotFound, got %v", err)
+	}
+}
+
+// isDark reports whether c is closer to black than to white.
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+// TestRenderWithCaption ensures that the caption is drawn beneath the barcode
+// in an image that is higher by the caption height.
+func TestRenderWithCaption(t *testing.T) {
+	pdf := createPdf()
+	key := barcode.RegisterCode128(pdf, "caption")
+
+	img, err := barcode.RenderWithCaption(key, 400, 50, 20, basicfont.Face7x13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 400 || img.Bounds().Dy() != 50+20 {
+		t.Fatalf("expected a 400x70 image, got %v", img.Bounds())
+	}
+
+	dark := 0
+	for y := 50; y < 70; y++ {
+		for x := 0; x < 400; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r < 0x8000 {
+				dark++
+			}
+		}
+	}
+	if dark == 0 {
+		t.Error("expected the caption to be drawn beneath the barcode")
+	}
+
+	if _, err := barcode.RenderWithCaption(key, 400, 50, -1, basicfont.Face7x13); !errors.Is(err, barcode.ErrInvalidSize) {
+		t.Errorf("expected ErrInvalidSize, got %v", err)
+	}
+	if _, err := barcode.RenderWithCaption(key, 400, 50, 20, nil); err == nil {
+		t.Error("expected an error without a font face")
+	}
+}
+
+// TestRegisterCode11 ensures that Code 11 barcodes get the requested check
+// digits and consist of the expected bars.
+func TestRegisterCode11(t *testing.T) {
+	tests := []struct {
+		checkDigits int
+		content     string
+		modules     int
+	}{
+		{0, "123-45", 62},
+		{1, "123-455", 70},
+		{2, "123-4552", 78},
+	}
+
+	pdf := createPdf()
+	for _, tt := range tests {
+		key, err := barcode.RegisterCode11E(pdf, "123-45", tt.checkDigits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, content, _ := barcode.GetMetadata(key); content != tt.content {
+			t.Errorf("expected content %q for %d check digits, got %q", tt.content, tt.checkDigits, content)
+		}
+		if w, _, _ := barcode.GetBarcodeDimensions(key); w != tt.modules {
+			t.Errorf("expected %d modules for %d check digits, got %d", tt.modules, tt.checkDigits, w)
+		}
+	}
+
+	// A C check value of 10 is encoded as a dash
+	key, _ := barcode.RegisterCode11E(pdf, "26", 1)
+	if _, content, _ := barcode.GetMetadata(key); content != "26-" {
+		t.Errorf("expected content %q, got %q", "26-", content)
+	}
+
+	// The digit 0 is encoded as narrow elements with a wide bar last, between
+	// the start and stop characters.
+	rects := &rectPdf{imagePdf: createImagePdf()}
+	key = barcode.RegisterCode11(rects, "0", 0)
+	barcode.BarcodeVector(rects, key, 0, 0, 22, 10, false)
+	var widths []float64
+	for _, rect := range rects.rects {
+		widths = append(widths, math.Round(rect.Wd))
+	}
+	if want := []float64{1, 2, 1, 1, 1, 2, 1, 2, 1}; !reflect.DeepEqual(widths, want) {
+		t.Errorf("expected bars %v, got %v", want, widths)
+	}
+
+	if _, err := barcode.RegisterCode11E(pdf, "123", 3); err == nil {
+		t.Error("expected an error for 3 check digits")
+	}
+}
+
+// TestBarcodeCell ensures that barcodes are fitted into their cells and that
+// the current position advances like it does for text cells.
+func TestBarcodeCell(t *testing.T) {
+	pdf := createImagePdf()
+	pdf.SetCellMargin(1)
+	pdf.SetXY(10, 10)
+
+	key := barcode.RegisterQR(pdf, "cell", qr.M, qr.Auto)
+	barcode.BarcodeCell(pdf, key, 40, 20, "1", 0, "CM")
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if x, y := pdf.GetXY(); x != 50 || y != 10 {
+		t.Errorf("expected the position to advance to 50,10, got %g,%g", x, y)
+	}
+	if size := pdf.sizes[0]; size.Wd != 18 || size.Ht != 18 {
+		t.Errorf("expected an 18x18 QR code, got %gx%g", size.Wd, size.Ht)
+	}
+
+	key = barcode.RegisterCode128(pdf, "cell")
+	barcode.BarcodeCell(pdf, key, 60, 20, "", 1, "")
+	if size := pdf.sizes[1]; size.Wd != 58 || size.Ht != 18 {
+		t.Errorf("expected a 58x18 Code 128 barcode, got %gx%g", size.Wd, size.Ht)
+	}
+	if y := pdf.GetY(); y != 30 {
+		t.Errorf("expected the position to move to the next line at 30, got %g", y)
+	}
+}
+
+// TestRegisterIntelligentMail ensures that Intelligent Mail barcodes match the
+// sample encodings of USPS-B-3200 and that invalid fields are rejected.
+func TestRegisterIntelligentMail(t *testing.T) {
+	tests := []struct {
+		routing, bars string
+	}{
+		{"", "ATTFATTDTTADTAATTDTDTATTDAFDDFADFDFTFFFFFTATFAAAATDFFTDAADFTFDTDT"},
+		{"01234", "DTTAFADDTTFTDTFTFDTDDADADAFADFATDDFTAAAFDTTADFAAATDFDTDFADDDTDFFT"},
+		{"012345678", "ADFTTAFDTTTTFATTADTAAATFTFTATDAAAFDDADATATDTDTTDFDTDATADADTDFFTFA"},
+		{"01234567891", "AADTFFDFTDADTAADAATFDTDDAAADDTDTTDAFADADDDTFFFDDTTTADFAAADFTDAADA"},
+	}
+
+	pdf := createPdf()
+	for _, tt := range tests {
+		key, err := barcode.RegisterIntelligentMailE(pdf, "01234567094987654321", tt.routing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bcode, _ := barcode.Get(key)
+		if bars := fmt.Sprint(bcode); bars != tt.bars {
+			t.Errorf("routing %q: expected\n%s, got\n%s", tt.routing, tt.bars, bars)
+		}
+	}
+
+	invalid := [][2]string{
+		{"0123456709498765432", ""},
+		{"01234567094987654321", "0123"},
+		{"05234567094987654321", ""},
+		{"0123456709498765432X", ""},
+	}
+	for _, fields := range invalid {
+		if _, err := barcode.RegisterIntelligentMailE(pdf, fields[0], fields[1]); err == nil {
+			t.Errorf("expected an error for tracking %q and routing %q", fields[0], fields[1])
+		}
+	}
+}
+
+// TestRegisterWithOptions ensures that barcodes registered with different
+// options are rendered with their own settings on the same page, that
+// BarcodeWithOptions() overrides them and that the package-wide settings
+// apply to barcodes without options.
+func TestRegisterWithOptions(t *testing.T) {
+	bcode, err := code128.Encode("options")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	navy := color.NRGBA{R: 0x00, G: 0x00, B: 0x80, A: 0xff}
+	pdf := createImagePdf()
+	jpgKey := barcode.RegisterWithOptions(pdf, bcode, barcode.BarcodeOptions{Format: "jpg", JPEGQuality: 95, DPI: 300})
+	navyKey := barcode.RegisterWithOptions(pdf, bcode, barcode.BarcodeOptions{
+		Foreground:    navy,
+		QuietZone:     10,
+		Rotation:      90,
+		Caption:       "options",
+		CaptionHeight: 5,
+	})
+	plainKey := barcode.Register(bcode)
+	if jpgKey == navyKey || jpgKey == plainKey || navyKey == plainKey {
+		t.Fatalf("expected distinct keys, got %q, %q and %q", jpgKey, navyKey, plainKey)
+	}
+
+	barcode.Barcode(pdf, jpgKey, 15, 15, 100, 10, false)
+	barcode.Barcode(pdf, navyKey, 15, 30, 100, 20, false)
+	barcode.Barcode(pdf, plainKey, 40, 30, 100, 10, false)
+	barcode.BarcodeWithOptions(pdf, plainKey, 40, 45, 100, 10, false, barcode.BarcodeOptions{DPI: 300})
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pdf.images) != 4 {
+		t.Fatalf("expected 4 images, got %d", len(pdf.images))
+	}
+
+	jpgImg, err := jpeg.Decode(bytes.NewReader(pdf.images[0]))
+	if err != nil {
+		t.Fatalf("expected a JPEG image: %v", err)
+	}
+	navyImg, err := png.Decode(bytes.NewReader(pdf.images[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainImg, err := png.Decode(bytes.NewReader(pdf.images[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overridden, err := png.Decode(bytes.NewReader(pdf.images[3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jpgImg.Bounds().Dx() <= plainImg.Bounds().Dx() {
+		t.Errorf("expected the 300 DPI image to be wider than %d pixels, got %d", plainImg.Bounds().Dx(), jpgImg.Bounds().Dx())
+	}
+	if overridden.Bounds() != jpgImg.Bounds() {
+		t.Errorf("expected the overridden image to match the 300 DPI one, got %v and %v", overridden.Bounds(), jpgImg.Bounds())
+	}
+	if navyImg.Bounds().Dy() <= navyImg.Bounds().Dx() {
+		t.Errorf("expected a rotated image, got %v", navyImg.Bounds())
+	}
+	if size := pdf.sizes[1]; size.Wd != 15 || size.Ht != 100 {
+		t.Errorf("expected the rotated barcode above its caption to take 15 x 100, got %g x %g", size.Wd, size.Ht)
+	}
+
+	hasColor := func(img image.Image, want color.NRGBA) bool {
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if color.NRGBAModel.Convert(img.At(x, y)) == want {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if !hasColor(navyImg, navy) || !hasColor(plainImg, color.NRGBA{A: 0xff}) {
+		t.Error("expected navy bars for the options and black bars otherwise")
+	}
+
+	// With a height of zero the bars keep their aspect ratio and the caption
+	// is added beneath them.
+	natural, err := barcode.BarcodeRect(pdf, plainKey, 40, 60, 100, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := barcode.BarcodeWithOptionsE(pdf, plainKey, 40, 70, 100, 0, false, barcode.BarcodeOptions{Caption: "auto", CaptionHeight: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if size := pdf.sizes[len(pdf.sizes)-1]; size != natural {
+		t.Errorf("expected the barcode above a caption to take %g x %g, got %g x %g", natural.Wd, natural.Ht, size.Wd, size.Ht)
+	}
+	if err := barcode.BarcodeWithOptionsE(pdf, plainKey, 40, 80, 100, 5, false, barcode.BarcodeOptions{Caption: "tall", CaptionHeight: 5}); err == nil {
+		t.Error("expected an error for a caption as high as the barcode")
+	}
+
+	if _, err := barcode.RegisterWithOptionsE(pdf, bcode, barcode.BarcodeOptions{Rotation: 45}); err == nil {
+		t.Error("expected an error for an unsupported rotation")
+	}
+	if _, err := barcode.RegisterWithOptionsE(pdf, bcode, barcode.BarcodeOptions{Format: "gif"}); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+// TestRegisterCached ensures that registering a code again returns the key of
+// the barcode that has been registered before.
+func TestRegisterCached(t *testing.T) {
+	pdf := createPdf()
+	reg := barcode.New(pdf)
+	key := reg.RegisterCode128("cached")
+	first, _ := reg.Get(key)
+	if again := reg.RegisterCode128("cached"); again != key {
+		t.Fatalf("expected key %q, got %q", key, again)
+	}
+	if second, _ := reg.Get(key); second != first {
+		t.Error("expected the registered barcode to be reused")
+	}
+
+	// EAN codes are stored with their check digit, with which they must be
+	// found again whether or not it is given.
+	eanKey := reg.RegisterEAN("9638507")
+	eanFirst, _ := reg.Get(eanKey)
+	for _, code := range []string{"9638507", "96385074"} {
+		if again := reg.RegisterEAN(code); again != eanKey {
+			t.Errorf("%s: expected key %q, got %q", code, eanKey, again)
+		}
+		if again, _ := reg.Get(eanKey); again != eanFirst {
+			t.Errorf("%s: expected the registered barcode to be reused", code)
+		}
+	}
+
+	// Codes registered with other arguments are encoded again, and registering
+	// a code again restores its barcode after it was replaced or unregistered.
+	checked := reg.RegisterCode39("CACHED", true, false)
+	withChecksum, _ := reg.Get(checked)
+	unchecked := reg.RegisterCode39("CACHED", false, false)
+	withoutChecksum, _ := reg.Get(unchecked)
+	if withoutChecksum.Bounds().Dx() >= withChecksum.Bounds().Dx() {
+		t.Errorf("expected the code without checksum to be narrower, got %d and %d modules",
+			withoutChecksum.Bounds().Dx(), withChecksum.Bounds().Dx())
+	}
+	if again, _ := reg.Get(reg.RegisterCode39("CACHED", true, false)); again != withChecksum {
+		t.Error("expected the barcode with checksum to be registered again")
+	}
+	reg.Unregister(checked)
+	if again, ok := reg.Get(reg.RegisterCode39("CACHED", true, false)); !ok || again == withChecksum {
+		t.Error("expected the unregistered barcode to be encoded again")
+	}
+
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkRegisterCode128 compares registering the same code repeatedly,
+// which reuses the registered barcode, with registering distinct codes, each
+// of which is encoded.
+func BenchmarkRegisterCode128(b *testing.B) {
+	b.Run("Repeated", func(b *testing.B) {
+		reg := barcode.New(createPdf())
+		for i := 0; i < b.N; i++ {
+			reg.RegisterCode128("gofpdf-0123456789")
+		}
+	})
+	b.Run("Distinct", func(b *testing.B) {
+		reg := barcode.New(createPdf())
+		for i := 0; i < b.N; i++ {
+			reg.RegisterCode128("gofpdf-" + strconv.Itoa(i))
+		}
+	})
+}
+
+// BenchmarkRegisterEAN compares registering the same EAN-13 code without check
+// digit repeatedly, which reuses the registered barcode, with registering
+// distinct codes, each of which is encoded.
+func BenchmarkRegisterEAN(b *testing.B) {
+	b.Run("Repeated", func(b *testing.B) {
+		reg := barcode.New(createPdf())
+		for i := 0; i < b.N; i++ {
+			reg.RegisterEAN("400638133393")
+		}
+	})
+	b.Run("Distinct", func(b *testing.B) {
+		reg := barcode.New(createPdf())
+		for i := 0; i < b.N; i++ {
+			reg.RegisterEAN(fmt.Sprintf("4006%08d", i%100000000))
+		}
+	})
+}
+
+// BenchmarkRegisterMany compares the serial and concurrent registration of
+// distinct Data Matrix barcodes.
+func BenchmarkRegisterMany(b *testing.B) {
+	codes := make([]string, 200)
+	for i := range codes {
+		codes[i] = "https://example.com/labels/" + strconv.Itoa(i)
+	}
+	b.Run("Serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			reg := barcode.New(createPdf())
+			for _, code := range codes {
+				if _, err := reg.RegisterDataMatrixE(code); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("Parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			reg := barcode.New(createPdf())
+			if _, err := reg.RegisterMany(bc.TypeDataMatrix, codes); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// dataBarElements returns the widths of the alternating spaces and bars of a
+// DataBar barcode, which starts with a space.
+func dataBarElements(bcode bc.Barcode) []int {
+	elements := []int{0}
+	dark := false
+	for x := 0; x < bcode.Bounds().Dx(); x++ {
+		if r, _, _, _ := bcode.At(x, 0).RGBA(); (r == 0) != dark {
+			elements = append(elements, 0)
+			dark = !dark
+		}
+		elements[len(elements)-1]++
+	}
+
+	return elements
+}
+
+// moduleString returns the modules of the first row of a barcode, with 1 for
+// a bar and 0 for a space.
+func moduleString(bcode bc.Barcode) string {
+	var modules strings.Builder
+	for x := 0; x < bcode.Bounds().Dx(); x++ {
+		if r, _, _, _ := bcode.At(x, 0).RGBA(); r == 0 {
+			modules.WriteByte('1')
+		} else {
+			modules.WriteByte('0')
+		}
+	}
+
+	return modules.String()
+}
+
+// sum returns the sum of the given widths.
+func sum(widths []int) int {
+	n := 0
+	for _, w := range widths {
+		n += w
+	}
+
+	return n
+}
+
+// TestRegisterDataBarLimited ensures that DataBar Limited barcodes consist of
+// the guards and the data and check characters of ISO/IEC 24724 and that only
+// GTINs starting with 0 or 1 are accepted.
+func TestRegisterDataBarLimited(t *testing.T) {
+	pdf := createPdf()
+	key, err := barcode.RegisterDataBarLimitedE(pdf, "15012345678907")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, content, _ := barcode.GetMetadata(key); content != "(01)15012345678907" {
+		t.Errorf("expected content (01)15012345678907, got %q", content)
+	}
+
+	bcode, _ := barcode.Get(key)
+	elements := dataBarElements(bcode)
+	if len(elements) != 46 || sum(elements) != 74 {
+		t.Fatalf("expected 46 elements of 74 modules, got %d of %d", len(elements), sum(elements))
+	}
+	if guards := fmt.Sprint(elements[:2], elements[44:]); guards != "[1 1] [1 1]" {
+		t.Errorf("expected guards of one module, got %s", guards)
+	}
+	// Left data character, check character and right data character.
+	for i, want := range []int{26, 18, 26} {
+		if n := sum(elements[2+14*i : 16+14*i]); n != want {
+			t.Errorf("expected character %d to be %d modules wide, got %d", i, want, n)
+		}
+	}
+
+	// The symbol of the GTIN of the DataBar Limited example of ISO/IEC 24724,
+	// with the left character value 745558 and the right character value
+	// 600272, which give the checksum 74.
+	want := "01000110011000110110101001110101101001011001010010010110001101110011001101"
+	if got := moduleString(bcode); got != want {
+		t.Errorf("unexpected modules for (01)15012345678907:\ngot  %s\nwant %s", got, want)
+	}
+
+	for _, gtin := range []string{"25012345678904", "15012345678906", "1501234567890", "1501234567890A"} {
+		if _, err := barcode.RegisterDataBarLimitedE(pdf, gtin); err == nil {
+			t.Errorf("expected an error for %q", gtin)
+		}
+	}
+}
+
+// TestRegisterDataBarExpanded ensures that DataBar Expanded barcodes consist
+// of pairs of symbol characters around the finder patterns of ISO/IEC 24724
+// and that invalid or too long data is rejected.
+func TestRegisterDataBarExpanded(t *testing.T) {
+	pdf := createPdf()
+	key, err := barcode.RegisterDataBarExpandedE(pdf, map[string]string{
+		"3202": "012345",
+		"15":   "991231",
+		"01":   "98898765432106",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, content, _ := barcode.GetMetadata(key); content != "(01)98898765432106(15)991231(3202)012345" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	// The GTIN takes 4, the other fields 5 and the check character 1 of
+	// the 11 symbol characters, which are arranged in 6 pairs.
+	bcode, _ := barcode.Get(key)
+	elements := dataBarElements(bcode)
+	if n := len(elements); n != 2+5*21+13+2 {
+		t.Fatalf("expected 11 symbol characters and 6 finder patterns, got %d elements", n)
+	}
+	finders := []string{"[1 8 4 1 1]", "[1 1 5 6 2]", "[3 6 4 1 1]", "[1 1 8 2 3]", "[3 2 8 1 1]", "[1 1 9 2 2]"}
+	for i, want := range finders {
+		pair := elements[2+21*i:]
+		if finder := fmt.Sprint(pair[8:13]); finder != want {
+			t.Errorf("expected finder pattern %s in pair %d, got %s", want, i, finder)
+		}
+		if n := sum(pair[:8]); n != 17 {
+			t.Errorf("expected symbol character of 17 modules in pair %d, got %d", i, n)
+		}
+	}
+
+	// A single field without GTIN is padded to the minimum of four symbol
+	// characters.
+	key, err = barcode.RegisterDataBarExpandedE(pdf, map[string]string{"10": "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _, _ := barcode.GetBarcodeDimensions(key); w != 2+2*49+2 {
+		t.Errorf("expected two pairs of symbol characters, got %d modules", w)
+	}
+
+	// The GTIN of the DataBar Expanded example of ISO/IEC 24724 with a batch
+	// number, which is encoded with encodation method 1 and general purpose
+	// data in numeric and alphanumeric mode, in 8 data characters with the
+	// values 1655, 2523, 543, 842, 609, 67, 280 and 3714.
+	key, err = barcode.RegisterDataBarExpandedE(pdf, map[string]string{"01": "98898765432106", "10": "ABC123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcode, _ = barcode.Get(key)
+	want := "0100001011100011001011111111000010110011110001100101110000101011100010111110000001101110111000111001001100110000011010001111110000101110001100100001010011101111110110101111111100111001011011111101110011011100101111100011110000001010"
+	if got := moduleString(bcode); got != want {
+		t.Errorf("unexpected modules for (01)98898765432106(10)ABC123:\ngot  %s\nwant %s", got, want)
+	}
+
+	invalid := []map[string]string{
+		nil,
+		{"01": "98898765432107"},
+		{"10": "ä"},
+		{"AB": "1"},
+		{"91": strings.Repeat("A", 42)},
+	}
+	for _, ais := range invalid {
+		if _, err := barcode.RegisterDataBarExpandedE(pdf, ais); err == nil {
+			t.Errorf("expected an error for %v", ais)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/boombuler/barcode"
+)
+
+// BatchError is returned by the batch registration functions for the first
+// code that could not be registered.
+type BatchError struct {
+	Index int   // Index of the code in the batch
+	Err   error // Error of the code
+}
+
+// Error returns the error message including the index of the code.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("barcode %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the code.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// RegisterCode128Batch registers a barcode of type Code128 for each of the
+// given codes. See the package-level RegisterCode128Batch() for details.
+func (r *Registry) RegisterCode128Batch(codes []string) ([]string, error) {
+	return r.registerBatch(codes, r.RegisterCode128E)
+}
+
+// RegisterMany registers a barcode of the given kind for each of the given
+// codes concurrently. See the package-level RegisterMany() for details.
+func (r *Registry) RegisterMany(kind string, codes []string) ([]string, error) {
+	register, ok := r.manyRegisterers()[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown barcode kind %q for RegisterMany", kind)
+	}
+
+	return r.registerParallel(codes, register)
+}
+
+// manyRegisterers returns the register functions of the barcode kinds that
+// RegisterMany() supports.
+func (r *Registry) manyRegisterers() map[string]func(code string) (string, error) {
+	return map[string]func(code string) (string, error){
+		barcode.TypeCodabar:         r.RegisterCodabarE,
+		barcode.TypeCode128:         r.RegisterCode128E,
+		barcode.TypeCode39:          func(code string) (string, error) { return r.RegisterCode39E(code, false, false) },
+		TypeCode39FullASCII:         func(code string) (string, error) { return r.RegisterCode39E(code, false, true) },
+		barcode.TypeCode93:          func(code string) (string, error) { return r.RegisterCode93E(code, true, false) },
+		TypeCode93FullASCII:         func(code string) (string, error) { return r.RegisterCode93E(code, true, true) },
+		barcode.TypeDataMatrix:      r.RegisterDataMatrixE,
+		barcode.TypeEAN8:            r.registerEANKind(barcode.TypeEAN8),
+		barcode.TypeEAN13:           r.registerEANKind(barcode.TypeEAN13),
+		barcode.Type2of5:            func(code string) (string, error) { return r.RegisterTwoOfFiveE(code, false) },
+		barcode.Type2of5Interleaved: func(code string) (string, error) { return r.RegisterTwoOfFiveE(code, true) },
+		TypeDataBarLimited:          r.RegisterDataBarLimitedE,
+		TypeITF14:                   r.RegisterITF14E,
+		TypeUPCA:                    r.RegisterUPCAE,
+		TypeUPCE:                    r.RegisterUPCEE,
+	}
+}
+
+// registerEANKind returns a function that registers EAN barcodes of the given
+// kind, which RegisterEANE() would otherwise derive from the length of the
+// code.
+func (r *Registry) registerEANKind(kind string) func(code string) (string, error) {
+	return func(code string) (string, error) {
+		if err := Validate(kind, code); err != nil {
+			return "", err
+		}
+
+		return r.RegisterEANE(code)
+	}
+}
+
+// registerParallel works like registerBatch() but registers the codes with a
+// worker per CPU.
+func (r *Registry) registerParallel(codes []string, register func(code string) (string, error)) ([]string, error) {
+	keys := make([]string, len(codes))
+	errs := make([]error, len(codes))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				keys[i], errs[i] = register(codes[i])
+			}
+		}()
+	}
+	for i := range codes {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return keys, &BatchError{Index: i, Err: err}
+		}
+	}
+
+	return keys, nil
+}
+
+// registerBatch registers each of the given codes with register and returns
+// their keys and the first error as a *BatchError.
+func (r *Registry) registerBatch(codes []string, register func(code string) (string, error)) ([]string, error) {
+	keys := make([]string, len(codes))
+	var batchErr error
+
+	for i, code := range codes {
+		key, err := register(code)
+		if err != nil {
+			if batchErr == nil {
+				batchErr = &BatchError{Index: i, Err: err}
+			}
+			continue
+		}
+		keys[i] = key
+	}
+
+	return keys, batchErr
+}
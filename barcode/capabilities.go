@@ -0,0 +1,88 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"github.com/boombuler/barcode"
+)
+
+// digitChars holds the characters of numeric barcodes.
+const digitChars = "0123456789"
+
+// SymbologyInfo describes the data a barcode kind can encode.
+type SymbologyInfo struct {
+	// Kind is the kind of the barcode, as passed to Capabilities().
+	Kind string
+	// Dimensions is 1 for linear and 2 for two-dimensional barcodes.
+	Dimensions int
+	// Charset holds the characters the data may consist of. It is empty if
+	// the data isn't restricted to a small set of characters: Code 128 and
+	// the full ASCII modes of Code 39 and Code 93 encode any ASCII
+	// character, two-dimensional barcodes any text.
+	Charset string
+	// Numeric reports whether the data consists of digits only.
+	Numeric bool
+	// MinLength and MaxLength are the minimum and maximum number of
+	// characters of the data. MaxLength is 0 if the length is not limited by
+	// the symbology; the capacity of two-dimensional barcodes depends on the
+	// data and the error correction level and isn't given.
+	MinLength, MaxLength int
+	// CheckDigit reports whether the barcode has a check digit, either as
+	// part of the data or calculated when it is encoded.
+	CheckDigit bool
+}
+
+// symbologies holds the capabilities of the barcode kinds accepted by
+// Validate().
+var symbologies = map[string]SymbologyInfo{
+	barcode.TypeAztec:           {Dimensions: 2, MinLength: 1},
+	barcode.TypeCodabar:         {Dimensions: 1, Charset: codabarChars + "ABCD", MinLength: 2},
+	barcode.TypeCode128:         {Dimensions: 1, MinLength: 1, CheckDigit: true},
+	barcode.TypeCode39:          {Dimensions: 1, Charset: code39Chars, MinLength: 1, CheckDigit: true},
+	TypeCode39FullASCII:         {Dimensions: 1, MinLength: 1, CheckDigit: true},
+	barcode.TypeCode93:          {Dimensions: 1, Charset: code39Chars, MinLength: 1, CheckDigit: true},
+	TypeCode93FullASCII:         {Dimensions: 1, MinLength: 1, CheckDigit: true},
+	barcode.TypeDataMatrix:      {Dimensions: 2, MinLength: 1},
+	barcode.TypeEAN8:            {Dimensions: 1, Charset: digitChars, MinLength: 7, MaxLength: 8, CheckDigit: true},
+	barcode.TypeEAN13:           {Dimensions: 1, Charset: digitChars, MinLength: 12, MaxLength: 13, CheckDigit: true},
+	barcode.TypePDF:             {Dimensions: 2, MinLength: 1},
+	barcode.TypeQR:              {Dimensions: 2, MinLength: 1},
+	barcode.Type2of5:            {Dimensions: 1, Charset: digitChars, MinLength: 1},
+	barcode.Type2of5Interleaved: {Dimensions: 1, Charset: digitChars, MinLength: 2},
+	TypeCode11:                  {Dimensions: 1, Charset: code11Chars, MinLength: 1, CheckDigit: true},
+	TypeDataBarLimited:          {Dimensions: 1, Charset: digitChars, MinLength: 14, MaxLength: 14, CheckDigit: true},
+	TypeIntelligentMail:         {Dimensions: 1, Charset: digitChars, MinLength: 20, MaxLength: 31},
+	TypeITF14:                   {Dimensions: 1, Charset: digitChars, MinLength: 14, MaxLength: 14, CheckDigit: true},
+	TypeMSI:                     {Dimensions: 1, Charset: digitChars, MinLength: 1, CheckDigit: true},
+	TypePharmacode:              {Dimensions: 1, Charset: digitChars, MinLength: 1, MaxLength: 6},
+	TypeUPCA:                    {Dimensions: 1, Charset: digitChars, MinLength: 12, MaxLength: 12, CheckDigit: true},
+	TypeUPCE:                    {Dimensions: 1, Charset: digitChars, MinLength: 8, MaxLength: 8, CheckDigit: true},
+}
+
+// Capabilities returns a description of the data that barcodes of the given
+// kind can encode, so that callers can validate input or pick a suitable
+// kind. kind is one of the kinds accepted by Validate(); ok is false for
+// other kinds.
+func Capabilities(kind string) (info SymbologyInfo, ok bool) {
+	info, ok = symbologies[kind]
+	if !ok {
+		return SymbologyInfo{}, false
+	}
+
+	info.Kind = kind
+	info.Numeric = info.Charset == digitChars
+
+	return info, true
+}
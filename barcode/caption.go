@@ -0,0 +1,69 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// RenderWithCaption returns the image of the barcode of this registry
+// associated with the given key, with its content printed beneath it. See the
+// package-level RenderWithCaption() for details.
+func (r *Registry) RenderWithCaption(key string, widthPx, heightPx, captionPx int, face font.Face) (image.Image, error) {
+	if captionPx < 0 {
+		return nil, fmt.Errorf("%w: caption height of %d pixels must not be negative", ErrInvalidSize, captionPx)
+	}
+	if face == nil {
+		return nil, errors.New("font face for the caption must not be nil")
+	}
+
+	bar, err := r.Render(key, widthPx, heightPx)
+	if err != nil {
+		return nil, err
+	}
+	registered, _ := r.lookup(key)
+	opts := r.options(key)
+
+	bounds := bar.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()+captionPx))
+	var bg color.Color = color.Transparent
+	if !opts.transparent || imageType(opts.format) != "png" {
+		bg = opts.bg
+	}
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, bounds.Dx(), bounds.Dy()), bar, bounds.Min, draw.Src)
+
+	// The text is centered horizontally and its line vertically in the
+	// caption.
+	text := registered.Content()
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(opts.fg), Face: face}
+	metrics := face.Metrics()
+	textW := drawer.MeasureString(text)
+	lineH := metrics.Ascent + metrics.Descent
+	drawer.Dot = fixed.Point26_6{
+		X: (fixed.I(bounds.Dx()) - textW) / 2,
+		Y: fixed.I(bounds.Dy()) + (fixed.I(captionPx)-lineH)/2 + metrics.Ascent,
+	}
+	drawer.DrawString(text)
+
+	return img, nil
+}
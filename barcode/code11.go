@@ -0,0 +1,105 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/utils"
+)
+
+// TypeCode11 is the code kind of Code 11 barcodes.
+const TypeCode11 = "Code 11"
+
+// code11Chars holds the characters of Code 11 barcodes in the order of their
+// values.
+const code11Chars = "0123456789-"
+
+// code11Widths holds the widths in modules of the bars and spaces of the
+// characters of code11Chars, including the narrow space that separates them
+// from the next character. Wide elements are twice as wide as narrow ones.
+var code11Widths = [11]string{
+	"111121", "211121", "121121", "221111", "112121",
+	"212111", "122111", "111221", "211211", "211111",
+	"112111",
+}
+
+// code11Guard holds the widths of the start and stop character.
+const code11Guard = "11221"
+
+// encodeCode11 returns a Code 11 barcode for the given code with checkDigits
+// check digits appended: none, C, or C and K.
+func encodeCode11(code string, checkDigits int) (barcode.Barcode, error) {
+	if err := Validate(TypeCode11, code); err != nil {
+		return nil, err
+	}
+	if checkDigits < 0 || checkDigits > 2 {
+		return nil, fmt.Errorf("Code 11 supports 0, 1 or 2 check digits, got %d", checkDigits)
+	}
+
+	if checkDigits > 0 {
+		code += code11CheckDigit(code, 10)
+	}
+	if checkDigits > 1 {
+		code += code11CheckDigit(code, 9)
+	}
+
+	bars := new(utils.BitList)
+	addWidths(bars, code11Guard+"1")
+	for _, r := range code {
+		addWidths(bars, code11Widths[strings.IndexRune(code11Chars, r)])
+	}
+	addWidths(bars, code11Guard)
+
+	return utils.New1DCode(TypeCode11, code, bars), nil
+}
+
+// code11CheckDigit returns the check digit of code, whose characters are
+// weighted from the right with 1 to maxWeight. The C check digit uses a
+// maximum weight of 10 and the K check digit one of 9.
+func code11CheckDigit(code string, maxWeight int) string {
+	sum := 0
+	for i := range code {
+		sum += strings.IndexByte(code11Chars, code[len(code)-1-i]) * (i%maxWeight + 1)
+	}
+
+	return string(code11Chars[sum%11])
+}
+
+// addWidths appends alternating bars and spaces, starting with a bar, of the
+// given widths in modules to bars.
+func addWidths(bars *utils.BitList, widths string) {
+	for i, r := range widths {
+		for j := 0; j < int(r-'0'); j++ {
+			bars.AddBit(i%2 == 0)
+		}
+	}
+}
+
+// validateCode11 validates that code consists of digits and dashes.
+func validateCode11(code string) error {
+	if code == "" {
+		return fmt.Errorf("Code 11 code must not be empty")
+	}
+	for i, r := range code {
+		if !strings.ContainsRune(code11Chars, r) {
+			return fmt.Errorf("Code 11 code contains invalid character %q at position %d", r, i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/boombuler/barcode"
+)
+
+// defaultQRContrast is the minimum contrast ratio between the foreground and
+// background colors of QR codes unless it is changed with SetQRContrast(). It
+// is the ratio that the Web Content Accessibility Guidelines require for
+// text, which leaves a margin for printing and for cameras in poor light.
+const defaultQRContrast = 4.5
+
+// checkContrast returns ErrLowContrast if bcode is a QR code whose colors have
+// a lower contrast ratio than opts require. The colors of transparent
+// backgrounds are unknown and not checked.
+func checkContrast(bcode barcode.Barcode, opts options) error {
+	if bcode.Metadata().CodeKind != barcode.TypeQR || opts.minQRContrast <= 0 || opts.transparent {
+		return nil
+	}
+
+	ratio := contrastRatio(opts.fg, opts.bg)
+	if ratio < opts.minQRContrast {
+		return fmt.Errorf("%w: contrast ratio %.2f of the QR code colors is below %.2f", ErrLowContrast, ratio, opts.minQRContrast)
+	}
+
+	return nil
+}
+
+// contrastRatio returns the contrast ratio of the colors a and b as defined by
+// the Web Content Accessibility Guidelines, from 1 for equal colors to 21 for
+// black and white.
+func contrastRatio(a, b color.Color) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// relativeLuminance returns the relative luminance of the sRGB color c, from 0
+// for black to 1 for white. Transparency is ignored.
+func relativeLuminance(c color.Color) float64 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	linear := func(v uint8) float64 {
+		s := float64(v) / 0xff
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+
+	return 0.2126*linear(n.R) + 0.7152*linear(n.G) + 0.0722*linear(n.B)
+}
@@ -0,0 +1,507 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/utils"
+)
+
+// TypeDataBarExpanded and TypeDataBarLimited are the code kinds of GS1
+// DataBar Expanded and GS1 DataBar Limited barcodes.
+const (
+	TypeDataBarExpanded = "GS1 DataBar Expanded"
+	TypeDataBarLimited  = "GS1 DataBar Limited"
+)
+
+// dataBarGroup describes a group of DataBar character values: the first value
+// of the group, the number of modules and the widest element of the odd and
+// even elements, and the number of combinations of the even elements.
+type dataBarGroup struct {
+	start                   int
+	oddModules, evenModules int
+	oddWidest, evenWidest   int
+	evenCombinations        int
+}
+
+// dataBarLimitedGroups and dataBarExpandedGroups hold the character groups of
+// DataBar Limited and DataBar Expanded. See ISO/IEC 24724, tables 6 and 13.
+var (
+	dataBarLimitedGroups = []dataBarGroup{
+		{0, 17, 9, 6, 3, 28},
+		{183064, 13, 13, 5, 4, 728},
+		{820064, 9, 17, 3, 6, 6454},
+		{1000776, 15, 11, 5, 4, 203},
+		{1491021, 11, 15, 4, 5, 2408},
+		{1979845, 19, 7, 8, 1, 1},
+		{1996939, 7, 19, 1, 8, 16632},
+	}
+	dataBarExpandedGroups = []dataBarGroup{
+		{0, 12, 5, 7, 2, 4},
+		{348, 10, 7, 5, 4, 20},
+		{1388, 8, 9, 4, 5, 52},
+		{2948, 6, 11, 3, 6, 104},
+		{3988, 4, 13, 1, 8, 204},
+	}
+)
+
+// dataBarFinders holds the finder patterns A to F of DataBar Expanded.
+var dataBarFinders = [6][5]int{
+	{1, 8, 4, 1, 1},
+	{3, 6, 4, 1, 1},
+	{3, 4, 6, 1, 1},
+	{3, 2, 8, 1, 1},
+	{2, 6, 5, 1, 1},
+	{2, 2, 9, 1, 1},
+}
+
+// dataBarFinderSequences holds the finder patterns of DataBar Expanded
+// symbols with 2 to 11 finder patterns. A 1 after the finder pattern means it
+// is printed as is and a 2 that it is reversed.
+var dataBarFinderSequences = []string{
+	"A1A2",
+	"A1B2B1",
+	"A1C2B1D2",
+	"A1E2B1D2C1",
+	"A1E2B1D2D1F2",
+	"A1E2B1D2E1F2F1",
+	"A1A2B1B2C1C2D1D2",
+	"A1A2B1B2C1C2D1E2E1",
+	"A1A2B1B2C1C2D1E2F1F2",
+	"A1A2B1B2C1D2D1E2E1F2F1",
+}
+
+// dataBarSeparator stands for FNC1 in the general purpose data of DataBar
+// Expanded barcodes. It is not a valid character in GS1 fields.
+const dataBarSeparator = '\x1d'
+
+// dataBarISO646Specials holds the characters that are encoded with 8 bits in
+// the ISO/IEC 646 mode of DataBar Expanded, starting at value 232.
+const dataBarISO646Specials = "!\"%&'()*+,-./:;<=>?_ "
+
+// Encodation modes of the general purpose data of DataBar Expanded.
+const (
+	dataBarNumeric = iota
+	dataBarAlphanumeric
+	dataBarISO646
+)
+
+// encodeDataBarLimited returns a DataBar Limited barcode of the given GTIN.
+func encodeDataBarLimited(gtin string) (barcode.Barcode, error) {
+	if err := validateDataBarLimited(gtin); err != nil {
+		return nil, err
+	}
+
+	// The check digit is not encoded.
+	value, err := strconv.ParseInt(gtin[:13], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	left := dataBarCharacter(int(value/2013571), dataBarLimitedGroups, 7, true)
+	right := dataBarCharacter(int(value%2013571), dataBarLimitedGroups, 7, true)
+
+	checksum := 0
+	for i := range left {
+		checksum += left[i]*dataBarWeight(i, 89) + right[i]*dataBarWeight(i+14, 89)
+	}
+	checksum %= 89
+
+	check := make([]int, 14)
+	odd := dataBarWidths(checksum/21, 8, 6, 3, false)
+	even := dataBarWidths(checksum%21, 8, 6, 3, false)
+	for i := 0; i < 6; i++ {
+		check[2*i], check[2*i+1] = odd[i], even[i]
+	}
+	check[12], check[13] = 1, 1
+
+	elements := []int{1, 1}
+	elements = append(elements, left...)
+	elements = append(elements, check...)
+	elements = append(elements, right...)
+	elements = append(elements, 1, 1)
+
+	return utils.New1DCode(TypeDataBarLimited, "(01)"+gtin, dataBarModules(elements)), nil
+}
+
+// validateDataBarLimited validates that gtin consists of 14 digits with a
+// valid check digit and starts with 0 or 1, as DataBar Limited can't encode
+// larger values.
+func validateDataBarLimited(gtin string) error {
+	if len(gtin) != 14 || !isDigits(gtin) {
+		return fmt.Errorf("GS1 DataBar Limited code must consist of 14 digits, got %q", gtin)
+	}
+	if gtin[0] > '1' {
+		return fmt.Errorf("GS1 DataBar Limited code must start with 0 or 1, got %q", gtin)
+	}
+
+	return verifyGS1CheckDigit(gtin)
+}
+
+// encodeDataBarExpanded returns a DataBar Expanded barcode of the given
+// application identifiers and values.
+func encodeDataBarExpanded(ais map[string]string) (barcode.Barcode, error) {
+	if len(ais) == 0 {
+		return nil, errors.New("GS1 DataBar Expanded barcode requires at least one application identifier")
+	}
+
+	fixed, variable, err := sortGS1AIs(ais)
+	if err != nil {
+		return nil, err
+	}
+
+	// The GTIN is compressed by encodation method 1 if it comes first.
+	fields := append(fixed, variable...)
+	for i, ai := range fields {
+		if ai == "01" {
+			copy(fields[1:i+1], fields[:i])
+			fields[0] = ai
+			break
+		}
+	}
+
+	var data, text strings.Builder
+	for i, ai := range fields {
+		text.WriteString("(" + ai + ")" + ais[ai])
+		if ai == "01" {
+			continue
+		}
+		data.WriteString(ai + ais[ai])
+		if i >= len(fixed) && i < len(fields)-1 {
+			data.WriteByte(dataBarSeparator)
+		}
+	}
+
+	bits := new(utils.BitList)
+	bits.AddBit(false) // no composite component
+	if fields[0] == "01" {
+		gtin := ais["01"]
+		bits.AddBit(true)
+		bits.AddBits(int(gtin[0]-'0'), 4)
+		for i := 1; i < 13; i += 3 {
+			n, _ := strconv.Atoi(gtin[i : i+3])
+			bits.AddBits(n, 10)
+		}
+	} else {
+		bits.AddBits(0, 2)
+	}
+
+	// The variable length symbol field is set once the length is known.
+	vls := bits.Len()
+	bits.AddBits(0, 2)
+
+	if err := dataBarEncodeGeneral(bits, data.String()); err != nil {
+		return nil, err
+	}
+
+	dataChars := bits.Len() / 12
+	bits.SetBit(vls, (dataChars+1)%2 == 1)
+	bits.SetBit(vls+1, dataChars+1 > 14)
+
+	chars := make([][]int, dataChars+1)
+	for i := 1; i < len(chars); i++ {
+		value := 0
+		for j := 0; j < 12; j++ {
+			value <<= 1
+			if bits.GetBit((i-1)*12 + j) {
+				value |= 1
+			}
+		}
+		chars[i] = dataBarCharacter(value, dataBarExpandedGroups, 4, false)
+	}
+
+	sequence := dataBarFinderSequences[(len(chars)+1)/2-2]
+	checksum := 0
+	for i := 1; i < len(chars); i++ {
+		finder, reversed := dataBarFinder(sequence, i/2)
+		row := 4*finder + i%2 - 1
+		if reversed {
+			row += 2
+		}
+		for j, w := range chars[i] {
+			checksum += w * dataBarWeight(8*row+j, 211)
+		}
+	}
+	chars[0] = dataBarCharacter(211*(len(chars)-4)+checksum%211, dataBarExpandedGroups, 4, false)
+
+	elements := []int{1, 1}
+	for i := 0; i < len(chars); i += 2 {
+		elements = append(elements, chars[i]...)
+
+		finder, reversed := dataBarFinder(sequence, i/2)
+		elements = append(elements, dataBarReverse(dataBarFinders[finder][:], reversed)...)
+
+		if i+1 < len(chars) {
+			elements = append(elements, dataBarReverse(chars[i+1], true)...)
+		}
+	}
+	elements = append(elements, 1, 1)
+
+	return utils.New1DCode(TypeDataBarExpanded, text.String(), dataBarModules(elements)), nil
+}
+
+// dataBarEncodeGeneral appends the general purpose data to bits and pads
+// them to a whole number of symbol characters. See ISO/IEC 24724, 7.2.5.5.
+func dataBarEncodeGeneral(bits *utils.BitList, data string) error {
+	mode := dataBarNumeric
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch mode {
+		case dataBarNumeric:
+			switch {
+			case i+1 < len(data) && dataBarIsNumeric(c) && dataBarIsNumeric(data[i+1]):
+				bits.AddBits(11*dataBarDigit(c)+dataBarDigit(data[i+1])+8, 7)
+				i += 2
+			case i+1 == len(data) && c >= '0' && c <= '9':
+				// A final digit is encoded with 4 bits if that fills the
+				// last symbol character.
+				if remaining := dataBarPaddedLen(bits.Len()) - bits.Len(); remaining >= 4 && remaining <= 6 {
+					bits.AddBits(dataBarDigit(c)+1, 4)
+				} else {
+					bits.AddBits(11*dataBarDigit(c)+10+8, 7)
+				}
+				i++
+			default:
+				bits.AddBits(0, 4)
+				mode = dataBarAlphanumeric
+			}
+			continue
+		case dataBarAlphanumeric, dataBarISO646:
+			if run := dataBarNumericRun(data[i:]); run >= 6 || (run >= 4 && run == len(data)-i) {
+				bits.AddBits(0, 3)
+				mode = dataBarNumeric
+				continue
+			}
+		}
+
+		switch {
+		case c == dataBarSeparator:
+			// FNC1 returns to numeric mode.
+			bits.AddBits(15, 5)
+			mode = dataBarNumeric
+		case c >= '0' && c <= '9':
+			bits.AddBits(int(c-'0')+5, 5)
+		case mode == dataBarAlphanumeric && c >= 'A' && c <= 'Z':
+			bits.AddBits(int(c-'A')+32, 6)
+		case mode == dataBarAlphanumeric && strings.IndexByte("*,-./", c) >= 0:
+			bits.AddBits(strings.IndexByte("*,-./", c)+58, 6)
+		case mode == dataBarAlphanumeric:
+			bits.AddBits(4, 5)
+			mode = dataBarISO646
+			continue
+		case c >= 'A' && c <= 'Z':
+			bits.AddBits(int(c-'A')+64, 7)
+		case c >= 'a' && c <= 'z':
+			bits.AddBits(int(c-'a')+90, 7)
+		case strings.IndexByte(dataBarISO646Specials, c) >= 0:
+			bits.AddBits(strings.IndexByte(dataBarISO646Specials, c)+232, 8)
+		default:
+			return fmt.Errorf("invalid character %q for GS1 DataBar Expanded", c)
+		}
+		i++
+	}
+
+	length := dataBarPaddedLen(bits.Len())
+	if length > 21*12 {
+		return fmt.Errorf("GS1 DataBar Expanded data requires %d bits, at most %d are possible", bits.Len(), 21*12)
+	}
+
+	// Padding starts with a latch to alphanumeric mode, followed by
+	// repeated latches to ISO/IEC 646 mode.
+	padding := new(utils.BitList)
+	if mode == dataBarNumeric && data != "" {
+		padding.AddBits(0, 4)
+	}
+	for padding.Len() < length-bits.Len() {
+		padding.AddBits(4, 5)
+	}
+	for i := 0; bits.Len() < length; i++ {
+		bits.AddBit(padding.GetBit(i))
+	}
+
+	return nil
+}
+
+// dataBarPaddedLen returns the number of bits that n bits of DataBar Expanded
+// data are padded to: a whole number of symbol characters, at least three.
+func dataBarPaddedLen(n int) int {
+	if n < 36 {
+		return 36
+	}
+
+	return (n + 11) / 12 * 12
+}
+
+// dataBarIsNumeric reports whether c can be encoded in numeric mode.
+func dataBarIsNumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c == dataBarSeparator
+}
+
+// dataBarDigit returns the value of c in numeric mode, where FNC1 is 10.
+func dataBarDigit(c byte) int {
+	if c == dataBarSeparator {
+		return 10
+	}
+
+	return int(c - '0')
+}
+
+// dataBarNumericRun returns the number of leading characters of data that can
+// be encoded in numeric mode.
+func dataBarNumericRun(data string) int {
+	n := 0
+	for n < len(data) && dataBarIsNumeric(data[n]) {
+		n++
+	}
+
+	return n
+}
+
+// dataBarFinder returns the index of the i-th finder pattern of the given
+// sequence and whether it is reversed.
+func dataBarFinder(sequence string, i int) (finder int, reversed bool) {
+	return int(sequence[2*i] - 'A'), sequence[2*i+1] == '2'
+}
+
+// dataBarCharacter returns the element widths of the DataBar character with
+// the given value, alternating between its odd and even elements. Either the
+// odd or the even elements must include an element of one module:
+// oddNoNarrow is set if it is the even ones.
+func dataBarCharacter(value int, groups []dataBarGroup, elements int, oddNoNarrow bool) []int {
+	g := groups[0]
+	for _, group := range groups {
+		if value >= group.start {
+			g = group
+		}
+	}
+
+	value -= g.start
+	odd := dataBarWidths(value/g.evenCombinations, g.oddModules, elements, g.oddWidest, oddNoNarrow)
+	even := dataBarWidths(value%g.evenCombinations, g.evenModules, elements, g.evenWidest, !oddNoNarrow)
+
+	widths := make([]int, 2*elements)
+	for i := 0; i < elements; i++ {
+		widths[2*i], widths[2*i+1] = odd[i], even[i]
+	}
+
+	return widths
+}
+
+// dataBarWidths returns the widths of the given number of elements that
+// together have n modules, none of them wider than maxWidth, for the given
+// value. Unless noNarrow is set at least one element is one module wide. See
+// ISO/IEC 24724, annex B.
+func dataBarWidths(value, n, elements, maxWidth int, noNarrow bool) []int {
+	widths := make([]int, elements)
+	narrowMask := 0
+	for bar := 0; bar < elements-1; bar++ {
+		width, subValue := 1, 0
+		narrowMask |= 1 << uint(bar)
+		for ; ; width++ {
+			// All combinations of the remaining elements.
+			subValue = dataBarCombinations(n-width-1, elements-bar-2)
+			// Less those without a narrow element.
+			if !noNarrow && narrowMask == 0 && n-width-(elements-bar-1) >= elements-bar-1 {
+				subValue -= dataBarCombinations(n-width-(elements-bar), elements-bar-2)
+			}
+			// Less those with an element wider than maxWidth.
+			if elements-bar-1 > 1 {
+				less := 0
+				for widest := n - width - (elements - bar - 2); widest > maxWidth; widest-- {
+					less += dataBarCombinations(n-width-widest-1, elements-bar-3)
+				}
+				subValue -= less * (elements - 1 - bar)
+			} else if n-width > maxWidth {
+				subValue--
+			}
+
+			value -= subValue
+			if value < 0 {
+				break
+			}
+			narrowMask &^= 1 << uint(bar)
+		}
+		value += subValue
+		n -= width
+		widths[bar] = width
+	}
+	widths[elements-1] = n
+
+	return widths
+}
+
+// dataBarCombinations returns the number of combinations of r out of n
+// items.
+func dataBarCombinations(n, r int) int {
+	minDenom, maxDenom := r, n-r
+	if n-r < r {
+		minDenom, maxDenom = n-r, r
+	}
+
+	value, j := 1, 1
+	for i := n; i > maxDenom; i-- {
+		value *= i
+		if j <= minDenom {
+			value /= j
+			j++
+		}
+	}
+	for ; j <= minDenom; j++ {
+		value /= j
+	}
+
+	return value
+}
+
+// dataBarWeight returns the checksum weight 3^n modulo m of the n-th element.
+func dataBarWeight(n, m int) int {
+	weight := 1
+	for i := 0; i < n; i++ {
+		weight = weight * 3 % m
+	}
+
+	return weight
+}
+
+// dataBarReverse returns the widths in reverse order if reversed is set.
+func dataBarReverse(widths []int, reversed bool) []int {
+	if !reversed {
+		return widths
+	}
+
+	r := make([]int, len(widths))
+	for i, w := range widths {
+		r[len(widths)-1-i] = w
+	}
+
+	return r
+}
+
+// dataBarModules returns the modules of the given element widths, which start
+// with a space and alternate between spaces and bars.
+func dataBarModules(elements []int) *utils.BitList {
+	bars := new(utils.BitList)
+	for i, w := range elements {
+		for j := 0; j < w; j++ {
+			bars.AddBit(i%2 == 1)
+		}
+	}
+
+	return bars
+}
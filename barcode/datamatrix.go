@@ -0,0 +1,342 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/utils"
+)
+
+// dataMatrixSize describes a Data Matrix ECC 200 symbol size. The data regions
+// are arranged in regionRows by regionCols, and the error correction
+// codewords are interleaved in blocks.
+type dataMatrixSize struct {
+	rows, cols             int
+	regionRows, regionCols int
+	ecc, blocks            int
+}
+
+// dataMatrixSizes holds the square and rectangular Data Matrix ECC 200 symbol
+// sizes.
+var dataMatrixSizes = []dataMatrixSize{
+	{10, 10, 1, 1, 5, 1},
+	{12, 12, 1, 1, 7, 1},
+	{14, 14, 1, 1, 10, 1},
+	{16, 16, 1, 1, 12, 1},
+	{18, 18, 1, 1, 14, 1},
+	{20, 20, 1, 1, 18, 1},
+	{22, 22, 1, 1, 20, 1},
+	{24, 24, 1, 1, 24, 1},
+	{26, 26, 1, 1, 28, 1},
+	{32, 32, 2, 2, 36, 1},
+	{36, 36, 2, 2, 42, 1},
+	{40, 40, 2, 2, 48, 1},
+	{44, 44, 2, 2, 56, 1},
+	{48, 48, 2, 2, 68, 1},
+	{52, 52, 2, 2, 84, 2},
+	{64, 64, 4, 4, 112, 2},
+	{72, 72, 4, 4, 144, 4},
+	{80, 80, 4, 4, 192, 4},
+	{88, 88, 4, 4, 224, 4},
+	{96, 96, 4, 4, 272, 4},
+	{104, 104, 4, 4, 336, 6},
+	{120, 120, 6, 6, 408, 6},
+	{132, 132, 6, 6, 496, 8},
+	{144, 144, 6, 6, 620, 10},
+	{8, 18, 1, 1, 7, 1},
+	{8, 32, 1, 2, 11, 1},
+	{12, 26, 1, 1, 14, 1},
+	{12, 36, 1, 2, 18, 1},
+	{16, 36, 1, 2, 24, 1},
+	{16, 48, 1, 2, 28, 1},
+}
+
+// dataMatrixRS is the Reed-Solomon encoder of Data Matrix error correction.
+var dataMatrixRS = utils.NewReedSolomonEncoder(utils.NewGaloisField(301, 256, 1))
+
+// regionHeight and regionWidth return the size of a single data region,
+// without its finder and timing patterns.
+func (s dataMatrixSize) regionHeight() int { return s.rows/s.regionRows - 2 }
+func (s dataMatrixSize) regionWidth() int  { return s.cols/s.regionCols - 2 }
+
+// dataCodewords returns the number of data codewords of the symbol.
+func (s dataMatrixSize) dataCodewords() int {
+	return s.regionHeight()*s.regionRows*s.regionWidth()*s.regionCols/8 - s.ecc
+}
+
+// dataMatrixCode is a Data Matrix symbol of a fixed size.
+type dataMatrixCode struct {
+	content    string
+	rows, cols int
+	modules    []bool
+}
+
+// encodeDataMatrixSized returns a Data Matrix ECC 200 symbol of the given size
+// for code.
+func encodeDataMatrixSized(code string, rows, cols int) (barcode.Barcode, error) {
+	if err := Validate(barcode.TypeDataMatrix, code); err != nil {
+		return nil, err
+	}
+
+	var size *dataMatrixSize
+	for i := range dataMatrixSizes {
+		if dataMatrixSizes[i].rows == rows && dataMatrixSizes[i].cols == cols {
+			size = &dataMatrixSizes[i]
+			break
+		}
+	}
+	if size == nil {
+		return nil, fmt.Errorf("unsupported Data Matrix size %dx%d", rows, cols)
+	}
+
+	data := dataMatrixEncodeASCII(code)
+	if len(data) > size.dataCodewords() {
+		return nil, fmt.Errorf("Data Matrix code requires %d codewords, but size %dx%d holds %d", len(data), rows, cols, size.dataCodewords())
+	}
+	data = dataMatrixPad(data, size.dataCodewords())
+	data = dataMatrixECC(data, size)
+
+	return dataMatrixRender(code, data, size), nil
+}
+
+// dataMatrixEncodeASCII returns the codewords of code in ASCII encodation.
+// Pairs of digits are encoded in a single codeword, characters above 127 are
+// preceded by the upper shift codeword.
+func dataMatrixEncodeASCII(code string) []byte {
+	var data []byte
+	input := []byte(code)
+
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case isDigit(c) && i+1 < len(input) && isDigit(input[i+1]):
+			data = append(data, (c-'0')*10+(input[i+1]-'0')+130)
+			i++
+		case c > 127:
+			data = append(data, 235, c-127)
+		default:
+			data = append(data, c+1)
+		}
+	}
+
+	return data
+}
+
+// dataMatrixPad pads data to the given number of codewords with the pad
+// codeword 129 followed by pseudo-random pad codewords.
+func dataMatrixPad(data []byte, count int) []byte {
+	if len(data) < count {
+		data = append(data, 129)
+	}
+	for len(data) < count {
+		r := (149*(len(data)+1))%253 + 1
+		data = append(data, byte((129+r)%254))
+	}
+
+	return data
+}
+
+// dataMatrixECC returns data followed by its error correction codewords, which
+// are calculated for interleaved blocks.
+func dataMatrixECC(data []byte, size *dataMatrixSize) []byte {
+	dataCount := len(data)
+	eccPerBlock := size.ecc / size.blocks
+	result := append(data, make([]byte, size.ecc)...)
+
+	for block := 0; block < size.blocks; block++ {
+		var buf []int
+		for i := block; i < dataCount; i += size.blocks {
+			buf = append(buf, int(data[i]))
+		}
+
+		ecc := dataMatrixRS.Encode(buf, eccPerBlock)
+		for i, cw := range ecc {
+			result[dataCount+block+i*size.blocks] = byte(cw)
+		}
+	}
+
+	return result
+}
+
+// dataMatrixRender places the codewords in the data regions of the symbol
+// and adds the finder and timing patterns of each region.
+func dataMatrixRender(content string, codewords []byte, size *dataMatrixSize) *dataMatrixCode {
+	rh, rw := size.regionHeight(), size.regionWidth()
+	matrix := dataMatrixPlace(codewords, rh*size.regionRows, rw*size.regionCols)
+
+	code := &dataMatrixCode{
+		content: content,
+		rows:    size.rows,
+		cols:    size.cols,
+		modules: make([]bool, size.rows*size.cols),
+	}
+
+	for y := 0; y < size.rows; y++ {
+		for x := 0; x < size.cols; x++ {
+			ry, rx := y%(rh+2), x%(rw+2)
+			var dark bool
+			switch {
+			case rx == 0 || ry == rh+1:
+				dark = true
+			case ry == 0:
+				dark = x%2 == 0
+			case rx == rw+1:
+				dark = y%2 == 1
+			default:
+				my := y/(rh+2)*rh + ry - 1
+				mx := x/(rw+2)*rw + rx - 1
+				dark = matrix[my*rw*size.regionCols+mx]
+			}
+			code.modules[y*size.cols+x] = dark
+		}
+	}
+
+	return code
+}
+
+// dataMatrixPlace places the bits of the codewords in a mapping matrix of the
+// given size with the ECC 200 placement algorithm.
+func dataMatrixPlace(codewords []byte, nrow, ncol int) []bool {
+	matrix := make([]bool, nrow*ncol)
+	occupied := make([]bool, nrow*ncol)
+
+	module := func(row, col int, cw byte, bit uint) {
+		if row < 0 {
+			row += nrow
+			col += 4 - (nrow+4)%8
+		}
+		if col < 0 {
+			col += ncol
+			row += 4 - (ncol+4)%8
+		}
+		occupied[row*ncol+col] = true
+		matrix[row*ncol+col] = cw&(0x80>>bit) != 0
+	}
+	utah := func(row, col int, cw byte) {
+		module(row-2, col-2, cw, 0)
+		module(row-2, col-1, cw, 1)
+		module(row-1, col-2, cw, 2)
+		module(row-1, col-1, cw, 3)
+		module(row-1, col, cw, 4)
+		module(row, col-2, cw, 5)
+		module(row, col-1, cw, 6)
+		module(row, col, cw, 7)
+	}
+	corner := func(cw byte, positions [8][2]int) {
+		for bit, p := range positions {
+			module(p[0], p[1], cw, uint(bit))
+		}
+	}
+
+	idx := 0
+	next := func() byte {
+		cw := codewords[idx]
+		idx++
+		return cw
+	}
+
+	row, col := 4, 0
+	for row < nrow || col < ncol {
+		if row == nrow && col == 0 {
+			corner(next(), [8][2]int{{nrow - 1, 0}, {nrow - 1, 1}, {nrow - 1, 2}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 1}, {2, ncol - 1}, {3, ncol - 1}})
+		}
+		if row == nrow-2 && col == 0 && ncol%4 != 0 {
+			corner(next(), [8][2]int{{nrow - 3, 0}, {nrow - 2, 0}, {nrow - 1, 0}, {0, ncol - 4}, {0, ncol - 3}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 1}})
+		}
+		if row == nrow-2 && col == 0 && ncol%8 == 4 {
+			corner(next(), [8][2]int{{nrow - 3, 0}, {nrow - 2, 0}, {nrow - 1, 0}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 1}, {2, ncol - 1}, {3, ncol - 1}})
+		}
+		if row == nrow+4 && col == 2 && ncol%8 == 0 {
+			corner(next(), [8][2]int{{nrow - 1, 0}, {nrow - 1, ncol - 1}, {0, ncol - 3}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 3}, {1, ncol - 2}, {1, ncol - 1}})
+		}
+
+		for {
+			if row < nrow && col >= 0 && !occupied[row*ncol+col] {
+				utah(row, col, next())
+			}
+			row -= 2
+			col += 2
+			if row < 0 || col >= ncol {
+				break
+			}
+		}
+		row++
+		col += 3
+
+		for {
+			if row >= 0 && col < ncol && !occupied[row*ncol+col] {
+				utah(row, col, next())
+			}
+			row += 2
+			col -= 2
+			if row >= nrow || col < 0 {
+				break
+			}
+		}
+		row += 3
+		col++
+	}
+
+	if !occupied[nrow*ncol-1] {
+		matrix[nrow*ncol-1] = true
+		matrix[(nrow-1)*ncol-2] = true
+	}
+
+	return matrix
+}
+
+// isDigit reports whether c is one of the digits 0 to 9.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// key returns the key of the code in the registry, which includes its size so
+// that it is kept apart from the same code in another size.
+func (c *dataMatrixCode) key() string {
+	return fmt.Sprintf("%s %dx%d%s", barcode.TypeDataMatrix, c.rows, c.cols, c.content)
+}
+
+// Content returns the encoded content.
+func (c *dataMatrixCode) Content() string {
+	return c.content
+}
+
+// Metadata returns the kind of the code.
+func (c *dataMatrixCode) Metadata() barcode.Metadata {
+	return barcode.Metadata{CodeKind: barcode.TypeDataMatrix, Dimensions: 2}
+}
+
+// ColorModel returns the color model of the code.
+func (c *dataMatrixCode) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+// Bounds returns the size of the symbol in modules.
+func (c *dataMatrixCode) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.cols, c.rows)
+}
+
+// At returns the color of the module at x, y.
+func (c *dataMatrixCode) At(x, y int) color.Color {
+	if image.Pt(x, y).In(c.Bounds()) && c.modules[y*c.cols+x] {
+		return color.Black
+	}
+
+	return color.White
+}
@@ -0,0 +1,368 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"image"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// The Draw* functions register a barcode and put it in the current page in a
+// single call, for the common case of drawing a barcode once. They take the
+// arguments of the corresponding Register* function followed by those of
+// Barcode(). Errors are set on the PDF. Use the Register* functions and
+// Barcode() instead to put the same barcode on the page several times.
+
+// DrawAztec registers an Aztec code and puts it in the current page. See
+// RegisterAztec() and Barcode() for the arguments.
+func DrawAztec(pdf barcodePdf, code string, minECCPercent int, userSpecifiedLayers int, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawAztec(code, minECCPercent, userSpecifiedLayers, x, y, w, h, flow)
+}
+
+// DrawCodabar registers a Codabar barcode and puts it in the current page. See
+// RegisterCodabar() and Barcode() for the arguments.
+func DrawCodabar(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawCodabar(code, x, y, w, h, flow)
+}
+
+// DrawCodabarWithGuards registers a Codabar barcode with the given start and
+// stop characters and puts it in the current page. See
+// RegisterCodabarWithGuards() and Barcode() for the arguments.
+func DrawCodabarWithGuards(pdf barcodePdf, code string, start, stop byte, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawCodabarWithGuards(code, start, stop, x, y, w, h, flow)
+}
+
+// DrawCode11 registers a Code 11 barcode and puts it in the current page. See
+// RegisterCode11() and Barcode() for the arguments.
+func DrawCode11(pdf barcodePdf, code string, checkDigits int, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawCode11(code, checkDigits, x, y, w, h, flow)
+}
+
+// DrawCode128 registers a Code 128 barcode and puts it in the current page. See
+// RegisterCode128() and Barcode() for the arguments.
+func DrawCode128(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawCode128(code, x, y, w, h, flow)
+}
+
+// DrawCode39 registers a Code 39 barcode and puts it in the current page. See
+// RegisterCode39() and Barcode() for the arguments.
+func DrawCode39(pdf barcodePdf, code string, includeChecksum, fullASCIIMode bool, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawCode39(code, includeChecksum, fullASCIIMode, x, y, w, h, flow)
+}
+
+// DrawCode93 registers a Code 93 barcode and puts it in the current page. See
+// RegisterCode93() and Barcode() for the arguments.
+func DrawCode93(pdf barcodePdf, code string, includeChecksum, fullASCIIMode bool, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawCode93(code, includeChecksum, fullASCIIMode, x, y, w, h, flow)
+}
+
+// DrawDataMatrix registers a Data Matrix code and puts it in the current page.
+// See RegisterDataMatrix() and Barcode() for the arguments.
+func DrawDataMatrix(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawDataMatrix(code, x, y, w, h, flow)
+}
+
+// DrawGS1_128 registers a GS1-128 barcode and puts it in the current page. See
+// RegisterGS1_128() and Barcode() for the arguments.
+func DrawGS1_128(pdf barcodePdf, ais map[string]string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawGS1_128(ais, x, y, w, h, flow)
+}
+
+// DrawIntelligentMail registers a USPS Intelligent Mail barcode and puts it in
+// the current page. See RegisterIntelligentMail() and Barcode() for the
+// arguments.
+func DrawIntelligentMail(pdf barcodePdf, tracking, routing string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawIntelligentMail(tracking, routing, x, y, w, h, flow)
+}
+
+// DrawMSI registers an MSI Plessey barcode and puts it in the current page.
+// See RegisterMSI() and Barcode() for the arguments.
+func DrawMSI(pdf barcodePdf, code string, checksum MSIChecksumMode, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawMSI(code, checksum, x, y, w, h, flow)
+}
+
+// DrawPdf417 registers a PDF417 code and puts it in the current page. See
+// RegisterPdf417() and Barcode() for the arguments.
+func DrawPdf417(pdf barcodePdf, code string, columns int, securityLevel int, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawPdf417(code, columns, securityLevel, x, y, w, h, flow)
+}
+
+// DrawDataMatrixSized registers a Data Matrix code of the given size and puts
+// it in the current page. See RegisterDataMatrixSized() and Barcode() for the
+// arguments.
+func DrawDataMatrixSized(pdf barcodePdf, code string, rows, cols int, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawDataMatrixSized(code, rows, cols, x, y, w, h, flow)
+}
+
+// DrawDataBarExpanded registers a GS1 DataBar Expanded barcode and puts it in
+// the current page. See RegisterDataBarExpanded() and Barcode() for the
+// arguments.
+func DrawDataBarExpanded(pdf barcodePdf, ais map[string]string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawDataBarExpanded(ais, x, y, w, h, flow)
+}
+
+// DrawDataBarLimited registers a GS1 DataBar Limited barcode and puts it in
+// the current page. See RegisterDataBarLimited() and Barcode() for the
+// arguments.
+func DrawDataBarLimited(pdf barcodePdf, gtin string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawDataBarLimited(gtin, x, y, w, h, flow)
+}
+
+// DrawEAN registers an EAN barcode and puts it in the current page. See
+// RegisterEAN() and Barcode() for the arguments.
+func DrawEAN(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawEAN(code, x, y, w, h, flow)
+}
+
+// DrawEANWithAddon registers an EAN barcode with an add-on and puts it in the
+// current page. See RegisterEANWithAddon() and Barcode() for the arguments.
+func DrawEANWithAddon(pdf barcodePdf, code, addon string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawEANWithAddon(code, addon, x, y, w, h, flow)
+}
+
+// DrawPharmacode registers a Pharmacode barcode and puts it in the current
+// page. See RegisterPharmacode() and Barcode() for the arguments.
+func DrawPharmacode(pdf barcodePdf, number int, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawPharmacode(number, x, y, w, h, flow)
+}
+
+// DrawQR registers a QR code and puts it in the current page. See RegisterQR()
+// and Barcode() for the arguments.
+func DrawQR(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawQR(code, ecl, mode, x, y, w, h, flow)
+}
+
+// DrawQRWithLogo registers a QR code with a logo and puts it in the current
+// page. See RegisterQRWithLogo() and Barcode() for the arguments.
+func DrawQRWithLogo(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, logo image.Image, coverage float64, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawQRWithLogo(code, ecl, logo, coverage, x, y, w, h, flow)
+}
+
+// DrawQRVersion registers a QR code of the given version and puts it in the
+// current page. See RegisterQRVersion() and Barcode() for the arguments.
+func DrawQRVersion(pdf barcodePdf, code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, version int, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawQRVersion(code, ecl, mode, version, x, y, w, h, flow)
+}
+
+// DrawTwoOfFive registers a 2 of 5 barcode and puts it in the current page. See
+// RegisterTwoOfFive() and Barcode() for the arguments.
+func DrawTwoOfFive(pdf barcodePdf, code string, interleaved bool, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawTwoOfFive(code, interleaved, x, y, w, h, flow)
+}
+
+// DrawITF14 registers an ITF-14 barcode and puts it in the current page. See
+// RegisterITF14() and Barcode() for the arguments.
+func DrawITF14(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawITF14(code, x, y, w, h, flow)
+}
+
+// DrawUPCA registers a UPC-A barcode and puts it in the current page. See
+// RegisterUPCA() and Barcode() for the arguments.
+func DrawUPCA(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawUPCA(code, x, y, w, h, flow)
+}
+
+// DrawUPCE registers a UPC-E barcode and puts it in the current page. See
+// RegisterUPCE() and Barcode() for the arguments.
+func DrawUPCE(pdf barcodePdf, code string, x, y, w, h float64, flow bool) {
+	defaultRegistry(pdf).DrawUPCE(code, x, y, w, h, flow)
+}
+
+// DrawAztec registers an Aztec code with this registry and puts it in the
+// current page.
+func (r *Registry) DrawAztec(code string, minECCPercent int, userSpecifiedLayers int, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterAztecE(code, minECCPercent, userSpecifiedLayers)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawCodabar registers a Codabar barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawCodabar(code string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterCodabarE(code)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawCodabarWithGuards registers a Codabar barcode with the given start and
+// stop characters with this registry and puts it in the current page.
+func (r *Registry) DrawCodabarWithGuards(code string, start, stop byte, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterCodabarWithGuardsE(code, start, stop)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawCode11 registers a Code 11 barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawCode11(code string, checkDigits int, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterCode11E(code, checkDigits)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawCode128 registers a Code 128 barcode with this registry and puts it in
+// the current page.
+func (r *Registry) DrawCode128(code string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterCode128E(code)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawCode39 registers a Code 39 barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawCode39(code string, includeChecksum, fullASCIIMode bool, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterCode39E(code, includeChecksum, fullASCIIMode)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawCode93 registers a Code 93 barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawCode93(code string, includeChecksum, fullASCIIMode bool, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterCode93E(code, includeChecksum, fullASCIIMode)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawDataMatrix registers a Data Matrix code with this registry and puts it in
+// the current page.
+func (r *Registry) DrawDataMatrix(code string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterDataMatrixE(code)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawGS1_128 registers a GS1-128 barcode with this registry and puts it in
+// the current page.
+func (r *Registry) DrawGS1_128(ais map[string]string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterGS1_128E(ais)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawIntelligentMail registers a USPS Intelligent Mail barcode with this
+// registry and puts it in the current page.
+func (r *Registry) DrawIntelligentMail(tracking, routing string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterIntelligentMailE(tracking, routing)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawMSI registers an MSI Plessey barcode with this registry and puts it in
+// the current page.
+func (r *Registry) DrawMSI(code string, checksum MSIChecksumMode, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterMSIE(code, checksum)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawPdf417 registers a PDF417 code with this registry and puts it in the
+// current page.
+func (r *Registry) DrawPdf417(code string, columns int, securityLevel int, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterPdf417E(code, columns, securityLevel)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawDataMatrixSized registers a Data Matrix code of the given size with
+// this registry and puts it in the current page.
+func (r *Registry) DrawDataMatrixSized(code string, rows, cols int, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterDataMatrixSizedE(code, rows, cols)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawDataBarExpanded registers a GS1 DataBar Expanded barcode with this
+// registry and puts it in the current page.
+func (r *Registry) DrawDataBarExpanded(ais map[string]string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterDataBarExpandedE(ais)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawDataBarLimited registers a GS1 DataBar Limited barcode with this
+// registry and puts it in the current page.
+func (r *Registry) DrawDataBarLimited(gtin string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterDataBarLimitedE(gtin)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawEAN registers an EAN barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawEAN(code string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterEANE(code)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawEANWithAddon registers an EAN barcode with an add-on with this registry
+// and puts it in the current page.
+func (r *Registry) DrawEANWithAddon(code, addon string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterEANWithAddonE(code, addon)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawPharmacode registers a Pharmacode barcode with this registry and puts
+// it in the current page.
+func (r *Registry) DrawPharmacode(number int, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterPharmacodeE(number)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawQR registers a QR code with this registry and puts it in the current
+// page.
+func (r *Registry) DrawQR(code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterQRE(code, ecl, mode)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawQRWithLogo registers a QR code with a logo with this registry and puts
+// it in the current page.
+func (r *Registry) DrawQRWithLogo(code string, ecl qr.ErrorCorrectionLevel, logo image.Image, coverage float64, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterQRWithLogoE(code, ecl, logo, coverage)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawQRVersion registers a QR code of the given version with this registry
+// and puts it in the current page.
+func (r *Registry) DrawQRVersion(code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, version int, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterQRVersionE(code, ecl, mode, version)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawTwoOfFive registers a 2 of 5 barcode with this registry and puts it in
+// the current page.
+func (r *Registry) DrawTwoOfFive(code string, interleaved bool, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterTwoOfFiveE(code, interleaved)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawITF14 registers an ITF-14 barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawITF14(code string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterITF14E(code)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawUPCA registers a UPC-A barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawUPCA(code string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterUPCAE(code)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// DrawUPCE registers a UPC-E barcode with this registry and puts it in the
+// current page.
+func (r *Registry) DrawUPCE(code string, x, y, w, h float64, flow bool) {
+	key, err := r.RegisterUPCEE(code)
+	r.draw(key, err, x, y, w, h, flow)
+}
+
+// draw puts the barcode registered with key in the current page, unless err
+// is not nil, in which case it is reported according to the error policy.
+func (r *Registry) draw(key string, err error, x, y, w, h float64, flow bool) {
+	if err != nil {
+		r.setError(err)
+		return
+	}
+
+	r.Barcode(key, x, y, w, h, flow)
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+)
+
+// gs1AI describes the data field of a GS1 application identifier. length is
+// the exact length of fixed-length fields and the maximum length of all
+// others. check is set for fields that end with a GS1 check digit.
+type gs1AI struct {
+	length  int
+	fixed   bool
+	numeric bool
+	check   bool
+}
+
+// gs1AIs holds the supported GS1 application identifiers. Keys ending in 'n'
+// denote four digit identifiers whose last digit is the decimal point position
+// of the value.
+var gs1AIs = map[string]gs1AI{
+	"00":   {18, true, true, true},
+	"01":   {14, true, true, true},
+	"02":   {14, true, true, true},
+	"10":   {20, false, false, false},
+	"11":   {6, true, true, false},
+	"12":   {6, true, true, false},
+	"13":   {6, true, true, false},
+	"15":   {6, true, true, false},
+	"16":   {6, true, true, false},
+	"17":   {6, true, true, false},
+	"20":   {2, true, true, false},
+	"21":   {20, false, false, false},
+	"22":   {20, false, false, false},
+	"240":  {30, false, false, false},
+	"241":  {30, false, false, false},
+	"250":  {30, false, false, false},
+	"251":  {30, false, false, false},
+	"254":  {20, false, false, false},
+	"30":   {8, false, true, false},
+	"37":   {8, false, true, false},
+	"310n": {6, true, true, false},
+	"311n": {6, true, true, false},
+	"312n": {6, true, true, false},
+	"313n": {6, true, true, false},
+	"314n": {6, true, true, false},
+	"315n": {6, true, true, false},
+	"316n": {6, true, true, false},
+	"320n": {6, true, true, false},
+	"330n": {6, true, true, false},
+	"340n": {6, true, true, false},
+	"390n": {15, false, true, false},
+	"392n": {15, false, true, false},
+	"400":  {30, false, false, false},
+	"401":  {30, false, false, false},
+	"402":  {17, true, true, true},
+	"403":  {30, false, false, false},
+	"410":  {13, true, true, true},
+	"411":  {13, true, true, true},
+	"412":  {13, true, true, true},
+	"413":  {13, true, true, true},
+	"414":  {13, true, true, true},
+	"415":  {13, true, true, true},
+	"420":  {20, false, false, false},
+	"421":  {12, false, false, false},
+	"422":  {3, true, true, false},
+	"7003": {10, true, true, false},
+	"8004": {30, false, false, false},
+	"8005": {6, true, true, false},
+	"8018": {18, true, true, true},
+	"8020": {25, false, false, false},
+	"90":   {30, false, false, false},
+	"91":   {90, false, false, false},
+	"92":   {90, false, false, false},
+	"93":   {90, false, false, false},
+	"94":   {90, false, false, false},
+	"95":   {90, false, false, false},
+	"96":   {90, false, false, false},
+	"97":   {90, false, false, false},
+	"98":   {90, false, false, false},
+	"99":   {90, false, false, false},
+}
+
+// gs1Chars holds the characters that are allowed in alphanumeric GS1 fields.
+const gs1Chars = "!\"%&'()*+,-./0123456789:;<=>?ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+// gs1Barcode is a GS1-128 barcode whose content is the human readable element
+// string, so that it can be used as a caption.
+type gs1Barcode struct {
+	barcode.Barcode
+	text string
+}
+
+// Content returns the human readable element string of the barcode.
+func (g *gs1Barcode) Content() string {
+	return g.text
+}
+
+// GS1ElementString returns the human readable element string of the given
+// application identifiers and values, such as "(01)09501101530003(10)AB-123",
+// as it is printed beneath GS1-128 barcodes. See RegisterGS1_128() for
+// details.
+func GS1ElementString(ais map[string]string) (string, error) {
+	_, text, err := gs1ElementStrings(ais)
+	return text, err
+}
+
+// encodeGS1_128 returns a GS1-128 barcode of the given application identifiers
+// and values.
+func encodeGS1_128(ais map[string]string) (barcode.Barcode, error) {
+	data, text, err := gs1ElementStrings(ais)
+	if err != nil {
+		return nil, err
+	}
+
+	bcode, err := code128.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gs1Barcode{Barcode: bcode, text: text}, nil
+}
+
+// gs1ElementStrings validates the application identifiers and values and
+// returns the element string to encode, which starts with FNC1 and separates
+// variable-length fields with FNC1, and its human readable form. Fixed-length
+// fields are put first so that as few separators as possible are needed.
+func gs1ElementStrings(ais map[string]string) (data, text string, err error) {
+	if len(ais) == 0 {
+		return "", "", errors.New("GS1-128 barcode requires at least one application identifier")
+	}
+
+	fixed, variable, err := sortGS1AIs(ais)
+	if err != nil {
+		return "", "", err
+	}
+
+	var dataBuf, textBuf strings.Builder
+	dataBuf.WriteRune(code128.FNC1)
+	for i, ai := range append(fixed, variable...) {
+		dataBuf.WriteString(ai + ais[ai])
+		if i >= len(fixed) && i < len(ais)-1 {
+			dataBuf.WriteRune(code128.FNC1)
+		}
+		textBuf.WriteString("(" + ai + ")" + ais[ai])
+	}
+
+	return dataBuf.String(), textBuf.String(), nil
+}
+
+// sortGS1AIs validates the application identifiers and values and returns the
+// sorted identifiers of fixed-length and of variable-length fields.
+func sortGS1AIs(ais map[string]string) (fixed, variable []string, err error) {
+	for ai, value := range ais {
+		def, ok := lookupGS1AI(ai)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown GS1 application identifier %q", ai)
+		}
+		if err := def.validate(ai, value); err != nil {
+			return nil, nil, err
+		}
+
+		if def.fixed {
+			fixed = append(fixed, ai)
+		} else {
+			variable = append(variable, ai)
+		}
+	}
+	sort.Strings(fixed)
+	sort.Strings(variable)
+
+	return fixed, variable, nil
+}
+
+// lookupGS1AI returns the definition of the given application identifier.
+func lookupGS1AI(ai string) (gs1AI, bool) {
+	if !isDigits(ai) {
+		return gs1AI{}, false
+	}
+	if def, ok := gs1AIs[ai]; ok {
+		return def, true
+	}
+	if len(ai) == 4 {
+		def, ok := gs1AIs[ai[:3]+"n"]
+		return def, ok
+	}
+
+	return gs1AI{}, false
+}
+
+// validate returns an error if value is not valid for the application
+// identifier ai described by def.
+func (def gs1AI) validate(ai, value string) error {
+	switch {
+	case def.fixed && len(value) != def.length:
+		return fmt.Errorf("GS1 application identifier %s requires %d characters, got %q", ai, def.length, value)
+	case value == "" || len(value) > def.length:
+		return fmt.Errorf("GS1 application identifier %s allows 1 to %d characters, got %q", ai, def.length, value)
+	case def.numeric && !isDigits(value):
+		return fmt.Errorf("GS1 application identifier %s requires digits, got %q", ai, value)
+	}
+
+	for _, r := range value {
+		if !strings.ContainsRune(gs1Chars, r) {
+			return fmt.Errorf("invalid character %q for GS1 application identifier %s", r, ai)
+		}
+	}
+
+	if def.check {
+		return verifyGS1CheckDigit(value)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"strings"
+
+	"github.com/boombuler/barcode"
+)
+
+// guardBarExtension is the number of modules by which the guard bars of EAN
+// and UPC barcodes extend below the other bars.
+const guardBarExtension = 5
+
+// guardPatterns holds the start and end modules of the start, center and end
+// guard patterns of the EAN and UPC barcodes by code kind. UPC-E has no center
+// guard.
+var guardPatterns = map[string][][2]int{
+	barcode.TypeEAN13: {{0, 3}, {45, 50}, {92, 95}},
+	barcode.TypeEAN8:  {{0, 3}, {31, 36}, {64, 67}},
+	TypeUPCE:          {{0, 3}, {45, 51}},
+}
+
+// guardModules returns for every module of the one-dimensional barcode,
+// including a quiet zone of the given number of modules, whether it belongs
+// to a guard pattern. It returns nil if the barcode is not an EAN or UPC
+// barcode. The modules of an add-on are not part of a guard pattern.
+func guardModules(bcode barcode.Barcode, quietZone int) []bool {
+	kind := bcode.Metadata().CodeKind
+	if i := strings.Index(kind, "+"); i >= 0 {
+		kind = kind[:i]
+	}
+	patterns, ok := guardPatterns[kind]
+	if !ok {
+		return nil
+	}
+	if quietZone < 0 {
+		quietZone = 0
+	}
+
+	guards := make([]bool, bcode.Bounds().Dx()+2*quietZone)
+	for _, p := range patterns {
+		for x := p[0]; x < p[1]; x++ {
+			guards[quietZone+x] = true
+		}
+	}
+	return guards
+}
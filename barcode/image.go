@@ -0,0 +1,70 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+
+	"github.com/boombuler/barcode"
+)
+
+// typeImage is the code kind of images that are registered as barcodes.
+const typeImage = "Image"
+
+// imageBarcode is an arbitrary image that is registered as a barcode.
+type imageBarcode struct {
+	image.Image
+	name string
+}
+
+// newImageBarcode returns img as a barcode that is registered with the given
+// key.
+func newImageBarcode(key string, img image.Image) (barcode.Barcode, error) {
+	if key == "" {
+		return nil, errors.New("image barcode key must not be empty")
+	}
+	if img == nil || img.Bounds().Empty() {
+		return nil, errors.New("image barcode must not be empty")
+	}
+
+	return &imageBarcode{Image: img, name: key}, nil
+}
+
+// Metadata returns the metadata of the image, which is treated as a
+// two-dimensional barcode so that it is scaled in both directions.
+func (i *imageBarcode) Metadata() barcode.Metadata {
+	return barcode.Metadata{CodeKind: typeImage, Dimensions: 2}
+}
+
+// Content returns the key of the image.
+func (i *imageBarcode) Content() string {
+	return i.name
+}
+
+// key returns the key of the image in the registry.
+func (i *imageBarcode) key() string {
+	return i.name
+}
+
+// render returns the image in its own colors.
+func (i *imageBarcode) render(bcode, padded barcode.Barcode, opts options) image.Image {
+	bounds := bcode.Bounds()
+	img := image.NewNRGBA(bounds)
+	draw.Draw(img, bounds, bcode, bounds.Min, draw.Src)
+
+	return img
+}
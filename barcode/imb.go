@@ -0,0 +1,285 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/big"
+
+	"github.com/boombuler/barcode"
+)
+
+// TypeIntelligentMail is the code kind of USPS Intelligent Mail barcodes.
+const TypeIntelligentMail = "Intelligent Mail"
+
+// imbBars holds the characters and bits that determine the 65 bars of an
+// Intelligent Mail barcode, from left to right: the character (0 for A to 9
+// for J) and bit of the descender, followed by those of the ascender. See
+// USPS-B-3200, Appendix D.
+var imbBars = [65][4]uint8{
+	{7, 2, 4, 3}, {1, 10, 0, 0}, {9, 12, 2, 8}, {5, 5, 6, 11}, {8, 9, 3, 1},
+	{0, 1, 5, 12}, {2, 5, 1, 8}, {4, 4, 9, 11}, {6, 3, 8, 10}, {3, 9, 7, 6},
+	{5, 11, 1, 4}, {8, 5, 2, 12}, {9, 10, 0, 2}, {7, 1, 6, 7}, {3, 6, 4, 9},
+	{0, 3, 8, 6}, {6, 4, 2, 7}, {1, 1, 9, 9}, {7, 10, 5, 2}, {4, 0, 3, 8},
+	{6, 2, 0, 4}, {8, 11, 1, 0}, {9, 8, 3, 12}, {2, 6, 7, 7}, {5, 1, 4, 10},
+	{1, 12, 6, 9}, {7, 3, 8, 0}, {5, 8, 9, 7}, {4, 6, 2, 10}, {3, 4, 0, 5},
+	{8, 4, 5, 7}, {7, 11, 1, 9}, {6, 0, 9, 6}, {0, 6, 4, 8}, {2, 1, 3, 2},
+	{5, 9, 8, 12}, {4, 11, 6, 1}, {9, 5, 7, 4}, {3, 3, 1, 2}, {0, 7, 2, 0},
+	{1, 3, 4, 1}, {6, 10, 3, 5}, {8, 7, 9, 4}, {2, 11, 5, 6}, {0, 8, 7, 12},
+	{4, 2, 8, 1}, {5, 10, 3, 0}, {9, 3, 0, 9}, {6, 5, 2, 4}, {7, 8, 1, 7},
+	{5, 0, 4, 5}, {2, 3, 0, 10}, {6, 12, 9, 2}, {3, 11, 1, 6}, {8, 8, 7, 9},
+	{5, 4, 0, 11}, {1, 5, 2, 2}, {9, 1, 4, 12}, {8, 3, 6, 6}, {7, 0, 3, 7},
+	{4, 7, 7, 5}, {0, 12, 1, 11}, {2, 9, 9, 0}, {6, 8, 5, 3}, {3, 10, 8, 2},
+}
+
+// imbTable5, imbTable2 hold the 13 bit characters with five and two bits set
+// that represent the codewords of Intelligent Mail barcodes.
+var imbTable5, imbTable2 = imbCharTable(5, 1287), imbCharTable(2, 78)
+
+// imbCharTable returns the table of the given length of 13 bit characters
+// with n bits set, built as described in USPS-B-3200, Appendix E.
+func imbCharTable(n, length int) []uint16 {
+	table := make([]uint16, length)
+	lower, upper := 0, length-1
+	for c := uint16(0); c < 1<<13; c++ {
+		bits := 0
+		for b := c; b != 0; b >>= 1 {
+			bits += int(b & 1)
+		}
+		if bits != n {
+			continue
+		}
+
+		var reverse uint16
+		for i := uint(0); i < 13; i++ {
+			if c&(1<<i) != 0 {
+				reverse |= 1 << (12 - i)
+			}
+		}
+		switch {
+		case reverse < c:
+		case reverse == c:
+			table[upper] = c
+			upper--
+		default:
+			table[lower] = c
+			table[lower+1] = reverse
+			lower += 2
+		}
+	}
+
+	return table
+}
+
+// Bar states of Intelligent Mail barcodes.
+const (
+	imbTracker   = 0
+	imbAscender  = 1
+	imbDescender = 2
+	imbFull      = imbAscender | imbDescender
+)
+
+// intelligentMailCode is a USPS Intelligent Mail barcode. Its image has a
+// column for each bar and each space between bars and three rows, for the
+// ascenders, the trackers and the descenders.
+type intelligentMailCode struct {
+	content string
+	bars    [65]uint8
+}
+
+// encodeIntelligentMail returns an Intelligent Mail barcode for the given 20
+// digit tracking code and 0, 5, 9 or 11 digit routing code.
+func encodeIntelligentMail(tracking, routing string) (barcode.Barcode, error) {
+	if err := validateIntelligentMail(tracking, routing); err != nil {
+		return nil, err
+	}
+
+	// Convert the routing and tracking codes into a binary value
+	value := new(big.Int)
+	if routing != "" {
+		value.SetString(routing, 10)
+		switch len(routing) {
+		case 11:
+			value.Add(value, big.NewInt(1000000000))
+			fallthrough
+		case 9:
+			value.Add(value, big.NewInt(100000))
+			fallthrough
+		case 5:
+			value.Add(value, big.NewInt(1))
+		}
+	}
+	mulAdd := func(m int64, digit byte) {
+		value.Mul(value, big.NewInt(m))
+		value.Add(value, big.NewInt(int64(digit-'0')))
+	}
+	mulAdd(10, tracking[0])
+	mulAdd(5, tracking[1])
+	for i := 2; i < len(tracking); i++ {
+		mulAdd(10, tracking[i])
+	}
+
+	var data [13]byte
+	value.FillBytes(data[:])
+	fcs := imbFrameCheckSequence(data)
+
+	// Convert the value into codewords A to J
+	var codewords [10]int
+	mod := new(big.Int)
+	value.DivMod(value, big.NewInt(636), mod)
+	codewords[9] = int(mod.Int64())
+	for i := 8; i > 0; i-- {
+		value.DivMod(value, big.NewInt(1365), mod)
+		codewords[i] = int(mod.Int64())
+	}
+	codewords[0] = int(value.Int64())
+	codewords[9] *= 2
+	if fcs&(1<<10) != 0 {
+		codewords[0] += 659
+	}
+
+	// Convert the codewords into characters
+	var chars [10]uint16
+	for i, cw := range codewords {
+		if cw < len(imbTable5) {
+			chars[i] = imbTable5[cw]
+		} else {
+			chars[i] = imbTable2[cw-len(imbTable5)]
+		}
+		if fcs&(1<<uint(i)) != 0 {
+			chars[i] ^= 1<<13 - 1
+		}
+	}
+
+	code := &intelligentMailCode{content: tracking + routing}
+	for i, bar := range imbBars {
+		if chars[bar[0]]&(1<<bar[1]) != 0 {
+			code.bars[i] |= imbDescender
+		}
+		if chars[bar[2]]&(1<<bar[3]) != 0 {
+			code.bars[i] |= imbAscender
+		}
+	}
+
+	return code, nil
+}
+
+// imbFrameCheckSequence returns the 11 bit CRC of the 102 bits of data, which
+// are right-aligned in the 13 bytes.
+func imbFrameCheckSequence(data [13]byte) uint16 {
+	const polynomial = 0x0F35
+	fcs := uint16(0x07FF)
+	for i, b := range data {
+		bits := uint(8)
+		if i == 0 {
+			bits = 6
+		}
+		d := uint16(b) << 3
+		if i == 0 {
+			d <<= 2
+		}
+		for ; bits > 0; bits-- {
+			if (fcs^d)&0x400 != 0 {
+				fcs = fcs<<1 ^ polynomial
+			} else {
+				fcs <<= 1
+			}
+			fcs &= 0x7FF
+			d <<= 1
+		}
+	}
+
+	return fcs
+}
+
+// String returns the bars as the letters T, A, D and F for tracker,
+// ascender, descender and full bars.
+func (c *intelligentMailCode) String() string {
+	s := make([]byte, len(c.bars))
+	for i, bar := range c.bars {
+		s[i] = "TADF"[bar]
+	}
+
+	return string(s)
+}
+
+// Content returns the tracking code followed by the routing code.
+func (c *intelligentMailCode) Content() string {
+	return c.content
+}
+
+// Metadata returns the kind of the code.
+func (c *intelligentMailCode) Metadata() barcode.Metadata {
+	return barcode.Metadata{CodeKind: TypeIntelligentMail, Dimensions: 2}
+}
+
+// ColorModel returns the color model of the code.
+func (c *intelligentMailCode) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+// Bounds returns the size of the code: a column for each bar and each space
+// and a row for the ascenders, the trackers and the descenders.
+func (c *intelligentMailCode) Bounds() image.Rectangle {
+	return image.Rect(0, 0, 2*len(c.bars)-1, 3)
+}
+
+// At returns the color of the module at x, y.
+func (c *intelligentMailCode) At(x, y int) color.Color {
+	if !image.Pt(x, y).In(c.Bounds()) || x%2 != 0 {
+		return color.White
+	}
+
+	bar := c.bars[x/2]
+	if y == 1 || y == 0 && bar&imbAscender != 0 || y == 2 && bar&imbDescender != 0 {
+		return color.Black
+	}
+
+	return color.White
+}
+
+// validateIntelligentMailContent validates code as the content of an
+// Intelligent Mail barcode, the tracking code followed by the routing code.
+func validateIntelligentMailContent(code string) error {
+	if len(code) < 20 {
+		return fmt.Errorf("Intelligent Mail code must consist of 20, 25, 29 or 31 digits, got %q", code)
+	}
+
+	return validateIntelligentMail(code[:20], code[20:])
+}
+
+// validateIntelligentMail validates that tracking consists of 20 digits, the
+// second of which is 0 to 4, and that routing consists of 0, 5, 9 or 11
+// digits.
+func validateIntelligentMail(tracking, routing string) error {
+	if len(tracking) != 20 || !isDigits(tracking) {
+		return fmt.Errorf("Intelligent Mail tracking code must consist of 20 digits, got %q", tracking)
+	}
+	if tracking[1] > '4' {
+		return fmt.Errorf("Intelligent Mail barcode identifier must end with 0 to 4, got %q", tracking[:2])
+	}
+	switch len(routing) {
+	case 0, 5, 9, 11:
+	default:
+		return fmt.Errorf("Intelligent Mail routing code must consist of 0, 5, 9 or 11 digits, got %q", routing)
+	}
+	if routing != "" && !isDigits(routing) {
+		return fmt.Errorf("Intelligent Mail routing code must consist of digits, got %q", routing)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/twooffive"
+)
+
+// TypeITF14 is the code kind of ITF-14 barcodes.
+const TypeITF14 = "ITF-14"
+
+// The layout of an ITF-14 barcode in modules, i.e. multiples of the narrow
+// bar width.
+const (
+	itf14QuietZone = 10 // minimum light margin left and right of the bars
+	itf14Bearer    = 5  // thickness of the bearer bar frame
+	itf14Height    = 32 // height of the bars
+)
+
+// itf14 is an Interleaved 2 of 5 barcode surrounded by quiet zones and a
+// bearer bar frame. The frame extends in both directions, so the barcode is
+// rendered as a two-dimensional image rather than a row of bars.
+type itf14 struct {
+	barcode.Barcode
+}
+
+// encodeITF14 returns an ITF-14 barcode for the given 14 digit GTIN, including
+// the check digit.
+func encodeITF14(code string) (barcode.Barcode, error) {
+	if err := validateITF14(code); err != nil {
+		return nil, err
+	}
+
+	bcode, err := twooffive.Encode(code, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &itf14{bcode}, nil
+}
+
+func (c *itf14) Metadata() barcode.Metadata {
+	return barcode.Metadata{CodeKind: TypeITF14, Dimensions: 2}
+}
+
+func (c *itf14) Bounds() image.Rectangle {
+	w := c.Barcode.Bounds().Dx() + 2*(itf14QuietZone+itf14Bearer)
+	return image.Rect(0, 0, w, itf14Height+2*itf14Bearer)
+}
+
+func (c *itf14) At(x, y int) color.Color {
+	b := c.Bounds()
+	if x < itf14Bearer || y < itf14Bearer || x >= b.Max.X-itf14Bearer || y >= b.Max.Y-itf14Bearer {
+		return color.Black
+	}
+
+	x -= itf14Bearer + itf14QuietZone
+	if x < 0 || x >= c.Barcode.Bounds().Dx() {
+		return color.White
+	}
+
+	return c.Barcode.At(x, 0)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	xdraw "golang.org/x/image/draw"
+)
+
+// qrRecovery holds the share of a QR code that can be restored by each error
+// correction level.
+var qrRecovery = map[qr.ErrorCorrectionLevel]float64{
+	qr.L: 0.07,
+	qr.M: 0.15,
+	qr.Q: 0.25,
+	qr.H: 0.30,
+}
+
+// logoBarcode is a QR code with a logo in its center. The logo is drawn when
+// the barcode image is rendered, so that it is not reduced to the resolution
+// of the modules.
+type logoBarcode struct {
+	barcode.Barcode
+	logo     image.Image
+	coverage float64
+}
+
+// encodeQRWithLogo returns a QR code for code with the given logo covering the
+// given fraction of its area.
+func encodeQRWithLogo(code string, ecl qr.ErrorCorrectionLevel, logo image.Image, coverage float64) (barcode.Barcode, error) {
+	if err := Validate(barcode.TypeQR, code); err != nil {
+		return nil, err
+	}
+	if logo == nil || logo.Bounds().Empty() {
+		return nil, errors.New("QR code logo must not be empty")
+	}
+	if limit := qrRecovery[ecl]; coverage <= 0 || coverage > limit {
+		return nil, fmt.Errorf("QR code logo coverage must be greater than 0 and at most %g for this error correction level, got %g", limit, coverage)
+	}
+
+	bcode, err := qr.Encode(code, ecl, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logoBarcode{Barcode: bcode, logo: logo, coverage: coverage}, nil
+}
+
+// key returns the key of the barcode in the registry, which distinguishes it
+// from the same QR code without or with another logo.
+func (l *logoBarcode) key() string {
+	return l.Metadata().CodeKind + "+logo" + strconv.FormatUint(imageHash(l.logo), 16) +
+		"-" + strconv.FormatFloat(l.coverage, 'f', -1, 64) + l.Content()
+}
+
+// imageHash returns a hash of the colors of all pixels of img, or 0 if img is
+// nil.
+func imageHash(img image.Image) uint64 {
+	if img == nil {
+		return 0
+	}
+
+	h := fnv.New64a()
+	bounds := img.Bounds()
+	var buf [8]byte
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			binary.BigEndian.PutUint16(buf[0:], uint16(r))
+			binary.BigEndian.PutUint16(buf[2:], uint16(g))
+			binary.BigEndian.PutUint16(buf[4:], uint16(b))
+			binary.BigEndian.PutUint16(buf[6:], uint16(a))
+			h.Write(buf[:])
+		}
+	}
+
+	return h.Sum64()
+}
+
+// render returns the image of the barcode with the logo drawn in its center.
+// The logo covers the given fraction of the QR code itself, excluding any
+// quiet zone.
+func (l *logoBarcode) render(bcode, padded barcode.Barcode, opts options) image.Image {
+	img := renderImage(bcode, opts)
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+
+	factor := bounds.Dx() / padded.Bounds().Dx()
+	if f := bounds.Dy() / padded.Bounds().Dy(); f < factor {
+		factor = f
+	}
+	symbol := float64(l.Bounds().Dx() * factor)
+
+	logo := l.logo.Bounds()
+	aspect := float64(logo.Dx()) / float64(logo.Dy())
+	h := math.Min(math.Sqrt(l.coverage*symbol*symbol/aspect), symbol)
+	w := math.Min(h*aspect, symbol)
+
+	rect := image.Rect(0, 0, int(w), int(h))
+	rect = rect.Add(bounds.Min).Add(image.Pt((bounds.Dx()-rect.Dx())/2, (bounds.Dy()-rect.Dy())/2))
+	xdraw.CatmullRom.Scale(dst, rect, l.logo, logo, xdraw.Over, nil)
+
+	return dst
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/utils"
+)
+
+// TypeMSI is the code kind of MSI Plessey barcodes.
+const TypeMSI = "MSI"
+
+// MSIChecksumMode selects the check digits that are appended to MSI Plessey
+// barcodes.
+type MSIChecksumMode int
+
+// The MSI Plessey check digit modes. MSIChecksumMod11 uses the IBM weights 2
+// to 7; a check value of 10 is appended as the digits "10".
+const (
+	MSIChecksumNone    MSIChecksumMode = iota // No check digit
+	MSIChecksumMod10                          // Modulo 10 check digit
+	MSIChecksumMod11                          // Modulo 11 check digit
+	MSIChecksumMod1010                        // Two modulo 10 check digits
+	MSIChecksumMod1110                        // Modulo 11 followed by modulo 10 check digit
+)
+
+// Patterns of MSI Plessey barcodes. Each digit is encoded as four bits, most
+// significant first.
+const (
+	msiStart = "110"
+	msiStop  = "1001"
+	msiOne   = "110"
+	msiZero  = "100"
+)
+
+// encodeMSI returns an MSI Plessey barcode for the given digits with the check
+// digits of the given mode appended.
+func encodeMSI(code string, checksum MSIChecksumMode) (barcode.Barcode, error) {
+	if err := Validate(TypeMSI, code); err != nil {
+		return nil, err
+	}
+
+	switch checksum {
+	case MSIChecksumNone:
+	case MSIChecksumMod10:
+		code += msiMod10(code)
+	case MSIChecksumMod11:
+		code += msiMod11(code)
+	case MSIChecksumMod1010:
+		code += msiMod10(code)
+		code += msiMod10(code)
+	case MSIChecksumMod1110:
+		code += msiMod11(code)
+		code += msiMod10(code)
+	default:
+		return nil, fmt.Errorf("unknown MSI checksum mode %d", checksum)
+	}
+
+	bars := new(utils.BitList)
+	addPattern(bars, msiStart)
+	for _, r := range code {
+		d := r - '0'
+		for bit := 3; bit >= 0; bit-- {
+			if d&(1<<uint(bit)) != 0 {
+				addPattern(bars, msiOne)
+			} else {
+				addPattern(bars, msiZero)
+			}
+		}
+	}
+	addPattern(bars, msiStop)
+
+	return utils.New1DCode(TypeMSI, code, bars), nil
+}
+
+// msiMod10 returns the Luhn modulo 10 check digit of the digits, doubling
+// every other digit starting with the rightmost one.
+func msiMod10(digits string) string {
+	sum := 0
+	for i := range digits {
+		n := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+
+	return strconv.Itoa((10 - sum%10) % 10)
+}
+
+// msiMod11 returns the modulo 11 check digit of the digits, which are weighted
+// from the right with 2 to 7.
+func msiMod11(digits string) string {
+	sum := 0
+	for i := range digits {
+		sum += int(digits[len(digits)-1-i]-'0') * (i%6 + 2)
+	}
+
+	return strconv.Itoa((11 - sum%11) % 11)
+}
+
+// validateMSI validates that code consists of digits.
+func validateMSI(code string) error {
+	if !isDigits(code) {
+		return fmt.Errorf("MSI code must consist of digits, got %q", code)
+	}
+
+	return nil
+}
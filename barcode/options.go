@@ -0,0 +1,241 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+)
+
+// BarcodeOptions holds the settings of a single barcode. They take precedence
+// over the package-wide settings of SetImageFormat(), SetDPI(), SetColors()
+// and SetQuietZone(). A field with its zero value keeps the package-wide
+// setting, so only the settings that differ need to be given.
+type BarcodeOptions struct {
+	Format        string      // Image format, "png" or "jpg"
+	JPEGQuality   int         // Quality of JPEG images, 1 to 100
+	DPI           float64     // Resolution of the image
+	Foreground    color.Color // Color of the bars or modules
+	Background    color.Color // Color of the spaces
+	QuietZone     int         // Quiet zone in modules, negative for none
+	Rotation      int         // Counter-clockwise rotation: 0, 90, 180 or 270
+	Caption       string      // Text printed centered beneath the barcode
+	CaptionHeight float64     // Height of the caption
+}
+
+// validate returns an error if one of the options is not supported.
+func (o BarcodeOptions) validate() error {
+	switch {
+	case o.Format != "" && imageType(o.Format) == "":
+		return fmt.Errorf("unsupported barcode image format %q", o.Format)
+	case o.JPEGQuality < 0 || o.JPEGQuality > 100:
+		return fmt.Errorf("JPEG quality %d must be between 1 and 100", o.JPEGQuality)
+	case o.DPI < 0:
+		return fmt.Errorf("invalid barcode resolution of %g DPI", o.DPI)
+	case !validRotation(o.Rotation):
+		return fmt.Errorf("unsupported barcode rotation of %d degrees", o.Rotation)
+	case o.Caption != "" && o.CaptionHeight <= 0:
+		return errors.New("barcode caption requires a positive caption height")
+	}
+
+	return nil
+}
+
+// merge returns o with the options that are set in override replaced.
+func (o BarcodeOptions) merge(override BarcodeOptions) BarcodeOptions {
+	if override.Format != "" {
+		o.Format = override.Format
+	}
+	if override.JPEGQuality != 0 {
+		o.JPEGQuality = override.JPEGQuality
+	}
+	if override.DPI != 0 {
+		o.DPI = override.DPI
+	}
+	if override.Foreground != nil {
+		o.Foreground = override.Foreground
+	}
+	if override.Background != nil {
+		o.Background = override.Background
+	}
+	if override.QuietZone != 0 {
+		o.QuietZone = override.QuietZone
+	}
+	if override.Rotation != 0 {
+		o.Rotation = override.Rotation
+	}
+	if override.Caption != "" {
+		o.Caption, o.CaptionHeight = override.Caption, override.CaptionHeight
+	}
+
+	return o
+}
+
+// apply returns opts with the rendering options that are set in o replaced.
+func (o BarcodeOptions) apply(opts options) options {
+	if o.Format != "" {
+		opts.format = o.Format
+	}
+	if o.JPEGQuality != 0 {
+		opts.jpegQuality = o.JPEGQuality
+	}
+	if o.DPI != 0 {
+		opts.dpi = o.DPI
+	}
+	if o.Foreground != nil {
+		opts.fg = o.Foreground
+	}
+	if o.Background != nil {
+		opts.bg = o.Background
+	}
+	if o.QuietZone != 0 {
+		opts.quietZone = o.QuietZone
+		if opts.quietZone < 0 {
+			opts.quietZone = 0
+		}
+	}
+
+	return opts
+}
+
+// name returns a string that identifies the options, so that a barcode that
+// is registered with different options gets a different key.
+func (o BarcodeOptions) name() string {
+	colorOrDefault := func(c color.Color) string {
+		if c == nil {
+			return ""
+		}
+		return colorName(c)
+	}
+
+	return fmt.Sprintf("%s/%d/%g/%s/%s/%d/%d/%g/%s", o.Format, o.JPEGQuality, o.DPI,
+		colorOrDefault(o.Foreground), colorOrDefault(o.Background), o.QuietZone, o.Rotation,
+		o.CaptionHeight, o.Caption)
+}
+
+// RegisterWithOptions registers a barcode together with the options it is put
+// on the page with. See the package-level RegisterWithOptions() for details.
+func (r *Registry) RegisterWithOptions(bcode barcode.Barcode, opts BarcodeOptions) string {
+	return r.keyOrSetError(r.RegisterWithOptionsE(bcode, opts))
+}
+
+// RegisterWithOptionsE registers a barcode together with the options it is put
+// on the page with and returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterWithOptionsE(bcode barcode.Barcode, opts BarcodeOptions) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+
+	key := barcodeKey(bcode) + "-" + opts.name()
+
+	r.barcodes.Lock()
+	if len(r.barcodes.cache) == 0 {
+		r.barcodes.cache = make(map[string]barcode.Barcode)
+	}
+	if len(r.barcodes.options) == 0 {
+		r.barcodes.options = make(map[string]BarcodeOptions)
+	}
+	r.barcodes.cache[key] = bcode
+	r.barcodes.options[key] = opts
+	r.barcodes.Unlock()
+
+	return key, nil
+}
+
+// BarcodeWithOptions puts a barcode of this registry in the current page with
+// the given options. See the package-level BarcodeWithOptions() for details.
+func (r *Registry) BarcodeWithOptions(code string, x, y, w, h float64, flow bool, opts BarcodeOptions) {
+	r.setError(r.BarcodeWithOptionsE(code, x, y, w, h, flow, opts))
+}
+
+// BarcodeWithOptionsE puts a barcode of this registry in the current page with
+// the given options and returns any error instead of setting it on the PDF.
+func (r *Registry) BarcodeWithOptionsE(code string, x, y, w, h float64, flow bool, opts BarcodeOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	merged := r.lookupOptions(code).merge(opts)
+	if merged.Caption == "" {
+		return r.printBarcodeWithOptions(code, x, y, &w, &h, flow, 0, 0, "", merged)
+	}
+
+	pdf, ok := r.pdf.(barcodeTextPdf)
+	if !ok {
+		return errors.New("PDF does not support printing the barcode caption")
+	}
+	w, barHeight, err := r.barSize(code, w, h, merged)
+	if err != nil {
+		return err
+	}
+	if err := r.printBarcodeWithOptions(code, x, y, &w, &barHeight, flow, 0, 0, "", merged); err != nil {
+		return err
+	}
+
+	footprintW, footprintH := w, barHeight
+	if merged.Rotation == 90 || merged.Rotation == 270 {
+		footprintW, footprintH = footprintH, footprintW
+	}
+	printCaption(pdf, merged.Caption, x, y+footprintH, footprintW, merged.CaptionHeight, flow)
+
+	return nil
+}
+
+// barSize returns the width and height of the bars of the barcode with the
+// given key, before rotation, when it is put with width w and height h and
+// the given options. The caption takes up part of h. If h is zero, the bars
+// get the height that keeps their aspect ratio, as without a caption, and the
+// caption is added beneath them.
+func (r *Registry) barSize(code string, w, h float64, bopts BarcodeOptions) (float64, float64, error) {
+	registered, ok := r.lookup(code)
+	if !ok {
+		return 0, 0, ErrBarcodeNotFound
+	}
+	if err := validateSize(w, h); err != nil {
+		return 0, 0, err
+	}
+
+	barHeight := h
+	if bopts.Caption != "" && h != 0 {
+		if bopts.CaptionHeight >= h {
+			return 0, 0, fmt.Errorf("caption height %g must be less than the barcode height %g", bopts.CaptionHeight, h)
+		}
+		barHeight -= bopts.CaptionHeight
+	}
+
+	opts := bopts.apply(currentOptions())
+	w, barHeight = naturalSize(r.pdf, withQuietZone(registered, opts.quietZone), w, barHeight)
+	return w, barHeight, nil
+}
+
+// lookupOptions returns the options the barcode with the given key has been
+// registered with, which are empty unless it has been registered with
+// RegisterWithOptions().
+func (r *Registry) lookupOptions(code string) BarcodeOptions {
+	r.barcodes.RLock()
+	opts := r.barcodes.options[code]
+	r.barcodes.RUnlock()
+
+	return opts
+}
+
+// options returns the package-wide options with the options of the barcode
+// with the given key applied.
+func (r *Registry) options(code string) options {
+	return r.lookupOptions(code).apply(currentOptions())
+}
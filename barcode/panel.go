@@ -0,0 +1,56 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// BarcodeOnPanel puts a barcode of this registry in the current page on top
+// of a filled rectangle. The PDF of the registry must support drawing
+// rectangles. See the package-level BarcodeOnPanel() for details.
+func (r *Registry) BarcodeOnPanel(code string, x, y, w, h, pad float64, panel color.Color) {
+	pdf, ok := r.pdf.(barcodeVectorPdf)
+	if !ok {
+		r.setError(errors.New("PDF does not support drawing the barcode panel"))
+		return
+	}
+
+	if _, ok := r.lookup(code); !ok {
+		r.setError(ErrBarcodeNotFound)
+		return
+	}
+
+	if err := validateSize(w, h); err != nil {
+		r.setError(err)
+		return
+	}
+	if pad < 0 || math.IsNaN(pad) || math.IsInf(pad, 0) {
+		r.setError(fmt.Errorf("%w: panel padding %g must not be negative", ErrInvalidSize, pad))
+		return
+	}
+
+	size := r.placedSize(code, w, h)
+	red, green, blue := pdf.GetFillColor()
+	c := color.NRGBAModel.Convert(panel).(color.NRGBA)
+	pdf.SetFillColor(int(c.R), int(c.G), int(c.B))
+	pdf.Rect(x-pad, y-pad, size.Wd+2*pad, size.Ht+2*pad, "F")
+	pdf.SetFillColor(red, green, blue)
+
+	r.Barcode(code, x, y, w, h, false)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/utils"
+)
+
+// TypePharmacode is the code kind of Pharmacode barcodes.
+const TypePharmacode = "Pharmacode"
+
+// Bar and space patterns of Pharmacode barcodes. A wide bar is three times as
+// wide as a narrow one, and bars are separated by spaces twice as wide as a
+// narrow bar.
+const (
+	pharmacodeNarrow = "1"
+	pharmacodeWide   = "111"
+	pharmacodeSpace  = "00"
+)
+
+// encodePharmacode returns a one-track Pharmacode barcode for number, which
+// must be between 3 and 131070.
+func encodePharmacode(number int) (barcode.Barcode, error) {
+	code := strconv.Itoa(number)
+	if err := Validate(TypePharmacode, code); err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for n := number; n > 0; {
+		if n%2 == 0 {
+			patterns = append([]string{pharmacodeWide}, patterns...)
+			n = (n - 2) / 2
+		} else {
+			patterns = append([]string{pharmacodeNarrow}, patterns...)
+			n = (n - 1) / 2
+		}
+	}
+
+	bars := new(utils.BitList)
+	for i, pattern := range patterns {
+		if i > 0 {
+			addPattern(bars, pharmacodeSpace)
+		}
+		addPattern(bars, pattern)
+	}
+
+	return utils.New1DCode(TypePharmacode, code, bars), nil
+}
+
+// validatePharmacode validates that code is a number between 3 and 131070.
+func validatePharmacode(code string) error {
+	n, err := strconv.Atoi(code)
+	if err != nil || !isDigits(code) || n < 3 || n > 131070 {
+		return fmt.Errorf("Pharmacode must be a number between 3 and 131070, got %q", code)
+	}
+
+	return nil
+}
@@ -0,0 +1,674 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/boombuler/barcode/utils"
+)
+
+// qrECCPerBlock and qrBlocks hold the number of error correction codewords
+// per block and the number of blocks of the QR code versions 1 to 40, indexed
+// by the error correction level L, M, Q and H.
+var (
+	qrECCPerBlock = [4][40]int{
+		{7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		{13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	qrBlocks = [4][40]int{
+		{1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		{1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		{1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		{1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// qrFormatLevel holds the error correction level bits of the format
+// information, indexed by the error correction level.
+var qrFormatLevel = [4]int{1, 0, 3, 2}
+
+// qrRS is the Reed-Solomon encoder of QR code error correction.
+var qrRS = utils.NewReedSolomonEncoder(utils.NewGaloisField(285, 256, 0))
+
+// qrAlphaNumeric holds the characters of the QR alphanumeric mode in the order
+// of their values.
+const qrAlphaNumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// QR code mode indicators.
+const (
+	qrModeNumeric          = 1
+	qrModeAlphaNumeric     = 2
+	qrModeStructuredAppend = 3
+	qrModeByte             = 4
+)
+
+// qrMaxStructuredSymbols is the maximum number of QR codes in a structured
+// append sequence.
+const qrMaxStructuredSymbols = 16
+
+// qrCode is a QR code of a fixed version. The symbols of a structured append
+// sequence also hold their position in the sequence and the parity of the
+// data of the whole sequence.
+type qrCode struct {
+	content string
+	version int
+	ecl     qr.ErrorCorrectionLevel
+	modules []bool
+
+	index, total int
+	parity       byte
+}
+
+// encodeQRVersion returns a QR code of the given version for code, or an error
+// if code does not fit in that version.
+func encodeQRVersion(code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, version int) (barcode.Barcode, error) {
+	if err := Validate(barcode.TypeQR, code); err != nil {
+		return nil, err
+	}
+	if ecl > qr.H {
+		return nil, fmt.Errorf("invalid QR code error correction level %d", ecl)
+	}
+
+	bits := new(utils.BitList)
+	if err := qrAddSegment(bits, code, mode, version); err != nil {
+		return nil, err
+	}
+
+	return qrRender(&qrCode{content: code, version: version, ecl: ecl}, bits)
+}
+
+// encodeQRStructured splits data into parts of at most maxPerSymbol bytes and
+// returns a QR code for each part. Each code starts with a structured append
+// header that holds its position in the sequence and the parity of data, so
+// that readers can reassemble data. Each code has the smallest version that
+// holds its part.
+func encodeQRStructured(data string, ecl qr.ErrorCorrectionLevel, maxPerSymbol int) ([]barcode.Barcode, error) {
+	if err := Validate(barcode.TypeQR, data); err != nil {
+		return nil, err
+	}
+	if ecl > qr.H {
+		return nil, fmt.Errorf("invalid QR code error correction level %d", ecl)
+	}
+	if maxPerSymbol < 1 {
+		return nil, fmt.Errorf("invalid QR code symbol capacity %d", maxPerSymbol)
+	}
+
+	parts := qrSplit(data, maxPerSymbol)
+	if len(parts) > qrMaxStructuredSymbols {
+		return nil, fmt.Errorf("QR code data requires %d symbols, but structured append supports %d", len(parts), qrMaxStructuredSymbols)
+	}
+
+	var parity byte
+	for i := 0; i < len(data); i++ {
+		parity ^= data[i]
+	}
+
+	codes := make([]barcode.Barcode, len(parts))
+	for i, part := range parts {
+		code := &qrCode{content: part, ecl: ecl, index: i, total: len(parts), parity: parity}
+		for code.version = 1; code.version <= 40; code.version++ {
+			bits := new(utils.BitList)
+			bits.AddBits(qrModeStructuredAppend, 4)
+			bits.AddBits(i, 4)
+			bits.AddBits(len(parts)-1, 4)
+			bits.AddByte(parity)
+			if err := qrAddSegment(bits, part, qr.Auto, code.version); err != nil {
+				return nil, err
+			}
+			if bits.Len() <= qrDataCodewords(code.version, ecl)*8 {
+				if _, err := qrRender(code, bits); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if code.version > 40 {
+			return nil, fmt.Errorf("QR code symbol %d of %d does not fit into version 40", i+1, len(parts))
+		}
+		codes[i] = code
+	}
+
+	return codes, nil
+}
+
+// qrSplit splits data into parts of at most max bytes. UTF-8 encoded
+// characters are not split, unless a single one is longer than max.
+func qrSplit(data string, max int) []string {
+	var parts []string
+	for len(data) > 0 {
+		n := max
+		if n >= len(data) {
+			n = len(data)
+		} else {
+			for n > 0 && !utf8.RuneStart(data[n]) {
+				n--
+			}
+			if n == 0 {
+				n = max
+			}
+		}
+		parts = append(parts, data[:n])
+		data = data[n:]
+	}
+
+	return parts
+}
+
+// qrAddSegment appends the mode indicator, the character count and the data
+// of code in the given mode to bits. The length of the character count
+// depends on the version.
+func qrAddSegment(bits *utils.BitList, code string, mode qr.Encoding, version int) error {
+	if version < 1 || version > 40 {
+		return fmt.Errorf("invalid QR code version %d, expected 1 to 40", version)
+	}
+
+	numeric, alphaNumeric := true, true
+	for i := 0; i < len(code); i++ {
+		numeric = numeric && isDigit(code[i])
+		alphaNumeric = alphaNumeric && strings.IndexByte(qrAlphaNumeric, code[i]) >= 0
+	}
+
+	switch {
+	case mode == qr.Auto && numeric, mode == qr.Numeric:
+		if !numeric {
+			return fmt.Errorf("%q can not be encoded as %s", code, qr.Numeric)
+		}
+		bits.AddBits(qrModeNumeric, 4)
+		bits.AddBits(len(code), qrCountBits(qrModeNumeric, version))
+		for i := 0; i < len(code); i += 3 {
+			group := code[i:]
+			if len(group) > 3 {
+				group = group[:3]
+			}
+			n := 0
+			for j := 0; j < len(group); j++ {
+				n = n*10 + int(group[j]-'0')
+			}
+			bits.AddBits(n, byte(len(group)*3+1))
+		}
+	case mode == qr.Auto && alphaNumeric, mode == qr.AlphaNumeric:
+		if !alphaNumeric {
+			return fmt.Errorf("%q can not be encoded as %s", code, qr.AlphaNumeric)
+		}
+		bits.AddBits(qrModeAlphaNumeric, 4)
+		bits.AddBits(len(code), qrCountBits(qrModeAlphaNumeric, version))
+		for i := 0; i < len(code); i += 2 {
+			n := strings.IndexByte(qrAlphaNumeric, code[i])
+			if i+1 < len(code) {
+				bits.AddBits(n*45+strings.IndexByte(qrAlphaNumeric, code[i+1]), 11)
+			} else {
+				bits.AddBits(n, 6)
+			}
+		}
+	case mode == qr.Auto, mode == qr.Unicode:
+		bits.AddBits(qrModeByte, 4)
+		bits.AddBits(len(code), qrCountBits(qrModeByte, version))
+		for i := 0; i < len(code); i++ {
+			bits.AddByte(code[i])
+		}
+	default:
+		return fmt.Errorf("unsupported QR code encoding %d", mode)
+	}
+
+	return nil
+}
+
+// qrCountBits returns the length of the character count of a segment in the
+// given mode for the given version.
+func qrCountBits(mode, version int) byte {
+	var lengths [3]byte
+	switch mode {
+	case qrModeNumeric:
+		lengths = [3]byte{10, 12, 14}
+	case qrModeAlphaNumeric:
+		lengths = [3]byte{9, 11, 13}
+	default:
+		lengths = [3]byte{8, 16, 16}
+	}
+
+	switch {
+	case version < 10:
+		return lengths[0]
+	case version < 27:
+		return lengths[1]
+	}
+
+	return lengths[2]
+}
+
+// qrSize returns the width and height of a QR code of the given version in
+// modules.
+func qrSize(version int) int {
+	return 17 + 4*version
+}
+
+// qrDataCodewords returns the number of data codewords of a QR code of the
+// given version and error correction level.
+func qrDataCodewords(version int, ecl qr.ErrorCorrectionLevel) int {
+	return qrRawCodewords(version) - qrECCPerBlock[ecl][version-1]*qrBlocks[ecl][version-1]
+}
+
+// qrRawCodewords returns the number of data and error correction codewords
+// of a QR code of the given version, which is the number of modules that are
+// not taken by function patterns divided by 8.
+func qrRawCodewords(version int) int {
+	modules := (16*version+128)*version + 64
+	if version >= 2 {
+		n := version/7 + 2
+		modules -= (25*n-10)*n - 55
+		if version >= 7 {
+			modules -= 36
+		}
+	}
+
+	return modules / 8
+}
+
+// qrAlignmentPositions returns the row and column coordinates of the centers
+// of the alignment patterns of a QR code of the given version.
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	positions := make([]int, n)
+	positions[0] = 6
+	for i, pos := n-1, qrSize(version)-7; i > 0; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+
+	return positions
+}
+
+// qrRender pads bits to the capacity of the version of code, adds the error
+// correction codewords and places them in the modules of code together with
+// the function patterns. The mask with the lowest penalty is applied.
+func qrRender(code *qrCode, bits *utils.BitList) (*qrCode, error) {
+	capacity := qrDataCodewords(code.version, code.ecl)
+	if bits.Len() > capacity*8 {
+		return nil, fmt.Errorf("QR code requires %d data bits, but version %d-%s holds %d", bits.Len(), code.version, code.ecl, capacity*8)
+	}
+
+	for i := 0; i < 4 && bits.Len() < capacity*8; i++ {
+		bits.AddBit(false)
+	}
+	for bits.Len()%8 != 0 {
+		bits.AddBit(false)
+	}
+	for pad := byte(0xEC); bits.Len() < capacity*8; pad ^= 0xEC ^ 0x11 {
+		bits.AddByte(pad)
+	}
+
+	codewords := qrInterleave(bits.GetBytes(), code.version, code.ecl)
+
+	size := qrSize(code.version)
+	code.modules = make([]bool, size*size)
+	function := make([]bool, size*size)
+	set := func(x, y int, dark bool) {
+		code.modules[y*size+x] = dark
+		function[y*size+x] = true
+	}
+	qrDrawFunctionPatterns(code.version, set)
+	qrDrawFormat(code.ecl, 0, size, set)
+
+	qrPlace(codewords, size, code.modules, function)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qrApplyMask(mask, size, code.modules, function)
+		qrDrawFormat(code.ecl, mask, size, set)
+		if penalty := qrPenalty(size, code.modules); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qrApplyMask(mask, size, code.modules, function)
+	}
+	qrApplyMask(best, size, code.modules, function)
+	qrDrawFormat(code.ecl, best, size, set)
+
+	return code, nil
+}
+
+// qrInterleave splits the data codewords into the blocks of the given version
+// and error correction level, calculates the error correction codewords of
+// each block and returns the interleaved codewords of all blocks.
+func qrInterleave(data []byte, version int, ecl qr.ErrorCorrectionLevel) []byte {
+	blocks := qrBlocks[ecl][version-1]
+	eccLen := qrECCPerBlock[ecl][version-1]
+	raw := qrRawCodewords(version)
+	short := blocks - raw%blocks
+	shortLen := raw/blocks - eccLen
+
+	dataBlocks := make([][]int, blocks)
+	eccBlocks := make([][]int, blocks)
+	for i, start := 0, 0; i < blocks; i++ {
+		n := shortLen
+		if i >= short {
+			n++
+		}
+		dataBlocks[i] = make([]int, n)
+		for j := range dataBlocks[i] {
+			dataBlocks[i][j] = int(data[start+j])
+		}
+		eccBlocks[i] = qrRS.Encode(dataBlocks[i], eccLen)
+		start += n
+	}
+
+	result := make([]byte, 0, raw)
+	for j := 0; j <= shortLen; j++ {
+		for i := range dataBlocks {
+			if j < len(dataBlocks[i]) {
+				result = append(result, byte(dataBlocks[i][j]))
+			}
+		}
+	}
+	for j := 0; j < eccLen; j++ {
+		for i := range eccBlocks {
+			result = append(result, byte(eccBlocks[i][j]))
+		}
+	}
+
+	return result
+}
+
+// qrDrawFunctionPatterns draws the timing, finder and alignment patterns and
+// the version information of a QR code of the given version with set.
+func qrDrawFunctionPatterns(version int, set func(x, y int, dark bool)) {
+	size := qrSize(version)
+
+	for i := 0; i < size; i++ {
+		set(6, i, i%2 == 0)
+		set(i, 6, i%2 == 0)
+	}
+
+	for _, c := range [3][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || x >= size || y < 0 || y >= size {
+					continue
+				}
+				dist := abs(dx)
+				if abs(dy) > dist {
+					dist = abs(dy)
+				}
+				set(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	positions := qrAlignmentPositions(version)
+	last := len(positions) - 1
+	for i, cx := range positions {
+		for j, cy := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					set(cx+dx, cy+dy, abs(dx) == 2 || abs(dy) == 2 || dx == 0 && dy == 0)
+				}
+			}
+		}
+	}
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ rem>>11*0x1F25
+		}
+		info := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := info>>uint(i)&1 != 0
+			a, b := size-11+i%3, i/3
+			set(a, b, dark)
+			set(b, a, dark)
+		}
+	}
+}
+
+// qrDrawFormat draws both copies of the format information for the given
+// error correction level and mask, and the dark module, with set.
+func qrDrawFormat(ecl qr.ErrorCorrectionLevel, mask, size int, set func(x, y int, dark bool)) {
+	data := qrFormatLevel[ecl]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ rem>>9*0x537
+	}
+	info := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return info>>uint(i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		set(8, i, bit(i))
+	}
+	set(8, 7, bit(6))
+	set(8, 8, bit(7))
+	set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		set(size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		set(8, size-15+i, bit(i))
+	}
+	set(8, size-8, true)
+}
+
+// qrPlace places the bits of the codewords in the modules that are not taken
+// by function patterns, in pairs of columns from the bottom right corner in
+// alternating upward and downward direction. Remaining modules stay light.
+func qrPlace(codewords []byte, size int, modules, function []bool) {
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if function[y*size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				modules[y*size+x] = codewords[i/8]&(0x80>>uint(i%8)) != 0
+				i++
+			}
+		}
+	}
+}
+
+// qrApplyMask inverts the modules that are not taken by function patterns
+// according to the given mask pattern. Applying a mask twice undoes it.
+func qrApplyMask(mask, size int, modules, function []bool) {
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !function[y*size+x] {
+				modules[y*size+x] = !modules[y*size+x]
+			}
+		}
+	}
+}
+
+// qrPenalty returns the penalty score of the modules, which is used to pick
+// the mask pattern that is easiest to read. It adds the scores of runs of
+// five or more modules of the same color, 2x2 blocks of the same color,
+// patterns that look like finder patterns and an unbalanced ratio of dark
+// modules.
+func qrPenalty(size int, modules []bool) int {
+	penalty := 0
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			x, y = y, x
+		}
+		return modules[y*size+x]
+	}
+
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < size; y++ {
+			run := 0
+			for x := 0; x < size; x++ {
+				if x > 0 && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					penalty += 3
+				} else if run > 5 {
+					penalty++
+				}
+
+				if x+len(finder) > size {
+					continue
+				}
+				match := true
+				for i, dark := range finder {
+					if at(x+i, y, transpose) != dark {
+						match = false
+						break
+					}
+				}
+				if match && (qrLightRun(x-4, x, y, size, at, transpose) || qrLightRun(x+7, x+11, y, size, at, transpose)) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := modules[y*size+x]
+			if c {
+				dark++
+			}
+			if x < size-1 && y < size-1 && c == modules[y*size+x+1] && c == modules[(y+1)*size+x] && c == modules[(y+1)*size+x+1] {
+				penalty += 3
+			}
+		}
+	}
+
+	total := size * size
+	deviation := abs(dark*20 - total*10)
+	penalty += ((deviation+total-1)/total - 1) * 10
+
+	return penalty
+}
+
+// qrLightRun reports whether the modules from x0 up to x1 in row y are light.
+// Modules outside the symbol belong to the quiet zone and are light.
+func qrLightRun(x0, x1, y, size int, at func(x, y int, transpose bool) bool, transpose bool) bool {
+	for x := x0; x < x1; x++ {
+		if x >= 0 && x < size && at(x, y, transpose) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// key returns the key of the code in the registry, which includes its
+// version and error correction level and, for symbols of a structured append
+// sequence, their position and parity.
+func (c *qrCode) key() string {
+	if c.total > 0 {
+		return fmt.Sprintf("%s %d-%s %d/%d %02x %s", barcode.TypeQR, c.version, c.ecl, c.index+1, c.total, c.parity, c.content)
+	}
+
+	return fmt.Sprintf("%s %d-%s %s", barcode.TypeQR, c.version, c.ecl, c.content)
+}
+
+// StructuredAppend returns the zero-based position of the code in its
+// structured append sequence, the number of codes in the sequence and the
+// parity of the data of the sequence. total is 0 if the code is not part of a
+// sequence.
+func (c *qrCode) StructuredAppend() (index, total int, parity byte) {
+	return c.index, c.total, c.parity
+}
+
+// Content returns the encoded content.
+func (c *qrCode) Content() string {
+	return c.content
+}
+
+// Metadata returns the kind of the code.
+func (c *qrCode) Metadata() barcode.Metadata {
+	return barcode.Metadata{CodeKind: barcode.TypeQR, Dimensions: 2}
+}
+
+// ColorModel returns the color model of the code.
+func (c *qrCode) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+// Bounds returns the size of the symbol in modules.
+func (c *qrCode) Bounds() image.Rectangle {
+	size := qrSize(c.version)
+	return image.Rect(0, 0, size, size)
+}
+
+// At returns the color of the module at x, y.
+func (c *qrCode) At(x, y int) color.Color {
+	if image.Pt(x, y).In(c.Bounds()) && c.modules[y*qrSize(c.version)+x] {
+		return color.Black
+	}
+
+	return color.White
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+)
+
+// quietZone is a barcode that is surrounded by a quiet zone of light modules.
+// One-dimensional barcodes are only padded on the left and right.
+type quietZone struct {
+	barcode.Barcode
+	modules int
+}
+
+// withQuietZone returns bcode surrounded by a quiet zone of the given number
+// of modules, or bcode itself if modules is not positive.
+func withQuietZone(bcode barcode.Barcode, modules int) barcode.Barcode {
+	if modules <= 0 {
+		return bcode
+	}
+
+	return &quietZone{Barcode: bcode, modules: modules}
+}
+
+// padding returns the horizontal and vertical size of the quiet zone.
+func (q *quietZone) padding() (int, int) {
+	if q.Metadata().Dimensions == 1 {
+		return q.modules, 0
+	}
+
+	return q.modules, q.modules
+}
+
+// Bounds returns the bounds of the barcode including its quiet zone.
+func (q *quietZone) Bounds() image.Rectangle {
+	bounds := q.Barcode.Bounds()
+	px, py := q.padding()
+
+	return image.Rect(0, 0, bounds.Dx()+2*px, bounds.Dy()+2*py)
+}
+
+// At returns the color of the module at x, y, which is light within the quiet
+// zone.
+func (q *quietZone) At(x, y int) color.Color {
+	bounds := q.Barcode.Bounds()
+	px, py := q.padding()
+	pt := image.Pt(x-px, y-py).Add(bounds.Min)
+
+	if !pt.In(bounds) {
+		return quietZoneWhite{}
+	}
+
+	return q.Barcode.At(pt.X, pt.Y)
+}
+
+// quietZoneWhite is the color of the modules of the quiet zone. It is white
+// like the light modules of the barcode but of its own type, so that the quiet
+// zone can be told apart from them after the barcode has been scaled and
+// rotated. See SetQuietZoneColor().
+type quietZoneWhite struct{}
+
+// RGBA returns opaque white.
+func (quietZoneWhite) RGBA() (r, g, b, a uint32) {
+	return 0xffff, 0xffff, 0xffff, 0xffff
+}
+
+// isQuietZone reports whether the module of the barcode at x, y belongs to
+// its quiet zone.
+func isQuietZone(bcode barcode.Barcode, x, y int) bool {
+	_, ok := bcode.At(x, y).(quietZoneWhite)
+	return ok
+}
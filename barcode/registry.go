@@ -0,0 +1,1233 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/aztec"
+	"github.com/boombuler/barcode/codabar"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/code39"
+	"github.com/boombuler/barcode/code93"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+	"github.com/boombuler/barcode/twooffive"
+	"github.com/jung-kurt/gofpdf/v2"
+	"github.com/ruudk/golang-pdf417"
+)
+
+// ErrBarcodeNotFound is returned when a barcode is put on the page with a key
+// that has not been registered.
+var ErrBarcodeNotFound = errors.New("Barcode not found")
+
+// ErrDistorted is set on the PDF in strict mode when a two-dimensional barcode
+// would be put on the page with an aspect ratio that distorts it. See
+// SetStrictAspect().
+var ErrDistorted = errors.New("Barcode would be distorted")
+
+// ErrLowContrast is set on the PDF when a QR code would be put on the page in
+// colors whose contrast is too low to scan it reliably. See SetQRContrast().
+var ErrLowContrast = errors.New("Barcode colors have too little contrast")
+
+// ErrInvalidSize is set on the PDF when a barcode is put on the page with a
+// negative, infinite or NaN width or height.
+var ErrInvalidSize = errors.New("Invalid barcode size")
+
+// Registry holds the barcodes registered for a single PDF document.
+//
+// The package-level functions share one registry for the life of the process,
+// so barcodes registered for one document stay in memory after the document
+// has been written. A Registry obtained from New() only references the barcodes
+// of its own document and is garbage collected together with it once neither
+// is referenced anymore. This makes it the preferred choice for long-running
+// processes that generate many documents.
+//
+// A Registry may be used from several goroutines concurrently.
+type Registry struct {
+	pdf      barcodePdf
+	barcodes *barcodeCache
+}
+
+// barcodeCache maps the keys returned by the Register functions to the
+// unscaled barcodes and, for barcodes registered with RegisterWithOptions(),
+// to their options. It also records the pixel size of the image of each
+// barcode that was last put on a page. The maps are guarded by a read/write mutex so that
+// barcodes can be registered and put on pages from several goroutines
+// concurrently.
+type barcodeCache struct {
+	sync.RWMutex
+	cache   map[string]barcode.Barcode
+	options map[string]BarcodeOptions
+	scales  map[string][2]int
+	encoded map[string]encoding
+}
+
+// encoding holds the barcodes that a call of a Register function was encoded
+// to and the keys they are registered with.
+type encoding struct {
+	keys     []string
+	barcodes []barcode.Barcode
+}
+
+// New returns a new Registry for the given PDF document.
+func New(pdf barcodePdf) *Registry {
+	return &Registry{
+		pdf:      pdf,
+		barcodes: &barcodeCache{cache: make(map[string]barcode.Barcode)},
+	}
+}
+
+// lookup returns the unscaled barcode registered with the given key.
+func (r *Registry) lookup(code string) (barcode.Barcode, bool) {
+	r.barcodes.RLock()
+	unscaled, ok := r.barcodes.cache[code]
+	r.barcodes.RUnlock()
+
+	return unscaled, ok
+}
+
+// printBarcode internally prints the scaled or unscaled barcode to the PDF. Used by both
+// Barcode() and BarcodeUnscalable(). The scaled barcode is rotated by degrees,
+// which must be 0, 90, 180 or 270. link and linkStr are passed on to
+// Fpdf.Image(). The barcode is printed with the options it has been registered
+// with.
+func (r *Registry) printBarcode(code string, x, y float64, w, h *float64, flow bool, degrees int, link int, linkStr string) error {
+	return r.printBarcodeWithOptions(code, x, y, w, h, flow, degrees, link, linkStr, r.lookupOptions(code))
+}
+
+// printBarcodeWithOptions works like printBarcode() but prints the barcode
+// with the given options. Their rotation is added to degrees.
+func (r *Registry) printBarcodeWithOptions(code string, x, y float64, w, h *float64, flow bool, degrees int, link int, linkStr string, bopts BarcodeOptions) error {
+	if !validRotation(degrees) {
+		return fmt.Errorf("unsupported barcode rotation of %d degrees", degrees)
+	}
+	degrees = (degrees + bopts.Rotation) % 360
+
+	registered, ok := r.lookup(code)
+
+	if !ok {
+		return ErrBarcodeNotFound
+	}
+
+	opts := bopts.apply(currentOptions())
+	unscaled := withQuietZone(registered, opts.quietZone)
+
+	scaleToWidthF := float64(unscaled.Bounds().Dx())
+	scaleToHeightF := float64(unscaled.Bounds().Dy())
+
+	if w != nil {
+		scaleToWidthF = *w
+	}
+	if h != nil {
+		scaleToHeightF = *h
+	}
+	if err := validateSize(scaleToWidthF, scaleToHeightF); err != nil {
+		return err
+	}
+
+	scaleToWidthF, scaleToHeightF = naturalSize(r.pdf, unscaled, scaleToWidthF, scaleToHeightF)
+
+	if opts.strictAspect {
+		if err := checkAspect(unscaled, scaleToWidthF, scaleToHeightF); err != nil {
+			return err
+		}
+	}
+
+	wPx, hPx := scaledSize(r.pdf, unscaled, scaleToWidthF, scaleToHeightF, opts.dpi)
+	bname := uniqueBarcodeName(code, x, y, scaleToWidthF, scaleToHeightF, opts)
+	if opts.nameFunc != nil {
+		bname = opts.nameFunc(code, x, y, scaleToWidthF, scaleToHeightF, opts.dpi)
+	} else if opts.deterministic {
+		bname = deterministicBarcodeName(code, wPx, hPx, opts)
+	} else if opts.reuse {
+		bname = sharedBarcodeName(code, scaleToWidthF, scaleToHeightF, opts)
+	}
+	if degrees != 0 {
+		bname += "-r" + strconv.Itoa(degrees)
+	}
+	info := r.pdf.GetImageInfo(bname)
+
+	if info == nil {
+		img, err := scaledImage(r.pdf, registered, unscaled, scaleToWidthF, scaleToHeightF, degrees, opts)
+		if err != nil {
+			return err
+		}
+
+		err = registerScaledBarcode(r.pdf, bname, img, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if degrees == 90 || degrees == 270 {
+		scaleToWidthF, scaleToHeightF = scaleToHeightF, scaleToWidthF
+	}
+
+	r.pdf.Image(bname, x, y, scaleToWidthF, scaleToHeightF, flow, imageType(opts.format), link, linkStr)
+
+	// The PDF does not register the image once it has an error, in which
+	// case the barcode has not been put on the page.
+	if r.pdf.GetImageInfo(bname) != nil {
+		r.barcodes.Lock()
+		if r.barcodes.scales == nil {
+			r.barcodes.scales = make(map[string][2]int)
+		}
+		r.barcodes.scales[code] = [2]int{wPx, hPx}
+		r.barcodes.Unlock()
+	}
+
+	return nil
+}
+
+// setError reports err according to the error policy unless it is nil. See
+// SetErrorPolicy().
+func (r *Registry) setError(err error) {
+	if err == nil {
+		return
+	}
+
+	switch currentErrorPolicy() {
+	case ErrorPolicyPanic:
+		panic(err)
+	case ErrorPolicyReturn:
+	default:
+		r.pdf.SetError(err)
+	}
+}
+
+// BarcodeHere puts a barcode of this registry at the current position. See
+// the package-level BarcodeHere() for details.
+func (r *Registry) BarcodeHere(code string, w, h float64, flow bool) {
+	pdf, ok := r.pdf.(barcodeCursorPdf)
+	if !ok {
+		r.setError(errors.New("PDF does not support putting barcodes at the current position"))
+		return
+	}
+
+	r.Barcode(code, pdf.GetX(), pdf.GetY(), w, h, flow)
+}
+
+// BarcodeUnscalable puts a barcode of this registry in the current page. See
+// the package-level BarcodeUnscalable() for details.
+func (r *Registry) BarcodeUnscalable(code string, x, y float64, w, h *float64, flow bool) {
+	r.setError(r.printBarcode(code, x, y, w, h, flow, 0, 0, ""))
+}
+
+// Barcode puts a barcode of this registry in the current page. See the
+// package-level Barcode() for details.
+func (r *Registry) Barcode(code string, x, y, w, h float64, flow bool) {
+	r.setError(r.BarcodeE(code, x, y, w, h, flow))
+}
+
+// BarcodeE puts a barcode of this registry in the current page and returns
+// any error instead of setting it on the PDF. See the package-level
+// BarcodeE() for details.
+func (r *Registry) BarcodeE(code string, x, y, w, h float64, flow bool) error {
+	return r.BarcodeWithOptionsE(code, x, y, w, h, flow, BarcodeOptions{})
+}
+
+// BarcodeRect puts a barcode of this registry in the current page and returns
+// the size it takes up. See the package-level BarcodeRect() for details.
+func (r *Registry) BarcodeRect(code string, x, y, w, h float64, flow bool) (gofpdf.SizeType, error) {
+	if err := r.BarcodeE(code, x, y, w, h, flow); err != nil {
+		return gofpdf.SizeType{}, err
+	}
+
+	return r.placedSize(code, w, h), nil
+}
+
+// MeasureBarcode returns the size of the area a barcode of this registry
+// would take up on the page without putting it there. See the package-level
+// MeasureBarcode() for details.
+func (r *Registry) MeasureBarcode(code string, w, h float64) (gofpdf.SizeType, error) {
+	bopts := r.lookupOptions(code)
+	barW, barH, err := r.barSize(code, w, h, bopts)
+	if err != nil {
+		return gofpdf.SizeType{}, err
+	}
+
+	opts := bopts.apply(currentOptions())
+	if opts.strictAspect {
+		registered, _ := r.lookup(code)
+		if err := checkAspect(withQuietZone(registered, opts.quietZone), barW, barH); err != nil {
+			return gofpdf.SizeType{}, err
+		}
+	}
+
+	return r.placedSize(code, w, h), nil
+}
+
+// checkAspect returns ErrDistorted if the two-dimensional barcode unscaled
+// would be distorted by more than aspectTolerance when it is put with the
+// given width and height.
+func checkAspect(unscaled barcode.Barcode, w, h float64) error {
+	if unscaled.Bounds().Dy() <= 1 {
+		return nil
+	}
+
+	native := float64(unscaled.Bounds().Dx()) / float64(unscaled.Bounds().Dy())
+	requested := w / h
+	if math.Abs(requested/native-1) > aspectTolerance {
+		return fmt.Errorf("%w: aspect ratio %.2f requested for %.2f", ErrDistorted, requested, native)
+	}
+
+	return nil
+}
+
+// placedSize returns the size of the area a barcode put with BarcodeE() and
+// the given width and height takes up on the page. The barcode must have been
+// registered.
+func (r *Registry) placedSize(code string, w, h float64) gofpdf.SizeType {
+	bopts := r.lookupOptions(code)
+	w, barHeight, _ := r.barSize(code, w, h, bopts)
+
+	if bopts.Rotation == 90 || bopts.Rotation == 270 {
+		w, barHeight = barHeight, w
+	}
+	if bopts.Caption != "" {
+		return gofpdf.SizeType{Wd: w, Ht: barHeight + bopts.CaptionHeight}
+	}
+
+	return gofpdf.SizeType{Wd: w, Ht: barHeight}
+}
+
+// BarcodeByModule puts a barcode of this registry in the current page with
+// the given module width. See the package-level BarcodeByModule() for details.
+func (r *Registry) BarcodeByModule(code string, x, y, moduleWidth, height float64, flow bool) {
+	r.setError(r.barcodeByModule(code, x, y, moduleWidth, height, flow))
+}
+
+// barcodeByModule puts the barcode with a resolution at which a module is a
+// whole number of pixels wide, which makes the image scale exactly to the
+// width of modules × moduleWidth.
+func (r *Registry) barcodeByModule(code string, x, y, moduleWidth, height float64, flow bool) error {
+	if err := validateSize(moduleWidth, height); err != nil {
+		return err
+	}
+	if moduleWidth == 0 {
+		return fmt.Errorf("%w: module width must be positive", ErrInvalidSize)
+	}
+
+	registered, ok := r.lookup(code)
+	if !ok {
+		return ErrBarcodeNotFound
+	}
+
+	opts := r.options(code)
+	modules := float64(withQuietZone(registered, opts.quietZone).Bounds().Dx())
+	pixels := math.Max(1, math.Round(convertToDpi(r.pdf, moduleWidth, opts.dpi)))
+
+	// The resolution is raised by a tiny fraction so that rounding errors do
+	// not truncate the number of pixels per module in scaledSize().
+	dpi := pixels / convertToDpi(r.pdf, moduleWidth, 1) * (1 + 1e-9)
+
+	return r.BarcodeWithOptionsE(code, x, y, modules*moduleWidth, height, flow, BarcodeOptions{DPI: dpi})
+}
+
+// BarcodePx puts a barcode of this registry in the current page with an image
+// of the given size in pixels. See the package-level BarcodePx() for details.
+func (r *Registry) BarcodePx(code string, x, y float64, widthPx, heightPx int, flow bool) {
+	r.setError(r.barcodePx(code, x, y, widthPx, heightPx, flow))
+}
+
+// barcodePx registers the image rendered at the given size in pixels unless
+// it has been registered before and puts it on the page.
+func (r *Registry) barcodePx(code string, x, y float64, widthPx, heightPx int, flow bool) error {
+	img, err := r.Render(code, widthPx, heightPx)
+	if err != nil {
+		return err
+	}
+
+	opts := r.options(code)
+	bounds := img.Bounds()
+	bname := sharedBarcodeName(code, float64(bounds.Dx()), float64(bounds.Dy()), opts) + "-px"
+	if r.pdf.GetImageInfo(bname) == nil {
+		if err := registerScaledBarcode(r.pdf, bname, img, opts); err != nil {
+			return err
+		}
+	}
+
+	w := convertFromDpi(r.pdf, float64(bounds.Dx()), opts.dpi)
+	h := convertFromDpi(r.pdf, float64(bounds.Dy()), opts.dpi)
+	r.pdf.Image(bname, x, y, w, h, flow, imageType(opts.format), 0, "")
+
+	return nil
+}
+
+// BarcodeLink puts a barcode of this registry in the current page as a link.
+// See the package-level BarcodeLink() for details.
+func (r *Registry) BarcodeLink(code string, x, y, w, h float64, flow bool, link int, linkStr string) {
+	r.setError(r.printBarcode(code, x, y, &w, &h, flow, 0, link, linkStr))
+}
+
+// BarcodeWithText puts a barcode of this registry in the current page and
+// prints its content beneath it. The PDF of the registry must support printing
+// text. See the package-level BarcodeWithText() for details.
+func (r *Registry) BarcodeWithText(code string, x, y, w, h float64, flow bool, textHeight float64) {
+	pdf, ok := r.pdf.(barcodeTextPdf)
+	if !ok {
+		r.setError(errors.New("PDF does not support printing the barcode text"))
+		return
+	}
+
+	unscaled, ok := r.lookup(code)
+	if !ok {
+		r.setError(ErrBarcodeNotFound)
+		return
+	}
+
+	if textHeight <= 0 || textHeight >= h {
+		r.setError(fmt.Errorf("text height %g must be between 0 and the barcode height %g", textHeight, h))
+		return
+	}
+
+	barHeight := h - textHeight
+	err := r.printBarcode(code, x, y, &w, &barHeight, flow, 0, 0, "")
+	if err != nil {
+		r.setError(err)
+		return
+	}
+
+	printCaption(pdf, unscaled.Content(), x, y+barHeight, w, textHeight, flow)
+}
+
+// printCaption prints text centered in the box given by x, y, w and h. When
+// flow is true the text is printed at the current vertical position instead,
+// which is then moved beneath the text.
+func printCaption(pdf barcodeTextPdf, text string, x, y, w, h float64, flow bool) {
+	if flow {
+		_, y = pdf.GetXY()
+		pdf.SetXY(x, y)
+		pdf.CellFormat(w, h, text, "", 2, "C", false, 0, "")
+		return
+	}
+
+	curX, curY := pdf.GetXY()
+	pdf.SetXY(x, y)
+	pdf.CellFormat(w, h, text, "", 0, "C", false, 0, "")
+	pdf.SetXY(curX, curY)
+}
+
+// BarcodeCell puts a barcode of this registry into a cell at the current
+// position. The PDF of the registry must support cells. See the package-level
+// BarcodeCell() for details.
+func (r *Registry) BarcodeCell(code string, w, h float64, border string, ln int, align string) {
+	pdf, ok := r.pdf.(barcodeCellPdf)
+	if !ok {
+		r.setError(errors.New("PDF does not support barcode cells"))
+		return
+	}
+
+	registered, ok := r.lookup(code)
+	if !ok {
+		r.setError(ErrBarcodeNotFound)
+		return
+	}
+
+	x, y := pdf.GetXY()
+	margin := pdf.GetCellMargin()
+	bx, by := x+margin, y+margin
+	bw, bh := w-2*margin, h-2*margin
+
+	unscaled := withQuietZone(registered, r.options(code).quietZone)
+	if unscaled.Bounds().Dy() > 1 {
+		dx, dy := float64(unscaled.Bounds().Dx()), float64(unscaled.Bounds().Dy())
+		fw, fh := bw, bw*dy/dx
+		if fh > bh {
+			fw, fh = bh*dx/dy, bh
+		}
+		switch {
+		case strings.Contains(align, "C"):
+			bx += (bw - fw) / 2
+		case strings.Contains(align, "R"):
+			bx += bw - fw
+		}
+		switch {
+		case strings.Contains(align, "T"):
+		case strings.Contains(align, "B"):
+			by += bh - fh
+		default:
+			by += (bh - fh) / 2
+		}
+		bw, bh = fw, fh
+	}
+
+	if err := r.printBarcode(code, bx, by, &bw, &bh, false, 0, 0, ""); err != nil {
+		r.setError(err)
+		return
+	}
+
+	pdf.SetXY(x, y)
+	pdf.CellFormat(w, h, "", border, ln, "", false, 0, "")
+}
+
+// GetUnscaledBarcodeDimensions returns the width and height of the
+// unscaled barcode associated with the given code.
+func (r *Registry) GetUnscaledBarcodeDimensions(code string) (w, h float64) {
+	unscaled, ok := r.lookup(code)
+
+	if !ok {
+		r.setError(ErrBarcodeNotFound)
+		return
+	}
+
+	unscaled = withQuietZone(unscaled, r.options(code).quietZone)
+
+	return convertFromDpi(r.pdf, float64(unscaled.Bounds().Dx()), defaultDPI),
+		convertFromDpi(r.pdf, float64(unscaled.Bounds().Dy()), defaultDPI)
+}
+
+// GetBarcodeDimensions returns the width and height in modules of the
+// unscaled barcode associated with the given code. See the package-level
+// GetBarcodeDimensions() for details.
+func (r *Registry) GetBarcodeDimensions(code string) (width, height int, ok bool) {
+	unscaled, ok := r.lookup(code)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return unscaled.Bounds().Dx(), unscaled.Bounds().Dy(), true
+}
+
+// LastScale returns the size in pixels of the image of the barcode associated
+// with the given key when it was last put on a page. See the package-level
+// LastScale() for details.
+func (r *Registry) LastScale(key string) (wPx, hPx int, ok bool) {
+	r.barcodes.RLock()
+	scale, ok := r.barcodes.scales[key]
+	r.barcodes.RUnlock()
+
+	return scale[0], scale[1], ok
+}
+
+// Encode writes the image of the barcode associated with the given key to w.
+// See the package-level Encode() for details.
+func (r *Registry) Encode(w io.Writer, key string, widthDoc, heightDoc float64, dpi float64, format string) error {
+	registered, ok := r.lookup(key)
+	if !ok {
+		return ErrBarcodeNotFound
+	}
+
+	opts := r.options(key)
+	if dpi > 0 {
+		opts.dpi = dpi
+	}
+	if format != "" {
+		opts.format = format
+	}
+	unscaled := withQuietZone(registered, opts.quietZone)
+
+	if err := validateSize(widthDoc, heightDoc); err != nil {
+		return err
+	}
+	widthDoc, heightDoc = naturalSize(points{}, unscaled, widthDoc, heightDoc)
+	img, err := scaledImage(points{}, registered, unscaled, widthDoc, heightDoc, 0, opts)
+	if err != nil {
+		return err
+	}
+
+	return encodeImage(w, img, opts)
+}
+
+// Render returns the image of the barcode associated with the given key at the
+// given size in pixels. See the package-level Render() for details.
+func (r *Registry) Render(key string, widthPx, heightPx int) (img image.Image, err error) {
+	defer recoverEncodeError(&err)
+	registered, ok := r.lookup(key)
+	if !ok {
+		return nil, ErrBarcodeNotFound
+	}
+	if widthPx < 0 || heightPx < 0 {
+		return nil, fmt.Errorf("%w: %d x %d pixels, width and height must not be negative", ErrInvalidSize, widthPx, heightPx)
+	}
+
+	opts := r.options(key)
+	if err := checkContrast(registered, opts); err != nil {
+		return nil, err
+	}
+	unscaled := withQuietZone(registered, opts.quietZone)
+	dx := unscaled.Bounds().Dx()
+	dy := unscaled.Bounds().Dy()
+
+	switch {
+	case widthPx == 0 && heightPx == 0:
+		widthPx, heightPx = dx, dy
+	case widthPx == 0:
+		widthPx = int(math.Round(float64(heightPx) * float64(dx) / float64(dy)))
+	case heightPx == 0:
+		heightPx = int(math.Round(float64(widthPx) * float64(dy) / float64(dx)))
+	}
+
+	bcode, err := scaleBarcode(unscaled, widthPx, heightPx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if custom, ok := registered.(renderer); ok {
+		return custom.render(bcode, unscaled, opts), nil
+	}
+
+	return renderImage(bcode, opts), nil
+}
+
+// Get returns the unscaled barcode registered with the given key. See the
+// package-level Get() for details.
+func (r *Registry) Get(key string) (bcode barcode.Barcode, ok bool) {
+	return r.lookup(key)
+}
+
+// Keys returns the keys of all barcodes of this registry in sorted order. See
+// the package-level Keys() for details.
+func (r *Registry) Keys() []string {
+	r.barcodes.RLock()
+	keys := make([]string, 0, len(r.barcodes.cache))
+	for key := range r.barcodes.cache {
+		keys = append(keys, key)
+	}
+	r.barcodes.RUnlock()
+
+	sort.Strings(keys)
+	return keys
+}
+
+// GetMetadata returns the code kind and content of the barcode associated
+// with the given key. See the package-level GetMetadata() for details.
+func (r *Registry) GetMetadata(key string) (kind string, content string, ok bool) {
+	bcode, ok := r.lookup(key)
+	if !ok {
+		return "", "", false
+	}
+
+	return bcode.Metadata().CodeKind, bcode.Content(), true
+}
+
+// Register registers a barcode to the registry but does not put it on the
+// page. Use Barcode() with the same code to put the barcode on the PDF page.
+func (r *Registry) Register(bcode barcode.Barcode) string {
+	r.barcodes.Lock()
+	if len(r.barcodes.cache) == 0 {
+		r.barcodes.cache = make(map[string]barcode.Barcode)
+	}
+
+	key := barcodeKey(bcode)
+	r.barcodes.cache[key] = bcode
+	r.barcodes.Unlock()
+
+	return key
+}
+
+// Unregister removes the barcode with the given key from the registry. Images
+// of the barcode that have already been added to the PDF are not affected.
+func (r *Registry) Unregister(code string) {
+	r.barcodes.Lock()
+	delete(r.barcodes.cache, code)
+	delete(r.barcodes.options, code)
+	delete(r.barcodes.scales, code)
+	for request, enc := range r.barcodes.encoded {
+		for _, key := range enc.keys {
+			if key == code {
+				delete(r.barcodes.encoded, request)
+				break
+			}
+		}
+	}
+	r.barcodes.Unlock()
+}
+
+// Clear removes all barcodes from the registry, releasing the memory they
+// hold. Images of the barcodes that have already been added to the PDF are
+// not affected.
+func (r *Registry) Clear() {
+	r.barcodes.Lock()
+	r.barcodes.cache = make(map[string]barcode.Barcode)
+	r.barcodes.options = nil
+	r.barcodes.scales = nil
+	r.barcodes.encoded = nil
+	r.barcodes.Unlock()
+}
+
+// RegisterAztec registers a barcode of type Aztec. See the package-level
+// RegisterAztec() for details.
+func (r *Registry) RegisterAztec(code string, minECCPercent int, userSpecifiedLayers int) string {
+	return r.keyOrSetError(r.RegisterAztecE(code, minECCPercent, userSpecifiedLayers))
+}
+
+// RegisterAztecE registers a barcode of type Aztec and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterAztecE(code string, minECCPercent int, userSpecifiedLayers int) (key string, err error) {
+	defer recoverEncodeError(&err)
+	if err := Validate(barcode.TypeAztec, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("Aztec", code, minECCPercent, userSpecifiedLayers), func() (barcode.Barcode, error) {
+		return aztec.Encode([]byte(code), minECCPercent, userSpecifiedLayers)
+	})
+}
+
+// RegisterCodabar registers a barcode of type Codabar. See the package-level
+// RegisterCodabar() for details.
+func (r *Registry) RegisterCodabar(code string) string {
+	return r.keyOrSetError(r.RegisterCodabarE(code))
+}
+
+// RegisterCodabarE registers a barcode of type Codabar and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterCodabarE(code string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	if err := Validate(barcode.TypeCodabar, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("Codabar", code), func() (barcode.Barcode, error) {
+		return codabar.Encode(code)
+	})
+}
+
+// RegisterCodabarWithGuards registers a barcode of type Codabar with the given
+// start and stop characters. See the package-level
+// RegisterCodabarWithGuards() for details.
+func (r *Registry) RegisterCodabarWithGuards(code string, start, stop byte) string {
+	return r.keyOrSetError(r.RegisterCodabarWithGuardsE(code, start, stop))
+}
+
+// RegisterCodabarWithGuardsE registers a barcode of type Codabar with the
+// given start and stop characters and returns any error instead of setting it
+// on the PDF.
+func (r *Registry) RegisterCodabarWithGuardsE(code string, start, stop byte) (string, error) {
+	if err := validateCodabarGuard("start", start); err != nil {
+		return "", err
+	}
+	if err := validateCodabarGuard("stop", stop); err != nil {
+		return "", err
+	}
+
+	return r.RegisterCodabarE(string(start) + code + string(stop))
+}
+
+// RegisterCode11 registers a barcode of type Code 11. See the package-level
+// RegisterCode11() for details.
+func (r *Registry) RegisterCode11(code string, checkDigits int) string {
+	return r.keyOrSetError(r.RegisterCode11E(code, checkDigits))
+}
+
+// RegisterCode11E registers a barcode of type Code 11 and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterCode11E(code string, checkDigits int) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("Code11", code, checkDigits), func() (barcode.Barcode, error) {
+		return encodeCode11(code, checkDigits)
+	})
+}
+
+// RegisterCode128 registers a barcode of type Code128. See the package-level
+// RegisterCode128() for details.
+func (r *Registry) RegisterCode128(code string) string {
+	return r.keyOrSetError(r.RegisterCode128E(code))
+}
+
+// RegisterCode128E registers a barcode of type Code128 and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterCode128E(code string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	if err := Validate(barcode.TypeCode128, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("Code128", code), func() (barcode.Barcode, error) {
+		return code128.Encode(code)
+	})
+}
+
+// RegisterCode39 registers a barcode of type Code39. See the package-level
+// RegisterCode39() for details.
+func (r *Registry) RegisterCode39(code string, includeChecksum, fullASCIIMode bool) string {
+	return r.keyOrSetError(r.RegisterCode39E(code, includeChecksum, fullASCIIMode))
+}
+
+// RegisterCode39E registers a barcode of type Code39 and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterCode39E(code string, includeChecksum, fullASCIIMode bool) (key string, err error) {
+	defer recoverEncodeError(&err)
+	kind := barcode.TypeCode39
+	if fullASCIIMode {
+		kind = TypeCode39FullASCII
+	}
+	if err := Validate(kind, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("Code39", code, includeChecksum, fullASCIIMode), func() (barcode.Barcode, error) {
+		return code39.Encode(code, includeChecksum, fullASCIIMode)
+	})
+}
+
+// RegisterCode93 registers a barcode of type Code93. See the package-level
+// RegisterCode93() for details.
+func (r *Registry) RegisterCode93(code string, includeChecksum, fullASCIIMode bool) string {
+	return r.keyOrSetError(r.RegisterCode93E(code, includeChecksum, fullASCIIMode))
+}
+
+// RegisterCode93E registers a barcode of type Code93 and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterCode93E(code string, includeChecksum, fullASCIIMode bool) (key string, err error) {
+	defer recoverEncodeError(&err)
+	kind := barcode.TypeCode93
+	if fullASCIIMode {
+		kind = TypeCode93FullASCII
+	}
+	if err := Validate(kind, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("Code93", code, includeChecksum, fullASCIIMode), func() (barcode.Barcode, error) {
+		return code93.Encode(code, includeChecksum, fullASCIIMode)
+	})
+}
+
+// RegisterDataMatrix registers a barcode of type DataMatrix. See the
+// package-level RegisterDataMatrix() for details.
+func (r *Registry) RegisterDataMatrix(code string) string {
+	return r.keyOrSetError(r.RegisterDataMatrixE(code))
+}
+
+// RegisterDataMatrixE registers a barcode of type DataMatrix and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterDataMatrixE(code string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	if err := Validate(barcode.TypeDataMatrix, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("DataMatrix", code), func() (barcode.Barcode, error) {
+		return datamatrix.Encode(code)
+	})
+}
+
+// RegisterImage registers an image as a barcode. See the package-level
+// RegisterImage() for details.
+func (r *Registry) RegisterImage(key string, img image.Image) string {
+	return r.keyOrSetError(r.RegisterImageE(key, img))
+}
+
+// RegisterImageE registers an image as a barcode and returns any error instead
+// of setting it on the PDF.
+func (r *Registry) RegisterImageE(key string, img image.Image) (string, error) {
+	bcode, err := newImageBarcode(key, img)
+	return r.registerBarcode(bcode, err)
+}
+
+// RegisterIntelligentMail registers a USPS Intelligent Mail barcode. See the
+// package-level RegisterIntelligentMail() for details.
+func (r *Registry) RegisterIntelligentMail(tracking, routing string) string {
+	return r.keyOrSetError(r.RegisterIntelligentMailE(tracking, routing))
+}
+
+// RegisterIntelligentMailE registers a USPS Intelligent Mail barcode and
+// returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterIntelligentMailE(tracking, routing string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("IntelligentMail", tracking, routing), func() (barcode.Barcode, error) {
+		return encodeIntelligentMail(tracking, routing)
+	})
+}
+
+// RegisterMSI registers a barcode of type MSI Plessey. See the package-level
+// RegisterMSI() for details.
+func (r *Registry) RegisterMSI(code string, checksum MSIChecksumMode) string {
+	return r.keyOrSetError(r.RegisterMSIE(code, checksum))
+}
+
+// RegisterMSIE registers a barcode of type MSI Plessey and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterMSIE(code string, checksum MSIChecksumMode) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("MSI", code, checksum), func() (barcode.Barcode, error) {
+		return encodeMSI(code, checksum)
+	})
+}
+
+// RegisterPdf417 registers a barcode of type Pdf417. See the package-level
+// RegisterPdf417() for details.
+func (r *Registry) RegisterPdf417(code string, columns int, securityLevel int) string {
+	return r.keyOrSetError(r.RegisterPdf417E(code, columns, securityLevel))
+}
+
+// RegisterPdf417E registers a barcode of type Pdf417 and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterPdf417E(code string, columns int, securityLevel int) (key string, err error) {
+	defer recoverEncodeError(&err)
+	if err := Validate(barcode.TypePDF, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("Pdf417", code, columns, securityLevel), func() (barcode.Barcode, error) {
+		return pdf417.Encode(code, columns, securityLevel), nil
+	})
+}
+
+// RegisterDataMatrixSized registers a barcode of type DataMatrix with the
+// given size. See the package-level RegisterDataMatrixSized() for details.
+func (r *Registry) RegisterDataMatrixSized(code string, rows, cols int) string {
+	return r.keyOrSetError(r.RegisterDataMatrixSizedE(code, rows, cols))
+}
+
+// RegisterDataMatrixSizedE registers a barcode of type DataMatrix with the
+// given size and returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterDataMatrixSizedE(code string, rows, cols int) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("DataMatrixSized", code, rows, cols), func() (barcode.Barcode, error) {
+		return encodeDataMatrixSized(code, rows, cols)
+	})
+}
+
+// RegisterDataBarExpanded registers a barcode of type GS1 DataBar Expanded.
+// See the package-level RegisterDataBarExpanded() for details.
+func (r *Registry) RegisterDataBarExpanded(ais map[string]string) string {
+	return r.keyOrSetError(r.RegisterDataBarExpandedE(ais))
+}
+
+// RegisterDataBarExpandedE registers a barcode of type GS1 DataBar Expanded
+// and returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterDataBarExpandedE(ais map[string]string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("DataBarExpanded", ais), func() (barcode.Barcode, error) {
+		return encodeDataBarExpanded(ais)
+	})
+}
+
+// RegisterDataBarLimited registers a barcode of type GS1 DataBar Limited. See
+// the package-level RegisterDataBarLimited() for details.
+func (r *Registry) RegisterDataBarLimited(gtin string) string {
+	return r.keyOrSetError(r.RegisterDataBarLimitedE(gtin))
+}
+
+// RegisterDataBarLimitedE registers a barcode of type GS1 DataBar Limited and
+// returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterDataBarLimitedE(gtin string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("DataBarLimited", gtin), func() (barcode.Barcode, error) {
+		return encodeDataBarLimited(gtin)
+	})
+}
+
+// RegisterEAN registers a barcode of type EAN. See the package-level
+// RegisterEAN() for details.
+func (r *Registry) RegisterEAN(code string) string {
+	return r.keyOrSetError(r.RegisterEANE(code))
+}
+
+// RegisterEANE registers a barcode of type EAN and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterEANE(code string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	if err := Validate(eanKind(code), code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("EAN", normalizeEAN(code)), func() (barcode.Barcode, error) {
+		return ean.Encode(code)
+	})
+}
+
+// RegisterEANChecked registers a barcode of type EAN whose code includes the
+// check digit and returns an error if the check digit is missing or wrong.
+// See the package-level RegisterEANChecked() for details.
+func (r *Registry) RegisterEANChecked(code string) (string, error) {
+	if (len(code) != 8 && len(code) != 13) || !isDigits(code) {
+		return "", fmt.Errorf("EAN code with check digit must consist of 8 or 13 digits, got %q", code)
+	}
+
+	// The check digit of codes of full length is verified on registration.
+	return r.RegisterEANE(code)
+}
+
+// RegisterEANWithAddon registers a barcode of type EAN with an EAN-2 or EAN-5
+// add-on. See the package-level RegisterEANWithAddon() for details.
+func (r *Registry) RegisterEANWithAddon(code, addon string) string {
+	return r.keyOrSetError(r.RegisterEANWithAddonE(code, addon))
+}
+
+// RegisterEANWithAddonE registers a barcode of type EAN with an EAN-2 or EAN-5
+// add-on and returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterEANWithAddonE(code, addon string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("EANWithAddon", code, addon), func() (barcode.Barcode, error) {
+		return encodeEANWithAddon(code, addon)
+	})
+}
+
+// RegisterPharmacode registers a barcode of type Pharmacode. See the
+// package-level RegisterPharmacode() for details.
+func (r *Registry) RegisterPharmacode(number int) string {
+	return r.keyOrSetError(r.RegisterPharmacodeE(number))
+}
+
+// RegisterPharmacodeE registers a barcode of type Pharmacode and returns any
+// error instead of setting it on the PDF.
+func (r *Registry) RegisterPharmacodeE(number int) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("Pharmacode", number), func() (barcode.Barcode, error) {
+		return encodePharmacode(number)
+	})
+}
+
+// RegisterQR registers a barcode of type QR. See the package-level
+// RegisterQR() for details.
+func (r *Registry) RegisterQR(code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding) string {
+	return r.keyOrSetError(r.RegisterQRE(code, ecl, mode))
+}
+
+// RegisterQRE registers a barcode of type QR and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterQRE(code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding) (key string, err error) {
+	defer recoverEncodeError(&err)
+	if err := Validate(barcode.TypeQR, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("QR", code, ecl, mode), func() (barcode.Barcode, error) {
+		return qr.Encode(code, ecl, mode)
+	})
+}
+
+// RegisterQRWithLogo registers a barcode of type QR with a logo in its center.
+// See the package-level RegisterQRWithLogo() for details.
+func (r *Registry) RegisterQRWithLogo(code string, ecl qr.ErrorCorrectionLevel, logo image.Image, coverage float64) string {
+	return r.keyOrSetError(r.RegisterQRWithLogoE(code, ecl, logo, coverage))
+}
+
+// RegisterQRWithLogoE registers a barcode of type QR with a logo in its center
+// and returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterQRWithLogoE(code string, ecl qr.ErrorCorrectionLevel, logo image.Image, coverage float64) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("QRWithLogo", code, ecl, imageHash(logo), coverage), func() (barcode.Barcode, error) {
+		return encodeQRWithLogo(code, ecl, logo, coverage)
+	})
+}
+
+// RegisterQRVersion registers a barcode of type QR with the given version. See
+// the package-level RegisterQRVersion() for details.
+func (r *Registry) RegisterQRVersion(code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, version int) string {
+	return r.keyOrSetError(r.RegisterQRVersionE(code, ecl, mode, version))
+}
+
+// RegisterQRVersionE registers a barcode of type QR with the given version and
+// returns any error instead of setting it on the PDF.
+func (r *Registry) RegisterQRVersionE(code string, ecl qr.ErrorCorrectionLevel, mode qr.Encoding, version int) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("QRVersion", code, ecl, mode, version), func() (barcode.Barcode, error) {
+		return encodeQRVersion(code, ecl, mode, version)
+	})
+}
+
+// RegisterQRStructured registers a structured append sequence of QR codes.
+// See the package-level RegisterQRStructured() for details.
+func (r *Registry) RegisterQRStructured(data string, ecl qr.ErrorCorrectionLevel, maxPerSymbol int) []string {
+	keys, err := r.RegisterQRStructuredE(data, ecl, maxPerSymbol)
+	r.setError(err)
+	return keys
+}
+
+// RegisterQRStructuredE registers a structured append sequence of QR codes
+// and returns any error instead of setting it on the PDF. No code is
+// registered if an error occurs.
+func (r *Registry) RegisterQRStructuredE(data string, ecl qr.ErrorCorrectionLevel, maxPerSymbol int) (keys []string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerEncoded(encodeRequest("QRStructured", data, ecl, maxPerSymbol), func() ([]barcode.Barcode, error) {
+		return encodeQRStructured(data, ecl, maxPerSymbol)
+	})
+}
+
+// RegisterTwoOfFive registers a barcode of type TwoOfFive. See the
+// package-level RegisterTwoOfFive() for details.
+func (r *Registry) RegisterTwoOfFive(code string, interleaved bool) string {
+	return r.keyOrSetError(r.RegisterTwoOfFiveE(code, interleaved))
+}
+
+// RegisterTwoOfFiveE registers a barcode of type TwoOfFive and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterTwoOfFiveE(code string, interleaved bool) (key string, err error) {
+	defer recoverEncodeError(&err)
+	kind := barcode.Type2of5
+	if interleaved {
+		kind = barcode.Type2of5Interleaved
+	}
+	if err := Validate(kind, code); err != nil {
+		return "", err
+	}
+
+	return r.registerCached(encodeRequest("TwoOfFive", code, interleaved), func() (barcode.Barcode, error) {
+		return twooffive.Encode(code, interleaved)
+	})
+}
+
+// RegisterITF14 registers a barcode of type ITF-14. See the package-level
+// RegisterITF14() for details.
+func (r *Registry) RegisterITF14(code string) string {
+	return r.keyOrSetError(r.RegisterITF14E(code))
+}
+
+// RegisterITF14E registers a barcode of type ITF-14 and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterITF14E(code string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("ITF14", code), func() (barcode.Barcode, error) {
+		return encodeITF14(code)
+	})
+}
+
+// RegisterGS1_128 registers a barcode of type GS1-128. See the package-level
+// RegisterGS1_128() for details.
+func (r *Registry) RegisterGS1_128(ais map[string]string) string {
+	return r.keyOrSetError(r.RegisterGS1_128E(ais))
+}
+
+// RegisterGS1_128E registers a barcode of type GS1-128 and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterGS1_128E(ais map[string]string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("GS1_128", ais), func() (barcode.Barcode, error) {
+		return encodeGS1_128(ais)
+	})
+}
+
+// RegisterUPCA registers a barcode of type UPC-A. See the package-level
+// RegisterUPCA() for details.
+func (r *Registry) RegisterUPCA(code string) string {
+	return r.keyOrSetError(r.RegisterUPCAE(code))
+}
+
+// RegisterUPCAE registers a barcode of type UPC-A and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterUPCAE(code string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("UPCA", code), func() (barcode.Barcode, error) {
+		return encodeUPCA(code)
+	})
+}
+
+// RegisterUPCE registers a barcode of type UPC-E. See the package-level
+// RegisterUPCE() for details.
+func (r *Registry) RegisterUPCE(code string) string {
+	return r.keyOrSetError(r.RegisterUPCEE(code))
+}
+
+// RegisterUPCEE registers a barcode of type UPC-E and returns any error
+// instead of setting it on the PDF.
+func (r *Registry) RegisterUPCEE(code string) (key string, err error) {
+	defer recoverEncodeError(&err)
+	return r.registerCached(encodeRequest("UPCE", code), func() (barcode.Barcode, error) {
+		return encodeUPCE(code)
+	})
+}
+
+// registerBarcode registers a barcode internally using the Register() function.
+// In case of an error generating the barcode it will not be registered and the
+// error is returned. Otherwise it will return a unique key for the barcode
+// type and content that can be used to put the barcode on the page.
+func (r *Registry) registerBarcode(bcode barcode.Barcode, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+
+	return r.Register(bcode), nil
+}
+
+// registerCached registers the barcode returned by encode like
+// registerEncoded() does and returns its key.
+func (r *Registry) registerCached(request string, encode func() (barcode.Barcode, error)) (string, error) {
+	keys, err := r.registerEncoded(request, func() ([]barcode.Barcode, error) {
+		bcode, err := encode()
+		if err != nil {
+			return nil, err
+		}
+		return []barcode.Barcode{bcode}, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return keys[0], nil
+}
+
+// registerEncoded registers the barcodes returned by encode and returns their
+// keys. request identifies the Register function and all arguments the
+// encoding depends on, see encodeRequest(). If the same request has been
+// encoded before, its barcodes are registered again without calling encode,
+// which saves encoding the same code repeatedly, as for a sheet of labels.
+func (r *Registry) registerEncoded(request string, encode func() ([]barcode.Barcode, error)) ([]string, error) {
+	r.barcodes.RLock()
+	enc, ok := r.barcodes.encoded[request]
+	r.barcodes.RUnlock()
+	if !ok {
+		bcodes, err := encode()
+		if err != nil {
+			return nil, err
+		}
+		enc = encoding{keys: make([]string, len(bcodes)), barcodes: bcodes}
+		for i, bcode := range bcodes {
+			enc.keys[i] = barcodeKey(bcode)
+		}
+	}
+
+	r.barcodes.Lock()
+	if r.barcodes.cache == nil {
+		r.barcodes.cache = make(map[string]barcode.Barcode)
+	}
+	for i, key := range enc.keys {
+		r.barcodes.cache[key] = enc.barcodes[i]
+	}
+	if !ok {
+		if r.barcodes.encoded == nil {
+			r.barcodes.encoded = make(map[string]encoding)
+		}
+		r.barcodes.encoded[request] = enc
+	}
+	r.barcodes.Unlock()
+
+	return enc.keys, nil
+}
+
+// encodeRequest returns the request of registerEncoded() for the Register
+// function with the given name and arguments.
+func encodeRequest(name string, args ...interface{}) string {
+	return fmt.Sprintf("%s%#v", name, args)
+}
+
+// recoverEncodeError assigns the value of a panic of a barcode encoder to err.
+// Some encoders panic on pathological input instead of returning an error,
+// which must not crash a server that generates PDFs from user input.
+func recoverEncodeError(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("barcode encoder failed: %v", r)
+	}
+}
+
+// keyOrSetError reports err unless it is nil and returns key.
+func (r *Registry) keyOrSetError(key string, err error) string {
+	r.setError(err)
+	return key
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+)
+
+// BarcodeRotated puts a barcode of this registry in the current page, rotated
+// counter-clockwise by degrees. See the package-level BarcodeRotated() for
+// details.
+func (r *Registry) BarcodeRotated(code string, x, y, w, h float64, flow bool, degrees int) {
+	r.setError(r.printBarcode(code, x, y, &w, &h, flow, degrees, 0, ""))
+}
+
+// rotated is a barcode that is rotated counter-clockwise by 90, 180 or 270
+// degrees.
+type rotated struct {
+	barcode.Barcode
+	degrees int
+}
+
+// validRotation reports whether degrees is a supported rotation.
+func validRotation(degrees int) bool {
+	switch degrees {
+	case 0, 90, 180, 270:
+		return true
+	}
+
+	return false
+}
+
+// rotate returns bcode rotated counter-clockwise by degrees, or bcode itself
+// if degrees is 0.
+func rotate(bcode barcode.Barcode, degrees int) barcode.Barcode {
+	if degrees == 0 {
+		return bcode
+	}
+
+	return &rotated{Barcode: bcode, degrees: degrees}
+}
+
+// Bounds returns the bounds of the rotated barcode.
+func (r *rotated) Bounds() image.Rectangle {
+	bounds := r.Barcode.Bounds()
+	if r.degrees == 180 {
+		return image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+	}
+
+	return image.Rect(0, 0, bounds.Dy(), bounds.Dx())
+}
+
+// At returns the color of the rotated barcode at x, y.
+func (r *rotated) At(x, y int) color.Color {
+	bounds := r.Barcode.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	switch r.degrees {
+	case 90:
+		x, y = w-1-y, x
+	case 180:
+		x, y = w-1-x, h-1-y
+	case 270:
+		x, y = y, h-1-x
+	}
+
+	return r.Barcode.At(bounds.Min.X+x, bounds.Min.Y+y)
+}
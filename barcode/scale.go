@@ -0,0 +1,99 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+)
+
+// moduleScaled is a barcode that is scaled by a whole number of pixels per
+// module. Every pixel takes the color of a single module, snapped to black or
+// white, so the edges of the bars stay hard whatever colors the unscaled
+// barcode has.
+type moduleScaled struct {
+	barcode.Barcode
+	bounds           image.Rectangle
+	factorX, factorY int
+	offsetX, offsetY int
+}
+
+// scaleModules scales bcode to width x height pixels with nearest neighbor
+// sampling. Like barcode.Scale(), it scales by the largest whole factor that
+// fits, the same for both axes of two-dimensional barcodes, and centers the
+// result on a white margin. One-dimensional barcodes fill the full height.
+func scaleModules(bcode barcode.Barcode, width, height int) (barcode.Barcode, error) {
+	bounds := bcode.Bounds()
+	dx, dy := bounds.Dx(), bounds.Dy()
+	factorX, factorY := width/dx, height/dy
+	if bcode.Metadata().Dimensions == 1 {
+		factorY = height
+	} else if factorX > factorY {
+		factorX = factorY
+	} else {
+		factorY = factorX
+	}
+	if factorX <= 0 || factorY <= 0 {
+		return nil, fmt.Errorf("can not scale barcode to an image smaller than %dx%d", dx, dy)
+	}
+
+	return &moduleScaled{
+		Barcode: bcode,
+		bounds:  image.Rect(0, 0, width, height),
+		factorX: factorX,
+		factorY: factorY,
+		offsetX: (width - dx*factorX) / 2,
+		offsetY: (height - dy*factorY) / 2,
+	}, nil
+}
+
+// Bounds returns the bounds of the scaled barcode.
+func (m *moduleScaled) Bounds() image.Rectangle {
+	return m.bounds
+}
+
+// At returns the color of the module that covers the pixel at x, y. Dark
+// modules are black and light modules white, except for those of a quiet
+// zone, which keep their color.
+func (m *moduleScaled) At(x, y int) color.Color {
+	bounds := m.Barcode.Bounds()
+	if x < m.offsetX || y < m.offsetY {
+		return color.White
+	}
+	pt := image.Pt((x-m.offsetX)/m.factorX, (y-m.offsetY)/m.factorY).Add(bounds.Min)
+	if !pt.In(bounds) {
+		return color.White
+	}
+
+	switch {
+	case isBar(m.Barcode, pt.X, pt.Y):
+		return color.Black
+	case isQuietZone(m.Barcode, pt.X, pt.Y):
+		return quietZoneWhite{}
+	}
+	return color.White
+}
+
+// scaleBarcode scales bcode to width x height pixels with barcode.Scale(), or
+// with scaleModules() if smoothing is disabled in opts.
+func scaleBarcode(bcode barcode.Barcode, width, height int, opts options) (barcode.Barcode, error) {
+	if opts.noSmoothing {
+		return scaleModules(bcode, width, height)
+	}
+	return barcode.Scale(bcode, width, height)
+}
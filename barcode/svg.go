@@ -0,0 +1,109 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/boombuler/barcode"
+)
+
+// EncodeSVG writes the barcode of this registry associated with the given key
+// to w as an SVG document. See the package-level EncodeSVG() for details.
+func (r *Registry) EncodeSVG(w io.Writer, key string, widthDoc, heightDoc float64) error {
+	registered, ok := r.lookup(key)
+	if !ok {
+		return ErrBarcodeNotFound
+	}
+	if err := validateSize(widthDoc, heightDoc); err != nil {
+		return err
+	}
+
+	opts := r.options(key)
+	if err := checkContrast(registered, opts); err != nil {
+		return err
+	}
+	bcode := withQuietZone(registered, opts.quietZone)
+	bounds := bcode.Bounds()
+	widthDoc, heightDoc = naturalSize(points{}, bcode, widthDoc, heightDoc)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%spt" height="%spt" viewBox="0 0 %d %d" preserveAspectRatio="none" shape-rendering="crispEdges">`+"\n",
+		svgNumber(widthDoc), svgNumber(heightDoc), bounds.Dx(), bounds.Dy())
+
+	if !opts.transparent {
+		if opts.qzColor != nil && opts.quietZone > 0 {
+			writeSVGRect(bw, 0, 0, bounds.Dx(), bounds.Dy(), opts.qzColor)
+			padX, padY := bcode.(*quietZone).padding()
+			writeSVGRect(bw, padX, padY, bounds.Dx()-2*padX, bounds.Dy()-2*padY, opts.bg)
+		} else {
+			writeSVGRect(bw, 0, 0, bounds.Dx(), bounds.Dy(), opts.bg)
+		}
+	}
+
+	if bcode.Metadata().Dimensions == 1 {
+		writeSVGBars(bw, bcode, opts.fg)
+	} else {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if isBar(bcode, x, y) {
+					writeSVGRect(bw, x-bounds.Min.X, y-bounds.Min.Y, 1, 1, opts.fg)
+				}
+			}
+		}
+	}
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// writeSVGBars writes the bars of the one-dimensional barcode as rectangles
+// of the full height. Adjacent bar modules are written as a single rectangle.
+func writeSVGBars(w io.Writer, bcode barcode.Barcode, fg color.Color) {
+	bounds := bcode.Bounds()
+	for start := bounds.Min.X; start < bounds.Max.X; {
+		if !isBar(bcode, start, bounds.Min.Y) {
+			start++
+			continue
+		}
+
+		end := start + 1
+		for end < bounds.Max.X && isBar(bcode, end, bounds.Min.Y) {
+			end++
+		}
+		writeSVGRect(w, start-bounds.Min.X, 0, end-start, bounds.Dy(), fg)
+		start = end
+	}
+}
+
+// writeSVGRect writes a rectangle filled with c, in modules of the barcode.
+func writeSVGRect(w io.Writer, x, y, width, height int, c color.Color) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="#%02x%02x%02x"`, x, y, width, height, n.R, n.G, n.B)
+	if n.A != 0xff {
+		fmt.Fprintf(w, ` fill-opacity="%s"`, svgNumber(float64(n.A)/0xff))
+	}
+	io.WriteString(w, "/>\n")
+}
+
+// svgNumber formats f for an SVG attribute, with no more digits than needed.
+func svgNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e4)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/utils"
+)
+
+// TypeUPCA and TypeUPCE are the code kinds of UPC-A and UPC-E barcodes. UPC-A
+// barcodes are registered as EAN-13 barcodes, so TypeUPCA is only used by
+// Validate().
+const (
+	TypeUPCA = "UPC-A"
+	TypeUPCE = "UPC-E"
+)
+
+// upcOdd and upcEven hold the odd (L) and even (G) parity patterns of the
+// digits 0 to 9 as used by the left half of EAN and UPC barcodes.
+var (
+	upcOdd = [10]string{
+		"0001101", "0011001", "0010011", "0111101", "0100011",
+		"0110001", "0101111", "0111011", "0110111", "0001011",
+	}
+	upcEven = [10]string{
+		"0100111", "0110011", "0011011", "0100001", "0011101",
+		"0111001", "0000101", "0010001", "0001001", "0010111",
+	}
+)
+
+// upcEParity holds the parity of the six UPC-E data digits for number system
+// 0, indexed by the check digit. 'E' means even and 'O' means odd parity.
+// Number system 1 uses the opposite parities.
+var upcEParity = [10]string{
+	"EEEOOO", "EEOEOO", "EEOOEO", "EEOOOE", "EOEEOO",
+	"EOOEEO", "EOOOEE", "EOEOEO", "EOEOOE", "EOOEOE",
+}
+
+// encodeUPCA returns a UPC-A barcode for the given 12 digit code, including
+// the check digit. UPC-A is a subset of EAN-13 with a leading zero, so the
+// barcode is encoded as such.
+func encodeUPCA(code string) (barcode.Barcode, error) {
+	if err := validateUPCA(code); err != nil {
+		return nil, err
+	}
+
+	return ean.Encode("0" + code)
+}
+
+// encodeUPCE returns a UPC-E barcode for the given 8 digit code, which
+// consists of the number system (0 or 1), six data digits and the check digit
+// of the equivalent UPC-A code.
+func encodeUPCE(code string) (barcode.Barcode, error) {
+	if err := validateUPCE(code); err != nil {
+		return nil, err
+	}
+
+	parity := upcEParity[code[7]-'0']
+	bars := new(utils.BitList)
+	addPattern(bars, "101")
+	for i, r := range code[1:7] {
+		even := parity[i] == 'E'
+		if code[0] == '1' {
+			even = !even
+		}
+		if even {
+			addPattern(bars, upcEven[r-'0'])
+		} else {
+			addPattern(bars, upcOdd[r-'0'])
+		}
+	}
+	addPattern(bars, "010101")
+
+	return utils.New1DCode(TypeUPCE, code, bars), nil
+}
+
+// expandUPCE returns the UPC-A code that is equivalent to the given 8 digit
+// UPC-E code.
+func expandUPCE(code string) string {
+	ns, d, check := code[:1], code[1:7], code[7:]
+
+	switch d[5] {
+	case '0', '1', '2':
+		return ns + d[0:2] + d[5:6] + "0000" + d[2:5] + check
+	case '3':
+		return ns + d[0:3] + "00000" + d[3:5] + check
+	case '4':
+		return ns + d[0:4] + "00000" + d[4:5] + check
+	}
+
+	return ns + d[0:5] + "0000" + d[5:6] + check
+}
+
+// gs1CheckDigit returns the modulo 10 check digit used by EAN, UPC, ITF-14 and
+// other GS1 data structures for the given digits, which do not include the
+// check digit.
+func gs1CheckDigit(digits string) int {
+	sum := 0
+	for i := range digits {
+		n := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			n *= 3
+		}
+		sum += n
+	}
+
+	return (10 - sum%10) % 10
+}
+
+// verifyGS1CheckDigit returns an error if the last of the given digits is not
+// the GS1 check digit of the others.
+func verifyGS1CheckDigit(code string) error {
+	data, check := code[:len(code)-1], int(code[len(code)-1]-'0')
+	if want := gs1CheckDigit(data); check != want {
+		return fmt.Errorf("invalid check digit %d in %q, expected %d", check, code, want)
+	}
+
+	return nil
+}
+
+// isDigits reports whether s is not empty and consists of the digits 0 to 9
+// only.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// addPattern appends the given pattern of '1' (bar) and '0' (space) modules
+// to bars.
+func addPattern(bars *utils.BitList, pattern string) {
+	for _, r := range pattern {
+		bars.AddBit(r == '1')
+	}
+}
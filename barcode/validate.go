@@ -0,0 +1,260 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+)
+
+// TypeCode39FullASCII and TypeCode93FullASCII are the kinds of Code 39 and
+// Code 93 barcodes in full ASCII mode, as used by Validate().
+const (
+	TypeCode39FullASCII = barcode.TypeCode39 + " (full ASCII)"
+	TypeCode93FullASCII = barcode.TypeCode93 + " (full ASCII)"
+)
+
+// code39Chars holds the characters of Code 39 and Code 93 barcodes that are
+// not in full ASCII mode.
+const code39Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ-. $/+%"
+
+// codabarChars holds the characters of Codabar barcodes between the start and
+// stop characters.
+const codabarChars = "0123456789-$:/.+"
+
+// validators holds the validation functions of the barcode kinds.
+var validators = map[string]func(code string) error{
+	barcode.TypeAztec:           validateNotEmpty(barcode.TypeAztec),
+	barcode.TypeCodabar:         validateCodabar,
+	barcode.TypeCode128:         validateCode128,
+	barcode.TypeCode39:          validateChars(barcode.TypeCode39, code39Chars),
+	TypeCode39FullASCII:         validateASCII(barcode.TypeCode39),
+	barcode.TypeCode93:          validateChars(barcode.TypeCode93, code39Chars),
+	TypeCode93FullASCII:         validateASCII(barcode.TypeCode93),
+	barcode.TypeDataMatrix:      validateNotEmpty(barcode.TypeDataMatrix),
+	barcode.TypeEAN8:            validateEAN(barcode.TypeEAN8, 8),
+	barcode.TypeEAN13:           validateEAN(barcode.TypeEAN13, 13),
+	barcode.TypePDF:             validateNotEmpty(barcode.TypePDF),
+	barcode.TypeQR:              validateNotEmpty(barcode.TypeQR),
+	barcode.Type2of5:            validateTwoOfFive(false),
+	barcode.Type2of5Interleaved: validateTwoOfFive(true),
+	TypeCode11:                  validateCode11,
+	TypeDataBarLimited:          validateDataBarLimited,
+	TypeIntelligentMail:         validateIntelligentMailContent,
+	TypeITF14:                   validateITF14,
+	TypeMSI:                     validateMSI,
+	TypePharmacode:              validatePharmacode,
+	TypeUPCA:                    validateUPCA,
+	TypeUPCE:                    validateUPCE,
+}
+
+// Validate returns a descriptive error if code can't be encoded as a barcode
+// of the given kind, so that user input can be checked before a barcode is
+// registered. The Register* functions validate their codes the same way.
+//
+// kind is one of the code kinds of the barcode package, such as
+// barcode.TypeCode128 or barcode.TypeEAN13, or TypeCode11,
+// TypeCode39FullASCII, TypeCode93FullASCII, TypeDataBarLimited,
+// TypeIntelligentMail, TypeITF14, TypeMSI, TypePharmacode, TypeUPCA or
+// TypeUPCE. Depending on the kind the length, the
+// characters and the check digit of code are validated.
+// Two-dimensional codes are only required not to be empty.
+func Validate(kind string, code string) error {
+	validate, ok := validators[kind]
+	if !ok {
+		return fmt.Errorf("unknown barcode kind %q", kind)
+	}
+
+	return validate(code)
+}
+
+// eanKind returns the kind of EAN barcode that code is encoded as, which
+// depends on its length.
+func eanKind(code string) string {
+	if len(code) <= 8 {
+		return barcode.TypeEAN8
+	}
+
+	return barcode.TypeEAN13
+}
+
+// normalizeEAN returns code with its check digit, which is appended if code
+// consists of the 7 or 12 digits of an EAN-8 or EAN-13 code without it, so
+// that both forms of a code are encoded once.
+func normalizeEAN(code string) string {
+	if (len(code) == 7 || len(code) == 12) && isDigits(code) {
+		return code + strconv.Itoa(gs1CheckDigit(code))
+	}
+
+	return code
+}
+
+// validateNotEmpty returns a function that validates that codes of the given
+// kind are not empty.
+func validateNotEmpty(kind string) func(code string) error {
+	return func(code string) error {
+		if code == "" {
+			return fmt.Errorf("%s code must not be empty", kind)
+		}
+
+		return nil
+	}
+}
+
+// validateChars returns a function that validates that codes of the given
+// kind consist of the given characters.
+func validateChars(kind, chars string) func(code string) error {
+	return func(code string) error {
+		if err := validateNotEmpty(kind)(code); err != nil {
+			return err
+		}
+		for i, r := range code {
+			if !strings.ContainsRune(chars, r) {
+				return fmt.Errorf("%s code contains invalid character %q at position %d", kind, r, i)
+			}
+		}
+
+		return nil
+	}
+}
+
+// validateASCII returns a function that validates that codes of the given
+// kind consist of ASCII characters.
+func validateASCII(kind string) func(code string) error {
+	return func(code string) error {
+		if err := validateNotEmpty(kind)(code); err != nil {
+			return err
+		}
+		for i, r := range code {
+			if r > 127 {
+				return fmt.Errorf("%s code contains non-ASCII character %q at position %d", kind, r, i)
+			}
+		}
+
+		return nil
+	}
+}
+
+// validateCodabar validates that code starts and ends with one of A, B, C or
+// D and consists of Codabar characters in between.
+func validateCodabar(code string) error {
+	if len(code) < 2 || !strings.ContainsRune("ABCD", rune(code[0])) || !strings.ContainsRune("ABCD", rune(code[len(code)-1])) {
+		return fmt.Errorf("Codabar code must start and end with A, B, C or D, got %q", code)
+	}
+
+	for i, r := range code[1 : len(code)-1] {
+		if !strings.ContainsRune(codabarChars, r) {
+			return fmt.Errorf("Codabar code contains invalid character %q at position %d", r, i+1)
+		}
+	}
+
+	return nil
+}
+
+// validateCodabarGuard validates that guard, the start or stop character of a
+// Codabar barcode as given by which, is one of A, B, C or D.
+func validateCodabarGuard(which string, guard byte) error {
+	if guard < 'A' || guard > 'D' {
+		return fmt.Errorf("Codabar %s character must be A, B, C or D, got %q", which, guard)
+	}
+
+	return nil
+}
+
+// validateCode128 validates that code consists of ASCII characters and the
+// function characters FNC1 to FNC4.
+func validateCode128(code string) error {
+	if err := validateNotEmpty(barcode.TypeCode128)(code); err != nil {
+		return err
+	}
+	for i, r := range code {
+		switch {
+		case r <= 127, r == code128.FNC1, r == code128.FNC2, r == code128.FNC3, r == code128.FNC4:
+		default:
+			return fmt.Errorf("Code 128 code contains invalid character %q at position %d", r, i)
+		}
+	}
+
+	return nil
+}
+
+// validateEAN returns a function that validates EAN codes of the given length
+// including the check digit. A code without the check digit is valid too, in
+// which case the check digit is calculated when it is encoded.
+func validateEAN(kind string, length int) func(code string) error {
+	return func(code string) error {
+		if (len(code) != length && len(code) != length-1) || !isDigits(code) {
+			return fmt.Errorf("%s code must consist of %d or %d digits, got %q", kind, length-1, length, code)
+		}
+		if len(code) == length {
+			return verifyGS1CheckDigit(code)
+		}
+
+		return nil
+	}
+}
+
+// validateTwoOfFive returns a function that validates 2 of 5 codes, which
+// consist of digits, an even number of them if they are interleaved.
+func validateTwoOfFive(interleaved bool) func(code string) error {
+	return func(code string) error {
+		if !isDigits(code) {
+			return fmt.Errorf("2 of 5 code must consist of digits, got %q", code)
+		}
+		if interleaved && len(code)%2 != 0 {
+			return fmt.Errorf("interleaved 2 of 5 code must consist of an even number of digits, got %q", code)
+		}
+
+		return nil
+	}
+}
+
+// validateITF14 validates that code consists of 14 digits including the check
+// digit.
+func validateITF14(code string) error {
+	if len(code) != 14 || !isDigits(code) {
+		return fmt.Errorf("ITF-14 code must consist of 14 digits, got %q", code)
+	}
+
+	return verifyGS1CheckDigit(code)
+}
+
+// validateUPCA validates that code consists of 12 digits including the check
+// digit.
+func validateUPCA(code string) error {
+	if len(code) != 12 || !isDigits(code) {
+		return fmt.Errorf("UPC-A code must consist of 12 digits, got %q", code)
+	}
+
+	return verifyGS1CheckDigit(code)
+}
+
+// validateUPCE validates that code consists of 8 digits, starts with the
+// number system 0 or 1 and ends with the check digit of the equivalent UPC-A
+// code.
+func validateUPCE(code string) error {
+	if len(code) != 8 || !isDigits(code) {
+		return fmt.Errorf("UPC-E code must consist of 8 digits, got %q", code)
+	}
+	if code[0] != '0' && code[0] != '1' {
+		return fmt.Errorf("UPC-E number system must be 0 or 1, got %c", code[0])
+	}
+
+	return verifyGS1CheckDigit(expandUPCE(code))
+}
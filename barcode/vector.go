@@ -0,0 +1,119 @@
+// Copyright (c) 2015 Jelmer Snoeck (Gmail: jelmer.snoeck)
+//
+// Permission to use, copy, modify, and distribute this software for any purpose
+// with or without fee is hereby granted, provided that the above copyright notice
+// and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+// REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+// FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+// INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+// LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR
+// OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR
+// PERFORMANCE OF THIS SOFTWARE.
+
+package barcode
+
+import (
+	"errors"
+	"image/color"
+	"math"
+
+	"github.com/boombuler/barcode"
+)
+
+// BarcodeVector puts a barcode of this registry in the current page, drawing
+// one-dimensional barcodes as vector rectangles. The PDF of the registry must
+// support drawing rectangles. See the package-level BarcodeVector() for
+// details.
+func (r *Registry) BarcodeVector(code string, x, y, w, h float64, flow bool) {
+	pdf, ok := r.pdf.(barcodeVectorPdf)
+	if !ok {
+		r.setError(errors.New("PDF does not support drawing barcodes as vectors"))
+		return
+	}
+
+	unscaled, ok := r.lookup(code)
+	if !ok {
+		r.setError(ErrBarcodeNotFound)
+		return
+	}
+
+	if unscaled.Metadata().Dimensions != 1 {
+		r.Barcode(code, x, y, w, h, flow)
+		return
+	}
+
+	if err := validateSize(w, h); err != nil {
+		r.setError(err)
+		return
+	}
+
+	opts := r.options(code)
+	var guards []bool
+	if opts.guardBars {
+		guards = guardModules(unscaled, opts.quietZone)
+	}
+	unscaled = withQuietZone(unscaled, opts.quietZone)
+	w, h = naturalSize(pdf, unscaled, w, h)
+
+	curX, curY := pdf.GetXY()
+	if flow {
+		y = curY
+	}
+
+	drawBars(pdf, unscaled, x, y, w, h, opts.fg, guards)
+
+	if flow {
+		pdf.SetXY(curX, curY+h)
+	}
+}
+
+// drawBars draws the bars of the one-dimensional barcode as rectangles filled
+// with the foreground color fg in the box given by x, y, w and h. Adjacent bar
+// modules are drawn as a single rectangle. If guards is not nil, it tells for
+// every module whether it belongs to a guard pattern; the guard bars take the
+// full height while the other bars leave space for the human readable digits
+// below them.
+func drawBars(pdf barcodeVectorPdf, bcode barcode.Barcode, x, y, w, h float64, fgColor color.Color, guards []bool) {
+	r, g, b := pdf.GetFillColor()
+	fg := color.NRGBAModel.Convert(fgColor).(color.NRGBA)
+	pdf.SetFillColor(int(fg.R), int(fg.G), int(fg.B))
+
+	bounds := bcode.Bounds()
+	module := w / float64(bounds.Dx())
+	isGuard := func(x int) bool {
+		return guards != nil && guards[x-bounds.Min.X]
+	}
+	short := h
+	if guards != nil {
+		short = h - math.Min(guardBarExtension*module, h/2)
+	}
+
+	for start := bounds.Min.X; start < bounds.Max.X; {
+		if !isBar(bcode, start, bounds.Min.Y) {
+			start++
+			continue
+		}
+
+		end := start + 1
+		for end < bounds.Max.X && isBar(bcode, end, bounds.Min.Y) && isGuard(end) == isGuard(start) {
+			end++
+		}
+
+		barH := short
+		if isGuard(start) {
+			barH = h
+		}
+		pdf.Rect(x+float64(start-bounds.Min.X)*module, y, float64(end-start)*module, barH, "F")
+		start = end
+	}
+
+	pdf.SetFillColor(r, g, b)
+}
+
+// isBar reports whether the module of the barcode at x, y is dark.
+func isBar(bcode barcode.Barcode, x, y int) bool {
+	r, g, b, _ := bcode.At(x, y).RGBA()
+	return r+g+b < 3*0x8000
+}
@@ -0,0 +1,339 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// linkAnnot is a link annotation of an imported page: the rectangle of the
+// annotation in default user space, given as the lower left and upper right
+// corners, and the URI it links to.
+type linkAnnot struct {
+	rect [4]float64
+	uri  string
+}
+
+// pdfRef is an indirect reference to an object of a PDF.
+type pdfRef int
+
+// pdfName is a name object of a PDF without the leading slash.
+type pdfName string
+
+// pdfObjects holds the objects of a PDF by object number.
+type pdfObjects map[int]interface{}
+
+// readLinkAnnots returns the URI link annotations of the given page of the PDF
+// held in data.
+func readLinkAnnots(data []byte, pageno int) ([]linkAnnot, error) {
+	objs, pages := readPages(data)
+	if pageno < 1 || pageno > len(pages) {
+		return nil, fmt.Errorf("gofpdi: page %d not found for link annotations, the PDF has %d readable pages", pageno, len(pages))
+	}
+
+	var links []linkAnnot
+	annots, _ := objs.resolve(dictValue(pages[pageno-1], "Annots")).([]interface{})
+	for _, a := range annots {
+		annot := objs.resolve(a)
+		if dictValue(annot, "Subtype") != pdfName("Link") {
+			continue
+		}
+		action := objs.resolve(dictValue(annot, "A"))
+		uri, ok := objs.resolve(dictValue(action, "URI")).(string)
+		if !ok || dictValue(action, "S") != pdfName("URI") {
+			continue
+		}
+		rect, ok := objs.resolve(dictValue(annot, "Rect")).([]interface{})
+		if !ok || len(rect) != 4 {
+			continue
+		}
+
+		link := linkAnnot{uri: uri}
+		for j, v := range rect {
+			link.rect[j], _ = objs.resolve(v).(float64)
+		}
+		// Normalize the rectangle to lower left and upper right corners.
+		if link.rect[0] > link.rect[2] {
+			link.rect[0], link.rect[2] = link.rect[2], link.rect[0]
+		}
+		if link.rect[1] > link.rect[3] {
+			link.rect[1], link.rect[3] = link.rect[3], link.rect[1]
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// readPages returns the objects of the PDF held in data and its page
+// dictionaries in document order.
+func readPages(data []byte) (pdfObjects, []map[string]interface{}) {
+	objs, trailer := readObjects(data)
+	root := objs.resolve(trailer["Root"])
+	return objs, objs.pages(dictValue(root, "Pages"), 0)
+}
+
+// resolve returns the object that obj refers to if it is a reference, and obj
+// itself otherwise.
+func (objs pdfObjects) resolve(obj interface{}) interface{} {
+	for depth := 0; depth < 32; depth++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = objs[int(ref)]
+	}
+	return nil
+}
+
+// pages returns the page dictionaries of the page tree node in document
+// order.
+func (objs pdfObjects) pages(node interface{}, depth int) []map[string]interface{} {
+	dict, ok := objs.resolve(node).(map[string]interface{})
+	if !ok || depth > 64 {
+		return nil
+	}
+	if dict["Type"] == pdfName("Page") {
+		return []map[string]interface{}{dict}
+	}
+	var pages []map[string]interface{}
+	kids, _ := objs.resolve(dict["Kids"]).([]interface{})
+	for _, kid := range kids {
+		pages = append(pages, objs.pages(kid, depth+1)...)
+	}
+	return pages
+}
+
+// dictValue returns the value of key if obj is a dictionary or a stream whose
+// dictionary contains it, and nil otherwise.
+func dictValue(obj interface{}, key string) interface{} {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		return o[key]
+	case *pdfStream:
+		return o.dict[key]
+	}
+	return nil
+}
+
+// pdfParser parses the objects of a PDF: dictionaries, arrays, names,
+// strings, numbers and references, and indirect objects including streams.
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+// parse returns the object at the current position.
+func (p *pdfParser) parse() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("gofpdi: unexpected end of PDF")
+	}
+
+	switch c := p.data[p.pos]; {
+	case bytes.HasPrefix(p.data[p.pos:], []byte("<<")):
+		return p.parseDict()
+	case c == '<':
+		return p.parseHexString()
+	case c == '[':
+		return p.parseArray()
+	case c == '(':
+		return p.parseString()
+	case c == '/':
+		return pdfName(p.parseToken()), nil
+	}
+
+	token := p.parseToken()
+	if n, err := strconv.Atoi(token); err == nil {
+		// A reference consists of the object number, the generation
+		// number and R.
+		save := p.pos
+		p.skipSpace()
+		if gen := p.parseToken(); gen != "" && isInteger(gen) {
+			p.skipSpace()
+			if p.parseToken() == "R" {
+				return pdfRef(n), nil
+			}
+		}
+		p.pos = save
+		return float64(n), nil
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, nil
+	}
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("gofpdi: unexpected token %q in PDF", token)
+}
+
+// parseDict parses a dictionary whose keys are stored without the leading
+// slash.
+func (p *pdfParser) parseDict() (interface{}, error) {
+	p.pos += 2
+	dict := map[string]interface{}{}
+	for {
+		p.skipSpace()
+		if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+			p.pos += 2
+			return dict, nil
+		}
+		key, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, fmt.Errorf("gofpdi: invalid dictionary key in PDF")
+		}
+		value, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		dict[string(name)] = value
+	}
+}
+
+// parseArray parses an array.
+func (p *pdfParser) parseArray() (interface{}, error) {
+	p.pos++
+	var array []interface{}
+	for {
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ']' {
+			p.pos++
+			return array, nil
+		}
+		value, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+	}
+}
+
+// parseString parses a literal string with balanced parentheses and escape
+// sequences.
+func (p *pdfParser) parseString() (interface{}, error) {
+	p.pos++
+	var buf bytes.Buffer
+	for depth := 0; p.pos < len(p.data); p.pos++ {
+		c := p.data[p.pos]
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth == 0:
+			p.pos++
+			return buf.String(), nil
+		case c == ')':
+			depth--
+		case c == '\\' && p.pos+1 < len(p.data):
+			p.pos++
+			c = p.data[p.pos]
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				continue
+			}
+			if c >= '0' && c <= '7' {
+				n := 0
+				for j := 0; j < 3 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; j++ {
+					n = n*8 + int(p.data[p.pos]-'0')
+					p.pos++
+				}
+				p.pos--
+				c = byte(n)
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return nil, fmt.Errorf("gofpdi: unterminated string in PDF")
+}
+
+// parseHexString parses a hexadecimal string.
+func (p *pdfParser) parseHexString() (interface{}, error) {
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 0 {
+		return nil, fmt.Errorf("gofpdi: unterminated hexadecimal string in PDF")
+	}
+	var digits []byte
+	for _, c := range p.data[p.pos+1 : p.pos+end] {
+		if isHexDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+	p.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, len(digits)/2)
+	for j := range s {
+		n, _ := strconv.ParseUint(string(digits[2*j:2*j+2]), 16, 8)
+		s[j] = byte(n)
+	}
+	return string(s), nil
+}
+
+// parseToken returns the regular characters at the current position. The
+// leading slash of a name is skipped.
+func (p *pdfParser) parseToken() string {
+	if p.pos < len(p.data) && p.data[p.pos] == '/' {
+		p.pos++
+	}
+	start := p.pos
+	for p.pos < len(p.data) && !isDelimiter(p.data[p.pos]) && !isSpace(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// skipSpace skips white space and comments.
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case isSpace(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// isInteger reports whether s is an unsigned decimal integer.
+func isInteger(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
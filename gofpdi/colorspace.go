@@ -0,0 +1,135 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+)
+
+// The device color spaces that GetPageColorSpace reports.
+const (
+	ColorSpaceGray = "/DeviceGray"
+	ColorSpaceRGB  = "/DeviceRGB"
+	ColorSpaceCMYK = "/DeviceCMYK"
+)
+
+// deviceColorRegexps match the operators of content streams that set a color
+// in one of the device color spaces.
+var deviceColorRegexps = map[string]*regexp.Regexp{
+	ColorSpaceGray: regexp.MustCompile(`(?:^|\s)[-+.\d]+\s+[gG](?:\s|$)|/DeviceGray\s+(?:cs|CS)\b`),
+	ColorSpaceRGB:  regexp.MustCompile(`(?:^|\s)(?:[-+.\d]+\s+){3}(?:rg|RG)(?:\s|$)|/DeviceRGB\s+(?:cs|CS)\b`),
+	ColorSpaceCMYK: regexp.MustCompile(`(?:^|\s)(?:[-+.\d]+\s+){4}[kK](?:\s|$)|/DeviceCMYK\s+(?:cs|CS)\b`),
+}
+
+// GetPageColorSpace returns the color spaces that page pageno of the PDF file
+// sourceFile uses, sorted by name, so that callers can tell whether merging
+// it into an RGB document will change its colors. The device color spaces
+// are reported as ColorSpaceGray, ColorSpaceRGB and ColorSpaceCMYK; other
+// color spaces by the name of their family, such as /ICCBased, /Separation
+// for spot colors or /Indexed.
+//
+// The color spaces are collected from the resources of the page, the images
+// it draws and the color operators of its content stream. The content must be
+// uncompressed or compressed with FlateDecode. Imported pages are not
+// converted to another color space.
+func GetPageColorSpace(sourceFile string, pageno int) (colorSpaces []string, err error) {
+	defer recoverError(&err)
+	data, err := ioutil.ReadFile(sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	return readColorSpaces(data, pageno)
+}
+
+// readColorSpaces returns the color spaces used by the given page of the PDF
+// held in data.
+func readColorSpaces(data []byte, pageno int) ([]string, error) {
+	objs, pages := readPages(data)
+	if pageno < 1 || pageno > len(pages) {
+		return nil, fmt.Errorf("gofpdi: page %d not found for color spaces, the PDF has %d readable pages", pageno, len(pages))
+	}
+	page := pages[pageno-1]
+
+	found := map[string]bool{}
+	resources := objs.resolve(objs.inherited(page, "Resources"))
+	colorSpaces, _ := objs.resolve(dictValue(resources, "ColorSpace")).(map[string]interface{})
+	for _, cs := range colorSpaces {
+		found[objs.colorSpaceFamily(cs)] = true
+	}
+	xobjects, _ := objs.resolve(dictValue(resources, "XObject")).(map[string]interface{})
+	for _, xobj := range xobjects {
+		if cs := dictValue(objs.resolve(xobj), "ColorSpace"); cs != nil {
+			found[objs.colorSpaceFamily(cs)] = true
+		}
+	}
+
+	content := objs.pageContent(page)
+	for name, re := range deviceColorRegexps {
+		if re.Match(content) {
+			found[name] = true
+		}
+	}
+
+	var names []string
+	for name := range found {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// inherited returns the value of key in the page dictionary, or in the
+// nearest of its parents that has it, as for the inheritable attributes of
+// pages.
+func (objs pdfObjects) inherited(page map[string]interface{}, key string) interface{} {
+	var node interface{} = page
+	for depth := 0; depth < 64 && node != nil; depth++ {
+		if value := dictValue(node, key); value != nil {
+			return value
+		}
+		node = objs.resolve(dictValue(node, "Parent"))
+	}
+	return nil
+}
+
+// colorSpaceFamily returns the name of the family of the color space cs with
+// a leading slash. cs is a name or an array whose first element is the
+// family.
+func (objs pdfObjects) colorSpaceFamily(cs interface{}) string {
+	cs = objs.resolve(cs)
+	if array, ok := cs.([]interface{}); ok && len(array) > 0 {
+		cs = objs.resolve(array[0])
+	}
+	if name, ok := cs.(pdfName); ok {
+		return "/" + string(name)
+	}
+	return ""
+}
+
+// pageContent returns the concatenated data of the content streams of the
+// page that are uncompressed or compressed with FlateDecode.
+func (objs pdfObjects) pageContent(page map[string]interface{}) []byte {
+	contents, ok := objs.resolve(page["Contents"]).([]interface{})
+	if !ok {
+		contents = []interface{}{page["Contents"]}
+	}
+
+	var content bytes.Buffer
+	for _, c := range contents {
+		stream, ok := objs.resolve(c).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := objs.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		content.Write(data)
+		content.WriteByte('\n')
+	}
+	return content.Bytes()
+}
@@ -0,0 +1,76 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// readPageContent returns the data of the content stream of the given page of
+// the PDF held in data if the page has a single content stream that is only
+// compressed with FlateDecode, and nil otherwise.
+func readPageContent(data []byte, pageno int) []byte {
+	objs, pages := readPages(data)
+	if pageno < 1 || pageno > len(pages) {
+		return nil
+	}
+
+	contents := pages[pageno-1]["Contents"]
+	if array, ok := objs.resolve(contents).([]interface{}); ok && len(array) == 1 {
+		contents = array[0]
+	}
+	stream, ok := objs.resolve(contents).(*pdfStream)
+	if !ok || stream.dict["DecodeParms"] != nil {
+		return nil
+	}
+	filter := objs.resolve(stream.dict["Filter"])
+	if array, ok := filter.([]interface{}); ok && len(array) == 1 {
+		filter = objs.resolve(array[0])
+	}
+	if filter != pdfName("FlateDecode") {
+		return nil
+	}
+
+	return stream.data
+}
+
+// replaceStream returns the form XObject obj written by the gofpdi library
+// with its stream data replaced by stream, or nil if obj is not laid out as
+// gofpdi writes it: the dictionary ends with the length of the stream, which
+// is followed by the stream data and the end of the object. As the length is
+// the last entry, the positions of the object references before it stay
+// valid.
+func replaceStream(obj, stream []byte) []byte {
+	const tail = "\nendstream\nendobj\n"
+	if !bytes.HasSuffix(obj, []byte(tail)) {
+		return nil
+	}
+	// The resources or the stream data may contain the same text, so each
+	// candidate is only accepted if its length leads to the end of obj.
+	for pos := 0; ; pos++ {
+		j := bytes.Index(obj[pos:], []byte("/Length "))
+		if j < 0 {
+			return nil
+		}
+		pos += j
+		digits := pos + len("/Length ")
+		for digits < len(obj) && obj[digits] >= '0' && obj[digits] <= '9' {
+			digits++
+		}
+		length, err := strconv.Atoi(string(obj[pos+len("/Length ") : digits]))
+		if err != nil {
+			continue
+		}
+		head := fmt.Sprintf("/Length %d >>\nstream\n", length)
+		if !bytes.HasPrefix(obj[pos:], []byte(head)) || len(obj)-pos != len(head)+length+len(tail) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		buf.Write(obj[:pos])
+		fmt.Fprintf(&buf, "/Length %d >>\nstream\n", len(stream))
+		buf.Write(stream)
+		buf.WriteString(tail)
+		return buf.Bytes()
+	}
+}
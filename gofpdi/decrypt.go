@@ -0,0 +1,438 @@
+package gofpdi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ErrWrongPassword is returned by ImportPageWithPassword if the password is
+// neither the user nor the owner password of the encrypted PDF.
+var ErrWrongPassword = errors.New("gofpdi: wrong password for the encrypted PDF")
+
+// passwordPadding pads passwords to 32 bytes for the standard security
+// handler.
+var passwordPadding = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41,
+	0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80,
+	0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+// Crypt filter methods of the standard security handler.
+const (
+	cryptNone = iota
+	cryptRC4
+	cryptAES
+)
+
+// securityHandler decrypts the objects of a PDF that is encrypted with the
+// standard security handler of revision 2, 3 or 4.
+type securityHandler struct {
+	key             []byte // File encryption key
+	streams         int    // Crypt filter method of streams
+	strings         int    // Crypt filter method of strings
+	encryptMetadata bool   // Whether metadata streams are encrypted
+}
+
+// decryptPdf returns the PDF held in data decrypted with password, which may
+// be its user or its owner password, or data itself if the PDF is not
+// encrypted. The decrypted PDF is written anew with a cross-reference table
+// and all objects outside of object streams, which the gofpdi library cannot
+// read.
+func decryptPdf(data []byte, password string) ([]byte, error) {
+	entries, trailer := readXref(data)
+	if trailer == nil {
+		return nil, errNoXref
+	}
+	if _, ok := trailer["Encrypt"]; !ok {
+		return data, nil
+	}
+	objs := readDirectObjects(data, entries)
+	encrypt, ok := objs.resolve(trailer["Encrypt"]).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("gofpdi: invalid /Encrypt dictionary in PDF")
+	}
+	var id []byte
+	if ids, ok := objs.resolve(trailer["ID"]).([]interface{}); ok && len(ids) > 0 {
+		first, _ := objs.resolve(ids[0]).(string)
+		id = []byte(first)
+	}
+	sh, err := newSecurityHandler(objs, encrypt, id, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+
+	// The /Encrypt dictionary itself is not encrypted, and neither are
+	// cross-reference streams.
+	encryptRef, _ := trailer["Encrypt"].(pdfRef)
+	for num, obj := range objs {
+		if num == int(encryptRef) || dictValue(obj, "Type") == pdfName("XRef") {
+			continue
+		}
+		if objs[num], err = sh.decryptObject(obj, num, entries[num].gen); err != nil {
+			return nil, err
+		}
+	}
+	objs.readStreamObjects(entries)
+	delete(objs, int(encryptRef))
+	return writePdf(data, objs, trailer), nil
+}
+
+// newSecurityHandler returns the security handler that the /Encrypt
+// dictionary encrypt describes, authenticating password as the user or the
+// owner password. id is the first element of the /ID array of the trailer.
+func newSecurityHandler(objs pdfObjects, encrypt map[string]interface{}, id, password []byte) (*securityHandler, error) {
+	if objs.resolve(encrypt["Filter"]) != pdfName("Standard") {
+		return nil, fmt.Errorf("gofpdi: unsupported security handler %v in PDF", objs.resolve(encrypt["Filter"]))
+	}
+	v, _ := objs.resolve(encrypt["V"]).(float64)
+	r, _ := objs.resolve(encrypt["R"]).(float64)
+	if r < 2 || r > 4 || (v != 1 && v != 2 && v != 4) {
+		return nil, fmt.Errorf("gofpdi: unsupported encryption /V %v /R %v in PDF", v, r)
+	}
+	length, ok := objs.resolve(encrypt["Length"]).(float64)
+	if !ok {
+		length = 40
+	}
+	if length < 40 || length > 128 || int(length)%8 != 0 {
+		return nil, fmt.Errorf("gofpdi: invalid key length %v in PDF", length)
+	}
+	o, _ := objs.resolve(encrypt["O"]).(string)
+	u, _ := objs.resolve(encrypt["U"]).(string)
+	p, _ := objs.resolve(encrypt["P"]).(float64)
+	if len(o) < 32 || len(u) < 32 {
+		return nil, fmt.Errorf("gofpdi: invalid /O or /U entry in PDF")
+	}
+
+	var err error
+	sh := &securityHandler{streams: cryptRC4, strings: cryptRC4, encryptMetadata: true}
+	if em, ok := objs.resolve(encrypt["EncryptMetadata"]).(bool); ok && v == 4 {
+		sh.encryptMetadata = em
+	}
+	if v == 4 {
+		// Crypt filters select the method for streams and strings; the
+		// key is always 128 bits long.
+		length = 128
+		filters, _ := objs.resolve(encrypt["CF"]).(map[string]interface{})
+		if sh.streams, err = cryptMethod(objs, filters, encrypt["StmF"]); err != nil {
+			return nil, err
+		}
+		if sh.strings, err = cryptMethod(objs, filters, encrypt["StrF"]); err != nil {
+			return nil, err
+		}
+	}
+
+	n := int(length) / 8
+	if r == 2 {
+		n = 5
+	}
+	userKey := func(user []byte) []byte {
+		return fileKey(user, []byte(o[:32]), int32(p), id, int(r), n, sh.encryptMetadata)
+	}
+	check := func(key []byte) bool {
+		value := userValue(key, id, int(r))
+		return bytes.Equal(value, []byte(u[:len(value)]))
+	}
+
+	// The password is tried as the user password first. The owner password
+	// decrypts /O to the padded user password.
+	if key := userKey(padPassword(password)); check(key) {
+		sh.key = key
+		return sh, nil
+	}
+	if key := userKey(ownerUserPassword(password, []byte(o[:32]), int(r), n)); check(key) {
+		sh.key = key
+		return sh, nil
+	}
+	return nil, ErrWrongPassword
+}
+
+// cryptMethod returns the method of the crypt filter with the given name.
+func cryptMethod(objs pdfObjects, filters map[string]interface{}, name interface{}) (int, error) {
+	name = objs.resolve(name)
+	if name == nil || name == pdfName("Identity") {
+		return cryptNone, nil
+	}
+	filter, _ := objs.resolve(filters[fmt.Sprint(name)]).(map[string]interface{})
+	switch method := objs.resolve(filter["CFM"]); method {
+	case pdfName("V2"):
+		return cryptRC4, nil
+	case pdfName("AESV2"):
+		return cryptAES, nil
+	case nil, pdfName("None"):
+		return cryptNone, nil
+	default:
+		return 0, fmt.Errorf("gofpdi: unsupported crypt filter method %v in PDF", method)
+	}
+}
+
+// padPassword returns password truncated or padded to 32 bytes.
+func padPassword(password []byte) []byte {
+	return append(append([]byte{}, password...), passwordPadding...)[:32]
+}
+
+// fileKey returns the file encryption key of length n for the padded user
+// password.
+func fileKey(user, o []byte, p int32, id []byte, r, n int, encryptMetadata bool) []byte {
+	h := md5.New()
+	h.Write(user)
+	h.Write(o)
+	binary.Write(h, binary.LittleEndian, p)
+	h.Write(id)
+	if r >= 4 && !encryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	sum := h.Sum(nil)
+	if r >= 3 {
+		for j := 0; j < 50; j++ {
+			next := md5.Sum(sum[:n])
+			sum = next[:]
+		}
+	}
+	return sum[:n]
+}
+
+// userValue returns the /U value for the file encryption key, of which only
+// the first 16 bytes are significant from revision 3.
+func userValue(key, id []byte, r int) []byte {
+	if r == 2 {
+		return rc4XOR(key, passwordPadding)
+	}
+	sum := md5.Sum(append(append([]byte{}, passwordPadding...), id...))
+	value := sum[:]
+	for j := 0; j < 20; j++ {
+		value = rc4XOR(xorKey(key, byte(j)), value)
+	}
+	return value
+}
+
+// ownerUserPassword returns the padded user password that /O holds
+// encrypted with the owner password.
+func ownerUserPassword(owner, o []byte, r, n int) []byte {
+	sum := md5.Sum(padPassword(owner))
+	key := sum[:]
+	if r >= 3 {
+		for j := 0; j < 50; j++ {
+			next := md5.Sum(key)
+			key = next[:]
+		}
+	}
+	key = key[:n]
+	if r == 2 {
+		return rc4XOR(key, o)
+	}
+	user := o
+	for j := 19; j >= 0; j-- {
+		user = rc4XOR(xorKey(key, byte(j)), user)
+	}
+	return user
+}
+
+// xorKey returns key with each byte XORed with b.
+func xorKey(key []byte, b byte) []byte {
+	x := make([]byte, len(key))
+	for j := range key {
+		x[j] = key[j] ^ b
+	}
+	return x
+}
+
+// rc4XOR returns data encrypted or decrypted with RC4 and key.
+func rc4XOR(key, data []byte) []byte {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// decryptObject returns obj, the object with the given number and
+// generation, with its strings and stream data decrypted.
+func (sh *securityHandler) decryptObject(obj interface{}, num, gen int) (interface{}, error) {
+	switch o := obj.(type) {
+	case string:
+		s, err := sh.decrypt(sh.strings, []byte(o), num, gen)
+		return string(s), err
+	case []interface{}:
+		for j, v := range o {
+			var err error
+			if o[j], err = sh.decryptObject(v, num, gen); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		for k, v := range o {
+			var err error
+			if o[k], err = sh.decryptObject(v, num, gen); err != nil {
+				return nil, err
+			}
+		}
+	case *pdfStream:
+		if _, err := sh.decryptObject(o.dict, num, gen); err != nil {
+			return nil, err
+		}
+		if o.dict["Type"] == pdfName("Metadata") && !sh.encryptMetadata {
+			return o, nil
+		}
+		var err error
+		o.data, err = sh.decrypt(sh.streams, o.data, num, gen)
+		return o, err
+	}
+	return obj, nil
+}
+
+// decrypt returns data of the object with the given number and generation
+// decrypted with method.
+func (sh *securityHandler) decrypt(method int, data []byte, num, gen int) ([]byte, error) {
+	if method == cryptNone {
+		return data, nil
+	}
+	h := md5.New()
+	h.Write(sh.key)
+	h.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), byte(gen), byte(gen >> 8)})
+	if method == cryptAES {
+		h.Write([]byte("sAlT"))
+	}
+	key := h.Sum(nil)
+	if len(sh.key)+5 < len(key) {
+		key = key[:len(sh.key)+5]
+	}
+	if method == cryptRC4 {
+		return rc4XOR(key, data), nil
+	}
+
+	// AES data starts with the initialization vector and is padded to
+	// whole blocks.
+	if len(data) == 0 {
+		return data, nil
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("gofpdi: invalid AES encrypted data in object %d", num)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+	pad := int(out[len(out)-1])
+	if pad < 1 || pad > aes.BlockSize {
+		return nil, fmt.Errorf("gofpdi: invalid AES padding in object %d", num)
+	}
+	return out[:len(out)-pad], nil
+}
+
+// writePdf returns a PDF of objs, numbered by their keys, with a
+// cross-reference table and the /Root, /Info and /ID entries of trailer. The
+// header is copied from data. Object and cross-reference streams are left
+// out, since their objects are held in objs directly.
+func writePdf(data []byte, objs pdfObjects, trailer map[string]interface{}) []byte {
+	var buf bytes.Buffer
+	header := "%PDF-1.4"
+	if bytes.HasPrefix(data, []byte("%PDF-1.")) && len(data) >= 8 {
+		header = string(data[:8])
+	}
+	buf.WriteString(header + "\n%\xe2\xe3\xcf\xd3\n")
+
+	var nums []int
+	for num, obj := range objs {
+		if t := dictValue(obj, "Type"); t != pdfName("ObjStm") && t != pdfName("XRef") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	size := 1
+	if len(nums) > 0 {
+		size = nums[len(nums)-1] + 1
+	}
+	offsets := make([]int, size)
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", num)
+		if s, ok := objs[num].(*pdfStream); ok {
+			dict := make(map[string]interface{}, len(s.dict))
+			for k, v := range s.dict {
+				dict[k] = v
+			}
+			dict["Length"] = float64(len(s.data))
+			writeObject(&buf, dict)
+			buf.WriteString("\nstream\n")
+			buf.Write(s.data)
+			buf.WriteString("\nendstream")
+		} else {
+			writeObject(&buf, objs[num])
+		}
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n", size)
+	for _, offset := range offsets {
+		if offset == 0 {
+			buf.WriteString("0000000000 65535 f \n")
+		} else {
+			fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+		}
+	}
+	dict := map[string]interface{}{"Size": float64(size)}
+	for _, key := range []string{"Root", "Info", "ID"} {
+		if v, ok := trailer[key]; ok {
+			dict[key] = v
+		}
+	}
+	buf.WriteString("trailer\n")
+	writeObject(&buf, dict)
+	fmt.Fprintf(&buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// writeObject writes obj to buf in PDF syntax. Strings are written in
+// hexadecimal and references with generation 0.
+func writeObject(buf *bytes.Buffer, obj interface{}) {
+	switch o := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(o))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(o, 'f', -1, 64))
+	case string:
+		fmt.Fprintf(buf, "<%x>", o)
+	case pdfName:
+		buf.WriteString("/" + string(o))
+	case pdfRef:
+		fmt.Fprintf(buf, "%d 0 R", int(o))
+	case []interface{}:
+		buf.WriteString("[")
+		for j, v := range o {
+			if j > 0 {
+				buf.WriteString(" ")
+			}
+			writeObject(buf, v)
+		}
+		buf.WriteString("]")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			buf.WriteString("/" + k + " ")
+			writeObject(buf, o[k])
+			buf.WriteString(" ")
+		}
+		buf.WriteString(">>")
+	}
+}
@@ -3,14 +3,37 @@ Package gofpdi wraps the gofpdi PDF library to import existing PDFs as templates
 for further information and examples.
 
 Users should call NewImporter() to obtain their own Importer instance to work with.
-To retain backwards compatibility, the package offers a default Importer that may be used via global functions. Note
-however that use of the default Importer is not thread safe.
+To retain backwards compatibility, the package offers global functions as well. They keep a separate Importer for each
+PDF they import pages into, so documents that are built at the same time do not affect each other. The Importers keep
+a reference to their PDF, so Release must be called once a PDF is done: until then neither the PDF nor its imported
+pages can be garbage collected. Reset releases all PDFs at once.
+
+Pages are numbered from 1. All import functions, GetPageSize and GetPageBoxes also accept 0 for the first page and
+negative page numbers, which count from the end of the PDF: -1 is the last page, -2 the one before it and so on.
+
+The gofpdi library cannot decrypt PDFs, so the import functions report ErrEncrypted for encrypted or
+password-protected PDFs. Import their pages with ImportPageWithPassword instead, which decrypts PDFs encrypted with RC4
+or 128-bit AES given their user or owner password.
 */
 package gofpdi
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jung-kurt/gofpdf/v2"
 	realgofpdi "github.com/phpdave11/gofpdi"
 	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 // gofpdiPdf is a partial interface that only implements the functions we need
@@ -23,61 +46,608 @@ type gofpdiPdf interface {
 	SetError(err error)
 }
 
-// Importer wraps an Importer from the gofpdi library.
+// gofpdiPagePdf extends gofpdiPdf with the functions that ImportAllPages()
+// needs to add pages of matching size.
+type gofpdiPagePdf interface {
+	gofpdiPdf
+	AddPageFormat(orientationStr string, size gofpdf.SizeType)
+	GetConversionRatio() float64
+}
+
+// gofpdiSheetPdf extends gofpdiPdf with the functions that ImportNUp() needs
+// to add sheets.
+type gofpdiSheetPdf interface {
+	gofpdiPdf
+	AddPage()
+	GetConversionRatio() float64
+	GetPageSize() (width, height float64)
+}
+
+// gofpdiWatermarkPdf extends gofpdiPdf with the functions that Watermark()
+// needs to draw translucent pages.
+type gofpdiWatermarkPdf interface {
+	gofpdiPdf
+	GetAlpha() (alpha float64, blendModeStr string)
+	SetAlpha(alpha float64, blendModeStr string)
+	GetPageSize() (width, height float64)
+}
+
+// gofpdiTransformPdf extends gofpdiPdf with the functions that
+// UseImportedTemplateRotated() needs to rotate templates.
+type gofpdiTransformPdf interface {
+	gofpdiPdf
+	TransformBegin()
+	TransformRotate(angle, x, y float64)
+	TransformEnd()
+}
+
+// gofpdiLinkPdf extends gofpdiPdf with the function that UseImportedTemplate()
+// needs to recreate the link annotations of pages imported with
+// ImportPageWithAnnots().
+type gofpdiLinkPdf interface {
+	gofpdiPdf
+	LinkString(x, y, w, h float64, linkStr string)
+}
+
+// The page boxes that can be passed as the box argument of the import
+// functions.
+const (
+	BoxMedia = "/MediaBox"
+	BoxCrop  = "/CropBox"
+	BoxBleed = "/BleedBox"
+	BoxTrim  = "/TrimBox"
+	BoxArt   = "/ArtBox"
+)
+
+// validateBox returns an error if box is not one of the Box constants.
+func validateBox(box string) error {
+	switch box {
+	case BoxMedia, BoxCrop, BoxBleed, BoxTrim, BoxArt:
+		return nil
+	}
+	return fmt.Errorf("gofpdi: invalid box %q, expected one of %s, %s, %s, %s or %s",
+		box, BoxMedia, BoxCrop, BoxBleed, BoxTrim, BoxArt)
+}
+
+// Importer wraps an Importer from the gofpdi library. An Importer keeps the
+// state of every source file it imports pages from, so pages of several
+// files can be imported into one PDF in any order, for instance to put a
+// cover, the body and an appendix together. Templates of different Importers
+// can be drawn on the same PDF as well.
 type Importer struct {
-	fpdi *realgofpdi.Importer
+	fpdi     *realgofpdi.Importer
+	sizes    map[int][2]float64        // Template sizes in points by template id
+	links    map[int][]linkAnnot       // Link annotations relative to the page box by template id
+	rotation map[int]int               // Angles by which templates are turned upright by template id
+	once     map[onceKey]int           // Template ids of pages imported with ImportPage into oncePdf
+	oncePdf  gofpdiPdf                 // PDF that the pages in once were imported into
+	names    map[int]string            // Template names in the PDF by template id
+	content  map[string][]byte         // Content streams of pages imported with ImportPageCompressed by form XObject hash
+	raw      map[string]bool           // Hashes of form XObjects drawn without the rotation of their source page
+	plain    map[string]bool           // Source files known not to be encrypted
+	streams  map[string]*io.ReadSeeker // Source streams by content hash
+	salt     string                    // Content hash of the current source stream, empty for source files
+	noRotate bool                      // Whether pages are imported without the rotation of their source page
+	client   *http.Client              // Client for downloads, nil for HTTPClient
+}
+
+// onceKey identifies a page imported with ImportPage.
+type onceKey struct {
+	sourceFile string
+	pageno     int
+	box        string
 }
 
 // NewImporter creates a new Importer wrapping functionality from the gofpdi library.
 func NewImporter() *Importer {
 	return &Importer{
-		fpdi: realgofpdi.NewImporter(),
+		fpdi:     realgofpdi.NewImporter(),
+		sizes:    make(map[int][2]float64),
+		links:    make(map[int][]linkAnnot),
+		rotation: make(map[int]int),
+		once:     make(map[onceKey]int),
+		names:    make(map[int]string),
+		content:  make(map[string][]byte),
+		raw:      make(map[string]bool),
+		plain:    make(map[string]bool),
+		streams:  make(map[string]*io.ReadSeeker),
+	}
+}
+
+// ErrEncrypted is returned for PDFs that are encrypted, which the gofpdi
+// library cannot import pages from. Use ImportPageWithPassword for them.
+var ErrEncrypted = errors.New("gofpdi: the PDF is encrypted, import it with ImportPageWithPassword")
+
+// errNoXref is returned for PDFs without a startxref keyword near their end,
+// which the gofpdi library searches for without ever giving up.
+var errNoXref = errors.New("gofpdi: no startxref found at the end of the PDF")
+
+// checkSource returns an error if the PDF held in data cannot be imported:
+// ErrEncrypted if it is encrypted and errNoXref if the gofpdi library would
+// not find its cross-reference table. The gofpdi library looks for startxref
+// in the last 1500 bytes only.
+func checkSource(data []byte) error {
+	tail := data
+	if len(tail) > 1500 {
+		tail = tail[len(tail)-1500:]
+	}
+	if !bytes.Contains(tail, []byte("startxref")) {
+		return errNoXref
+	}
+	if encrypted(data) {
+		return ErrEncrypted
+	}
+	return nil
+}
+
+// setSourceFile makes sourceFile the current source of the gofpdi library,
+// unless checkSource rejects it. Each source file is checked only once.
+func (i *Importer) setSourceFile(sourceFile string) error {
+	if !i.plain[sourceFile] {
+		data, err := ioutil.ReadFile(sourceFile)
+		if err != nil {
+			return err
+		}
+		if err = checkSource(data); err != nil {
+			return err
+		}
+		i.plain[sourceFile] = true
+	}
+	i.salt = ""
+	i.fpdi.SetSourceFile(sourceFile)
+	return nil
+}
+
+// setSourceStream makes the PDF read from rs the current source of the gofpdi
+// library, unless checkSource rejects it.
+func (i *Importer) setSourceStream(rs *io.ReadSeeker) error {
+	if _, err := (*rs).Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(*rs)
+	if err != nil {
+		return err
+	}
+	return i.setSourceData(data)
+}
+
+// setSourceData makes the PDF held in data the current source of the gofpdi
+// library, unless checkSource rejects it. The gofpdi library keeps the
+// sources it has read by the address of their stream, which is reused for
+// other sources once a stream is freed. So each source is read from a stream that
+// the Importer keeps by the hash of its content.
+func (i *Importer) setSourceData(data []byte) error {
+	if err := checkSource(data); err != nil {
+		return err
+	}
+	sum := sha1.Sum(data)
+	key := hex.EncodeToString(sum[:])
+	rs, ok := i.streams[key]
+	if !ok {
+		var r io.ReadSeeker = bytes.NewReader(data)
+		rs = &r
+		i.streams[key] = rs
 	}
+	i.salt = key
+	i.fpdi.SetSourceStream(rs)
+	return nil
 }
 
 // ImportPage imports a page of a PDF file with the specified box (/MediaBox,
 // /TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id that can
 // be used with UseImportedTemplate to draw the template onto the page.
+//
+// Each page is imported into f only once: later calls with the same PDF,
+// source file, page number and box return the template id of the first
+// import without parsing the source file again. This suits a page that is
+// drawn on many pages, such as the background of certificates, which can then
+// be imported right where it is used. Earlier versions imported the page again
+// on every call and returned a new template id each time. The Importer
+// remembers the pages of the last PDF it imported them into, so pages are
+// imported again once another PDF has been used in between. It keeps a
+// reference to that PDF until Release is called with it.
+//
+// Pages are numbered from 1. A pageno of 0 selects the first page, and
+// negative page numbers count from the end, so -1 selects the last page.
+// Other page numbers outside of the PDF are an error.
 func (i *Importer) ImportPage(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	tpl, err := i.ImportPageE(f, sourceFile, pageno, box)
+	if err != nil {
+		f.SetError(err)
+	}
+	return tpl
+}
+
+// ImportPageE works like ImportPage but returns any error, such as a missing
+// source file or an invalid page number, instead of setting it on the PDF.
+func (i *Importer) ImportPageE(f gofpdiPdf, sourceFile string, pageno int, box string) (tpl int, err error) {
+	defer recoverError(&err)
+	if err = validateBox(box); err != nil {
+		return 0, err
+	}
+	if f != i.oncePdf {
+		i.once = make(map[onceKey]int)
+		i.oncePdf = f
+	}
+	key := onceKey{sourceFile, pageno, box}
+	if tpl, ok := i.once[key]; ok {
+		return tpl, nil
+	}
+	if tpl, err = i.importPage(f, sourceFile, pageno, box); err != nil {
+		return 0, err
+	}
+	i.once[key] = tpl
+	return tpl, nil
+}
+
+// Release drops the reference to f that ImportPage keeps to import each page
+// into f only once. Call it once f has been output. Pages imported into f
+// afterwards are parsed and imported again.
+func (i *Importer) Release(f gofpdiPdf) {
+	if f == i.oncePdf {
+		i.once = make(map[onceKey]int)
+		i.oncePdf = nil
+	}
+}
+
+// importPage imports a page of a PDF file as a new template, whether it has
+// been imported before or not.
+func (i *Importer) importPage(f gofpdiPdf, sourceFile string, pageno int, box string) (tpl int, err error) {
+	defer recoverError(&err)
 	// Set source file for fpdi
-	i.fpdi.SetSourceFile(sourceFile)
+	if err = i.setSourceFile(sourceFile); err != nil {
+		return 0, err
+	}
+	if pageno, err = i.pageNumber(pageno); err != nil {
+		return 0, err
+	}
 	// return template id
-	return i.getTemplateID(f, pageno, box)
+	return i.getTemplateID(f, pageno, box, nil), nil
+}
+
+// pageNumber returns the number from 1 of the page of the current source that
+// pageno selects, resolving 0 and negative page numbers, or an error if
+// there is no such page.
+func (i *Importer) pageNumber(pageno int) (int, error) {
+	n := len(i.fpdi.GetPageSizes())
+	resolved := pageno
+	switch {
+	case pageno == 0:
+		resolved = 1
+	case pageno < 0:
+		resolved = n + 1 + pageno
+	}
+	if resolved < 1 || resolved > n {
+		return 0, fmt.Errorf("gofpdi: page %d not found, the PDF has %d pages", pageno, n)
+	}
+	return resolved, nil
+}
+
+// ImportPageCompressed works like ImportPage but passes the content stream of
+// the page through unchanged if it is compressed with FlateDecode. The gofpdi
+// library otherwise decompresses the content and compresses it again at its
+// own compression level, which may inflate the output of sources that were
+// compressed more strongly, and takes time for large pages. If the content
+// cannot be passed through, because the page has several content streams or
+// other filters, the page is imported as with ImportPage.
+func (i *Importer) ImportPageCompressed(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	tpl, err := i.ImportPageCompressedE(f, sourceFile, pageno, box)
+	if err != nil {
+		f.SetError(err)
+	}
+	return tpl
+}
+
+// ImportPageCompressedE works like ImportPageCompressed but returns any error
+// instead of setting it on the PDF.
+func (i *Importer) ImportPageCompressedE(f gofpdiPdf, sourceFile string, pageno int, box string) (tpl int, err error) {
+	defer recoverError(&err)
+	if err = validateBox(box); err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadFile(sourceFile)
+	if err != nil {
+		return 0, err
+	}
+	if err = i.setSourceFile(sourceFile); err != nil {
+		return 0, err
+	}
+	if pageno, err = i.pageNumber(pageno); err != nil {
+		return 0, err
+	}
+	return i.getTemplateID(f, pageno, box, readPageContent(data, pageno)), nil
+}
+
+// ImportPageOnce is the same as ImportPage, which imports each page into f
+// only once. The template id may be used with UseImportedTemplate on any page
+// of f.
+func (i *Importer) ImportPageOnce(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	return i.ImportPage(f, sourceFile, pageno, box)
+}
+
+// ImportPageWithAnnots works like ImportPage but also imports the link
+// annotations of the page, which UseImportedTemplate then adds to the page it
+// draws the template on, scaled and moved along with the template. Besides the
+// functions used by ImportPage, f must implement LinkString() as gofpdf.Fpdf
+// does.
+//
+// Only links to URIs are imported. Links to destinations within the source
+// PDF, other annotations and interactive forms (AcroForm) are not. Links are
+// not rotated by UseImportedTemplateRotated.
+func (i *Importer) ImportPageWithAnnots(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	tpl, err := i.ImportPageWithAnnotsE(f, sourceFile, pageno, box)
+	if err != nil {
+		f.SetError(err)
+	}
+	return tpl
+}
+
+// ImportPageWithAnnotsE works like ImportPageWithAnnots but returns any error
+// instead of setting it on the PDF.
+func (i *Importer) ImportPageWithAnnotsE(f gofpdiPdf, sourceFile string, pageno int, box string) (tpl int, err error) {
+	defer recoverError(&err)
+	if err = validateBox(box); err != nil {
+		return 0, err
+	}
+	// The annotations belong to the template, so it is not shared with
+	// ImportPage.
+	if tpl, err = i.importPage(f, sourceFile, pageno, box); err != nil {
+		return tpl, err
+	}
+	// The source is still current, so the page number resolves as on import.
+	pageno, _ = i.pageNumber(pageno)
+	data, err := ioutil.ReadFile(sourceFile)
+	if err != nil {
+		return tpl, err
+	}
+	links, err := readLinkAnnots(data, pageno)
+	if err != nil {
+		return tpl, err
+	}
+	// The links are moved and turned with the page like the form XObject.
+	size := i.fpdi.GetPageSizes()[pageno][box]
+	for j := range links {
+		links[j].rect[0] -= size["llx"]
+		links[j].rect[1] -= size["lly"]
+		links[j].rect[2] -= size["llx"]
+		links[j].rect[3] -= size["lly"]
+		links[j].rect = rotateRect(links[j].rect, i.rotation[tpl], size["w"], size["h"])
+	}
+	i.links[tpl] = links
+	return tpl, nil
+}
+
+// ImportPageWithPassword imports a page of the encrypted PDF file sourceFile
+// like ImportPageE, decrypting it with password, which may be its user or its
+// owner password. ErrWrongPassword is returned if it is neither. PDFs
+// encrypted with the standard security handler of revision 2 to 4, which use
+// RC4 or AES with 128-bit keys, are supported. Unencrypted PDFs are imported
+// as they are, whatever the password.
+func (i *Importer) ImportPageWithPassword(f gofpdiPdf, sourceFile, password string, pageno int, box string) (tpl int, err error) {
+	defer recoverError(&err)
+	if err = validateBox(box); err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadFile(sourceFile)
+	if err != nil {
+		return 0, err
+	}
+	if data, err = decryptPdf(data, password); err != nil {
+		return 0, err
+	}
+	if err = i.setSourceData(data); err != nil {
+		return 0, err
+	}
+	if pageno, err = i.pageNumber(pageno); err != nil {
+		return 0, err
+	}
+	return i.getTemplateID(f, pageno, box, nil), nil
 }
 
 // ImportPageFromStream imports a page of a PDF with the specified box
 // (/MediaBox, TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id
 // that can be used with UseImportedTemplate to draw the template onto the
-// page.
+// page. The PDF is read from the start of rs, which may be reused for other
+// PDFs afterwards.
 func (i *Importer) ImportPageFromStream(f gofpdiPdf, rs *io.ReadSeeker, pageno int, box string) int {
+	tpl, err := i.importPageFromStream(f, rs, pageno, box)
+	if err != nil {
+		f.SetError(err)
+	}
+	return tpl
+}
+
+// importPageFromStream imports a page of the PDF read from rs and returns any
+// error.
+func (i *Importer) importPageFromStream(f gofpdiPdf, rs *io.ReadSeeker, pageno int, box string) (tpl int, err error) {
+	defer recoverError(&err)
+	if err = validateBox(box); err != nil {
+		return 0, err
+	}
 	// Set source stream for fpdi
-	i.fpdi.SetSourceStream(rs)
+	if err = i.setSourceStream(rs); err != nil {
+		return 0, err
+	}
+	if pageno, err = i.pageNumber(pageno); err != nil {
+		return 0, err
+	}
+	// return template id
+	return i.getTemplateID(f, pageno, box, nil), nil
+}
+
+// ImportPageFromBytes imports a page of a PDF held in data with the specified
+// box (/MediaBox, TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a
+// template id that can be used with UseImportedTemplate to draw the template
+// onto the page.
+func (i *Importer) ImportPageFromBytes(f gofpdiPdf, data []byte, pageno int, box string) int {
+	var rs io.ReadSeeker = bytes.NewReader(data)
+	return i.ImportPageFromStream(f, &rs, pageno, box)
+}
+
+// ImportPageFromURL downloads a PDF with the client set with SetHTTPClient,
+// or HTTPClient by default, and imports a page of it with the specified box
+// (/MediaBox, TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id that can be used with UseImportedTemplate
+// to draw the template onto the page. An error is returned if the download
+// fails, the server does not respond with status 200 or the response is not a
+// PDF.
+func (i *Importer) ImportPageFromURL(f gofpdiPdf, urlStr string, pageno int, box string) (tpl int, err error) {
+	return i.ImportPageFromURLContext(context.Background(), f, urlStr, pageno, box)
+}
+
+// ImportPageFromURLContext works like ImportPageFromURL but downloads the PDF
+// with the given context. If the context is canceled or its deadline expires
+// before the download is complete, the context error is returned.
+func (i *Importer) ImportPageFromURLContext(ctx context.Context, f gofpdiPdf, urlStr string, pageno int, box string) (tpl int, err error) {
+	defer recoverError(&err)
+	if err = validateBox(box); err != nil {
+		return 0, err
+	}
+	client := i.client
+	if client == nil {
+		client = HTTPClient
+	}
+	data, err := downloadPdf(ctx, client, urlStr)
+	if err != nil {
+		return 0, err
+	}
+	// Set source stream for fpdi
+	if err = i.setSourceData(data); err != nil {
+		return 0, err
+	}
+	if pageno, err = i.pageNumber(pageno); err != nil {
+		return 0, err
+	}
 	// return template id
-	return i.getTemplateID(f, pageno, box)
+	return i.getTemplateID(f, pageno, box, nil), nil
+}
+
+// SetHTTPClient sets the client that ImportPageFromURL and
+// ImportPageFromURLContext use to download PDFs, for instance to go through a
+// proxy, to trust other certificates or to authenticate. A nil client
+// restores the default, HTTPClient.
+func (i *Importer) SetHTTPClient(c *http.Client) {
+	i.client = c
+}
+
+// downloadPdf returns the PDF at urlStr, downloaded with client. The context
+// error is returned if ctx ends before the download is complete.
+func downloadPdf(ctx context.Context, client *http.Client, urlStr string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gofpdi: downloading %s: %s", urlStr, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	// Servers often do not know the type of stored files, so anything that
+	// starts like a PDF is accepted as well
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/pdf" && !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("gofpdi: %s is not a PDF but %q", urlStr, contentType)
+	}
+	return data, nil
 }
 
-func (i *Importer) getTemplateID(f gofpdiPdf, pageno int, box string) int {
+// getTemplateID imports the page and its objects into f. If content is not nil,
+// it is the compressed content stream of the page that is passed through
+// instead of the one compressed again by gofpdi.
+func (i *Importer) getTemplateID(f gofpdiPdf, pageno int, box string, content []byte) int {
 	// Import page
 	tpl := i.fpdi.ImportPage(pageno, box)
+	size := i.fpdi.GetPageSizes()[pageno][box]
+	i.sizes[tpl] = [2]float64{size["w"], size["h"]}
+	tplName, _, _, _, _ := i.fpdi.UseTemplate(tpl, 0, 0, 1, 1)
 
 	// Import objects into current pdf document
 	// Unordered means that the objects will be returned with a sha1 hash instead of an integer
 	// The objects themselves may have references to other hashes which will be replaced in ImportObjects()
 	tplObjIDs := i.fpdi.PutFormXobjectsUnordered()
+	for name, hash := range tplObjIDs {
+		tplObjIDs[name] = i.saltHash(hash)
+	}
+
+	// gofpdi numbers the templates of each source from the number of
+	// templates imported before, so templates of different sources, or of
+	// different Importers, end up with the same name. The hash of the form
+	// XObject includes the source, which makes it a unique name.
+	hash := tplObjIDs[tplName]
+	i.names[tpl] = templateName(hash)
+	if i.noRotate {
+		i.raw[hash] = true
+	}
+	if content != nil {
+		i.content[hash] = content
+	}
 
 	// Set template names and ids (hashes) in gofpdf
-	f.ImportTemplates(tplObjIDs)
+	tpls := make(map[string]string, len(tplObjIDs))
+	for _, hash := range tplObjIDs {
+		tpls[templateName(hash)] = hash
+	}
+	f.ImportTemplates(tpls)
 
 	// Get a map[string]string of the imported objects.
 	// The map keys will be the ID of each object.
-	imported := i.fpdi.GetImportedObjectsUnordered()
+	imported := make(map[string][]byte)
+	for hash, obj := range i.fpdi.GetImportedObjectsUnordered() {
+		imported[i.saltHash(hash)] = obj
+	}
+
+	// gofpdi turns pages with a /Rotate entry upright, which swaps the
+	// width and height of pages rotated by 90 or 270 degrees.
+	if !i.noRotate {
+		i.rotation[tpl] = formRotation(imported[hash])
+		if i.rotation[tpl]%180 != 0 {
+			i.sizes[tpl] = [2]float64{size["h"], size["w"]}
+		}
+	}
+
+	// gofpdi writes the form XObjects of all templates of a source every
+	// time, so the matrix and content of each are replaced before it is
+	// imported.
+	for _, hash := range tplObjIDs {
+		if i.raw[hash] {
+			if obj := unrotateForm(imported[hash]); obj != nil {
+				imported[hash] = obj
+			}
+		}
+		if content, ok := i.content[hash]; ok {
+			if obj := replaceStream(imported[hash], content); obj != nil {
+				imported[hash] = obj
+			}
+		}
+	}
 
 	// Import gofpdi objects into gofpdf
 	f.ImportObjects(imported)
 
 	// Get a map[string]map[int]string of the object hashes and their positions within each object,
 	// to be replaced with object ids (integers).
-	importedObjPos := i.fpdi.GetImportedObjHashPos()
+	importedObjPos := make(map[string]map[int]string)
+	for hash, refs := range i.fpdi.GetImportedObjHashPos() {
+		salted := make(map[int]string, len(refs))
+		for pos, ref := range refs {
+			salted[pos] = i.saltHash(ref)
+		}
+		importedObjPos[i.saltHash(hash)] = salted
+	}
 
 	// Import gofpdi object hashes and their positions into gopdf
 	f.ImportObjPos(importedObjPos)
@@ -85,14 +655,173 @@ func (i *Importer) getTemplateID(f gofpdiPdf, pageno int, box string) int {
 	return tpl
 }
 
+// saltHash returns the hash of an object of the current source that is
+// unique to the source. gofpdi hashes the objects of source streams without
+// telling the streams apart, so their hashes are hashed again together with
+// the content hash of the stream. The hashes of source files are unique.
+func (i *Importer) saltHash(hash string) string {
+	if i.salt == "" {
+		return hash
+	}
+	sum := sha1.Sum([]byte(i.salt + hash))
+	return hex.EncodeToString(sum[:])
+}
+
+// templateName returns the name in the PDF of the template whose form XObject
+// has the given hash.
+func templateName(hash string) string {
+	return "/GOFPDITPL" + hash
+}
+
 // UseImportedTemplate draws the template onto the page at x,y. If w is 0, the
 // template will be scaled to fit based on h. If h is 0, the template will be
 // scaled to fit based on w.
 func (i *Importer) UseImportedTemplate(f gofpdiPdf, tplid int, x float64, y float64, w float64, h float64) {
+	if err := i.UseImportedTemplateE(f, tplid, x, y, w, h); err != nil {
+		f.SetError(err)
+	}
+}
+
+// UseImportedTemplateE works like UseImportedTemplate but returns any error,
+// such as an unknown template id, instead of setting it on the PDF.
+func (i *Importer) UseImportedTemplateE(f gofpdiPdf, tplid int, x float64, y float64, w float64, h float64) (err error) {
+	defer recoverError(&err)
+	// The template is scaled to its size, which differs from the size gofpdi
+	// knows for pages imported without their rotation.
+	size, ok := i.sizes[tplid]
+	if ok {
+		w, h = templateSize(size[0], size[1], w, h)
+	}
+
 	// Get values from fpdi
 	tplName, scaleX, scaleY, tX, tY := i.fpdi.UseTemplate(tplid, x, y, w, h)
+	if ok {
+		scaleX, scaleY = w/size[0], h/size[1]
+	}
+	if name, ok := i.names[tplid]; ok {
+		tplName = name
+	}
 
 	f.UseImportedTemplate(tplName, scaleX, scaleY, tX, tY)
+
+	// Recreate the link annotations of pages imported with
+	// ImportPageWithAnnots on the area covered by the template.
+	if links := i.links[tplid]; len(links) > 0 {
+		lf, ok := f.(gofpdiLinkPdf)
+		if !ok {
+			return fmt.Errorf("gofpdi: cannot add the links of template %d, the PDF does not implement LinkString", tplid)
+		}
+		h := i.sizes[tplid][1]
+		for _, l := range links {
+			lf.LinkString(x+l.rect[0]*scaleX, y+(h-l.rect[3])*scaleY,
+				(l.rect[2]-l.rect[0])*scaleX, (l.rect[3]-l.rect[1])*scaleY, l.uri)
+		}
+	}
+	return nil
+}
+
+// templateSize returns the size at which a template of size tplW,tplH is
+// drawn when UseImportedTemplate is called with w,h.
+func templateSize(tplW, tplH, w, h float64) (float64, float64) {
+	switch {
+	case w == 0 && h == 0:
+		return tplW, tplH
+	case w == 0:
+		return h * tplW / tplH, h
+	case h == 0:
+		return w, w * tplH / tplW
+	}
+	return w, h
+}
+
+// SetAutoRotate sets whether pages that are imported afterwards are turned
+// upright according to the /Rotate entry of the source page, as a viewer
+// shows them. This is the default. If autoRotate is false, pages are drawn in
+// the orientation of their page box, and the width and height of pages
+// rotated by 90 or 270 degrees are not swapped.
+func (i *Importer) SetAutoRotate(autoRotate bool) {
+	i.noRotate = !autoRotate
+}
+
+// GetTemplateSize returns the size in points of the template with the given
+// id. ok is false if no template with this id was imported. The size is that
+// of the page as it is drawn, so the width and height of the page box are
+// swapped for pages that are turned upright by 90 or 270 degrees.
+func (i *Importer) GetTemplateSize(tplid int) (w, h float64, ok bool) {
+	size, ok := i.sizes[tplid]
+	return size[0], size[1], ok
+}
+
+// UseImportedTemplateFit draws the template as large as possible into the box
+// at x,y of size boxW,boxH without distorting it. align specifies the position
+// of the template in the box if the aspect ratios differ: it combines "L",
+// "C" or "R" for left, center or right alignment with "T", "M" or "B" for top,
+// middle or bottom alignment. The default is "LT".
+func (i *Importer) UseImportedTemplateFit(f gofpdiPdf, tplid int, x, y, boxW, boxH float64, align string) {
+	size, ok := i.sizes[tplid]
+	if !ok {
+		f.SetError(fmt.Errorf("gofpdi: unknown template id %d", tplid))
+		return
+	}
+	x, y, w, h := fitRect(size[0], size[1], x, y, boxW, boxH, align)
+	i.UseImportedTemplate(f, tplid, x, y, w, h)
+}
+
+// fitRect returns the position and size of a rectangle with the aspect ratio
+// of w,h that fits into the box at x,y of size boxW,boxH with the given
+// alignment.
+func fitRect(w, h, x, y, boxW, boxH float64, align string) (float64, float64, float64, float64) {
+	w, h = fitSize(w, h, boxW, boxH)
+	switch {
+	case strings.Contains(align, "C"):
+		x += (boxW - w) / 2
+	case strings.Contains(align, "R"):
+		x += boxW - w
+	}
+	switch {
+	case strings.Contains(align, "M"):
+		y += (boxH - h) / 2
+	case strings.Contains(align, "B"):
+		y += boxH - h
+	}
+	return x, y, w, h
+}
+
+// UseImportedTemplateRotated draws the template rotated counter-clockwise by
+// angle degrees. w and h are the size of the template before the rotation;
+// the rotated template is placed so that the upper left corner of its
+// bounding box is at x,y. Besides the functions used by UseImportedTemplate, f
+// must implement TransformBegin(), TransformRotate() and TransformEnd() as
+// gofpdf.Fpdf does.
+func (i *Importer) UseImportedTemplateRotated(f gofpdiTransformPdf, tplid int, x, y, w, h, angle float64) {
+	tx, ty, cx, cy := rotatedPlacement(x, y, w, h, angle)
+	f.TransformBegin()
+	f.TransformRotate(angle, cx, cy)
+	i.UseImportedTemplate(f, tplid, tx, ty, w, h)
+	f.TransformEnd()
+}
+
+// rotatedPlacement returns the position tx,ty at which a template of size w,h
+// is drawn before it is rotated by angle degrees about cx,cy, so that the
+// bounding box of the rotated template has its upper left corner at x,y.
+func rotatedPlacement(x, y, w, h, angle float64) (tx, ty, cx, cy float64) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	bw := math.Abs(w*cos) + math.Abs(h*sin)
+	bh := math.Abs(w*sin) + math.Abs(h*cos)
+	cx, cy = x+bw/2, y+bh/2
+	return cx - w/2, cy - h/2, cx, cy
+}
+
+// recoverError assigns the value of a panic of the gofpdi library, which
+// panics on errors, to err.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = fmt.Errorf("gofpdi: %v", r)
+		}
+	}
 }
 
 // GetPageSizes returns page dimensions for all pages of the imported pdf.
@@ -104,32 +833,540 @@ func (i *Importer) GetPageSizes() map[int]map[string]map[string]float64 {
 	return i.fpdi.GetPageSizes()
 }
 
-// Default Importer used by global functions
-var fpdi = NewImporter()
+// Source is a PDF file that is parsed once and serves any number of page
+// imports. Importing many pages of a large PDF through a Source avoids parsing
+// the file for each page.
+type Source struct {
+	imp        *Importer
+	sourceFile string
+}
+
+// NewSource parses the PDF file sourceFile and returns a Source to import its
+// pages from.
+func NewSource(sourceFile string) (src *Source, err error) {
+	defer recoverError(&err)
+	imp := NewImporter()
+	if err = imp.setSourceFile(sourceFile); err != nil {
+		return nil, err
+	}
+	return &Source{imp: imp, sourceFile: sourceFile}, nil
+}
+
+// errSourceClosed is set on the PDF when a closed Source is used.
+var errSourceClosed = errors.New("gofpdi: source is closed")
+
+// NumPages returns the number of pages of the source PDF, or 0 if the source
+// is closed.
+func (s *Source) NumPages() int {
+	if s.imp == nil {
+		return 0
+	}
+	return len(s.imp.fpdi.GetPageSizes())
+}
+
+// ImportPage imports a page of the source PDF with the specified box
+// (/MediaBox, /TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id
+// that can be used with UseImportedTemplate to draw the template onto the
+// page.
+func (s *Source) ImportPage(f gofpdiPdf, pageno int, box string) int {
+	if s.imp == nil {
+		f.SetError(errSourceClosed)
+		return 0
+	}
+	return s.imp.ImportPage(f, s.sourceFile, pageno, box)
+}
+
+// UseImportedTemplate draws a template imported from the source PDF onto the
+// page at x,y. If w is 0, the template will be scaled to fit based on h. If h
+// is 0, the template will be scaled to fit based on w.
+func (s *Source) UseImportedTemplate(f gofpdiPdf, tplid int, x float64, y float64, w float64, h float64) {
+	if s.imp == nil {
+		f.SetError(errSourceClosed)
+		return
+	}
+	s.imp.UseImportedTemplate(f, tplid, x, y, w, h)
+}
+
+// Close releases the parsed source PDF. Templates that were imported from the
+// source cannot be used afterwards. Close always returns nil.
+func (s *Source) Close() error {
+	s.imp = nil
+	return nil
+}
+
+// ImportAllPages imports every page of the PDF file sourceFile with the
+// specified box (/MediaBox, /TrimBox, /ArtBox, /CropBox, or /BleedBox). For
+// each page it adds a page of the size of the box to f and draws the imported
+// page over all of it. Besides the functions used by ImportPage, f must
+// implement AddPageFormat() and GetConversionRatio() as gofpdf.Fpdf does.
+func ImportAllPages(f gofpdiPagePdf, sourceFile string, box string) {
+	if err := validateBox(box); err != nil {
+		f.SetError(err)
+		return
+	}
+	src, err := NewSource(sourceFile)
+	if err != nil {
+		f.SetError(err)
+		return
+	}
+
+	sizes := src.imp.GetPageSizes()
+	k := f.GetConversionRatio()
+	for j := 1; j <= src.NumPages(); j++ {
+		if _, ok := sizes[j][box]; !ok {
+			f.SetError(fmt.Errorf("gofpdi: no %s on page %d of %s", box, j, sourceFile))
+			return
+		}
+		// The page is imported first to know its size after it is
+		// turned upright.
+		tpl := src.ImportPage(f, j, box)
+		w, h, _ := src.imp.GetTemplateSize(tpl)
+		w, h = w/k, h/k
+		f.AddPageFormat("P", gofpdf.SizeType{Wd: w, Ht: h})
+		src.UseImportedTemplate(f, tpl, 0, 0, w, h)
+	}
+}
+
+// MergeFile appends every page of the PDF file sourceFile to f, so that the
+// output contains a faithful copy of the source. Each page is added with the
+// size of its /MediaBox, which may differ from page to page, and the imported
+// page covers all of it. Besides the functions used by ImportPage, f must
+// implement AddPageFormat() and GetConversionRatio() as gofpdf.Fpdf does. See
+// ImportAllPages to import the pages with another box.
+func MergeFile(f gofpdiPagePdf, sourceFile string) {
+	ImportAllPages(f, sourceFile, BoxMedia)
+}
+
+// Watermark imports page pageno of the PDF file sourceFile with its /MediaBox
+// and returns a function that draws it over the current page of f with the
+// given opacity between 0 (transparent) and 1 (opaque). The page is imported
+// once, however often the function is called. The watermark is scaled to fit
+// the page without distortion and centered on it. Pass the function to
+// SetHeaderFunc() to put the watermark on every page. Besides the functions
+// used by ImportPage, f must implement GetAlpha(), SetAlpha() and
+// GetPageSize() as gofpdf.Fpdf does.
+func Watermark(f gofpdiWatermarkPdf, sourceFile string, pageno int, opacity float64) func() {
+	imp := NewImporter()
+	tpl, err := imp.ImportPageE(f, sourceFile, pageno, BoxMedia)
+	if err != nil {
+		f.SetError(err)
+		return func() {}
+	}
+
+	return func() {
+		alpha, blendMode := f.GetAlpha()
+		f.SetAlpha(opacity, "Normal")
+		w, h := f.GetPageSize()
+		imp.UseImportedTemplateFit(f, tpl, 0, 0, w, h, "CM")
+		f.SetAlpha(alpha, blendMode)
+	}
+}
+
+// NUpLayout holds the spacing of the pages that ImportNUpLayout() puts on a
+// sheet, in the unit of measure of the document.
+type NUpLayout struct {
+	Margin float64 // Space between the pages and the edges of the sheet
+	Gutter float64 // Space between adjacent pages
+}
+
+// ImportNUp imports every page of the PDF file sourceFile with the specified
+// box (/MediaBox, /TrimBox, /ArtBox, /CropBox, or /BleedBox) and puts perSheet
+// pages on each page (sheet) that it adds to f, without margins or gutters.
+// See ImportNUpLayout for details.
+func ImportNUp(f gofpdiSheetPdf, sourceFile string, perSheet int, box string) {
+	ImportNUpLayout(f, sourceFile, perSheet, box, NUpLayout{})
+}
+
+// ImportNUpLayout imports every page of the PDF file sourceFile with the
+// specified box and puts perSheet pages on each sheet that it adds to f. The
+// sheets have the current page size of f and are divided into a grid of equal
+// cells, with the number of columns and rows chosen so that the pages are as
+// large as possible. The pages fill the grid row by row; each page is scaled
+// to fit its cell without distortion and centered in it. Besides the functions
+// used by ImportPage, f must implement AddPage(), GetConversionRatio() and
+// GetPageSize() as gofpdf.Fpdf does.
+func ImportNUpLayout(f gofpdiSheetPdf, sourceFile string, perSheet int, box string, layout NUpLayout) {
+	if err := validateBox(box); err != nil {
+		f.SetError(err)
+		return
+	}
+	if perSheet < 1 {
+		f.SetError(fmt.Errorf("gofpdi: invalid number of pages per sheet %d", perSheet))
+		return
+	}
+	src, err := NewSource(sourceFile)
+	if err != nil {
+		f.SetError(err)
+		return
+	}
+
+	// The pages are imported first to know their sizes after they are
+	// turned upright.
+	sizes := src.imp.GetPageSizes()
+	tpls := make([]int, src.NumPages())
+	for j := range tpls {
+		if _, ok := sizes[j+1][box]; !ok {
+			f.SetError(fmt.Errorf("gofpdi: no %s on page %d of %s", box, j+1, sourceFile))
+			return
+		}
+		tpls[j] = src.ImportPage(f, j+1, box)
+	}
+	if len(tpls) == 0 {
+		return
+	}
+
+	k := f.GetConversionRatio()
+	sheetW, sheetH := f.GetPageSize()
+	areaW, areaH := sheetW-2*layout.Margin, sheetH-2*layout.Margin
+	pageW, pageH, _ := src.imp.GetTemplateSize(tpls[0])
+	cols, rows := nUpGrid(perSheet, areaW, areaH, layout.Gutter, pageW, pageH)
+	cellW := (areaW - float64(cols-1)*layout.Gutter) / float64(cols)
+	cellH := (areaH - float64(rows-1)*layout.Gutter) / float64(rows)
+
+	for j, tpl := range tpls {
+		cell := j % perSheet
+		if cell == 0 {
+			f.AddPage()
+		}
+		x := layout.Margin + float64(cell%cols)*(cellW+layout.Gutter)
+		y := layout.Margin + float64(cell/cols)*(cellH+layout.Gutter)
+		pageW, pageH, _ := src.imp.GetTemplateSize(tpl)
+		w, h := fitSize(pageW/k, pageH/k, cellW, cellH)
+		src.UseImportedTemplate(f, tpl, x+(cellW-w)/2, y+(cellH-h)/2, w, h)
+	}
+}
+
+// nUpGrid returns the number of columns and rows of the grid for n pages of
+// size pageW,pageH in an area of size areaW,areaH in which the pages are the
+// largest. Pages side by side are preferred.
+func nUpGrid(n int, areaW, areaH, gutter, pageW, pageH float64) (cols, rows int) {
+	best := -1.0
+	for c := n; c >= 1; c-- {
+		if n%c != 0 {
+			continue
+		}
+		r := n / c
+		cellW := (areaW - float64(c-1)*gutter) / float64(c)
+		cellH := (areaH - float64(r-1)*gutter) / float64(r)
+		if scale := math.Min(cellW/pageW, cellH/pageH); scale > best {
+			best, cols, rows = scale, c, r
+		}
+	}
+	return
+}
+
+// fitSize returns the largest size with the aspect ratio of w,h that fits
+// into boxW,boxH.
+func fitSize(w, h, boxW, boxH float64) (float64, float64) {
+	scale := math.Min(boxW/w, boxH/h)
+	return w * scale, h * scale
+}
+
+// GetNumberOfPages returns the number of pages of the PDF file sourceFile.
+func GetNumberOfPages(sourceFile string) (n int, err error) {
+	defer recoverError(&err)
+	imp := NewImporter()
+	if err = imp.setSourceFile(sourceFile); err != nil {
+		return 0, err
+	}
+	return len(imp.fpdi.GetPageSizes()), nil
+}
+
+// GetNumberOfPagesFromStream returns the number of pages of the PDF read from
+// rs.
+func GetNumberOfPagesFromStream(rs *io.ReadSeeker) (n int, err error) {
+	defer recoverError(&err)
+	imp := NewImporter()
+	if err = imp.setSourceStream(rs); err != nil {
+		return 0, err
+	}
+	return len(imp.fpdi.GetPageSizes()), nil
+}
+
+// GetPageSize returns the width and height in points of the specified box
+// (/MediaBox, /TrimBox, /ArtBox, /CropBox, or /BleedBox) of page pageno of the
+// PDF file sourceFile. Like with ImportPage, a pageno of 0 selects the first
+// page and negative page numbers count from the end.
+func GetPageSize(sourceFile string, pageno int, box string) (w, h float64, err error) {
+	defer recoverError(&err)
+	if err = validateBox(box); err != nil {
+		return 0, 0, err
+	}
+	imp := NewImporter()
+	if err = imp.setSourceFile(sourceFile); err != nil {
+		return 0, 0, err
+	}
+	if pageno, err = imp.pageNumber(pageno); err != nil {
+		return 0, 0, err
+	}
+	size, ok := imp.fpdi.GetPageSizes()[pageno][box]
+	if !ok {
+		return 0, 0, fmt.Errorf("gofpdi: no %s on page %d of %s", box, pageno, sourceFile)
+	}
+	return size["w"], size["h"], nil
+}
+
+// GetPageBoxes returns the width and height in points of all boxes of page
+// pageno of the PDF file sourceFile, keyed by the Box constants. Boxes the
+// page doesn't define take their default from the PDF specification: the
+// /CropBox defaults to the /MediaBox, and the /BleedBox, /TrimBox and /ArtBox
+// default to the /CropBox. Like with ImportPage, a pageno of 0 selects the
+// first page and negative page numbers count from the end.
+func GetPageBoxes(sourceFile string, pageno int) (boxes map[string]gofpdf.SizeType, err error) {
+	defer recoverError(&err)
+	imp := NewImporter()
+	if err = imp.setSourceFile(sourceFile); err != nil {
+		return nil, err
+	}
+	if pageno, err = imp.pageNumber(pageno); err != nil {
+		return nil, err
+	}
+	sizes := imp.fpdi.GetPageSizes()[pageno]
+
+	boxes = make(map[string]gofpdf.SizeType, 5)
+	for _, box := range []string{BoxMedia, BoxCrop, BoxBleed, BoxTrim, BoxArt} {
+		size := sizes[box]
+		switch {
+		case len(size) > 0 || box == BoxMedia:
+			boxes[box] = gofpdf.SizeType{Wd: size["w"], Ht: size["h"]}
+		case box == BoxCrop:
+			boxes[box] = boxes[BoxMedia]
+		default:
+			boxes[box] = boxes[BoxCrop]
+		}
+	}
+	return boxes, nil
+}
+
+// HTTPClient is the client used by ImportPageFromURL and
+// ImportPageFromURLContext to download PDFs unless another one is set with
+// SetHTTPClient. Replace it or change its Timeout to configure the downloads.
+var HTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Importers used by the global functions, one for each PDF that pages are
+// imported into, and the settings that apply to all of them. An entry is
+// only removed by Release or Reset. The mutex serializes the calls of the
+// global functions.
+var (
+	importers  = make(map[gofpdiPdf]*Importer)
+	last       = NewImporter() // Importer used by the last call with a PDF
+	noRotate   bool
+	httpClient *http.Client
+	fpdiMu     sync.Mutex
+)
+
+// importer returns the Importer that the global functions use for f, which
+// is created on first use. fpdiMu must be held.
+func importer(f gofpdiPdf) *Importer {
+	imp, ok := importers[f]
+	if !ok {
+		imp = NewImporter()
+		imp.noRotate = noRotate
+		imp.client = httpClient
+		importers[f] = imp
+	}
+	last = imp
+	return imp
+}
+
+// Release frees the state that the global functions keep for f, including
+// its imported templates, which cannot be used afterwards. It must be called
+// for every PDF passed to the global functions once the PDF has been output,
+// since the state is kept for the life of the program otherwise.
+func Release(f gofpdiPdf) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	if importers[f] == last {
+		last = NewImporter()
+	}
+	delete(importers, f)
+}
+
+// Reset frees the state that the global functions keep for all PDFs, as
+// Release does for one, and restores the default settings of SetAutoRotate
+// and SetHTTPClient.
+func Reset() {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	importers = make(map[gofpdiPdf]*Importer)
+	last = NewImporter()
+	noRotate = false
+	httpClient = nil
+}
 
 // ImportPage imports a page of a PDF file with the specified box (/MediaBox,
 // /TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id that can
 // be used with UseImportedTemplate to draw the template onto the page.
-// Note: This uses the default Importer. Call NewImporter() to obtain a custom Importer.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
 func ImportPage(f gofpdiPdf, sourceFile string, pageno int, box string) int {
-	return fpdi.ImportPage(f, sourceFile, pageno, box)
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPage(f, sourceFile, pageno, box)
+}
+
+// SetAutoRotate sets whether pages that are imported afterwards are turned
+// upright according to the /Rotate entry of the source page. See
+// Importer.SetAutoRotate for details.
+// Note: This applies to the Importers of all PDFs. Call NewImporter() to obtain a custom Importer.
+func SetAutoRotate(autoRotate bool) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	noRotate = !autoRotate
+	for _, imp := range importers {
+		imp.SetAutoRotate(autoRotate)
+	}
+}
+
+// ImportPageE works like ImportPage but returns any error instead of setting it
+// on the PDF.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageE(f gofpdiPdf, sourceFile string, pageno int, box string) (int, error) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageE(f, sourceFile, pageno, box)
+}
+
+// ImportPageCompressed works like ImportPage but passes the compressed content
+// stream of the page through unchanged. See Importer.ImportPageCompressed for
+// details.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageCompressed(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageCompressed(f, sourceFile, pageno, box)
+}
+
+// ImportPageOnce is the same as ImportPage, which imports each page into f
+// only once. See Importer.ImportPageOnce for details.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageOnce(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageOnce(f, sourceFile, pageno, box)
+}
+
+// ImportPageWithAnnots works like ImportPage but also imports the URI link
+// annotations of the page. See Importer.ImportPageWithAnnots for details.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageWithAnnots(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageWithAnnots(f, sourceFile, pageno, box)
+}
+
+// ImportPageWithPassword imports a page of the encrypted PDF file sourceFile,
+// decrypting it with password. See Importer.ImportPageWithPassword for
+// details.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageWithPassword(f gofpdiPdf, sourceFile, password string, pageno int, box string) (int, error) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageWithPassword(f, sourceFile, password, pageno, box)
 }
 
 // ImportPageFromStream imports a page of a PDF with the specified box
 // (/MediaBox, TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id
 // that can be used with UseImportedTemplate to draw the template onto the
 // page.
-// Note: This uses the default Importer. Call NewImporter() to obtain a custom Importer.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
 func ImportPageFromStream(f gofpdiPdf, rs *io.ReadSeeker, pageno int, box string) int {
-	return fpdi.ImportPageFromStream(f, rs, pageno, box)
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageFromStream(f, rs, pageno, box)
+}
+
+// ImportPageFromBytes imports a page of a PDF held in data with the specified
+// box (/MediaBox, TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a
+// template id that can be used with UseImportedTemplate to draw the template
+// onto the page.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageFromBytes(f gofpdiPdf, data []byte, pageno int, box string) int {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageFromBytes(f, data, pageno, box)
+}
+
+// SetHTTPClient sets the client that ImportPageFromURL and
+// ImportPageFromURLContext use to download PDFs. See Importer.SetHTTPClient
+// for details.
+// Note: This applies to the Importers of all PDFs. Call NewImporter() to obtain a custom Importer.
+func SetHTTPClient(c *http.Client) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	httpClient = c
+	for _, imp := range importers {
+		imp.SetHTTPClient(c)
+	}
+}
+
+// ImportPageFromURL downloads a PDF with the client set with SetHTTPClient,
+// or HTTPClient by default, and imports a page of it with the specified box
+// (/MediaBox, TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id
+// that can be used with UseImportedTemplate to draw the template onto the
+// page.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageFromURL(f gofpdiPdf, urlStr string, pageno int, box string) (int, error) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageFromURL(f, urlStr, pageno, box)
+}
+
+// ImportPageFromURLContext works like ImportPageFromURL but downloads the PDF
+// with the given context, whose error is returned if it ends before the
+// download is complete.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func ImportPageFromURLContext(ctx context.Context, f gofpdiPdf, urlStr string, pageno int, box string) (int, error) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).ImportPageFromURLContext(ctx, f, urlStr, pageno, box)
 }
 
 // UseImportedTemplate draws the template onto the page at x,y. If w is 0, the
 // template will be scaled to fit based on h. If h is 0, the template will be
 // scaled to fit based on w.
-// Note: This uses the default Importer. Call NewImporter() to obtain a custom Importer.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
 func UseImportedTemplate(f gofpdiPdf, tplid int, x float64, y float64, w float64, h float64) {
-	fpdi.UseImportedTemplate(f, tplid, x, y, w, h)
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	importer(f).UseImportedTemplate(f, tplid, x, y, w, h)
+}
+
+// GetTemplateSize returns the size in points of the template with the given
+// id. ok is false if no template with this id was imported.
+// Note: This uses the Importer of the PDF that the global functions were last called with.
+func GetTemplateSize(tplid int) (w, h float64, ok bool) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return last.GetTemplateSize(tplid)
+}
+
+// UseImportedTemplateFit draws the template as large as possible into the box
+// at x,y of size boxW,boxH without distorting it. See
+// Importer.UseImportedTemplateFit for details.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func UseImportedTemplateFit(f gofpdiPdf, tplid int, x, y, boxW, boxH float64, align string) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	importer(f).UseImportedTemplateFit(f, tplid, x, y, boxW, boxH, align)
+}
+
+// UseImportedTemplateRotated draws the template rotated counter-clockwise by
+// angle degrees so that the upper left corner of its bounding box is at x,y.
+// See Importer.UseImportedTemplateRotated for details.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func UseImportedTemplateRotated(f gofpdiTransformPdf, tplid int, x, y, w, h, angle float64) {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	importer(f).UseImportedTemplateRotated(f, tplid, x, y, w, h, angle)
+}
+
+// UseImportedTemplateE works like UseImportedTemplate but returns any error
+// instead of setting it on the PDF.
+// Note: This uses the Importer kept for f. Call NewImporter() to obtain a custom Importer.
+func UseImportedTemplateE(f gofpdiPdf, tplid int, x float64, y float64, w float64, h float64) error {
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return importer(f).UseImportedTemplateE(f, tplid, x, y, w, h)
 }
 
 // GetPageSizes returns page dimensions for all pages of the imported pdf.
@@ -137,7 +1374,9 @@ func UseImportedTemplate(f gofpdiPdf, tplid int, x float64, y float64, w float64
 // <page number>: page number, note that page numbers start at 1
 // <box>: box identifier, e.g. "/MediaBox"
 // <dimension>: dimension string, either "w" or "h"
-// Note: This uses the default Importer. Call NewImporter() to obtain a custom Importer.
+// Note: This uses the Importer of the PDF that the global functions were last called with.
 func GetPageSizes() map[int]map[string]map[string]float64 {
-	return fpdi.GetPageSizes()
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	return last.GetPageSizes()
 }
@@ -2,11 +2,32 @@ package gofpdi
 
 import (
 	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"errors"
+	"fmt"
 	"github.com/jung-kurt/gofpdf/v2"
 	"github.com/jung-kurt/gofpdfcontrib/internal/example"
+	"image"
+	"image/png"
 	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func ExampleNewImporter() {
@@ -63,7 +84,1952 @@ func TestGofpdiConcurrent(t *testing.T) {
 	wg.Wait()
 }
 
+// TestGofpdiConcurrentSources imports different source PDFs into different
+// documents concurrently. Run it with -race to detect shared state.
+func TestGofpdiConcurrentSources(t *testing.T) {
+	sources := []string{"First source", "Second source"}
+	outputs := make([][]byte, len(sources))
+	errs := make([]error, len(sources))
+
+	wg := sync.WaitGroup{}
+	for j, text := range sources {
+		wg.Add(1)
+		go func(j int, text string) {
+			defer wg.Done()
+			tpdf := gofpdf.New("P", "pt", "A4", "")
+			for k := 0; k <= j; k++ {
+				tpdf.AddPage()
+				tpdf.SetFont("Arial", "", 12)
+				tpdf.Text(20, 20, text)
+			}
+			tbuf := bytes.Buffer{}
+			if errs[j] = tpdf.Output(&tbuf); errs[j] != nil {
+				return
+			}
+
+			pdf := gofpdf.New("P", "pt", "A4", "")
+			imp := NewImporter()
+			for k := 1; k <= j+1; k++ {
+				pdf.AddPage()
+				tpl := imp.ImportPageFromBytes(pdf, tbuf.Bytes(), k, "/MediaBox")
+				imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+			}
+			buf := bytes.Buffer{}
+			errs[j] = pdf.Output(&buf)
+			outputs[j] = buf.Bytes()
+		}(j, text)
+	}
+	wg.Wait()
+
+	for j, out := range outputs {
+		if errs[j] != nil {
+			t.Fatal(errs[j])
+		}
+		check := NewImporter()
+		check.ImportPageFromBytes(gofpdf.New("P", "pt", "A4", ""), out, 1, "/MediaBox")
+		if n := len(check.GetPageSizes()); n != j+1 {
+			t.Errorf("expected %d pages in output %d, got %d", j+1, j, n)
+		}
+	}
+}
+
+// TestGlobalImporters imports different source PDFs into different documents
+// concurrently with the global functions, which must keep the documents
+// apart. Run it with -race to detect shared state.
+func TestGlobalImporters(t *testing.T) {
+	defer Reset()
+	sources := make([][]byte, 4)
+	for j := range sources {
+		tpdf := gofpdf.New("P", "pt", "A4", "")
+		for k := 0; k <= j; k++ {
+			tpdf.AddPage()
+			tpdf.SetFont("Arial", "", 12)
+			tpdf.Text(20, 20, fmt.Sprintf("Source %d page %d", j, k+1))
+		}
+		var buf bytes.Buffer
+		if err := tpdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		sources[j] = buf.Bytes()
+	}
+
+	outputs := make([][]byte, len(sources))
+	errs := make([]error, len(sources))
+	wg := sync.WaitGroup{}
+	for j, data := range sources {
+		wg.Add(1)
+		go func(j int, data []byte) {
+			defer wg.Done()
+			pdf := gofpdf.New("P", "pt", "A4", "")
+			defer Release(pdf)
+			for k := 1; k <= j+1; k++ {
+				pdf.AddPage()
+				tpl := ImportPageFromBytes(pdf, data, k, BoxMedia)
+				UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+			}
+			var buf bytes.Buffer
+			errs[j] = pdf.Output(&buf)
+			outputs[j] = buf.Bytes()
+		}(j, data)
+	}
+	wg.Wait()
+
+	for j, out := range outputs {
+		if errs[j] != nil {
+			t.Fatal(errs[j])
+		}
+		var rs io.ReadSeeker = bytes.NewReader(out)
+		if n, err := GetNumberOfPagesFromStream(&rs); err != nil || n != j+1 {
+			t.Errorf("expected %d pages in output %d, got %d (%v)", j+1, j, n, err)
+		}
+	}
+	fpdiMu.Lock()
+	defer fpdiMu.Unlock()
+	if len(importers) != 0 {
+		t.Errorf("expected Release to release all Importers, %d are left", len(importers))
+	}
+}
+
+// TestImportAfterObjects ensures that imported objects do not clash with the
+// objects that the document created before the import, so that the resulting
+// PDF can be read again.
+func TestImportAfterObjects(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Courier", "", 12)
+	pdf.Text(20, 20, "Existing content")
+	pdf.SetFont("Times", "B", 12)
+	pdf.Text(20, 40, "More existing content")
+	pdf.LinkString(20, 60, 100, 20, "https://github.com/jung-kurt/gofpdf")
+
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	ibuf := bytes.Buffer{}
+	if err := png.Encode(&ibuf, img); err != nil {
+		t.Fatal(err)
+	}
+	pdf.RegisterImageOptionsReader("gray", gofpdf.ImageOptions{ImageType: "png"}, &ibuf)
+	pdf.Image("gray", 20, 100, 40, 40, false, "", 0, "")
+
+	rs, _ := getTemplatePdf()
+	imp := NewImporter()
+	pdf.AddPage()
+	tpl := imp.ImportPageFromStream(pdf, &rs, 2, "/MediaBox")
+	imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+
+	buf := bytes.Buffer{}
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out io.ReadSeeker = bytes.NewReader(buf.Bytes())
+	check := NewImporter()
+	check.ImportPageFromStream(gofpdf.New("P", "pt", "A4", ""), &out, 2, "/MediaBox")
+	if n := len(check.GetPageSizes()); n != 2 {
+		t.Errorf("expected 2 pages in the output, got %d", n)
+	}
+}
+
+// TestImportPageFromStream ensures that a page can be imported from a PDF
+// that is held in memory.
+func TestImportPageFromStream(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	var rs io.ReadSeeker = bytes.NewReader(data)
+	imp := NewImporter()
+	tpl := imp.ImportPageFromStream(pdf, &rs, 1, "/MediaBox")
+	imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+	if err := pdf.Output(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(imp.GetPageSizes()); n != 2 {
+		t.Errorf("expected 2 pages in the source, got %d", n)
+	}
+}
+
+// TestImportPageFromBytes ensures that a page can be imported from a byte
+// slice.
+func TestImportPageFromBytes(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	tpl := imp.ImportPageFromBytes(pdf, data, 2, "/MediaBox")
+	imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+	if err := pdf.Output(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(imp.GetPageSizes()); n != 2 {
+		t.Errorf("expected 2 pages in the source, got %d", n)
+	}
+}
+
+// TestImportDistinctStreams imports many distinct PDFs from memory, with
+// garbage collections in between that free the memory of earlier sources,
+// and ensures that each page is imported from its own source.
+func TestImportDistinctStreams(t *testing.T) {
+	sources := distinctPdfs(50)
+	imp := NewImporter()
+	all := gofpdf.New("P", "pt", "A4", "")
+	for j, data := range sources {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		imp.UseImportedTemplate(pdf, imp.ImportPageFromBytes(pdf, data, 1, BoxMedia), 0, 0, 595.28, 841.89)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if text := inflateStreams(buf.Bytes()); !strings.Contains(text, fmt.Sprintf("(Source %d)", j)) {
+			t.Errorf("expected the page of source %d, got %q", j, text)
+		}
+
+		all.AddPage()
+		imp.UseImportedTemplate(all, imp.ImportPageFromBytes(all, data, 1, BoxMedia), 0, 0, 595.28, 841.89)
+		runtime.GC()
+	}
+
+	var buf bytes.Buffer
+	if err := all.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	text := inflateStreams(buf.Bytes())
+	for j := range sources {
+		if !strings.Contains(text, fmt.Sprintf("(Source %d)", j)) {
+			t.Errorf("expected the page of source %d in the merged PDF", j)
+		}
+	}
+}
+
+// TestImportPageNumbers ensures that 0 selects the first page, that negative
+// page numbers count from the end and that other page numbers outside of the
+// source are rejected.
+func TestImportPageNumbers(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileStr := filepath.Join(t.TempDir(), "template.pdf")
+	if err := ioutil.WriteFile(fileStr, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pageno int
+		page   string
+	}{
+		{1, "Example Page 1"},
+		{2, "Example Page 2"},
+		{0, "Example Page 1"},
+		{-1, "Example Page 2"},
+		{-2, "Example Page 1"},
+		{3, ""},
+		{-3, ""},
+	}
+	for _, tt := range tests {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		imp := NewImporter()
+		tpl, err := imp.ImportPageE(pdf, fileStr, tt.pageno, BoxMedia)
+		if tt.page == "" {
+			if err == nil {
+				t.Errorf("page %d: expected an error", tt.pageno)
+			}
+			if imp.ImportPageFromBytes(pdf, data, tt.pageno, BoxMedia); pdf.Error() == nil {
+				t.Errorf("page %d: expected an error on the PDF", tt.pageno)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("page %d: %v", tt.pageno, err)
+		}
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 0, 0)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if text := inflateStreams(buf.Bytes()); !strings.Contains(text, "("+tt.page+")") {
+			t.Errorf("page %d: expected %s to be imported", tt.pageno, tt.page)
+		}
+	}
+}
+
+// TestImportPageE ensures that import errors are returned by the E variants
+// and set on the PDF by the others.
+func TestImportPageE(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	if _, err := imp.ImportPageE(pdf, "does-not-exist.pdf", 1, "/MediaBox"); err == nil {
+		t.Error("expected an error for a missing source file")
+	}
+	if err := imp.UseImportedTemplateE(pdf, 42, 0, 0, 100, 100); err == nil {
+		t.Error("expected an error for an unknown template id")
+	}
+	if !pdf.Ok() {
+		t.Fatalf("expected the E variants not to set an error, got %v", pdf.Error())
+	}
+
+	imp.ImportPage(pdf, "does-not-exist.pdf", 1, "/MediaBox")
+	if pdf.Ok() {
+		t.Error("expected an error to be set for a missing source file")
+	}
+}
+
+// TestImportEncrypted ensures that pages of encrypted PDFs are not imported
+// but ErrEncrypted is reported.
+func TestImportEncrypted(t *testing.T) {
+	fileStr := writePdfObjects(filepath.Join(t.TempDir(), "encrypted.pdf"), []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] /Resources << >> /Contents 4 0 R >>",
+		"<< /Length 0 >>\nstream\n\nendstream",
+		"<< /Filter /Standard /V 1 /R 2 /O <00> /U <00> /P -4 >>",
+	})
+	data, err := ioutil.ReadFile(fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("/Root 1 0 R >>"), []byte("/Root 1 0 R /Encrypt 5 0 R >>"), 1)
+	if err := ioutil.WriteFile(fileStr, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	for name, importE := range map[string]func() (int, error){
+		"ImportPageE": func() (int, error) {
+			return imp.ImportPageE(pdf, fileStr, 1, BoxMedia)
+		},
+		"ImportPageCompressedE": func() (int, error) {
+			return imp.ImportPageCompressedE(pdf, fileStr, 1, BoxMedia)
+		},
+		"ImportPageWithAnnotsE": func() (int, error) {
+			return imp.ImportPageWithAnnotsE(pdf, fileStr, 1, BoxMedia)
+		},
+		"ImportPageFromURL": func() (int, error) {
+			return imp.ImportPageFromURL(pdf, server.URL, 1, BoxMedia)
+		},
+	} {
+		if _, err := importE(); err != ErrEncrypted {
+			t.Errorf("%s: expected %v, got %v", name, ErrEncrypted, err)
+		}
+	}
+	if _, err := NewSource(fileStr); err != ErrEncrypted {
+		t.Errorf("NewSource: expected %v, got %v", ErrEncrypted, err)
+	}
+	if !pdf.Ok() {
+		t.Fatalf("expected no error to be set, got %v", pdf.Error())
+	}
+
+	imp.ImportPageFromBytes(pdf, data, 1, BoxMedia)
+	if pdf.Error() != ErrEncrypted {
+		t.Errorf("expected %v to be set, got %v", ErrEncrypted, pdf.Error())
+	}
+}
+
+// TestImportPageWithPassword ensures that pages of PDFs encrypted by gofpdf
+// are imported with their user or owner password and that other passwords
+// are rejected.
+func TestImportPageWithPassword(t *testing.T) {
+	src := gofpdf.New("P", "pt", "A4", "")
+	src.SetProtection(gofpdf.CnProtectPrint, "user", "owner")
+	src.AddPage()
+	src.SetFont("Arial", "", 12)
+	src.Text(20, 20, "Secret page")
+	fileStr := filepath.Join(t.TempDir(), "protected.pdf")
+	if err := src.OutputFileAndClose(fileStr); err != nil {
+		t.Fatal(err)
+	}
+
+	imp := NewImporter()
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	if _, err := imp.ImportPageE(pdf, fileStr, 1, BoxMedia); err != ErrEncrypted {
+		t.Errorf("expected %v, got %v", ErrEncrypted, err)
+	}
+	for _, password := range []string{"wrong", ""} {
+		if _, err := imp.ImportPageWithPassword(pdf, fileStr, password, 1, BoxMedia); err != ErrWrongPassword {
+			t.Errorf("password %q: expected %v, got %v", password, ErrWrongPassword, err)
+		}
+	}
+
+	for _, password := range []string{"user", "owner"} {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		tpl, err := imp.ImportPageWithPassword(pdf, fileStr, password, 1, BoxMedia)
+		if err != nil {
+			t.Fatalf("password %q: %v", password, err)
+		}
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 0, 0)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if text := inflateStreams(buf.Bytes()); !strings.Contains(text, "(Secret page)") {
+			t.Errorf("password %q: expected the decrypted page in the output", password)
+		}
+	}
+
+	// The global function imports the page as well.
+	defer Reset()
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	if _, err := ImportPageWithPassword(pdf, fileStr, "user", 1, BoxMedia); err != nil {
+		t.Error(err)
+	}
+	if _, err := ImportPageWithPassword(pdf, "does-not-exist.pdf", "user", 1, BoxMedia); err == nil {
+		t.Error("expected an error for a missing source file")
+	}
+}
+
+// TestImportPageWithPasswordAES ensures that pages of PDFs encrypted with
+// 128-bit AES are imported with their user or owner password.
+func TestImportPageWithPasswordAES(t *testing.T) {
+	id := []byte("0123456789abcdef")
+	user, owner := padPassword([]byte("user")), padPassword([]byte("owner"))
+
+	// /O holds the user password encrypted with a key from the owner
+	// password, /U a value encrypted with the file key.
+	sum := md5.Sum(owner)
+	ownerKey := sum[:]
+	for j := 0; j < 50; j++ {
+		next := md5.Sum(ownerKey)
+		ownerKey = next[:]
+	}
+	o := user
+	for j := 0; j < 20; j++ {
+		o = rc4XOR(xorKey(ownerKey, byte(j)), o)
+	}
+	key := fileKey(user, o, -4, id, 4, 16, true)
+	u := append(userValue(key, id, 4), make([]byte, 16)...)
+
+	// The content stream is object 4.
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write([]byte("BT /F1 12 Tf 20 20 Td (AES page) Tj ET\n"))
+	zw.Close()
+	objKey := md5.Sum(append(append(key, 4, 0, 0, 0, 0), "sAlT"...))
+	block, err := aes.NewCipher(objKey[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - content.Len()%aes.BlockSize
+	plain := append(content.Bytes(), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	encrypted := append(bytes.Repeat([]byte{7}, aes.BlockSize), make([]byte, len(plain))...)
+	cipher.NewCBCEncrypter(block, encrypted[:aes.BlockSize]).CryptBlocks(encrypted[aes.BlockSize:], plain)
+
+	fileStr := writePdfObjects(filepath.Join(t.TempDir(), "aes.pdf"), []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] /Resources << >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(encrypted), encrypted),
+		fmt.Sprintf("<< /Filter /Standard /V 4 /R 4 /Length 128 /CF << /StdCF << /CFM /AESV2 /Length 16 >> >> /StmF /StdCF /StrF /StdCF /O <%x> /U <%x> /P -4 >>", o, u),
+	})
+	data, err := ioutil.ReadFile(fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("/Root 1 0 R >>"), []byte(fmt.Sprintf("/Root 1 0 R /Encrypt 5 0 R /ID [<%x> <%x>] >>", id, id)), 1)
+	if err := ioutil.WriteFile(fileStr, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	imp := NewImporter()
+	for _, password := range []string{"user", "owner"} {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		tpl, err := imp.ImportPageWithPassword(pdf, fileStr, password, 1, BoxMedia)
+		if err != nil {
+			t.Fatalf("password %q: %v", password, err)
+		}
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 0, 0)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if text := inflateStreams(buf.Bytes()); !strings.Contains(text, "(AES page)") {
+			t.Errorf("password %q: expected the decrypted page in the output", password)
+		}
+	}
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	if _, err := imp.ImportPageWithPassword(pdf, fileStr, "wrong", 1, BoxMedia); err != ErrWrongPassword {
+		t.Errorf("expected %v, got %v", ErrWrongPassword, err)
+	}
+}
+
+// TestImportMalformed ensures that malformed PDFs are reported as errors by
+// every import function instead of panicking.
+func TestImportMalformed(t *testing.T) {
+	valid, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	objStm, err := ioutil.ReadFile(writeObjStmPdf(filepath.Join(dir, "objstm.pdf"), []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] >>",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	startxref := regexp.MustCompile(`startxref\s+\d+`)
+	inputs := map[string][]byte{
+		"empty":      nil,
+		"garbage":    []byte("%PDF-1.4\ngarbage"),
+		"truncated":  valid[:len(valid)/2],
+		"bad xref":   startxref.ReplaceAll(valid, []byte("startxref\n12")),
+		"bad widths": bytes.Replace(objStm, []byte("/W [1 2 1]"), []byte("/W [-1 9 1]"), 1),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(inputs[strings.TrimPrefix(r.URL.Path, "/")])
+	}))
+	defer server.Close()
+
+	for name, data := range inputs {
+		fileStr := filepath.Join(dir, "malformed.pdf")
+		if err := ioutil.WriteFile(fileStr, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		imp := NewImporter()
+		errs := map[string]error{}
+		_, errs["ImportPageE"] = imp.ImportPageE(pdf, fileStr, 1, BoxMedia)
+		_, errs["ImportPageCompressedE"] = imp.ImportPageCompressedE(pdf, fileStr, 1, BoxMedia)
+		_, errs["ImportPageWithAnnotsE"] = imp.ImportPageWithAnnotsE(pdf, fileStr, 1, BoxMedia)
+		_, errs["ImportPageWithPassword"] = imp.ImportPageWithPassword(pdf, fileStr, "", 1, BoxMedia)
+		_, errs["ImportPageFromURL"] = imp.ImportPageFromURL(pdf, server.URL+"/"+name, 1, BoxMedia)
+		_, errs["NewSource"] = NewSource(fileStr)
+		_, errs["GetPageColorSpace"] = GetPageColorSpace(fileStr, 1)
+		_, errs["GetNumberOfPages"] = GetNumberOfPages(fileStr)
+		_, _, errs["GetPageSize"] = GetPageSize(fileStr, 1, BoxMedia)
+		_, errs["GetPageBoxes"] = GetPageBoxes(fileStr, 1)
+		if !pdf.Ok() {
+			t.Errorf("%s: expected no error to be set, got %v", name, pdf.Error())
+		}
+
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		imp.ImportPageFromBytes(pdf, data, 1, BoxMedia)
+		errs["ImportPageFromBytes"] = pdf.Error()
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		var rs io.ReadSeeker = bytes.NewReader(data)
+		imp.ImportPageFromStream(pdf, &rs, 1, BoxMedia)
+		errs["ImportPageFromStream"] = pdf.Error()
+		_, errs["GetNumberOfPagesFromStream"] = GetNumberOfPagesFromStream(&rs)
+
+		for entry, err := range errs {
+			if err == nil {
+				t.Errorf("%s: expected an error from %s", name, entry)
+			}
+		}
+	}
+}
+
+// TestInvalidBox ensures that box names other than the Box constants are
+// rejected.
+func TestInvalidBox(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	if _, err := imp.ImportPageE(pdf, "template.pdf", 1, "MediaBox"); err == nil || !strings.Contains(err.Error(), "invalid box") {
+		t.Errorf("expected an invalid box error, got %v", err)
+	}
+	imp.ImportPageFromBytes(pdf, data, 1, "MediaBox")
+	if pdf.Ok() {
+		t.Error("expected an error to be set for an invalid box")
+	}
+}
+
+// TestImportPageFromURL ensures that pages are imported from PDFs served over
+// HTTP and that other responses are rejected.
+func TestImportPageFromURL(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/doc.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(data)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	tpl, err := imp.ImportPageFromURL(pdf, server.URL+"/doc.pdf", 1, BoxMedia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+	if err := pdf.Output(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/missing.pdf", "/page.html"} {
+		if _, err := imp.ImportPageFromURL(pdf, server.URL+path, 1, BoxMedia); err == nil {
+			t.Errorf("expected an error for %s", path)
+		}
+	}
+}
+
+// roundTripFunc is an http.RoundTripper that answers requests with a
+// function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// TestImportPageFromURLDistinct downloads many distinct PDFs, with garbage
+// collections in between, and ensures that each page is imported from its
+// own download.
+func TestImportPageFromURLDistinct(t *testing.T) {
+	sources := distinctPdfs(30)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var j int
+		fmt.Sscanf(r.URL.Path, "/%d.pdf", &j)
+		w.Write(sources[j])
+	}))
+	defer server.Close()
+
+	imp := NewImporter()
+	for j := range sources {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		tpl, err := imp.ImportPageFromURL(pdf, fmt.Sprintf("%s/%d.pdf", server.URL, j), 1, BoxMedia)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if text := inflateStreams(buf.Bytes()); !strings.Contains(text, fmt.Sprintf("(Source %d)", j)) {
+			t.Errorf("expected the page of source %d, got %q", j, text)
+		}
+		runtime.GC()
+	}
+}
+
+// TestSetHTTPClient ensures that PDFs are downloaded with the client set on
+// the Importer.
+func TestSetHTTPClient(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requested []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/pdf"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})}
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	imp.SetHTTPClient(client)
+	tpl, err := imp.ImportPageFromURL(pdf, "http://pdf.invalid/doc.pdf", 1, BoxMedia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+	if err := pdf.Output(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 1 || requested[0] != "http://pdf.invalid/doc.pdf" {
+		t.Errorf("expected a single request through the client, got %v", requested)
+	}
+}
+
+// TestImportPageFromURLContext ensures that a download is aborted with the
+// context error when the context is canceled while the PDF is being fetched.
+func TestImportPageFromURLContext(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(data[:len(data)/2])
+		w.(http.Flusher).Flush()
+		once.Do(func() { close(started) })
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	if _, err := NewImporter().ImportPageFromURLContext(ctx, pdf, server.URL, 1, BoxMedia); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := NewImporter().ImportPageFromURLContext(ctx, pdf, server.URL, 1, BoxMedia); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestUseImportedTemplateRotated ensures that the bounding box of a rotated
+// template has its upper left corner at the given position and the expected
+// size.
+func TestUseImportedTemplateRotated(t *testing.T) {
+	const x, y, w, h = 10.0, 20.0, 100.0, 50.0
+	tests := []struct {
+		angle, bw, bh float64
+	}{
+		{0, w, h},
+		{90, h, w},
+		{180, w, h},
+		{270, h, w},
+		{45, 75 * math.Sqrt2, 75 * math.Sqrt2},
+	}
+
+	for _, tt := range tests {
+		tx, ty, cx, cy := rotatedPlacement(x, y, w, h, tt.angle)
+		sin, cos := math.Sincos(tt.angle * math.Pi / 180)
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, p := range [][2]float64{{tx, ty}, {tx + w, ty}, {tx, ty + h}, {tx + w, ty + h}} {
+			dx, dy := p[0]-cx, p[1]-cy
+			px, py := cx+dx*cos-dy*sin, cy+dx*sin+dy*cos
+			minX, maxX = math.Min(minX, px), math.Max(maxX, px)
+			minY, maxY = math.Min(minY, py), math.Max(maxY, py)
+		}
+		if math.Abs(minX-x) > 1e-9 || math.Abs(minY-y) > 1e-9 ||
+			math.Abs(maxX-minX-tt.bw) > 1e-9 || math.Abs(maxY-minY-tt.bh) > 1e-9 {
+			t.Errorf("angle %.0f: expected bounding box %.2f,%.2f %.2fx%.2f, got %.2f,%.2f %.2fx%.2f",
+				tt.angle, x, y, tt.bw, tt.bh, minX, minY, maxX-minX, maxY-minY)
+		}
+	}
+
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("L", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	tpl := imp.ImportPageFromBytes(pdf, data, 1, BoxMedia)
+	imp.UseImportedTemplateRotated(pdf, tpl, 0, 0, 595.28, 841.89, 90)
+	if err := pdf.Output(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGetTemplateSize ensures that the size of an imported A4 page is
+// reported in points.
+func TestGetTemplateSize(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	tpl := imp.ImportPageFromBytes(pdf, data, 1, BoxMedia)
+	w, h, ok := imp.GetTemplateSize(tpl)
+	if !ok {
+		t.Fatal("expected the size of the imported template")
+	}
+	if math.Abs(w-595.28) > 0.01 || math.Abs(h-841.89) > 0.01 {
+		t.Errorf("expected A4 size 595.28 x 841.89, got %.2f x %.2f", w, h)
+	}
+	if _, _, ok := imp.GetTemplateSize(tpl + 1); ok {
+		t.Error("expected no size for an unknown template id")
+	}
+}
+
+// TestImportPageWithAnnots ensures that the link annotations of an imported
+// page are recreated where the template is placed.
+func TestImportPageWithAnnots(t *testing.T) {
+	src := gofpdf.New("P", "pt", "A4", "")
+	src.AddPage()
+	src.LinkString(50, 60, 100, 20, "https://example.com/(a)")
+	src.LinkString(200, 300, 40, 40, "https://example.org")
+	fileStr := filepath.Join(t.TempDir(), "links.pdf")
+	if err := src.OutputFileAndClose(fileStr); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	tpl := imp.ImportPageWithAnnots(pdf, fileStr, 1, BoxMedia)
+	imp.UseImportedTemplate(pdf, tpl, 10, 20, 595.28/2, 841.89/2)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := readLinkAnnots(buf.Bytes(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The links are scaled by half and moved by 10,20 from the top left.
+	expected := []linkAnnot{
+		{[4]float64{35, 841.89 - 60, 85, 841.89 - 50}, "https://example.com/(a)"},
+		{[4]float64{110, 841.89 - 170 - 20, 130, 841.89 - 170}, "https://example.org"},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("expected %d links, got %d", len(expected), len(links))
+	}
+	for j, l := range links {
+		if l.uri != expected[j].uri {
+			t.Errorf("link %d: expected URI %q, got %q", j, expected[j].uri, l.uri)
+		}
+		for k := range l.rect {
+			if math.Abs(l.rect[k]-expected[j].rect[k]) > 0.01 {
+				t.Errorf("link %d: expected rectangle %.2f, got %.2f", j, expected[j].rect, l.rect)
+				break
+			}
+		}
+	}
+
+	// Pages imported without annotations do not get links.
+	tpl = imp.ImportPage(pdf, fileStr, 1, BoxMedia)
+	if links := imp.links[tpl]; len(links) != 0 {
+		t.Errorf("expected no links for ImportPage, got %d", len(links))
+	}
+	if _, err := imp.ImportPageWithAnnotsE(pdf, "does-not-exist.pdf", 1, BoxMedia); err == nil {
+		t.Error("expected an error for a missing source file")
+	}
+}
+
+// TestImportPageWithAnnotsRotated ensures that the link annotations of a page
+// with /Rotate 90 are turned upright with the page.
+func TestImportPageWithAnnotsRotated(t *testing.T) {
+	fileStr := writePdfObjects(filepath.Join(t.TempDir(), "rotated.pdf"), []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] /Rotate 90 /Resources << >> /Contents 5 0 R /Annots [4 0 R] >>",
+		"<< /Type /Annot /Subtype /Link /Rect [50 60 150 80] /A << /S /URI /URI (https://example.com) >> >>",
+		"<< /Length 0 >>\nstream\n\nendstream",
+	})
+
+	tests := []struct {
+		autoRotate  bool
+		orientation string
+		rect        [4]float64
+	}{
+		// Turned clockwise, the left edge of the page is at its top.
+		{true, "L", [4]float64{60, 595.28 - 150, 80, 595.28 - 50}},
+		{false, "P", [4]float64{50, 60, 150, 80}},
+	}
+	for _, tt := range tests {
+		pdf := gofpdf.New(tt.orientation, "pt", "A4", "")
+		pdf.AddPage()
+		imp := NewImporter()
+		imp.SetAutoRotate(tt.autoRotate)
+		tpl := imp.ImportPageWithAnnots(pdf, fileStr, 1, BoxMedia)
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 0, 0)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		links, err := readLinkAnnots(buf.Bytes(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(links) != 1 {
+			t.Fatalf("auto rotate %v: expected 1 link, got %d", tt.autoRotate, len(links))
+		}
+		for k := range tt.rect {
+			if math.Abs(links[0].rect[k]-tt.rect[k]) > 0.01 {
+				t.Errorf("auto rotate %v: expected rectangle %.2f, got %.2f", tt.autoRotate, tt.rect, links[0].rect)
+				break
+			}
+		}
+	}
+}
+
+// importCountPdf counts the templates that are imported into the PDF.
+type importCountPdf struct {
+	*gofpdf.Fpdf
+	imports int
+}
+
+func (pdf *importCountPdf) ImportTemplates(tpls map[string]string) {
+	pdf.imports++
+	pdf.Fpdf.ImportTemplates(tpls)
+}
+
+// TestImportPageOnce overlays one page on 100 pages and ensures that it is
+// imported a single time.
+func TestImportPageOnce(t *testing.T) {
+	fileStr := writeBigPdf(t.TempDir(), 2)
+	pdf := &importCountPdf{Fpdf: gofpdf.New("P", "pt", "A4", "")}
+	imp := NewImporter()
+	first := -1
+	for j := 0; j < 100; j++ {
+		pdf.AddPage()
+		tpl := imp.ImportPageOnce(pdf, fileStr, 1, BoxMedia)
+		if first < 0 {
+			first = tpl
+		} else if tpl != first {
+			t.Fatalf("page %d: expected template %d, got %d", j+1, first, tpl)
+		}
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+	}
+	if pdf.imports != 1 {
+		t.Errorf("expected a single import, got %d", pdf.imports)
+	}
+
+	// Other pages and boxes are imported separately.
+	if tpl := imp.ImportPageOnce(pdf, fileStr, 2, BoxMedia); tpl == first {
+		t.Error("expected a new template for another page")
+	}
+	imp.ImportPageOnce(pdf, fileStr, 1, BoxCrop)
+	if pdf.imports != 3 {
+		t.Errorf("expected 3 imports, got %d", pdf.imports)
+	}
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf = &importCountPdf{Fpdf: gofpdf.New("P", "pt", "A4", "")}
+	imp.ImportPageOnce(pdf, "does-not-exist.pdf", 1, BoxMedia)
+	if pdf.Ok() {
+		t.Error("expected an error for a missing source file")
+	}
+}
+
+// TestImportPageCache ensures that importing the same page twice yields the
+// same template without parsing the source again, until the PDF is released
+// or the global functions are reset.
+func TestImportPageCache(t *testing.T) {
+	fileStr := writeBigPdf(t.TempDir(), 2)
+	pdf := &importCountPdf{Fpdf: gofpdf.New("P", "pt", "A4", "")}
+	imp := NewImporter()
+	first := imp.ImportPage(pdf, fileStr, 1, BoxMedia)
+	if err := os.Remove(fileStr); err != nil {
+		t.Fatal(err)
+	}
+	if tpl := imp.ImportPage(pdf, fileStr, 1, BoxMedia); tpl != first {
+		t.Errorf("expected template %d, got %d", first, tpl)
+	}
+	if err := pdf.Error(); err != nil {
+		t.Fatalf("expected the source not to be read again, got %v", err)
+	}
+	if pdf.imports != 1 {
+		t.Errorf("expected a single import, got %d", pdf.imports)
+	}
+	imp.Release(pdf)
+	if imp.oncePdf != nil || len(imp.once) != 0 {
+		t.Error("expected Release to drop the reference to the PDF")
+	}
+
+	// The Importer only remembers the pages of the last PDF.
+	fileStr = writeBigPdf(t.TempDir(), 2)
+	other := &importCountPdf{Fpdf: gofpdf.New("P", "pt", "A4", "")}
+	imp.ImportPage(pdf, fileStr, 1, BoxMedia)
+	imp.ImportPage(other, fileStr, 1, BoxMedia)
+	if imp.oncePdf != other {
+		t.Error("expected the Importer to keep a reference to the last PDF only")
+	}
+	imp.ImportPage(pdf, fileStr, 1, BoxMedia)
+	if pdf.imports != 3 || other.imports != 1 {
+		t.Errorf("expected the page to be imported again into the first PDF, got %d and %d imports", pdf.imports, other.imports)
+	}
+
+	Reset()
+	defer Reset()
+	ImportPage(pdf, fileStr, 1, BoxMedia)
+	ImportPage(pdf, fileStr, 1, BoxMedia)
+	Release(pdf)
+	ImportPage(pdf, fileStr, 1, BoxMedia)
+	Reset()
+	ImportPage(pdf, fileStr, 1, BoxMedia)
+	if pdf.imports != 6 {
+		t.Errorf("expected the page to be imported again after Release and Reset, got %d imports", pdf.imports)
+	}
+}
+
+// TestImportPageCompressed imports a large page whose content is compressed
+// more strongly than gofpdi does and ensures that the content is passed
+// through unchanged, which keeps the output smaller.
+func TestImportPageCompressed(t *testing.T) {
+	var content bytes.Buffer
+	for j := 0; j < 5000; j++ {
+		fmt.Fprintf(&content, "%d %d m %d %d l S\n", j%500, j%800, (j*7)%500, (j*13)%800)
+	}
+	var stream bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&stream, zlib.BestCompression)
+	zw.Write(content.Bytes())
+	zw.Close()
+	fileStr := writeFlatePdf(t.TempDir(), stream.Bytes(), 0)
+
+	output := func(compressed bool) []byte {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		imp := NewImporter()
+		var tpl int
+		if compressed {
+			tpl = imp.ImportPageCompressed(pdf, fileStr, 1, BoxMedia)
+		} else {
+			tpl = imp.ImportPage(pdf, fileStr, 1, BoxMedia)
+		}
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	plain, compressed := output(false), output(true)
+	if bytes.Contains(plain, stream.Bytes()) {
+		t.Error("expected gofpdi to compress the content again")
+	}
+	if !bytes.Contains(compressed, stream.Bytes()) {
+		t.Error("expected the compressed content to be passed through")
+	}
+	if len(compressed) > len(plain) {
+		t.Errorf("expected no larger output with passed through content, got %d and %d bytes", len(compressed), len(plain))
+	}
+
+	// The output must parse again, with the content intact.
+	objs, _ := readObjects(compressed)
+	forms := 0
+	for _, obj := range objs {
+		if s, ok := obj.(*pdfStream); ok && s.dict["Subtype"] == pdfName("Form") {
+			forms++
+			if data, err := objs.decodeStream(s); err != nil || !bytes.Equal(data, content.Bytes()) {
+				t.Errorf("expected the form XObject to hold the content, got %d bytes (%v)", len(data), err)
+			}
+		}
+	}
+	if forms != 1 {
+		t.Errorf("expected 1 form XObject, got %d", forms)
+	}
+	reimported := gofpdf.New("P", "pt", "A4", "")
+	reimported.AddPage()
+	imp := NewImporter()
+	imp.UseImportedTemplate(reimported, imp.ImportPageFromBytes(reimported, compressed, 1, BoxMedia), 0, 0, 595.28, 841.89)
+	if err := reimported.Output(ioutil.Discard); err != nil {
+		t.Errorf("expected the output to be imported again, got %v", err)
+	}
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	if _, err := NewImporter().ImportPageCompressedE(pdf, "does-not-exist.pdf", 1, BoxMedia); err == nil {
+		t.Error("expected an error for a missing source file")
+	}
+}
+
+// TestReplaceStream ensures that the stream of a form XObject is only
+// replaced where gofpdi writes it, whatever text the object contains.
+func TestReplaceStream(t *testing.T) {
+	for _, c := range []struct {
+		obj, expected string
+	}{
+		{"<</Type /XObject\n/Resources << >>\n/Length 3 >>\nstream\nabc\nendstream\nendobj\n",
+			"<</Type /XObject\n/Resources << >>\n/Length 4 >>\nstream\nnew!\nendstream\nendobj\n"},
+		{"<</Resources << /A (/Length 1 >>\nstream\n) >>\n/Length 12 >>\nstream\n/Length 2 >>\nendstream\nendobj\n",
+			"<</Resources << /A (/Length 1 >>\nstream\n) >>\n/Length 4 >>\nstream\nnew!\nendstream\nendobj\n"},
+		{"<</Length 3 >>\nstream\nabcd\nendstream\nendobj\n", ""},
+		{"<</Resources << >> >>\nendobj\n", ""},
+	} {
+		if obj := replaceStream([]byte(c.obj), []byte("new!")); string(obj) != c.expected {
+			t.Errorf("expected %q for %q, got %q", c.expected, c.obj, obj)
+		}
+	}
+}
+
+// templatePdf records the templates that are drawn on the PDF.
+type templatePdf struct {
+	*gofpdf.Fpdf
+	scales [][2]float64
+}
+
+func (pdf *templatePdf) UseImportedTemplate(tplName string, scaleX, scaleY, tX, tY float64) {
+	pdf.scales = append(pdf.scales, [2]float64{scaleX, scaleY})
+	pdf.Fpdf.UseImportedTemplate(tplName, scaleX, scaleY, tX, tY)
+}
+
+// TestAutoRotate imports a page with /Rotate 90 and ensures that it is turned
+// upright unless automatic rotation is disabled.
+func TestAutoRotate(t *testing.T) {
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write([]byte("0 0 m 100 200 l S\n"))
+	zw.Close()
+	fileStr := writeFlatePdf(t.TempDir(), stream.Bytes(), 90)
+
+	tests := []struct {
+		autoRotate bool
+		w, h       float64
+	}{
+		{true, 841.89, 595.28},
+		{false, 595.28, 841.89},
+	}
+	for _, tt := range tests {
+		pdf := &templatePdf{Fpdf: gofpdf.New("P", "pt", "A4", "")}
+		pdf.AddPage()
+		imp := NewImporter()
+		imp.SetAutoRotate(tt.autoRotate)
+		tpl := imp.ImportPage(pdf, fileStr, 1, BoxMedia)
+		w, h, _ := imp.GetTemplateSize(tpl)
+		if math.Abs(w-tt.w) > 0.01 || math.Abs(h-tt.h) > 0.01 {
+			t.Errorf("auto rotate %v: expected size %.2f x %.2f, got %.2f x %.2f", tt.autoRotate, tt.w, tt.h, w, h)
+		}
+
+		// A height of 0 keeps the aspect ratio of the page as it is drawn.
+		imp.UseImportedTemplate(pdf, tpl, 0, 0, 100, 0)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		scale := pdf.scales[0]
+		if pw, ph := scale[0]*tt.w, scale[1]*tt.h; math.Abs(pw-100) > 0.01 || math.Abs(ph-100*tt.h/tt.w) > 0.01 {
+			t.Errorf("auto rotate %v: expected the page placed at 100.00 x %.2f, got %.2f x %.2f", tt.autoRotate, 100*tt.h/tt.w, pw, ph)
+		}
+		if rotated := bytes.Contains(buf.Bytes(), []byte("/Matrix [0.00000 -1.00000")); rotated != tt.autoRotate {
+			t.Errorf("auto rotate %v: got a rotated form matrix %v", tt.autoRotate, rotated)
+		}
+	}
+}
+
+// TestUseImportedTemplateFit ensures that templates are fitted into boxes of
+// other aspect ratios without distortion.
+func TestUseImportedTemplateFit(t *testing.T) {
+	tests := []struct {
+		name             string
+		w, h             float64
+		align            string
+		x, y, fitW, fitH float64
+	}{
+		{"portrait in landscape", 100, 200, "", 0, 0, 50, 100},
+		{"portrait in landscape centered", 100, 200, "CM", 75, 0, 50, 100},
+		{"portrait in landscape right", 100, 200, "RT", 150, 0, 50, 100},
+		{"landscape in landscape", 400, 100, "CM", 0, 25, 200, 50},
+		{"landscape in landscape bottom", 400, 100, "LB", 0, 50, 200, 50},
+	}
+	for _, tt := range tests {
+		x, y, w, h := fitRect(tt.w, tt.h, 0, 0, 200, 100, tt.align)
+		if x != tt.x || y != tt.y || w != tt.fitW || h != tt.fitH {
+			t.Errorf("%s: expected %.1f,%.1f %.1fx%.1f, got %.1f,%.1f %.1fx%.1f",
+				tt.name, tt.x, tt.y, tt.fitW, tt.fitH, x, y, w, h)
+		}
+	}
+	// Landscape in portrait
+	if x, y, w, h := fitRect(200, 100, 10, 10, 100, 200, "CM"); x != 10 || y != 85 || w != 100 || h != 50 {
+		t.Errorf("landscape in portrait: expected 10.0,85.0 100.0x50.0, got %.1f,%.1f %.1fx%.1f", x, y, w, h)
+	}
+
+	pdf := gofpdf.New("L", "pt", "A4", "")
+	pdf.AddPage()
+	imp := NewImporter()
+	imp.UseImportedTemplateFit(pdf, 42, 0, 0, 841.89, 595.28, "CM")
+	if pdf.Ok() {
+		t.Error("expected an error for an unknown template id")
+	}
+}
+
+// TestGetNumberOfPages ensures that the page count of a source PDF is
+// reported for files and streams.
+func TestGetNumberOfPages(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileStr := filepath.Join(t.TempDir(), "template.pdf")
+	if err := ioutil.WriteFile(fileStr, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := GetNumberOfPages(fileStr); err != nil || n != 2 {
+		t.Errorf("expected 2 pages in file, got %d (%v)", n, err)
+	}
+	var rs io.ReadSeeker = bytes.NewReader(data)
+	if n, err := GetNumberOfPagesFromStream(&rs); err != nil || n != 2 {
+		t.Errorf("expected 2 pages in stream, got %d (%v)", n, err)
+	}
+	if _, err := GetNumberOfPages("does-not-exist.pdf"); err == nil {
+		t.Error("expected an error for a missing source file")
+	}
+}
+
+// TestGetPageSize ensures that the box dimensions of an A4 page are reported
+// in points.
+func TestGetPageSize(t *testing.T) {
+	data, err := getTemplateBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileStr := filepath.Join(t.TempDir(), "template.pdf")
+	if err := ioutil.WriteFile(fileStr, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, h, err := GetPageSize(fileStr, 1, "/MediaBox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(w-595.28) > 0.01 || math.Abs(h-841.89) > 0.01 {
+		t.Errorf("expected A4 size 595.28 x 841.89, got %.2f x %.2f", w, h)
+	}
+	for _, pageno := range []int{0, -1} {
+		if pw, ph, err := GetPageSize(fileStr, pageno, "/MediaBox"); err != nil || pw != w || ph != h {
+			t.Errorf("page %d: expected %.2f x %.2f, got %.2f x %.2f (%v)", pageno, w, h, pw, ph, err)
+		}
+	}
+	for _, pageno := range []int{3, -3} {
+		if _, _, err := GetPageSize(fileStr, pageno, "/MediaBox"); err == nil {
+			t.Errorf("page %d: expected an error for a page out of range", pageno)
+		}
+	}
+}
+
+// TestGetPageBoxes ensures that all boxes of a page are returned, and that
+// missing boxes default to the box they are defined relative to.
+func TestGetPageBoxes(t *testing.T) {
+	tpdf := gofpdf.New("P", "pt", "A4", "")
+	tpdf.SetPageBox("crop", 10, 10, 575.28, 821.89)
+	tpdf.SetPageBox("trim", 20, 20, 555.28, 801.89)
+	tpdf.AddPage()
+	fileStr := filepath.Join(t.TempDir(), "boxes.pdf")
+	if err := tpdf.OutputFileAndClose(fileStr); err != nil {
+		t.Fatal(err)
+	}
+
+	boxes, err := GetPageBoxes(fileStr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]gofpdf.SizeType{
+		BoxMedia: {Wd: 595.28, Ht: 841.89},
+		BoxCrop:  {Wd: 575.28, Ht: 821.89},
+		BoxBleed: {Wd: 575.28, Ht: 821.89},
+		BoxTrim:  {Wd: 555.28, Ht: 801.89},
+		BoxArt:   {Wd: 575.28, Ht: 821.89},
+	}
+	if len(boxes) != len(want) {
+		t.Fatalf("expected %d boxes, got %v", len(want), boxes)
+	}
+	for box, size := range want {
+		got := boxes[box]
+		if math.Abs(got.Wd-size.Wd) > 0.01 || math.Abs(got.Ht-size.Ht) > 0.01 {
+			t.Errorf("expected %s of %.2f x %.2f, got %.2f x %.2f", box, size.Wd, size.Ht, got.Wd, got.Ht)
+		}
+	}
+
+	for _, pageno := range []int{0, -1} {
+		if got, err := GetPageBoxes(fileStr, pageno); err != nil || got[BoxTrim] != boxes[BoxTrim] {
+			t.Errorf("page %d: expected the boxes of page 1, got %v (%v)", pageno, got, err)
+		}
+	}
+	for _, pageno := range []int{2, -2} {
+		if _, err := GetPageBoxes(fileStr, pageno); err == nil {
+			t.Errorf("page %d: expected an error for a page out of range", pageno)
+		}
+	}
+}
+
+// TestGetPageColorSpace ensures that the color spaces of a page are read from
+// its resources and from the color operators of its content.
+func TestGetPageColorSpace(t *testing.T) {
+	content := "0 0 0 1 k 10 10 100 100 re f /CS0 cs 1 scn 120 10 100 100 re f"
+	fileStr := writePdfObjects(filepath.Join(t.TempDir(), "cmyk.pdf"), []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /ColorSpace << /CS0 [/Separation /Gold /DeviceCMYK 5 0 R] >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /FunctionType 2 /Domain [0 1] /C0 [0 0 0 0] /C1 [0 0.2 1 0] /N 1 >>",
+	})
+
+	colorSpaces, err := GetPageColorSpace(fileStr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{ColorSpaceCMYK, "/Separation"}; strings.Join(colorSpaces, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, colorSpaces)
+	}
+
+	// gofpdf compresses the content, which sets colors in DeviceRGB.
+	tpdf := gofpdf.New("P", "pt", "A4", "")
+	tpdf.AddPage()
+	tpdf.SetFillColor(200, 0, 0)
+	tpdf.Rect(10, 10, 100, 100, "F")
+	fileStr = filepath.Join(t.TempDir(), "rgb.pdf")
+	if err := tpdf.OutputFileAndClose(fileStr); err != nil {
+		t.Fatal(err)
+	}
+	colorSpaces, err = GetPageColorSpace(fileStr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, cs := range colorSpaces {
+		found = found || cs == ColorSpaceRGB
+		if cs == ColorSpaceCMYK {
+			t.Errorf("expected no %s in %v", ColorSpaceCMYK, colorSpaces)
+		}
+	}
+	if !found {
+		t.Errorf("expected %s in %v", ColorSpaceRGB, colorSpaces)
+	}
+
+	if _, err := GetPageColorSpace(fileStr, 2); err == nil {
+		t.Error("expected an error for a page out of range")
+	}
+}
+
+// TestReadPdfObjects ensures that PDF objects are read through the
+// cross-reference sections, including incremental updates, binary streams
+// and object streams.
+func TestReadPdfObjects(t *testing.T) {
+	dir := t.TempDir()
+	annot := func(uri string) string {
+		return fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [10 10 50 50] /A << /S /URI /URI (%s) >> >>", uri)
+	}
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] /Annots [4 0 R] >>",
+		annot("https://example.com/v1"),
+	}
+
+	// Each update replaces the link annotation.
+	incremental := writePdfObjects(filepath.Join(dir, "incremental.pdf"), objs)
+	appendPdfUpdate(incremental, map[int]string{4: annot("https://example.com/v2")})
+	appendPdfUpdate(incremental, map[int]string{4: annot("https://example.com/v3")})
+
+	// The stream holds binary data and text that looks like a page without
+	// links, which must not be mistaken for object 3.
+	fake := "\x00\xff\x80\nendstream\nendobj\n3 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 10 10] >>\nendobj\n\xfe"
+	binary := writePdfObjects(filepath.Join(dir, "binary.pdf"), append(objs[:4:4],
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(fake), fake)))
+
+	objStm := writeObjStmPdf(filepath.Join(dir, "objstm.pdf"), objs)
+
+	for _, c := range []struct {
+		name, fileStr, uri string
+	}{
+		{"incremental", incremental, "https://example.com/v3"},
+		{"binary", binary, "https://example.com/v1"},
+		{"objstm", objStm, "https://example.com/v1"},
+	} {
+		data, err := ioutil.ReadFile(c.fileStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		links, err := readLinkAnnots(data, 1)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if len(links) != 1 || links[0].uri != c.uri {
+			t.Errorf("%s: expected a link to %s, got %v", c.name, c.uri, links)
+		}
+	}
+}
+
+// TestReadInvalidStreams ensures that cross-reference streams with invalid
+// field widths and object streams with invalid counts are reported as
+// errors.
+func TestReadInvalidStreams(t *testing.T) {
+	data, err := ioutil.ReadFile(writeObjStmPdf(filepath.Join(t.TempDir(), "objstm.pdf"), []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] >>",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xrefPos, err := strconv.Atoi(string(regexp.MustCompile(`startxref\s+(\d+)`).FindSubmatch(data)[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const stmNum = 4
+
+	for _, widths := range []string{"[-1 2 1]", "[1 9 1]", "[0 0 0]", "[1 2]"} {
+		invalid := bytes.Replace(data, []byte("/W [1 2 1]"), []byte("/W "+widths), 1)
+		if _, err := readXrefSection(invalid, xrefPos, map[int]xrefEntry{}); err == nil {
+			t.Errorf("/W %s: expected an error", widths)
+		}
+	}
+	stream := []byte("1 0 2 6 << >>\n<< >>\n")
+	for _, counts := range [][2]float64{{-1, 8}, {1000, 8}, {2, -1}, {2, 1000}} {
+		objs := pdfObjects{stmNum: &pdfStream{
+			dict: map[string]interface{}{"Type": pdfName("ObjStm"), "N": counts[0], "First": counts[1]},
+			data: stream,
+		}}
+		if _, err := objs.readObjectStream(stmNum); err == nil {
+			t.Errorf("/N %v /First %v: expected an error", counts[0], counts[1])
+		}
+	}
+	objs := pdfObjects{stmNum: &pdfStream{
+		dict: map[string]interface{}{"Type": pdfName("ObjStm"), "N": 2.0, "First": 8.0},
+		data: stream,
+	}}
+	if stmObjs, err := objs.readObjectStream(stmNum); err != nil || len(stmObjs) != 2 {
+		t.Errorf("expected 2 objects, got %v, %v", stmObjs, err)
+	}
+}
+
+// TestSource ensures that all pages of a source can be imported.
+func TestSource(t *testing.T) {
+	fileStr := writeBigPdf(t.TempDir(), 3)
+	src, err := NewSource(fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := src.NumPages(); n != 3 {
+		t.Fatalf("expected 3 pages, got %d", n)
+	}
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	for j := 1; j <= src.NumPages(); j++ {
+		pdf.AddPage()
+		tpl := src.ImportPage(pdf, j, "/MediaBox")
+		src.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+	}
+	if err := pdf.Output(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSource("does-not-exist.pdf"); err == nil {
+		t.Error("expected an error for a missing source file")
+	}
+}
+
+// TestImportAllPages round-trips a PDF with pages of different sizes and
+// ensures that the output has the same pages.
+func TestImportAllPages(t *testing.T) {
+	dir := t.TempDir()
+	tpdf := gofpdf.New("P", "pt", "A4", "")
+	tpdf.SetFont("Arial", "", 12)
+	tpdf.AddPage()
+	tpdf.Text(20, 20, "Portrait A4")
+	tpdf.AddPageFormat("L", gofpdf.SizeType{Wd: 419.53, Ht: 595.28})
+	tpdf.Text(20, 20, "Landscape A5")
+	srcStr := filepath.Join(dir, "source.pdf")
+	if err := tpdf.OutputFileAndClose(srcStr); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	ImportAllPages(pdf, srcStr, "/MediaBox")
+	outStr := filepath.Join(dir, "output.pdf")
+	if err := pdf.OutputFileAndClose(outStr); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := GetNumberOfPages(outStr); err != nil || n != 2 {
+		t.Fatalf("expected 2 pages in output, got %d (%v)", n, err)
+	}
+	for j := 1; j <= 2; j++ {
+		sw, sh, _ := GetPageSize(srcStr, j, "/MediaBox")
+		ow, oh, err := GetPageSize(outStr, j, "/MediaBox")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(sw-ow) > 0.01 || math.Abs(sh-oh) > 0.01 {
+			t.Errorf("expected page %d to be %.2f x %.2f, got %.2f x %.2f", j, sw, sh, ow, oh)
+		}
+	}
+}
+
+// TestImportMultipleSources interleaves pages of two source files, imported
+// with one Importer and with a Source for each file, and ensures that every
+// page is drawn with its own template.
+func TestImportMultipleSources(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a", "b"} {
+		tpdf := gofpdf.New("P", "pt", "A4", "")
+		tpdf.SetFont("Arial", "", 12)
+		for j := 1; j <= 2; j++ {
+			tpdf.AddPage()
+			tpdf.Text(20, 20, fmt.Sprintf("page %s%d", name, j))
+		}
+		fileStr := filepath.Join(dir, name+".pdf")
+		if err := tpdf.OutputFileAndClose(fileStr); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, fileStr)
+	}
+	pages := []struct {
+		file, pageno int
+	}{{0, 1}, {1, 1}, {0, 2}}
+
+	imp := NewImporter()
+	srcs := make([]*Source, len(files))
+	for j, fileStr := range files {
+		src, err := NewSource(fileStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer src.Close()
+		srcs[j] = src
+	}
+	imports := map[string]func(pdf *gofpdf.Fpdf, file, pageno int) int{
+		"Importer": func(pdf *gofpdf.Fpdf, file, pageno int) int {
+			tpl := imp.ImportPage(pdf, files[file], pageno, BoxMedia)
+			imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+			return tpl
+		},
+		"Source": func(pdf *gofpdf.Fpdf, file, pageno int) int {
+			tpl := srcs[file].ImportPage(pdf, pageno, BoxMedia)
+			srcs[file].UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+			return tpl
+		},
+	}
+
+	for name, importPage := range imports {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.SetCompression(false)
+		for _, p := range pages {
+			pdf.AddPage()
+			importPage(pdf, p.file, p.pageno)
+		}
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		// Every page draws a template of its own, whose content holds the
+		// text of the source page.
+		used := map[string]bool{}
+		for _, m := range regexp.MustCompile(`(/GOFPDITPL\w+) Do`).FindAllSubmatch(buf.Bytes(), -1) {
+			used[string(m[1])] = true
+		}
+		if len(used) != len(pages) {
+			t.Errorf("%s: expected %d different templates, got %d", name, len(pages), len(used))
+		}
+		text := inflateStreams(buf.Bytes())
+		for _, p := range pages {
+			if page := fmt.Sprintf("(page %s%d)", "ab"[p.file:p.file+1], p.pageno); !strings.Contains(text, page) {
+				t.Errorf("%s: expected the content of %s in the output", name, page)
+			}
+		}
+	}
+}
+
+// TestMergeFile appends a PDF with pages of different sizes to a document and
+// ensures that every page keeps its size.
+func TestMergeFile(t *testing.T) {
+	dir := t.TempDir()
+	sizes := []gofpdf.SizeType{{Wd: 595.28, Ht: 841.89}, {Wd: 595.28, Ht: 419.53}, {Wd: 612, Ht: 792}}
+	tpdf := gofpdf.New("P", "pt", "A4", "")
+	tpdf.SetFont("Arial", "", 12)
+	for j, size := range sizes {
+		tpdf.AddPageFormat("P", size)
+		tpdf.Text(20, 20, fmt.Sprintf("Page %d", j+1))
+	}
+	srcStr := filepath.Join(dir, "source.pdf")
+	if err := tpdf.OutputFileAndClose(srcStr); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	MergeFile(pdf, srcStr)
+	outStr := filepath.Join(dir, "output.pdf")
+	if err := pdf.OutputFileAndClose(outStr); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := GetNumberOfPages(outStr); err != nil || n != 1+len(sizes) {
+		t.Fatalf("expected %d pages in output, got %d (%v)", 1+len(sizes), n, err)
+	}
+	for j, size := range sizes {
+		w, h, err := GetPageSize(outStr, j+2, BoxMedia)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(w-size.Wd) > 0.01 || math.Abs(h-size.Ht) > 0.01 {
+			t.Errorf("expected page %d to be %.2f x %.2f, got %.2f x %.2f", j+2, size.Wd, size.Ht, w, h)
+		}
+	}
+}
+
+// TestRelease ensures that the global functions keep one Importer for each
+// PDF until it is released.
+func TestRelease(t *testing.T) {
+	Reset()
+	defer Reset()
+	fileStr := writeBigPdf(t.TempDir(), 1)
+	pdfs := make([]*gofpdf.Fpdf, 3)
+	for j := range pdfs {
+		pdfs[j] = gofpdf.New("P", "pt", "A4", "")
+		ImportPage(pdfs[j], fileStr, 1, BoxMedia)
+		ImportPage(pdfs[j], fileStr, 1, BoxMedia)
+		if len(importers) != j+1 {
+			t.Errorf("expected %d Importers, got %d", j+1, len(importers))
+		}
+	}
+	for j, pdf := range pdfs {
+		Release(pdf)
+		if _, ok := importers[pdf]; ok || len(importers) != len(pdfs)-j-1 {
+			t.Errorf("expected %d Importers after releasing PDF %d, got %d", len(pdfs)-j-1, j, len(importers))
+		}
+	}
+	if last.oncePdf != nil {
+		t.Error("expected no reference to a released PDF to be left")
+	}
+	for _, pdf := range pdfs {
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestReset ensures that Reset releases the Importers of the global functions
+// and that a closed Source releases its Importer.
+func TestReset(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	rs, _ := getTemplatePdf()
+	ImportPageFromStream(pdf, &rs, 1, "/MediaBox")
+	if _, ok := importers[pdf]; !ok {
+		t.Error("expected an Importer to be kept for the PDF")
+	}
+	Reset()
+	if len(importers) != 0 {
+		t.Errorf("expected Reset to release all Importers, %d are left", len(importers))
+	}
+
+	src, err := NewSource(writeBigPdf(t.TempDir(), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closer io.Closer = src
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if src.imp != nil {
+		t.Error("expected Close to release the Importer")
+	}
+	src.ImportPage(pdf, 1, "/MediaBox")
+	if pdf.Ok() {
+		t.Error("expected an error when importing from a closed source")
+	}
+}
+
+// TestWatermark overlays a watermark on every page of a multi-page document.
+func TestWatermark(t *testing.T) {
+	dir := t.TempDir()
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Arial", "", 12)
+	pdf.SetHeaderFunc(Watermark(pdf, writeBigPdf(dir, 1), 1, 0.25))
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		pdf.Cell(40, 10, fmt.Sprintf("Page %d", j))
+	}
+	if alpha, _ := pdf.GetAlpha(); alpha != 1 {
+		t.Errorf("expected the alpha to be restored to 1, got %.2f", alpha)
+	}
+	outStr := filepath.Join(dir, "output.pdf")
+	if err := pdf.OutputFileAndClose(outStr); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := GetNumberOfPages(outStr); err != nil || n != 3 {
+		t.Errorf("expected 3 pages, got %d (%v)", n, err)
+	}
+
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	Watermark(pdf, "does-not-exist.pdf", 1, 0.25)
+	if pdf.Ok() {
+		t.Error("expected an error for a missing source file")
+	}
+}
+
+// TestImportNUp produces a 4-up document from an 8 page source and ensures
+// that the grids are chosen as expected.
+func TestImportNUp(t *testing.T) {
+	tests := []struct {
+		n            int
+		areaW, areaH float64
+		cols, rows   int
+	}{
+		{2, 841.89, 595.28, 2, 1},
+		{2, 595.28, 841.89, 2, 1},
+		{4, 595.28, 841.89, 2, 2},
+		{8, 841.89, 595.28, 4, 2},
+	}
+	for _, tt := range tests {
+		cols, rows := nUpGrid(tt.n, tt.areaW, tt.areaH, 0, 595.28, 841.89)
+		if cols != tt.cols || rows != tt.rows {
+			t.Errorf("expected %d-up grid %dx%d, got %dx%d", tt.n, tt.cols, tt.rows, cols, rows)
+		}
+	}
+
+	dir := t.TempDir()
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	ImportNUpLayout(pdf, writeBigPdf(dir, 8), 4, BoxMedia, NUpLayout{Margin: 10, Gutter: 5})
+	outStr := filepath.Join(dir, "output.pdf")
+	if err := pdf.OutputFileAndClose(outStr); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := GetNumberOfPages(outStr); err != nil || n != 2 {
+		t.Errorf("expected 2 sheets, got %d (%v)", n, err)
+	}
+}
+
+// BenchmarkImportPages imports every page of a large PDF with a new Importer
+// for each page, which parses the file for each page.
+func BenchmarkImportPages(b *testing.B) {
+	fileStr := writeBigPdf(b.TempDir(), 50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		for j := 1; j <= 50; j++ {
+			NewImporter().ImportPage(pdf, fileStr, j, "/MediaBox")
+		}
+	}
+}
+
+// BenchmarkImportPageOnce overlays one imported page on 100 pages.
+func BenchmarkImportPageOnce(b *testing.B) {
+	fileStr := writeBigPdf(b.TempDir(), 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		imp := NewImporter()
+		for j := 0; j < 100; j++ {
+			pdf.AddPage()
+			tpl := imp.ImportPageOnce(pdf, fileStr, 1, BoxMedia)
+			imp.UseImportedTemplate(pdf, tpl, 0, 0, 595.28, 841.89)
+		}
+		if err := pdf.Output(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSourceImportPages imports every page of a large PDF from a Source,
+// which parses the file once.
+func BenchmarkSourceImportPages(b *testing.B) {
+	fileStr := writeBigPdf(b.TempDir(), 50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		src, err := NewSource(fileStr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := 1; j <= 50; j++ {
+			src.ImportPage(pdf, j, "/MediaBox")
+		}
+	}
+}
+
+// writeBigPdf writes a PDF with the given number of pages to dir and returns
+// its file name.
+func writeBigPdf(dir string, pages int) string {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetFont("Arial", "", 12)
+	for j := 1; j <= pages; j++ {
+		pdf.AddPage()
+		for k := 0; k < 40; k++ {
+			pdf.Text(20, float64(20+k*18), fmt.Sprintf("Page %d, line %d", j, k))
+		}
+	}
+	fileStr := filepath.Join(dir, "big.pdf")
+	if err := pdf.OutputFileAndClose(fileStr); err != nil {
+		panic(err)
+	}
+	return fileStr
+}
+
+// inflateStreams returns the decompressed content of the streams of the PDF
+// held in data that are compressed with FlateDecode.
+func inflateStreams(data []byte) string {
+	var text bytes.Buffer
+	for _, m := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(data, -1) {
+		if zr, err := zlib.NewReader(bytes.NewReader(m[1])); err == nil {
+			io.Copy(&text, zr)
+		}
+	}
+	return text.String()
+}
+
+// writeFlatePdf writes a single page PDF with the given FlateDecode
+// compressed content stream and /Rotate entry to dir and returns its file
+// name.
+func writeFlatePdf(dir string, stream []byte, rotate int) string {
+	return writePdfObjects(filepath.Join(dir, "flate.pdf"), []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.28 841.89] /Rotate %d /Resources << >> /Contents 4 0 R >>", rotate),
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(stream), stream),
+	})
+}
+
+// writePdfObjects writes a PDF of the given objects, numbered from 1, the
+// first of which is the catalog, to fileStr and returns fileStr.
+func writePdfObjects(fileStr string, objs []string) string {
+	var buf bytes.Buffer
+	var offsets []int
+	buf.WriteString("%PDF-1.4\n")
+	for _, obj := range objs {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	if err := ioutil.WriteFile(fileStr, buf.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+	return fileStr
+}
+
+// appendPdfUpdate appends an incremental update with the given objects,
+// keyed by object number, to the PDF fileStr.
+func appendPdfUpdate(fileStr string, objs map[int]string) {
+	data, err := ioutil.ReadFile(fileStr)
+	if err != nil {
+		panic(err)
+	}
+	startxref := regexp.MustCompile(`startxref\s+(\d+)`).FindAllSubmatch(data, -1)
+	prev := string(startxref[len(startxref)-1][1])
+	sizes := regexp.MustCompile(`/Size (\d+)`).FindAllSubmatch(data, -1)
+	size, _ := strconv.Atoi(string(sizes[len(sizes)-1][1]))
+
+	var nums []int
+	for num := range objs {
+		nums = append(nums, num)
+		if num >= size {
+			size = num + 1
+		}
+	}
+	sort.Ints(nums)
+	buf := bytes.NewBuffer(data)
+	offsets := make(map[int]int)
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", num, objs[num])
+	}
+	xref := buf.Len()
+	buf.WriteString("xref\n")
+	for _, num := range nums {
+		fmt.Fprintf(buf, "%d 1\n%010d 00000 n \n", num, offsets[num])
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R /Prev %s >>\nstartxref\n%d\n%%%%EOF\n", size, prev, xref)
+	if err := ioutil.WriteFile(fileStr, buf.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+}
+
+// writeObjStmPdf writes a PDF that stores the given objects, numbered from
+// 1, in a compressed object stream indexed by a cross-reference stream to
+// fileStr and returns fileStr.
+func writeObjStmPdf(fileStr string, objs []string) string {
+	var header, body bytes.Buffer
+	for j, obj := range objs {
+		fmt.Fprintf(&header, "%d %d ", j+1, body.Len())
+		body.WriteString(obj + "\n")
+	}
+	deflate := func(data []byte) []byte {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+	stm := deflate(append(header.Bytes(), body.Bytes()...))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	stmNum, stmOffset := len(objs)+1, buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		stmNum, len(objs), header.Len(), len(stm), stm)
+
+	// Each entry holds its type, the offset or object stream number and the
+	// index in the object stream, and is PNG predicted from the one above.
+	xrefNum, xrefOffset := len(objs)+2, buf.Len()
+	rows := [][]byte{{0, 0, 0, 0}}
+	for j := range objs {
+		rows = append(rows, []byte{2, byte(stmNum >> 8), byte(stmNum), byte(j)})
+	}
+	rows = append(rows, []byte{1, byte(stmOffset >> 8), byte(stmOffset), 0},
+		[]byte{1, byte(xrefOffset >> 8), byte(xrefOffset), 0})
+	var predicted []byte
+	prev := make([]byte, 4)
+	for _, row := range rows {
+		predicted = append(predicted, 2)
+		for j, b := range row {
+			predicted = append(predicted, b-prev[j])
+		}
+		prev = row
+	}
+	xs := deflate(predicted)
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /XRef /Size %d /Root 1 0 R /W [1 2 1] /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >> /Length %d >>\nstream\n%s\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n",
+		xrefNum, xrefNum+1, len(xs), xs, xrefOffset)
+	if err := ioutil.WriteFile(fileStr, buf.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+	return fileStr
+}
+
+// distinctPdfs returns n single page PDFs, each with the text "Source j"
+// with its index j.
+func distinctPdfs(n int) [][]byte {
+	sources := make([][]byte, n)
+	for j := range sources {
+		src := gofpdf.New("P", "pt", "A4", "")
+		src.AddPage()
+		src.SetFont("Arial", "", 12)
+		src.Text(20, 20, fmt.Sprintf("Source %d", j))
+		var buf bytes.Buffer
+		if err := src.Output(&buf); err != nil {
+			panic(err)
+		}
+		sources[j] = buf.Bytes()
+	}
+	return sources
+}
+
 func getTemplatePdf() (io.ReadSeeker, error) {
+	data, err := getTemplateBytes()
+	return bytes.NewReader(data), err
+}
+
+func getTemplateBytes() ([]byte, error) {
 	tpdf := gofpdf.New("P", "pt", "A4", "")
 	tpdf.AddPage()
 	tpdf.SetFont("Arial", "", 12)
@@ -72,5 +2038,5 @@ func getTemplatePdf() (io.ReadSeeker, error) {
 	tpdf.Text(20, 20, "Example Page 2")
 	tbuf := bytes.Buffer{}
 	err := tpdf.Output(&tbuf)
-	return bytes.NewReader(tbuf.Bytes()), err
+	return tbuf.Bytes(), err
 }
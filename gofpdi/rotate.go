@@ -0,0 +1,93 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	matrixRegexp = regexp.MustCompile(`/Matrix \[[^\]]*\]`)
+	bboxRegexp   = regexp.MustCompile(`/BBox \[([^\]]*)\]`)
+)
+
+// formRotation returns the angle in degrees between 0 and 359 by which the
+// form XObject obj written by the gofpdi library is rotated by its matrix.
+func formRotation(obj []byte) int {
+	matrix := matrixRegexp.Find(obj)
+	if matrix == nil {
+		return 0
+	}
+	values := strings.Fields(string(matrix[len("/Matrix [") : len(matrix)-1]))
+	if len(values) != 6 {
+		return 0
+	}
+	c, err1 := strconv.ParseFloat(values[0], 64)
+	s, err2 := strconv.ParseFloat(values[1], 64)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	angle := int(math.Round(math.Atan2(s, c) * 180 / math.Pi))
+	return (angle + 360) % 360
+}
+
+// rotateRect returns the rectangle rect with the corners llx, lly, urx, ury
+// on a page of size w,h turned counterclockwise by angle, which is 0, 90, 180
+// or 270 degrees, as formRotation returns it. The turned page is moved back
+// to the origin like the form XObject.
+func rotateRect(rect [4]float64, angle int, w, h float64) [4]float64 {
+	point := func(x, y float64) (float64, float64) {
+		switch angle {
+		case 90:
+			return h - y, x
+		case 180:
+			return w - x, h - y
+		case 270:
+			return y, w - x
+		}
+		return x, y
+	}
+	x1, y1 := point(rect[0], rect[1])
+	x2, y2 := point(rect[2], rect[3])
+	return [4]float64{math.Min(x1, x2), math.Min(y1, y2), math.Max(x1, x2), math.Max(y1, y2)}
+}
+
+// unrotateForm returns the form XObject obj written by the gofpdi library with
+// the rotation of the source page removed from its matrix, so that the page is
+// drawn in its raw orientation, or nil if obj has no matrix to change. The
+// new matrix is padded to the length of the old one, which keeps the
+// positions of the object references after it valid.
+func unrotateForm(obj []byte) []byte {
+	matrix := matrixRegexp.FindIndex(obj)
+	bbox := bboxRegexp.FindSubmatch(obj)
+	if matrix == nil || bbox == nil {
+		return nil
+	}
+	corners := strings.Fields(string(bbox[1]))
+	if len(corners) != 4 {
+		return nil
+	}
+	llx, err1 := strconv.ParseFloat(corners[0], 64)
+	lly, err2 := strconv.ParseFloat(corners[1], 64)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	// The matrix of an unrotated page only moves the lower left corner of
+	// the box to the origin.
+	unrotated := fmt.Sprintf("/Matrix [1 0 0 1 %.5F %.5F]", -llx, -lly)
+	length := matrix[1] - matrix[0]
+	if len(unrotated) > length {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(obj[:matrix[0]])
+	buf.WriteString(unrotated)
+	buf.WriteString(strings.Repeat(" ", length-len(unrotated)))
+	buf.Write(obj[matrix[1]:])
+	return buf.Bytes()
+}
@@ -0,0 +1,460 @@
+package gofpdi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+// pdfStream is a stream object of a PDF: its dictionary and its encoded data.
+type pdfStream struct {
+	dict  map[string]interface{}
+	data  []byte
+	start int
+}
+
+// xrefEntry locates an object of a PDF: either at a byte offset, with the
+// generation number gen, or as the object with the given index in the object
+// stream with number stream.
+type xrefEntry struct {
+	offset int
+	stream int
+	gen    int
+	free   bool
+}
+
+// readObjects returns the objects of the PDF held in data and its trailer
+// dictionary. The objects are located through the cross-reference tables and
+// streams of the PDF, starting with the last one, so that objects changed by
+// incremental updates are read in their latest version and data in streams
+// is never mistaken for objects. Objects in object streams are read as well.
+func readObjects(data []byte) (pdfObjects, map[string]interface{}) {
+	entries, trailer := readXref(data)
+	objs := readDirectObjects(data, entries)
+	objs.readStreamObjects(entries)
+	return objs, trailer
+}
+
+// readDirectObjects returns the objects of the PDF held in data that are not
+// in object streams, located through the cross-reference entries.
+func readDirectObjects(data []byte, entries map[int]xrefEntry) pdfObjects {
+	objs := pdfObjects{}
+	for num, entry := range entries {
+		if entry.free || entry.stream != 0 {
+			continue
+		}
+		p := &pdfParser{data: data, pos: entry.offset}
+		if obj, err := p.parseObject(num); err == nil {
+			objs[num] = obj
+		}
+	}
+
+	// The length of a stream may be given by another object, so the data of
+	// streams is cut once all objects have been read.
+	for _, obj := range objs {
+		if s, ok := obj.(*pdfStream); ok {
+			length, _ := objs.resolve(s.dict["Length"]).(float64)
+			s.data = streamData(data, s.start, int(length))
+		}
+	}
+	return objs
+}
+
+// readStreamObjects adds the objects in object streams to objs, which must
+// hold the object streams.
+func (objs pdfObjects) readStreamObjects(entries map[int]xrefEntry) {
+	objStms := map[int][]interface{}{}
+	for num, entry := range entries {
+		if entry.free || entry.stream == 0 {
+			continue
+		}
+		stmObjs, ok := objStms[entry.stream]
+		if !ok {
+			// Objects of invalid object streams are skipped like other
+			// objects that cannot be parsed.
+			stmObjs, _ = objs.readObjectStream(entry.stream)
+			objStms[entry.stream] = stmObjs
+		}
+		if entry.offset < len(stmObjs) && stmObjs[entry.offset] != nil {
+			objs[num] = stmObjs[entry.offset]
+		}
+	}
+}
+
+// encrypted reports whether the trailer of the PDF held in data has an
+// /Encrypt entry.
+func encrypted(data []byte) bool {
+	_, trailer := readXref(data)
+	_, ok := trailer["Encrypt"]
+	return ok
+}
+
+// readXref returns the entries of the cross-reference sections of the PDF
+// held in data by object number, and the trailer dictionary of the last
+// section. The sections are followed from the last one through their /Prev
+// entries, and entries of later sections take precedence over earlier ones.
+func readXref(data []byte) (map[int]xrefEntry, map[string]interface{}) {
+	entries := map[int]xrefEntry{}
+	var trailer map[string]interface{}
+
+	start := bytes.LastIndex(data, []byte("startxref"))
+	if start < 0 {
+		return entries, nil
+	}
+	p := &pdfParser{data: data, pos: start + len("startxref")}
+	p.skipSpace()
+	pos, err := strconv.Atoi(p.parseToken())
+	if err != nil {
+		return entries, nil
+	}
+
+	seen := map[int]bool{}
+	for !seen[pos] {
+		seen[pos] = true
+		dict, err := readXrefSection(data, pos, entries)
+		if err != nil {
+			break
+		}
+		if trailer == nil {
+			trailer = dict
+		}
+		// Hybrid files keep the entries of compressed objects in a
+		// cross-reference stream besides the table.
+		if stm, ok := dict["XRefStm"].(float64); ok && !seen[int(stm)] {
+			seen[int(stm)] = true
+			readXrefSection(data, int(stm), entries)
+		}
+		prev, ok := dict["Prev"].(float64)
+		if !ok {
+			break
+		}
+		pos = int(prev)
+	}
+
+	return entries, trailer
+}
+
+// readXrefSection adds the entries of the cross-reference table or stream at
+// pos in data to entries, unless entries already holds an entry for the
+// object, and returns its trailer dictionary.
+func readXrefSection(data []byte, pos int, entries map[int]xrefEntry) (map[string]interface{}, error) {
+	if pos < 0 || pos >= len(data) {
+		return nil, fmt.Errorf("gofpdi: cross-reference section at invalid offset %d", pos)
+	}
+	p := &pdfParser{data: data, pos: pos}
+	p.skipSpace()
+	save := p.pos
+	if p.parseToken() != "xref" {
+		p.pos = save
+		return readXrefStream(p, entries)
+	}
+
+	for {
+		p.skipSpace()
+		token := p.parseToken()
+		if token == "trailer" {
+			trailer, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			dict, ok := trailer.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("gofpdi: invalid trailer in PDF")
+			}
+			return dict, nil
+		}
+		first, err := strconv.Atoi(token)
+		if err != nil {
+			return nil, fmt.Errorf("gofpdi: invalid cross-reference table in PDF")
+		}
+		p.skipSpace()
+		count, err := strconv.Atoi(p.parseToken())
+		if err != nil {
+			return nil, fmt.Errorf("gofpdi: invalid cross-reference table in PDF")
+		}
+		for num := first; num < first+count; num++ {
+			p.skipSpace()
+			offset, err1 := strconv.Atoi(p.parseToken())
+			p.skipSpace()
+			gen, err2 := strconv.Atoi(p.parseToken())
+			p.skipSpace()
+			kind := p.parseToken()
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("gofpdi: invalid cross-reference entry for object %d in PDF", num)
+			}
+			if _, ok := entries[num]; !ok {
+				entries[num] = xrefEntry{offset: offset, gen: gen, free: kind == "f"}
+			}
+		}
+	}
+}
+
+// readXrefStream adds the entries of the cross-reference stream at the
+// current position of p to entries like readXrefSection() and returns its
+// dictionary.
+func readXrefStream(p *pdfParser, entries map[int]xrefEntry) (map[string]interface{}, error) {
+	obj, err := p.parseObject(-1)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*pdfStream)
+	if !ok || s.dict["Type"] != pdfName("XRef") {
+		return nil, fmt.Errorf("gofpdi: no cross-reference stream in PDF")
+	}
+	length, _ := s.dict["Length"].(float64)
+	s.data = streamData(p.data, s.start, int(length))
+	stream, err := pdfObjects{}.decodeStream(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each field fits into an int, and a row holds at least one byte.
+	var widths []int
+	w, _ := s.dict["W"].([]interface{})
+	for _, v := range w {
+		n, _ := v.(float64)
+		if n < 0 || n > 8 {
+			return nil, fmt.Errorf("gofpdi: invalid field width %v in cross-reference stream", n)
+		}
+		widths = append(widths, int(n))
+	}
+	if len(widths) != 3 || widths[0]+widths[1]+widths[2] == 0 {
+		return nil, fmt.Errorf("gofpdi: invalid cross-reference stream in PDF")
+	}
+	size, _ := s.dict["Size"].(float64)
+	index, ok := s.dict["Index"].([]interface{})
+	if !ok {
+		index = []interface{}{0.0, size}
+	}
+
+	rowLen := widths[0] + widths[1] + widths[2]
+	row := 0
+	for j := 0; j+1 < len(index); j += 2 {
+		first, _ := index[j].(float64)
+		count, _ := index[j+1].(float64)
+		for num := int(first); num < int(first+count); num, row = num+1, row+1 {
+			if (row+1)*rowLen > len(stream) {
+				return s.dict, nil
+			}
+			fields := stream[row*rowLen:]
+			kind := 1
+			if widths[0] > 0 {
+				kind = xrefField(fields, widths[0])
+			}
+			field2 := xrefField(fields[widths[0]:], widths[1])
+			field3 := xrefField(fields[widths[0]+widths[1]:], widths[2])
+			if _, ok := entries[num]; ok {
+				continue
+			}
+			switch kind {
+			case 0:
+				entries[num] = xrefEntry{free: true}
+			case 1:
+				entries[num] = xrefEntry{offset: field2, gen: field3}
+			case 2:
+				entries[num] = xrefEntry{offset: field3, stream: field2}
+			}
+		}
+	}
+	return s.dict, nil
+}
+
+// xrefField returns the big-endian number of the given width at the start of
+// data.
+func xrefField(data []byte, width int) int {
+	n := 0
+	for _, b := range data[:width] {
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+// readObjectStream returns the objects of the object stream with the given
+// number, indexed by their position in the stream.
+func (objs pdfObjects) readObjectStream(num int) ([]interface{}, error) {
+	s, ok := objs[num].(*pdfStream)
+	if !ok || s.dict["Type"] != pdfName("ObjStm") {
+		return nil, fmt.Errorf("gofpdi: object %d is not an object stream", num)
+	}
+	stream, err := objs.decodeStream(s)
+	if err != nil {
+		return nil, err
+	}
+	// Each pair of numbers takes at least two bytes of the stream.
+	n, _ := objs.resolve(s.dict["N"]).(float64)
+	first, _ := objs.resolve(s.dict["First"]).(float64)
+	if n < 0 || 2*n > float64(len(stream)) || first < 0 || first > float64(len(stream)) {
+		return nil, fmt.Errorf("gofpdi: invalid object stream %d with %v objects from offset %v", num, n, first)
+	}
+
+	// The stream starts with pairs of object numbers and offsets.
+	p := &pdfParser{data: stream}
+	offsets := make([]int, int(n))
+	for j := range offsets {
+		p.skipSpace()
+		p.parseToken()
+		p.skipSpace()
+		offsets[j], _ = strconv.Atoi(p.parseToken())
+	}
+	stmObjs := make([]interface{}, len(offsets))
+	for j, offset := range offsets {
+		p := &pdfParser{data: stream, pos: int(first) + offset}
+		if offset >= 0 && p.pos < len(stream) {
+			stmObjs[j], _ = p.parse()
+		}
+	}
+	return stmObjs, nil
+}
+
+// decodeStream returns the decoded data of the stream s, which must be
+// uncompressed or compressed with FlateDecode, optionally with a PNG
+// predictor.
+func (objs pdfObjects) decodeStream(s *pdfStream) ([]byte, error) {
+	filter, parms := objs.resolve(s.dict["Filter"]), objs.resolve(s.dict["DecodeParms"])
+	if array, ok := filter.([]interface{}); ok && len(array) == 1 {
+		filter = objs.resolve(array[0])
+	}
+	if array, ok := parms.([]interface{}); ok && len(array) == 1 {
+		parms = objs.resolve(array[0])
+	}
+
+	switch filter {
+	case nil:
+		return s.data, nil
+	case pdfName("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("gofpdi: unsupported stream filter %v in PDF", filter)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	predictor, _ := objs.resolve(dictValue(parms, "Predictor")).(float64)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns, ok := objs.resolve(dictValue(parms, "Columns")).(float64)
+	if !ok {
+		columns = 1
+	}
+	return pngUnpredict(data, int(columns))
+}
+
+// pngUnpredict reverses the PNG prediction of data with rows of the given
+// number of columns of one byte each, each row preceded by its filter type.
+func pngUnpredict(data []byte, columns int) ([]byte, error) {
+	var out []byte
+	prev := make([]byte, columns)
+	for len(data) >= columns+1 {
+		filter, row := data[0], append([]byte(nil), data[1:columns+1]...)
+		data = data[columns+1:]
+		for j := range row {
+			var left, upLeft byte
+			if j > 0 {
+				left, upLeft = row[j-1], prev[j-1]
+			}
+			up := prev[j]
+			switch filter {
+			case 0:
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("gofpdi: invalid PNG predictor %d in PDF", filter)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paeth returns the Paeth predictor of the PNG specification.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// parseObject parses the indirect object at the current position, which must
+// have the given object number unless num is negative. Stream objects are
+// returned as a *pdfStream whose data has not been read yet.
+func (p *pdfParser) parseObject(num int) (interface{}, error) {
+	p.skipSpace()
+	n, err := strconv.Atoi(p.parseToken())
+	if err != nil || (num >= 0 && n != num) {
+		return nil, fmt.Errorf("gofpdi: object %d not found at its offset in PDF", num)
+	}
+	p.skipSpace()
+	p.parseToken()
+	p.skipSpace()
+	if p.parseToken() != "obj" {
+		return nil, fmt.Errorf("gofpdi: object %d not found at its offset in PDF", num)
+	}
+	obj, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := obj.(map[string]interface{})
+	if !ok {
+		return obj, nil
+	}
+	save := p.pos
+	p.skipSpace()
+	if p.parseToken() != "stream" {
+		p.pos = save
+		return obj, nil
+	}
+	// The keyword is followed by CRLF or LF before the data.
+	if bytes.HasPrefix(p.data[p.pos:], []byte("\r\n")) {
+		p.pos += 2
+	} else if bytes.HasPrefix(p.data[p.pos:], []byte("\n")) {
+		p.pos++
+	}
+	return &pdfStream{dict: dict, start: p.pos}, nil
+}
+
+// streamData returns the data of the stream that starts at start in data.
+// The data has the given length if it is followed by the endstream keyword,
+// otherwise it extends to the keyword.
+func streamData(data []byte, start, length int) []byte {
+	if length >= 0 && start+length <= len(data) {
+		p := &pdfParser{data: data, pos: start + length}
+		p.skipSpace()
+		if p.parseToken() == "endstream" {
+			return data[start : start+length]
+		}
+	}
+
+	end := bytes.Index(data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil
+	}
+	return bytes.TrimRight(data[start:start+end], "\r\n")
+}
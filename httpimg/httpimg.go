@@ -3,6 +3,8 @@ package httpimg
 import (
 	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/jung-kurt/gofpdf/v2"
 )
@@ -16,6 +18,31 @@ type httpimgPdf interface {
 	SetError(err error)
 }
 
+// defaultClient is the client that downloads the images unless another one
+// is set with SetHTTPClient(). Unlike http.DefaultClient, it gives up on
+// servers that do not respond.
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// client holds the client that downloads the images.
+var client = struct {
+	sync.RWMutex
+	*http.Client
+}{Client: defaultClient}
+
+// SetHTTPClient sets the client that Register() uses to download images, for
+// instance to go through a proxy, to trust other certificates or to
+// authenticate. A nil client restores the default, which times out after 30
+// seconds.
+func SetHTTPClient(c *http.Client) {
+	if c == nil {
+		c = defaultClient
+	}
+
+	client.Lock()
+	client.Client = c
+	client.Unlock()
+}
+
 // Register registers a HTTP image. Downloading the image from the provided URL
 // and adding it to the PDF but not adding it to the page. Use Image() with the
 // same URL to add the image to the page.
@@ -26,7 +53,11 @@ func Register(f httpimgPdf, urlStr, tp string) (info *gofpdf.ImageInfoType) {
 		return
 	}
 
-	resp, err := http.Get(urlStr)
+	client.RLock()
+	c := client.Client
+	client.RUnlock()
+
+	resp, err := c.Get(urlStr)
 
 	if err != nil {
 		f.SetError(err)
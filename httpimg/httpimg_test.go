@@ -1,6 +1,13 @@
 package httpimg_test
 
 import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
 	"github.com/jung-kurt/gofpdf/v2"
 	"github.com/jung-kurt/gofpdfcontrib/httpimg"
 	"github.com/jung-kurt/gofpdfcontrib/internal/example"
@@ -21,3 +28,45 @@ func ExampleRegister() {
 	// Output:
 	// Successfully generated ../pdf/contrib_httpimg_Register.pdf
 }
+
+// roundTripFunc is an http.RoundTripper that answers requests with a
+// function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// TestSetHTTPClient ensures that images are downloaded with the client set
+// with SetHTTPClient().
+func TestSetHTTPClient(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	var requested []string
+	defer httpimg.SetHTTPClient(nil)
+	httpimg.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"image/png"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(buf.Bytes())),
+			Request:    req,
+		}, nil
+	})})
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	url := "http://images.invalid/square.png"
+	if info := httpimg.Register(pdf, url, ""); info == nil {
+		t.Fatal(pdf.Error())
+	}
+	pdf.Image(url, 15, 15, 20, 0, false, "", 0, "")
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 1 || requested[0] != url {
+		t.Errorf("expected a single request through the client, got %v", requested)
+	}
+}